@@ -0,0 +1,250 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+)
+
+// Builder assembles a DHCP message field by field and checks the fields RFC
+// 2131 requires of its message type once it is built:
+//
+//	p, err := dhcp4.NewBuilder(dhcp4.BootRequest).
+//		MessageType(dhcp4.MessageTypeDiscover).
+//		TransactionID(xid).
+//		CHAddr(mac).
+//		Option(dhcp4.OptionHostName, hostname).
+//		Build()
+//
+// The first error of a setter is returned by Build.
+type Builder struct {
+	p   *Packet
+	err error
+}
+
+// NewBuilder returns a Builder for a packet with op code op and the
+// Ethernet hardware type.
+func NewBuilder(op OpCode) *Builder {
+	return &Builder{p: NewPacket(op)}
+}
+
+// MessageType sets the DHCP message type.
+func (b *Builder) MessageType(mt MessageType) *Builder {
+	b.p.SetMessageType(mt)
+	return b
+}
+
+// TransactionID sets the transaction ID.
+func (b *Builder) TransactionID(xid [4]byte) *Builder {
+	b.p.TransactionID = xid
+	return b
+}
+
+// HType sets the hardware type.
+func (b *Builder) HType(htype uint8) *Builder {
+	b.p.HType = htype
+	return b
+}
+
+// CHAddr sets the client hardware address.
+func (b *Builder) CHAddr(addr net.HardwareAddr) *Builder {
+	b.p.CHAddr = addr
+	return b
+}
+
+// Secs sets the seconds elapsed since the client started.
+func (b *Builder) Secs(secs uint16) *Builder {
+	b.p.Secs = secs
+	return b
+}
+
+// Broadcast sets or clears the broadcast flag.
+func (b *Builder) Broadcast(broadcast bool) *Builder {
+	b.p.SetBroadcast(broadcast)
+	return b
+}
+
+// CIAddr sets the client IP address.
+func (b *Builder) CIAddr(ip net.IP) *Builder {
+	b.p.CIAddr = ip
+	return b
+}
+
+// YIAddr sets the address assigned to the client.
+func (b *Builder) YIAddr(ip net.IP) *Builder {
+	b.p.YIAddr = ip
+	return b
+}
+
+// SIAddr sets the next server address.
+func (b *Builder) SIAddr(ip net.IP) *Builder {
+	b.p.SIAddr = ip
+	return b
+}
+
+// GIAddr sets the relay agent address.
+func (b *Builder) GIAddr(ip net.IP) *Builder {
+	b.p.GIAddr = ip
+	return b
+}
+
+// Option adds option code with the value v, as Options.Add does.
+func (b *Builder) Option(code OptionCode, v encoding.BinaryMarshaler) *Builder {
+	if err := b.p.Options.Add(code, v); err != nil && b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// RawOption adds option code with the raw value v.
+func (b *Builder) RawOption(code OptionCode, v []byte) *Builder {
+	b.p.Options.AddRaw(code, v)
+	return b
+}
+
+// field is the requirement of RFC 2131, Tables 3 and 5, on a field or
+// option.
+type field int
+
+const (
+	may field = iota
+	must
+	mustNot
+)
+
+// messageRules are the requirements of RFC 2131, Tables 3 and 5, on a
+// message type that do not depend on the client's state.
+type messageRules struct {
+	op             OpCode
+	ciaddr, yiaddr field
+	options        map[OptionCode]field
+}
+
+var messageTypeRules = map[MessageType]messageRules{
+	MessageTypeDiscover: {
+		op:     BootRequest,
+		ciaddr: mustNot,
+		yiaddr: mustNot,
+		options: map[OptionCode]field{
+			OptionServerIdentifier: mustNot,
+		},
+	},
+	MessageTypeRequest: {
+		op:     BootRequest,
+		yiaddr: mustNot,
+	},
+	MessageTypeDecline: {
+		op:     BootRequest,
+		ciaddr: mustNot,
+		yiaddr: mustNot,
+		options: map[OptionCode]field{
+			OptionRequestedIPAddress: must,
+			OptionServerIdentifier:   must,
+			OptionIPAddressLeaseTime: mustNot,
+		},
+	},
+	MessageTypeRelease: {
+		op:     BootRequest,
+		ciaddr: must,
+		yiaddr: mustNot,
+		options: map[OptionCode]field{
+			OptionRequestedIPAddress: mustNot,
+			OptionServerIdentifier:   must,
+			OptionIPAddressLeaseTime: mustNot,
+		},
+	},
+	MessageTypeInform: {
+		op:     BootRequest,
+		ciaddr: must,
+		yiaddr: mustNot,
+		options: map[OptionCode]field{
+			OptionRequestedIPAddress: mustNot,
+			OptionServerIdentifier:   mustNot,
+			OptionIPAddressLeaseTime: mustNot,
+		},
+	},
+	MessageTypeOffer: {
+		op:     BootReply,
+		ciaddr: mustNot,
+		yiaddr: must,
+		options: map[OptionCode]field{
+			OptionRequestedIPAddress: mustNot,
+			OptionServerIdentifier:   must,
+			OptionIPAddressLeaseTime: must,
+		},
+	},
+	MessageTypeACK: {
+		op: BootReply,
+		options: map[OptionCode]field{
+			OptionRequestedIPAddress: mustNot,
+			OptionServerIdentifier:   must,
+		},
+	},
+	MessageTypeNAK: {
+		op:     BootReply,
+		ciaddr: mustNot,
+		yiaddr: mustNot,
+		options: map[OptionCode]field{
+			OptionRequestedIPAddress: mustNot,
+			OptionServerIdentifier:   must,
+			OptionIPAddressLeaseTime: mustNot,
+		},
+	},
+}
+
+// Build returns the packet, checking that it has a message type and a
+// client hardware address, and that its op code, ciaddr, yiaddr, requested
+// IP address, server identifier, and lease time are as RFC 2131, Tables 3
+// and 5 require of its message type. Rules depending on the client's state,
+// such as whether a DHCPREQUEST carries a server identifier, are left to the
+// caller.
+//
+// Build returns a *ValidationError describing the first problem found.
+func (b *Builder) Build() (*Packet, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	p := b.p
+	mt := p.MessageType()
+	if mt == 0 {
+		return nil, invalid("option 53", "no DHCP message type")
+	}
+	if len(p.CHAddr) == 0 {
+		return nil, invalid("chaddr", "no client hardware address")
+	}
+	r, ok := messageTypeRules[mt]
+	if !ok {
+		return p, nil
+	}
+	if p.Op != r.op {
+		return nil, invalid("op", "%v requires op code %d, got %d", mt, r.op, p.Op)
+	}
+	if err := checkField("ciaddr", r.ciaddr, !isZeroIP(p.CIAddr), mt); err != nil {
+		return nil, err
+	}
+	if err := checkField("yiaddr", r.yiaddr, !isZeroIP(p.YIAddr), mt); err != nil {
+		return nil, err
+	}
+	for _, code := range []OptionCode{OptionRequestedIPAddress, OptionServerIdentifier, OptionIPAddressLeaseTime} {
+		if err := checkField(fmt.Sprintf("option %d", code), r.options[code], p.Options.Has(code), mt); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// checkField checks that the field name, which is set if present, is as
+// required of message type mt.
+func checkField(name string, f field, present bool, mt MessageType) error {
+	switch {
+	case f == must && !present:
+		return invalid(name, "required in %v", mt)
+	case f == mustNot && present:
+		return invalid(name, "not allowed in %v", mt)
+	}
+	return nil
+}
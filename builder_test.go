@@ -0,0 +1,117 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	mac := net.HardwareAddr{1, 2, 3, 4, 5, 6}
+	sid := []byte{192, 168, 0, 1}
+	lease := []byte{0, 0, 0x0e, 0x10}
+
+	for _, tt := range []struct {
+		desc      string
+		b         *Builder
+		wantField string
+	}{
+		{
+			desc: "discover",
+			b:    NewBuilder(BootRequest).MessageType(MessageTypeDiscover).CHAddr(mac).Broadcast(true),
+		},
+		{
+			desc:      "no message type",
+			b:         NewBuilder(BootRequest).CHAddr(mac),
+			wantField: "option 53",
+		},
+		{
+			desc:      "no chaddr",
+			b:         NewBuilder(BootRequest).MessageType(MessageTypeDiscover),
+			wantField: "chaddr",
+		},
+		{
+			desc:      "discover as reply",
+			b:         NewBuilder(BootReply).MessageType(MessageTypeDiscover).CHAddr(mac),
+			wantField: "op",
+		},
+		{
+			desc:      "discover with server identifier",
+			b:         NewBuilder(BootRequest).MessageType(MessageTypeDiscover).CHAddr(mac).RawOption(OptionServerIdentifier, sid),
+			wantField: "option 54",
+		},
+		{
+			desc:      "release without ciaddr",
+			b:         NewBuilder(BootRequest).MessageType(MessageTypeRelease).CHAddr(mac).RawOption(OptionServerIdentifier, sid),
+			wantField: "ciaddr",
+		},
+		{
+			desc:      "decline without requested address",
+			b:         NewBuilder(BootRequest).MessageType(MessageTypeDecline).CHAddr(mac).RawOption(OptionServerIdentifier, sid),
+			wantField: "option 50",
+		},
+		{
+			desc: "offer",
+			b: NewBuilder(BootReply).MessageType(MessageTypeOffer).CHAddr(mac).YIAddr(net.IP{192, 168, 0, 100}).
+				RawOption(OptionServerIdentifier, sid).RawOption(OptionIPAddressLeaseTime, lease),
+		},
+		{
+			desc: "offer without lease time",
+			b: NewBuilder(BootReply).MessageType(MessageTypeOffer).CHAddr(mac).YIAddr(net.IP{192, 168, 0, 100}).
+				RawOption(OptionServerIdentifier, sid),
+			wantField: "option 51",
+		},
+		{
+			desc: "nak with yiaddr",
+			b: NewBuilder(BootReply).MessageType(MessageTypeNAK).CHAddr(mac).YIAddr(net.IP{192, 168, 0, 100}).
+				RawOption(OptionServerIdentifier, sid),
+			wantField: "yiaddr",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			p, err := tt.b.Build()
+			if tt.wantField == "" {
+				if err != nil || p == nil {
+					t.Errorf("Build() = %v, %v, want a packet", p, err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Build() = %v, want *ValidationError", err)
+			}
+			if verr.Field != tt.wantField {
+				t.Errorf("Build() field = %q, want %q (%v)", verr.Field, tt.wantField, verr)
+			}
+		})
+	}
+}
+
+func TestBuilderFields(t *testing.T) {
+	xid := [4]byte{1, 2, 3, 4}
+	p, err := NewBuilder(BootRequest).
+		MessageType(MessageTypeRequest).
+		MessageType(MessageTypeDiscover).
+		TransactionID(xid).
+		CHAddr(net.HardwareAddr{1, 2, 3, 4, 5, 6}).
+		Secs(3).
+		Broadcast(true).
+		GIAddr(net.IP{10, 0, 0, 1}).
+		RawOption(OptionHostName, []byte("host")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TransactionID != xid || p.Secs != 3 || !p.Broadcast() || !p.GIAddr.Equal(net.IP{10, 0, 0, 1}) {
+		t.Errorf("Build() = %v, fields not set", p)
+	}
+	if mt := p.MessageType(); mt != MessageTypeDiscover {
+		t.Errorf("MessageType() = %v, want %v", mt, MessageTypeDiscover)
+	}
+	if got := string(p.Options.Get(OptionHostName)); got != "host" {
+		t.Errorf("host name option = %q, want %q", got, "host")
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+
+	"github.com/u-root/dhcp4/dhcp4client"
+)
+
+// rawConn returns a raw packet connection on iface.
+func rawConn(iface string) (net.PacketConn, error) {
+	return dhcp4client.NewPacketUDPConn(iface, dhcp4client.ClientPort)
+}
@@ -0,0 +1,18 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// rawConn returns a raw packet connection on iface.
+func rawConn(iface string) (net.PacketConn, error) {
+	return nil, fmt.Errorf("raw transport is only supported on Linux")
+}
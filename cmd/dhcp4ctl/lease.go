@@ -0,0 +1,76 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"net"
+	"strconv"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// lease is the JSON form of an offer or ACK.
+type lease struct {
+	MessageType string   `json:"message_type"`
+	Address     string   `json:"address,omitempty"`
+	ServerID    string   `json:"server_id,omitempty"`
+	SubnetMask  string   `json:"subnet_mask,omitempty"`
+	Routers     []string `json:"routers,omitempty"`
+	DNS         []string `json:"dns,omitempty"`
+	DomainName  string   `json:"domain_name,omitempty"`
+	LeaseTime   string   `json:"lease_time,omitempty"`
+	Renewal     string   `json:"renewal_time,omitempty"`
+	Rebinding   string   `json:"rebinding_time,omitempty"`
+
+	// Options holds every option in hex, keyed by option code.
+	Options map[string]string `json:"options"`
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+func ipStrings(ips dhcp4opts.IPs) []string {
+	var s []string
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	return s
+}
+
+func newLease(p *dhcp4.Packet) *lease {
+	l := &lease{
+		Address:    ipString(p.YIAddr),
+		ServerID:   ipString(net.IP(dhcp4opts.GetServerIdentifier(p.Options))),
+		Routers:    ipStrings(dhcp4opts.GetRouters(p.Options)),
+		DNS:        ipStrings(dhcp4opts.GetDomainNameServers(p.Options)),
+		DomainName: dhcp4opts.GetDomainName(p.Options),
+		Options:    make(map[string]string),
+	}
+	if mt := p.MessageType(); mt != 0 {
+		l.MessageType = mt.String()
+	}
+	if mask := dhcp4opts.GetSubnetMask(p.Options); mask != nil {
+		l.SubnetMask = net.IP(mask).String()
+	}
+	if t, err := dhcp4opts.GetLeaseTimes(p.Options); err == nil {
+		if t.Lease == dhcp4opts.InfiniteLease {
+			l.LeaseTime = "infinite"
+		} else {
+			l.LeaseTime = t.Lease.String()
+			l.Renewal = t.Renewal.String()
+			l.Rebinding = t.Rebinding.String()
+		}
+	}
+	for _, code := range p.Options.Codes() {
+		l.Options[strconv.Itoa(int(code))] = hex.EncodeToString(p.Options.Get(code))
+	}
+	return l
+}
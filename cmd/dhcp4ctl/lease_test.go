@@ -0,0 +1,52 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+func TestNewLease(t *testing.T) {
+	p := dhcp4.NewPacket(dhcp4.BootReply)
+	p.YIAddr = net.IPv4(10, 0, 0, 42)
+	p.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPACK)
+	p.Options.Add(dhcp4.OptionSubnetMask, dhcp4opts.SubnetMask{255, 255, 255, 0})
+	p.Options.Add(dhcp4.OptionRouters, dhcp4opts.IPs{net.IPv4(10, 0, 0, 1)})
+	p.Options.AddRaw(dhcp4.OptionIPAddressLeaseTime, []byte{0, 0, 0x0e, 0x10})
+
+	want := &lease{
+		MessageType: "DHCPACK",
+		Address:     "10.0.0.42",
+		SubnetMask:  "255.255.255.0",
+		Routers:     []string{"10.0.0.1"},
+		LeaseTime:   "1h0m0s",
+		Renewal:     "30m0s",
+		Rebinding:   "52m30s",
+		Options: map[string]string{
+			"53": "05",
+			"1":  "ffffff00",
+			"3":  "0a000001",
+			"51": "00000e10",
+		},
+	}
+	if got := newLease(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("newLease() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseCodes(t *testing.T) {
+	got, err := parseCodes("1, 3,6")
+	if want := []dhcp4.OptionCode{1, 3, 6}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCodes() = %v, %v, want %v", got, err, want)
+	}
+	if _, err := parseCodes("1,256"); err == nil {
+		t.Errorf("parseCodes(\"1,256\") = nil error, want error")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// dhcp4ctl obtains and manages DHCPv4 leases on a network interface.
+//
+// Synopsis:
+//
+//	dhcp4ctl -i IFACE [OPTIONS] COMMAND
+//
+// Commands:
+//
+//	discover  print the first offer received
+//	request   obtain a lease and print its ACK
+//	renew     renew the lease in -lease and print the ACK
+//	release   release the lease in -lease
+//	inform    ask for configuration for the address in -local
+//	dump      print the lease in -lease
+//
+// Leases are printed as JSON. dhcp4ctl does not configure the interface.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4client"
+)
+
+var (
+	ifName    = flag.String("i", "", "interface to use")
+	timeout   = flag.Duration("timeout", 5*time.Second, "time to wait for each response")
+	retry     = flag.Int("retry", 3, "number of times to send each request")
+	options   = flag.String("options", "", "comma-separated option codes to request, e.g. 1,3,6")
+	transport = flag.String("transport", "auto", "connection to use: auto, udp, or raw (Linux only)")
+	leaseFile = flag.String("lease", "", "file to save leases to and load them from")
+	local     = flag.String("local", "", "the interface's current IPv4 address, for inform and renew")
+	checksums = flag.Bool("checksums", false, "drop replies with wrong IPv4 or UDP checksums on the raw transport")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s -i IFACE [OPTIONS] discover|request|renew|release|inform|dump\n\n", os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+}
+
+func run(cmd string) error {
+	if cmd == "dump" {
+		ack, err := loadLease()
+		if err != nil {
+			return err
+		}
+		return printLease(ack)
+	}
+
+	if *ifName == "" {
+		return fmt.Errorf("no interface given; use -i")
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var reply *dhcp4.Packet
+	switch cmd {
+	case "discover":
+		reply, err = c.DiscoverOffer()
+	case "request":
+		reply, err = c.Request()
+	case "renew":
+		var ack *dhcp4.Packet
+		if ack, err = loadLease(); err == nil {
+			reply, err = c.Renew(ack)
+		}
+	case "release":
+		ack, err := loadLease()
+		if err != nil {
+			return err
+		}
+		return c.Release(ack)
+	case "inform":
+		reply, err = c.Inform()
+	default:
+		usage()
+	}
+	if err != nil {
+		return err
+	}
+	return printLease(reply)
+}
+
+func newClient() (*dhcp4client.Client, error) {
+	iface, err := dhcp4client.InterfaceByName(*ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []dhcp4client.ClientOpt{
+		dhcp4client.WithTimeout(*timeout),
+		dhcp4client.WithRetry(*retry),
+	}
+	if *options != "" {
+		codes, err := parseCodes(*options)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, dhcp4client.WithRequestedOptions(codes...))
+	}
+	if *leaseFile != "" {
+		opts = append(opts, dhcp4client.WithLeaseStore(dhcp4client.FileLeaseStore{Path: *leaseFile}))
+	}
+	if *local != "" {
+		ip := net.ParseIP(*local)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid local address %q", *local)
+		}
+		opts = append(opts, dhcp4client.WithLocalAddr(ip))
+	}
+	if *checksums {
+		opts = append(opts, dhcp4client.WithChecksumValidation(true))
+	}
+
+	switch *transport {
+	case "auto":
+	case "udp":
+		conn, err := dhcp4client.NewIPv4UDPConn(iface.Name, dhcp4client.ClientPort)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, dhcp4client.WithConn(conn))
+	case "raw":
+		conn, err := rawConn(iface.Name)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, dhcp4client.WithConn(conn))
+	default:
+		return nil, fmt.Errorf("unknown transport %q", *transport)
+	}
+	return dhcp4client.New(iface, opts...)
+}
+
+// parseCodes parses a comma-separated list of option codes.
+func parseCodes(s string) ([]dhcp4.OptionCode, error) {
+	var codes []dhcp4.OptionCode
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(f), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid option code %q", f)
+		}
+		codes = append(codes, dhcp4.OptionCode(n))
+	}
+	return codes, nil
+}
+
+func loadLease() (*dhcp4.Packet, error) {
+	if *leaseFile == "" {
+		return nil, fmt.Errorf("no lease file given; use -lease")
+	}
+	ack, err := dhcp4client.FileLeaseStore{Path: *leaseFile}.Load()
+	if err != nil {
+		return nil, err
+	}
+	if ack == nil {
+		return nil, fmt.Errorf("no lease saved in %s", *leaseFile)
+	}
+	return ack, nil
+}
+
+func printLease(p *dhcp4.Packet) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newLease(p))
+}
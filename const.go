@@ -15,6 +15,15 @@ const (
 	BootReply   OpCode = 2
 )
 
+// Hardware types of the htype field, from the IANA ARP hardware type
+// registry. DOCSIS cable modems use HTypeEthernet.
+const (
+	HTypeEthernet   uint8 = 1
+	HTypeIEEE802    uint8 = 6
+	HTypeEUI64      uint8 = 27
+	HTypeInfiniBand uint8 = 32
+)
+
 // OptionCode is a DHCP option code as defined by RFC 2132.
 type OptionCode uint8
 
@@ -87,4 +96,26 @@ const (
 	OptionClientIdentifier       OptionCode = 61
 	OptionTFTPServerName         OptionCode = 66
 	OptionBootFileName           OptionCode = 67
+
+	// Options defined by later RFCs.
+	OptionUserClass                 OptionCode = 77  // RFC 3004
+	OptionRelayAgentInformation     OptionCode = 82  // RFC 3046
+	OptionClientLastTransactionTime OptionCode = 91  // RFC 4388
+	OptionAssociatedIP              OptionCode = 92  // RFC 4388
+	OptionClientSystemArch          OptionCode = 93  // RFC 4578
+	OptionIPv6OnlyPreferred         OptionCode = 108 // RFC 8925
+	OptionCaptivePortal             OptionCode = 114 // RFC 8910
+	OptionSubnetSelection           OptionCode = 118 // RFC 3011
+	OptionDomainSearch              OptionCode = 119 // RFC 3397
+	OptionClasslessStaticRoute      OptionCode = 121 // RFC 3442
+	OptionMUDURL                    OptionCode = 161 // RFC 8520
+)
+
+// UDP ports used by DHCP as defined by RFC 2131, Section 4.1.
+const (
+	// ServerPort is the port that DHCP servers and relay agents listen on.
+	ServerPort = 67
+
+	// ClientPort is the port that DHCP clients listen on.
+	ClientPort = 68
 )
@@ -0,0 +1,82 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"crypto/rand"
+	"net"
+)
+
+// The constructors below fill in the fields and options RFC 2131, Tables 3
+// and 5 require of each message type, leaving the rest, such as the
+// parameter request list or the lease time, to the caller.
+
+// NewDiscover returns a DHCPDISCOVER from the Ethernet address mac, with a
+// random transaction ID and the broadcast flag set.
+func NewDiscover(mac net.HardwareAddr) *Packet {
+	p := NewPacket(BootRequest)
+	rand.Read(p.TransactionID[:])
+	p.CHAddr = mac
+	p.SetBroadcast(true)
+	p.Options.AddRaw(OptionDHCPMessageType, []byte{byte(MessageTypeDiscover)})
+	return p
+}
+
+// NewOffer returns a DHCPOFFER of yiaddr from the server serverID answering
+// req, a DHCPDISCOVER, as BuildReplySkeleton builds it.
+func NewOffer(req *Packet, yiaddr, serverID net.IP) *Packet {
+	p := BuildReplySkeleton(req, MessageTypeOffer, serverID)
+	p.YIAddr = yiaddr
+	return p
+}
+
+// NewRequest returns the DHCPREQUEST of a client in the SELECTING state
+// accepting offer: it requests the offered address from the server that
+// offered it, in the offer's transaction.
+func NewRequest(offer *Packet) *Packet {
+	p := NewPacket(BootRequest)
+	p.HType = offer.HType
+	p.CHAddr = offer.CHAddr
+	p.TransactionID = offer.TransactionID
+	p.Flags = offer.Flags
+	p.Options.AddRaw(OptionDHCPMessageType, []byte{byte(MessageTypeRequest)})
+	if ip := offer.YIAddr.To4(); ip != nil {
+		p.Options.AddRaw(OptionRequestedIPAddress, ip)
+	}
+	if sid := offer.Options.Get(OptionServerIdentifier); sid != nil {
+		p.Options.AddRaw(OptionServerIdentifier, sid)
+	}
+	return p
+}
+
+// NewAck returns a DHCPACK from the server serverID answering req, a
+// DHCPREQUEST or DHCPINFORM, as BuildReplySkeleton builds it.
+//
+// The address acknowledged is the requested IP address of req, or for
+// renewing and rebinding clients, which send none, their ciaddr. ACKs to
+// DHCPINFORM messages acknowledge no address.
+func NewAck(req *Packet, serverID net.IP) *Packet {
+	p := BuildReplySkeleton(req, MessageTypeACK, serverID)
+	if req.MessageType() == MessageTypeInform {
+		return p
+	}
+	if ip := req.Options.Get(OptionRequestedIPAddress); len(ip) == net.IPv4len {
+		p.YIAddr = net.IP(ip)
+	} else if !isZeroIP(req.CIAddr) {
+		p.YIAddr = req.CIAddr
+	}
+	return p
+}
+
+// NewNak returns a DHCPNAK from the server serverID answering req, as
+// BuildReplySkeleton builds it, with msg as its message option if msg is
+// not empty.
+func NewNak(req *Packet, serverID net.IP, msg string) *Packet {
+	p := BuildReplySkeleton(req, MessageTypeNAK, serverID)
+	if msg != "" {
+		p.Options.AddRaw(OptionMessage, []byte(msg))
+	}
+	return p
+}
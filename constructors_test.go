@@ -0,0 +1,79 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// rebuild checks p against the rules of Builder.Build.
+func rebuild(p *Packet) error {
+	_, err := (&Builder{p: p}).Build()
+	return err
+}
+
+func TestConstructors(t *testing.T) {
+	mac := net.HardwareAddr{1, 2, 3, 4, 5, 6}
+	sid := net.IP{192, 168, 0, 1}
+	yiaddr := net.IP{192, 168, 0, 100}
+
+	discover := NewDiscover(mac)
+	if err := rebuild(discover); err != nil {
+		t.Errorf("NewDiscover() = %v", err)
+	}
+	if discover.TransactionID == [4]byte{} || !discover.Broadcast() {
+		t.Errorf("NewDiscover() has no transaction ID or broadcast flag: %v", discover)
+	}
+
+	offer := NewOffer(discover, yiaddr, sid)
+	offer.Options.AddRaw(OptionIPAddressLeaseTime, []byte{0, 0, 0x0e, 0x10})
+	if err := rebuild(offer); err != nil {
+		t.Errorf("NewOffer() = %v", err)
+	}
+
+	req := NewRequest(offer)
+	if err := rebuild(req); err != nil {
+		t.Errorf("NewRequest() = %v", err)
+	}
+	if req.TransactionID != discover.TransactionID ||
+		!bytes.Equal(req.Options.Get(OptionRequestedIPAddress), yiaddr) ||
+		!bytes.Equal(req.Options.Get(OptionServerIdentifier), sid) {
+		t.Errorf("NewRequest() = %v, want a request for %v from %v", req, yiaddr, sid)
+	}
+
+	ack := NewAck(req, sid)
+	if err := rebuild(ack); err != nil {
+		t.Errorf("NewAck() = %v", err)
+	}
+	if !ack.YIAddr.Equal(yiaddr) {
+		t.Errorf("NewAck() yiaddr = %v, want %v", ack.YIAddr, yiaddr)
+	}
+
+	renew := NewPacket(BootRequest)
+	renew.CHAddr = mac
+	renew.CIAddr = yiaddr
+	renew.Options.AddRaw(OptionDHCPMessageType, []byte{byte(MessageTypeRequest)})
+	if ack := NewAck(renew, sid); !ack.YIAddr.Equal(yiaddr) || !ack.CIAddr.Equal(yiaddr) {
+		t.Errorf("NewAck(renewal) yiaddr, ciaddr = %v, %v, want %v", ack.YIAddr, ack.CIAddr, yiaddr)
+	}
+
+	inform := NewPacket(BootRequest)
+	inform.CHAddr = mac
+	inform.CIAddr = yiaddr
+	inform.Options.AddRaw(OptionDHCPMessageType, []byte{byte(MessageTypeInform)})
+	if ack := NewAck(inform, sid); ack.YIAddr != nil {
+		t.Errorf("NewAck(inform) yiaddr = %v, want none", ack.YIAddr)
+	}
+
+	nak := NewNak(req, sid, "wrong network")
+	if err := rebuild(nak); err != nil {
+		t.Errorf("NewNak() = %v", err)
+	}
+	if got := string(nak.Options.Get(OptionMessage)); got != "wrong network" {
+		t.Errorf("NewNak() message = %q, want %q", got, "wrong network")
+	}
+}
@@ -23,4 +23,8 @@ var (
 	// ErrOptionNotPresent is returned when a requested opcode is not in
 	// the packet.
 	ErrOptionNotPresent = errors.New("option code not present in packet")
+
+	// ErrUnknownOptionType is returned when a typed value is requested
+	// for an option code no OptionValue type is registered for.
+	ErrUnknownOptionType = errors.New("no type registered for option code")
 )
@@ -0,0 +1,136 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// Fallback is configuration that Acquire races against DHCP, such as a
+// static address.
+type Fallback struct {
+	// Delay is how long DHCP runs alone before the fallback starts.
+	Delay time.Duration
+
+	// Claim claims an address. It should give up when ctx is done.
+	Claim func(ctx context.Context) (*net.IPNet, error)
+
+	// Release, if set, gives up an address Claim returned, once DHCP has
+	// won the race.
+	Release func(addr *net.IPNet) error
+}
+
+// StaticFallback returns a Fallback claiming addr, after DHCP has had delay
+// to succeed.
+func StaticFallback(addr *net.IPNet, delay time.Duration) Fallback {
+	return Fallback{
+		Delay: delay,
+		Claim: func(ctx context.Context) (*net.IPNet, error) {
+			return addr, nil
+		},
+	}
+}
+
+// AcquireResult is the configuration Acquire obtained. Exactly one of its
+// fields is set.
+type AcquireResult struct {
+	// Lease is the ACK of the lease, if DHCP won.
+	Lease *dhcp4.Packet
+
+	// Fallback is the address the fallback claimed, if it won.
+	Fallback *net.IPNet
+}
+
+// acquireResult is the outcome of one side of the race.
+type acquireResult struct {
+	AcquireResult
+	err error
+}
+
+// Acquire obtains an address with DHCP, as RequestCached does, while
+// racing fb, and returns whichever succeeds first.
+//
+// Acquire fails only once both have failed, or when ctx is done. The loser
+// is stopped: the contexts of the DHCP exchange and the fallback are
+// canceled. A lease obtained too late is released without being saved or
+// reported to event handlers and hooks, which only learn of the lease that
+// wins; a fallback address claimed too late is released with fb.Release.
+//
+// Like RequestCached, Acquire returns a winning lease along with a
+// *HookError if the client's hooks fail.
+func (c *Client) Acquire(ctx context.Context, fb Fallback) (*AcquireResult, error) {
+	dhcpCtx, dhcpCancel := c.callContext(ctx)
+	defer dhcpCancel()
+	dhcpCh := make(chan acquireResult, 1)
+	go func() {
+		ack, err := c.requestCached(dhcpCtx)
+		if err == nil && messageType(ack) != dhcp4opts.DHCPACK {
+			ack, err = nil, fmt.Errorf("server answered with message type %d", messageType(ack))
+		}
+		dhcpCh <- acquireResult{AcquireResult{Lease: ack}, err}
+	}()
+
+	fbCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	fbCh := make(chan acquireResult, 1)
+	go func() {
+		select {
+		case <-time.After(fb.Delay):
+		case <-fbCtx.Done():
+			fbCh <- acquireResult{err: fbCtx.Err()}
+			return
+		}
+		addr, err := fb.Claim(fbCtx)
+		fbCh <- acquireResult{AcquireResult{Fallback: addr}, err}
+	}()
+
+	var dhcpErr, fbErr error
+	defer func() {
+		// Whatever is still running has lost.
+		go c.releaseLosers(dhcpCh, fbCh, fb)
+	}()
+	for dhcpCh != nil || fbCh != nil {
+		select {
+		case r := <-dhcpCh:
+			dhcpCh = nil
+			if r.err == nil {
+				return &r.AcquireResult, c.acked(r.Lease, LeaseAcked)
+			}
+			dhcpErr = r.err
+
+		case r := <-fbCh:
+			fbCh = nil
+			if r.err == nil {
+				return &r.AcquireResult, nil
+			}
+			fbErr = r.err
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("DHCP failed: %v; fallback failed: %v", dhcpErr, fbErr)
+}
+
+// releaseLosers waits for the sides of an Acquire race still running and
+// releases what they obtain. Nil channels are skipped.
+func (c *Client) releaseLosers(dhcpCh, fbCh <-chan acquireResult, fb Fallback) {
+	if dhcpCh != nil {
+		if r := <-dhcpCh; r.err == nil {
+			c.sendRelease(r.Lease)
+		}
+	}
+	if fbCh != nil {
+		if r := <-fbCh; r.err == nil && fb.Release != nil {
+			fb.Release(r.Fallback)
+		}
+	}
+}
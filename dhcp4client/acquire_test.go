@@ -0,0 +1,155 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+var staticAddr = &net.IPNet{IP: net.IP{10, 0, 0, 2}, Mask: net.CIDRMask(24, 32)}
+
+func TestAcquire(t *testing.T) {
+	for _, tt := range []struct {
+		desc         string
+		script       []dhcp4test.Action
+		fallback     Fallback
+		wantLease    bool
+		wantFallback bool
+	}{
+		{
+			desc:      "DHCP first",
+			fallback:  StaticFallback(staticAddr, time.Second),
+			wantLease: true,
+		},
+		{
+			desc:         "fallback first",
+			script:       []dhcp4test.Action{dhcp4test.Delay(200*time.Millisecond, dhcp4test.Offer())},
+			fallback:     StaticFallback(staticAddr, 0),
+			wantFallback: true,
+		},
+		{
+			desc:         "DHCP fails",
+			script:       []dhcp4test.Action{dhcp4test.Offer(), dhcp4test.Nak("no")},
+			fallback:     StaticFallback(staticAddr, time.Second),
+			wantFallback: true,
+		},
+		{
+			desc:   "both fail",
+			script: []dhcp4test.Action{dhcp4test.Drop(), dhcp4test.Drop()},
+			fallback: Fallback{Claim: func(context.Context) (*net.IPNet, error) {
+				return nil, errors.New("address in use")
+			}},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			srv, conn := dhcp4test.Start(dhcp4test.Config{}, tt.script...)
+			defer srv.Close()
+
+			var mu sync.Mutex
+			var acked, released int
+			mc, err := New(&Interface{Name: "dummy0"},
+				WithConn(conn),
+				WithTimeout(100*time.Millisecond),
+				WithRetry(1),
+				WithEventHandler(func(e Event) {
+					mu.Lock()
+					defer mu.Unlock()
+					switch e.Type {
+					case LeaseAcked:
+						acked++
+					case LeaseReleased:
+						released++
+					}
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mc.Close()
+
+			got, err := mc.Acquire(context.Background(), tt.fallback)
+			if wantErr := !tt.wantLease && !tt.wantFallback; (err != nil) != wantErr {
+				t.Fatalf("Acquire() = %v, want error %t", err, wantErr)
+			}
+			if got != nil && (got.Lease != nil) != tt.wantLease {
+				t.Errorf("Acquire() lease = %v, want lease %t", got.Lease, tt.wantLease)
+			}
+			if got != nil && (got.Fallback != nil) != tt.wantFallback {
+				t.Errorf("Acquire() fallback = %v, want fallback %t", got.Fallback, tt.wantFallback)
+			}
+
+			// Only a winning lease is reported.
+			time.Sleep(300 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			if wantAcked := map[bool]int{true: 1}[tt.wantLease]; acked != wantAcked || released != 0 {
+				t.Errorf("Acquire() reported %d acked and %d released leases, want %d and 0", acked, released, wantAcked)
+			}
+		})
+	}
+}
+
+func TestAcquireReleasesLoser(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	var events int
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithEventHandler(func(Event) { events++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	lease := srv.Reply(mc.DiscoverPacket(), dhcp4opts.DHCPACK)
+	dhcpCh := make(chan acquireResult, 1)
+	dhcpCh <- acquireResult{AcquireResult: AcquireResult{Lease: lease}}
+	mc.releaseLosers(dhcpCh, nil, Fallback{})
+
+	// A lease obtained too late is released without being reported.
+	var released bool
+	for deadline := time.Now().Add(2 * time.Second); !released && time.Now().Before(deadline); {
+		for _, p := range srv.Received() {
+			released = released || messageType(p) == dhcp4opts.DHCPRelease
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !released {
+		t.Errorf("lease not released")
+	}
+	if events != 0 {
+		t.Errorf("releasing a losing lease reported %d events, want 0", events)
+	}
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{}, dhcp4test.Drop())
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(200*time.Millisecond), WithRetry(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := mc.Acquire(ctx, StaticFallback(staticAddr, time.Second)); err != context.DeadlineExceeded {
+		t.Errorf("Acquire() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The DHCP exchange is canceled along with Acquire, rather than
+	// retransmitting its Discover.
+	time.Sleep(500 * time.Millisecond)
+	if got := len(srv.Received()); got != 1 {
+		t.Errorf("server received %d packets after Acquire returned, want 1", got)
+	}
+}
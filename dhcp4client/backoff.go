@@ -0,0 +1,65 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// maxBackoff is the longest retransmission timeout with backoff, as in RFC
+// 2131, Section 4.1.
+const maxBackoff = 64 * time.Second
+
+// WithRetryBudget configures the client to retransmit until budget has
+// passed rather than a number of times, for example to keep trying for a
+// minute at boot before falling back to static configuration.
+//
+// Retransmissions back off as RFC 2131, Section 4.1 describes: the timeout
+// of WithTimeout doubles after every transmission, up to 64 seconds, and is
+// randomized by up to a quarter, but at most a second, either way. For the
+// RFC's schedule of 4, 8, 16 seconds and so on, use WithTimeout(4 *
+// time.Second).
+//
+// Like WithExchangeTimeout, the budget bounds each call exchanging messages,
+// such as Request, and the retry count of WithRetry is ignored. It cannot be
+// combined with WithExchangeTimeout.
+func WithRetryBudget(budget time.Duration) ClientOpt {
+	return func(c *Client) error {
+		if budget <= 0 {
+			return fmt.Errorf("retry budget must be positive, got %v", budget)
+		}
+		if c.exchangeTimeout > 0 && !c.backoff {
+			return errExchangeTimeoutAndBudget
+		}
+		c.exchangeTimeout = budget
+		c.backoff = true
+		return nil
+	}
+}
+
+// retransmitTimeout returns how long to wait for responses to the
+// transmission numbered attempt, counting from 0, before retransmitting.
+func (c *Client) retransmitTimeout(attempt int) time.Duration {
+	if !c.backoff {
+		return c.timeout
+	}
+	d := c.timeout
+	for i := 0; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	jitter := d / 4
+	if jitter > time.Second {
+		jitter = time.Second
+	}
+	if jitter <= 0 {
+		return d
+	}
+	return d - jitter + time.Duration(rand.Int63n(2*int64(jitter)+1))
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestRetransmitTimeout(t *testing.T) {
+	c := &Client{timeout: 4 * time.Second}
+	if got := c.retransmitTimeout(3); got != 4*time.Second {
+		t.Errorf("retransmitTimeout(3) without backoff = %v, want %v", got, 4*time.Second)
+	}
+
+	c.backoff = true
+	for _, tt := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 4 * time.Second},
+		{1, 8 * time.Second},
+		{2, 16 * time.Second},
+		{4, 64 * time.Second},
+		{10, 64 * time.Second},
+	} {
+		got := c.retransmitTimeout(tt.attempt)
+		if got < tt.want-time.Second || got > tt.want+time.Second {
+			t.Errorf("retransmitTimeout(%d) = %v, want %v ± 1s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetryBudget(t *testing.T) {
+	drops := make([]dhcp4test.Action, 100)
+	for i := range drops {
+		drops[i] = dhcp4test.Drop()
+	}
+	srv, conn := dhcp4test.Start(dhcp4test.Config{}, drops...)
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(20*time.Millisecond), WithRetry(1), WithRetryBudget(250*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	start := time.Now()
+	if _, err := mc.SendAndReadOne(mc.DiscoverPacket()); !isTimeout(err) {
+		t.Errorf("SendAndReadOne() = %v, want a timeout", err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond || d > time.Second {
+		t.Errorf("SendAndReadOne() took %v, want about 250ms", d)
+	}
+	// Transmissions at about 0, 20, 60, and 140ms, and maybe one more
+	// before the budget runs out: backing off, fewer than the 12 of a
+	// fixed timeout.
+	if n := len(srv.Received()); n < 3 || n > 6 {
+		t.Errorf("server received %d requests, want 3 to 6", n)
+	}
+
+	if _, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithRetryBudget(0)); err == nil {
+		t.Error("New(WithRetryBudget(0)) succeeded")
+	}
+}
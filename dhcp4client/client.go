@@ -4,31 +4,38 @@
 
 // Package dhcp4client is a small, minimum-functionality client for DHCPv4.
 //
-// It only supports the 4-way DHCPv4 Discover-Offer-Request-Ack handshake as
-// well as the Request-Ack renewal process.
+// It only supports the 4-way DHCPv4 Discover-Offer-Request-Ack handshake, the
+// Request-Ack renewal process, and reclaiming a cached lease on reboot.
 package dhcp4client
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"math"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/u-root/dhcp4"
 	"github.com/u-root/dhcp4/dhcp4opts"
-	"github.com/vishvananda/netlink"
 )
 
 const (
-	maxMessageSize = 1500
+	// defaultMaxMessageSize is the default receive buffer size and
+	// advertised maximum DHCP message size, fitting a standard Ethernet
+	// MTU.
+	defaultMaxMessageSize = 1500
+
+	// minMaxMessageSize is the smallest legal maximum DHCP message size
+	// according to RFC 2132, Section 9.10.
+	minMaxMessageSize = 576
 
 	// ClientPort is the port that DHCP clients listen on.
-	ClientPort = 68
+	ClientPort = dhcp4.ClientPort
 
 	// ServerPort is the port that DHCP servers and relay agents listen on.
-	ServerPort = 67
+	ServerPort = dhcp4.ServerPort
 )
 
 var (
@@ -41,20 +48,119 @@ var (
 )
 
 // Client is an IPv4 DHCP client.
+//
+// A Client is safe for concurrent use by multiple goroutines, for example to
+// renew a lease while asking for configuration with Inform. Every exchange
+// has its own transaction ID, and a single reader hands each response on the
+// connection to the exchange it answers.
 type Client struct {
-	iface   netlink.Link
+	iface   *Interface
 	conn    net.PacketConn
 	timeout time.Duration
 	retry   int
+
+	// exchangeTimeout, if set, bounds each call exchanging messages,
+	// across all its exchanges and retransmissions.
+	exchangeTimeout time.Duration
+
+	// backoff is whether the retransmission timeout grows with every
+	// retransmission.
+	backoff bool
+
+	// maxMessageSize is both the size of the receive buffer and the
+	// maximum message size advertised to servers.
+	maxMessageSize int
+
+	// dispatcher reads all responses from conn.
+	dispatcher *dispatcher
+
+	metrics Metrics
+
+	// localAddr is the client's own address, if it has one.
+	localAddr net.IP
+
+	// gatewayIP is the relay agent address the client poses as, if any.
+	gatewayIP net.IP
+
+	// port is the port the client listens on, if not the default.
+	port int
+
+	// checksums is whether a raw connection drops packets with wrong
+	// checksums, and allowZeroUDPChecksum whether it then accepts UDP
+	// datagrams without a checksum.
+	checksums            bool
+	allowZeroUDPChecksum bool
+
+	// servers are the addresses requests are sent to, if not
+	// DefaultServers.
+	servers []*net.UDPAddr
+
+	// userClass lists the user classes the client identifies as.
+	userClass dhcp4opts.UserClass
+
+	// subnetSelection and linkSelection, if set, direct the server to
+	// allocate from a particular subnet.
+	subnetSelection net.IP
+	linkSelection   net.IP
+
+	// leaseStore, if set, keeps the last lease obtained.
+	leaseStore LeaseStore
+
+	// xidGen generates transaction IDs.
+	xidGen func() [4]byte
+
+	// mu guards broadcast, lastOffer, and calls to xidGen, the only state
+	// changing after New.
+	mu sync.Mutex
+
+	// offerCache enables keeping lastOffer.
+	offerCache bool
+
+	// lastOffer, if set, is an offer whose request went unanswered, to be
+	// requested again by the next Request.
+	lastOffer *cachedOffer
+
+	// broadcast is the broadcast flag set in requests. It is flipped
+	// when servers only answered once it was.
+	broadcast bool
+
+	// noDedup disables dropping duplicate responses.
+	noDedup bool
+
+	// hostname, if set, is sent in the host name option.
+	hostname string
+
+	// mudURL, if set, is sent in the MUD URL option.
+	mudURL dhcp4opts.MUDURL
+
+	// requested lists the options asked for in the parameter request
+	// list, if any.
+	requested dhcp4opts.OptionCodes
+
+	// ipv6Only is whether the client can operate with IPv6 only.
+	ipv6Only bool
+
+	// eventHandlers are called for every lease event.
+	eventHandlers []func(Event)
+
+	// hooks are run for every lease event, after eventHandlers.
+	hooks []func(Event) error
 }
 
 // New creates a new DHCP client that sends and receives packets on the given
 // interface.
-func New(iface netlink.Link, opts ...ClientOpt) (*Client, error) {
+//
+// Use InterfaceByName to look up an interface. If iface is nil, a connection
+// must be configured with WithConn.
+func New(iface *Interface, opts ...ClientOpt) (*Client, error) {
 	c := &Client{
-		iface:   iface,
-		timeout: 10 * time.Second,
-		retry:   3,
+		iface:          iface,
+		timeout:        10 * time.Second,
+		retry:          3,
+		maxMessageSize: defaultMaxMessageSize,
+		metrics:        nopMetrics{},
+		xidGen:         randomXID,
+		broadcast:      true,
 	}
 
 	for _, opt := range opts {
@@ -63,13 +169,32 @@ func New(iface netlink.Link, opts ...ClientOpt) (*Client, error) {
 		}
 	}
 
+	port := ClientPort
+	if c.port != 0 {
+		port = c.port
+	} else if c.gatewayIP != nil {
+		// Servers send replies for relay agents to the server port.
+		port = ServerPort
+	}
 	if c.conn == nil {
+		if iface == nil {
+			return nil, fmt.Errorf("either an interface or a connection must be given")
+		}
 		var err error
-		c.conn, err = NewPacketUDPConn(iface.Attrs().Name, ClientPort)
+		c.conn, err = newDefaultConn(iface, &net.UDPAddr{IP: c.localAddr, Port: port}, c.unicastServers())
 		if err != nil {
 			return nil, err
 		}
 	}
+	if v, ok := c.conn.(checksumValidator); ok && c.checksums {
+		v.validateChecksums(c.allowZeroUDPChecksum)
+	}
+	if t, ok := c.conn.(Transport); ok {
+		// Without a filter, the Client just sees more packets to
+		// discard.
+		_ = t.Filter(port, c.hardwareAddr())
+	}
+	c.dispatcher = newDispatcher(c.conn, c.maxMessageSize)
 	return c, nil
 }
 
@@ -100,6 +225,323 @@ func WithRetry(r int) ClientOpt {
 	}
 }
 
+// WithExchangeTimeout bounds the total time of each call exchanging
+// messages, such as Request, RequestCached, or Renew, across all its
+// exchanges and retransmissions, whatever WithTimeout and WithRetry are. A
+// call without the responses it needs by then fails as if its
+// retransmissions ran out.
+//
+// Request shares the bound between its Discovers and its Request, so it
+// returns within total. SendAndRead and SimpleSendAndRead are bounded by the
+// context they are given instead.
+//
+// WithExchangeTimeout cannot be combined with WithRetryBudget, which sets a
+// bound of its own.
+//
+// Default is no bound beyond the retransmissions.
+func WithExchangeTimeout(total time.Duration) ClientOpt {
+	return func(c *Client) error {
+		if total <= 0 {
+			return fmt.Errorf("exchange timeout must be positive, got %v", total)
+		}
+		if c.backoff {
+			return errExchangeTimeoutAndBudget
+		}
+		c.exchangeTimeout = total
+		return nil
+	}
+}
+
+// errExchangeTimeoutAndBudget is returned when both WithExchangeTimeout and
+// WithRetryBudget are given.
+var errExchangeTimeoutAndBudget = errors.New("WithExchangeTimeout and WithRetryBudget cannot be combined")
+
+// callContext returns the context of a call exchanging messages within
+// parent, which also ends when the exchange timeout, if any, expires. All
+// exchanges of the call share it.
+func (c *Client) callContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if c.exchangeTimeout > 0 {
+		return context.WithTimeout(parent, c.exchangeTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// WithMaxMessageSize configures the largest DHCP message the client accepts.
+//
+// The client advertises this size to servers in the maximum DHCP message size
+// option and sizes its receive buffer to match, so interfaces with jumbo
+// frames can receive replies larger than a standard Ethernet frame.
+//
+// Default is 1500. Sizes must be between 576 (RFC 2132, Section 9.10) and
+// 65535.
+func WithMaxMessageSize(size int) ClientOpt {
+	return func(c *Client) error {
+		if size < minMaxMessageSize || size > math.MaxUint16 {
+			return fmt.Errorf("maximum message size %d must be between %d and %d", size, minMaxMessageSize, math.MaxUint16)
+		}
+		c.maxMessageSize = size
+		return nil
+	}
+}
+
+// WithLocalAddr configures the client's own IPv4 address, for clients that
+// already hold a lease, such as when renewing or on interfaces with several
+// addresses.
+//
+// Unless a connection is given with WithConn, the client sends from ip over
+// a UDP socket; on Linux, the socket still receives broadcast replies, such
+// as DHCPNAKs. Renewals and rebinds carry ip as the client address
+// (ciaddr) instead of the address in the lease they extend.
+func WithLocalAddr(ip net.IP) ClientOpt {
+	return func(c *Client) error {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return fmt.Errorf("local address %v is not an IPv4 address", ip)
+		}
+		c.localAddr = ip4
+		return nil
+	}
+}
+
+// WithGatewayIP makes the client pose as a relay agent with address ip.
+//
+// Discover and Request packets carry ip as the relay agent address (giaddr)
+// and a hop count of 1, so servers select an address from the scope of ip
+// and send replies to ip on the server port, where the client listens unless
+// a connection is given with WithConn. This lets a management host test how
+// servers serve remote subnets.
+func WithGatewayIP(ip net.IP) ClientOpt {
+	return func(c *Client) error {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return fmt.Errorf("gateway address %v is not an IPv4 address", ip)
+		}
+		c.gatewayIP = ip4
+		return nil
+	}
+}
+
+// WithUserClass configures the user classes the client identifies as in the
+// user class option of its Discover and Request packets, as defined by RFC
+// 3004. Servers may use them to select configuration for a group of clients,
+// such as kiosks or lab machines.
+func WithUserClass(classes ...string) ClientOpt {
+	return func(c *Client) error {
+		uc := dhcp4opts.UserClass(classes)
+		b, err := uc.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if len(b) > 255 {
+			return fmt.Errorf("user classes take %d bytes, more than the 255 an option can hold", len(b))
+		}
+		c.userClass = uc
+		return nil
+	}
+}
+
+// WithSubnetSelection asks servers to allocate an address from the subnet
+// containing ip rather than from the subnet the request arrived on, using the
+// subnet selection option defined by RFC 3011.
+func WithSubnetSelection(ip net.IP) ClientOpt {
+	return func(c *Client) error {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return fmt.Errorf("subnet selection %v is not an IPv4 address", ip)
+		}
+		c.subnetSelection = ip4
+		return nil
+	}
+}
+
+// WithClientPort configures the client to listen on port instead of the
+// client port 68, or the server port 67 with WithGatewayIP, so that it does
+// not conflict with the system's DHCP client and can run unprivileged.
+//
+// Servers send replies to clients to port 68 whatever port requests come
+// from, unless the client poses as a relay agent with WithGatewayIP. In that
+// case, requests carry the relay source port sub-option of RFC 8357, asking
+// servers to reply to port instead of 67. Otherwise, only servers answering
+// the source port of requests, such as those of package dhcp4test, reach the
+// client.
+func WithClientPort(port int) ClientOpt {
+	return func(c *Client) error {
+		if port <= 0 || port > 0xffff {
+			return fmt.Errorf("invalid client port %d", port)
+		}
+		c.port = port
+		return nil
+	}
+}
+
+// WithChecksumValidation makes the client drop replies whose IPv4 header or
+// UDP checksum is wrong, and if allowZeroUDP is false, replies without a UDP
+// checksum, which RFC 768 allows and some servers send.
+//
+// It only affects raw connections, such as the one a Client uses by default
+// on Linux or one returned by NewPacketUDPConn, since the kernel does not
+// check the checksums of packets received on raw sockets. UDP sockets only
+// ever see packets the kernel has checked.
+//
+// Default is no validation.
+func WithChecksumValidation(allowZeroUDP bool) ClientOpt {
+	return func(c *Client) error {
+		c.checksums = true
+		c.allowZeroUDPChecksum = allowZeroUDP
+		return nil
+	}
+}
+
+// checksumValidator is a connection that can drop received packets with
+// wrong checksums.
+type checksumValidator interface {
+	validateChecksums(allowZeroUDP bool)
+}
+
+// WithLinkSelection sends ip as the link selection sub-option of the relay
+// agent information option, defined by RFC 3527, naming the subnet of the
+// link the client is on.
+//
+// Relay agents normally add this sub-option; it is meant for clients posing
+// as a relay agent with WithGatewayIP whose giaddr is not on the client's
+// subnet.
+func WithLinkSelection(ip net.IP) ClientOpt {
+	return func(c *Client) error {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return fmt.Errorf("link selection %v is not an IPv4 address", ip)
+		}
+		c.linkSelection = ip4
+		return nil
+	}
+}
+
+// WithBroadcastFlag configures whether the client sets the broadcast flag in
+// its requests, asking servers to broadcast their replies rather than
+// unicast them to the offered address (RFC 2131, Section 4.1).
+//
+// Some networks and Wi-Fi drivers only deliver one kind of reply. If a
+// Discover goes unanswered, it is retried once with the flag flipped, and the
+// setting that worked is kept for later requests.
+//
+// Default is true.
+func WithBroadcastFlag(broadcast bool) ClientOpt {
+	return func(c *Client) error {
+		c.broadcast = broadcast
+		return nil
+	}
+}
+
+// WithDedup configures whether the client drops duplicate responses to an
+// exchange. Responses are duplicates if they carry the same server
+// identifier, are for the same transaction, and offer the same address, as
+// happens when a server answers every retransmission of a Discover.
+//
+// Default is true.
+func WithDedup(dedup bool) ClientOpt {
+	return func(c *Client) error {
+		c.noDedup = !dedup
+		return nil
+	}
+}
+
+// WithMUDURL configures the client to send u in the Manufacturer Usage
+// Description URL option defined by RFC 8520, so that the network can look up
+// which access the device needs. u must be an absolute https URL.
+func WithMUDURL(u string) ClientOpt {
+	return func(c *Client) error {
+		m := dhcp4opts.MUDURL(u)
+		if _, err := m.MarshalBinary(); err != nil {
+			return err
+		}
+		c.mudURL = m
+		return nil
+	}
+}
+
+// WithRequestedOptions adds codes to the parameter request list the client
+// sends, asking servers to include those options in their replies (RFC 2132,
+// Section 9.8).
+//
+// By default, no parameter request list is sent.
+func WithRequestedOptions(codes ...dhcp4.OptionCode) ClientOpt {
+	return func(c *Client) error {
+		for _, code := range codes {
+			c.requestOption(code)
+		}
+		return nil
+	}
+}
+
+// requestOption adds code to the parameter request list, unless it is
+// already there.
+func (c *Client) requestOption(code dhcp4.OptionCode) {
+	for _, r := range c.requested {
+		if r == code {
+			return
+		}
+	}
+	c.requested = append(c.requested, code)
+}
+
+// WithCaptivePortal configures whether the client asks servers for the
+// captive portal API URI of RFC 8910 in its parameter request list.
+// dhcp4opts.GetCaptivePortal reads it from replies.
+//
+// Default is false.
+func WithCaptivePortal(enable bool) ClientOpt {
+	return func(c *Client) error {
+		if enable {
+			c.requestOption(dhcp4.OptionCaptivePortal)
+		}
+		return nil
+	}
+}
+
+// WithServerAddrs configures the client to send its requests to each of
+// addrs, rather than broadcasting them to DefaultServers, such as to reach
+// known servers across a routed management network or a test server on
+// another port. Replies from all of them are collected as from a broadcast.
+//
+// Unless a connection is given with WithConn, a client sending to unicast
+// addresses uses a UDP socket rather than a raw one on Linux, so that the
+// kernel routes its requests. The interface must then have an address.
+//
+// Releases still go to the server identified in the lease.
+func WithServerAddrs(addrs []*net.UDPAddr) ClientOpt {
+	return func(c *Client) error {
+		if len(addrs) == 0 {
+			return fmt.Errorf("no server addresses given")
+		}
+		for _, a := range addrs {
+			if a == nil || a.IP.To4() == nil || a.Port <= 0 || a.Port > math.MaxUint16 {
+				return fmt.Errorf("server address %v must be an IPv4 address and port", a)
+			}
+		}
+		c.servers = append([]*net.UDPAddr(nil), addrs...)
+		return nil
+	}
+}
+
+// unicastServers reports whether requests are sent to any address other than
+// the limited broadcast address.
+func (c *Client) unicastServers() bool {
+	for _, a := range c.servers {
+		if !a.IP.Equal(net.IPv4bcast) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverAddrs returns the addresses requests are sent to.
+func (c *Client) serverAddrs() []*net.UDPAddr {
+	if c.servers != nil {
+		return c.servers
+	}
+	return []*net.UDPAddr{DefaultServers}
+}
+
 // WithConn configures the packet connection to use.
 func WithConn(conn net.PacketConn) ClientOpt {
 	return func(c *Client) error {
@@ -110,9 +552,44 @@ func WithConn(conn net.PacketConn) ClientOpt {
 
 // DiscoverOffer sends a DHCPDiscover message and returns the first valid offer
 // received.
+//
+// If no server answers, the Discover is sent again with the broadcast flag
+// flipped; see WithBroadcastFlag.
 func (c *Client) DiscoverOffer() (*dhcp4.Packet, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	wg, out, errCh := c.SimpleSendAndRead(ctx, DefaultServers, c.DiscoverPacket())
+	ctx, cancel := c.callContext(context.Background())
+	defer cancel()
+	return c.discover(ctx)
+}
+
+// discover is DiscoverOffer, within ctx.
+func (c *Client) discover(ctx context.Context) (*dhcp4.Packet, error) {
+	offer, err := c.discoverOffer(ctx, c.DiscoverPacket())
+	if isTimeout(err) && ctx.Err() == nil {
+		p := c.DiscoverPacket()
+		p.SetBroadcast(!p.Broadcast())
+		offer, err = c.discoverOffer(ctx, p)
+		if err == nil {
+			c.mu.Lock()
+			c.broadcast = p.Broadcast()
+			c.mu.Unlock()
+		}
+	}
+	if err == nil {
+		err = c.emit(LeaseOffered, offer)
+	}
+	return offer, err
+}
+
+// isTimeout reports whether err is a ClientError for an exchange that went
+// unanswered.
+func isTimeout(err error) bool {
+	ce, ok := err.(*ClientError)
+	return ok && ce.Err == context.DeadlineExceeded
+}
+
+func (c *Client) discoverOffer(ctx context.Context, discover *dhcp4.Packet) (*dhcp4.Packet, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	wg, out, errCh := c.simpleSendAndRead(ctx, c.serverAddrs(), discover)
 	defer func() {
 		// Explicitly cancel first, then wait.
 		cancel()
@@ -134,22 +611,240 @@ func (c *Client) DiscoverOffer() (*dhcp4.Packet, error) {
 }
 
 // Request completes the 4-way Discover-Offer-Request-Ack handshake.
+//
+// With WithOfferCache, an offer whose request went unanswered is requested
+// again by the next call instead of starting over with a Discover.
 func (c *Client) Request() (*dhcp4.Packet, error) {
-	offer, err := c.DiscoverOffer()
+	ctx, cancel := c.callContext(context.Background())
+	defer cancel()
+	ack, err := c.request(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return ack, c.acked(ack, LeaseAcked)
+}
+
+// request is Request, within ctx, except that an ACK is not yet recorded
+// with acked.
+func (c *Client) request(ctx context.Context) (ack *dhcp4.Packet, err error) {
+	defer c.observeHandshake(time.Now(), &err)
+
+	cached := c.takeOffer()
+	if cached == nil {
+		cached = &cachedOffer{start: time.Now()}
+		cached.offer, err = c.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.ipv6OnlyWait(cached.offer); err != nil {
+			return nil, err
+		}
+	}
+
+	req := c.RequestPacket(cached.offer)
+	req.Secs = cached.secs()
+	ack, err = c.sendAndReadOne(ctx, req)
+	if err != nil {
+		if isTimeout(err) && !cached.reused {
+			c.keepOffer(cached)
+		}
+		return nil, err
+	}
+	if err := c.ipv6OnlyWait(ack); err != nil && messageType(ack) == dhcp4opts.DHCPACK {
+		c.Release(ack)
+		return nil, err
+	}
+	return ack, nil
+}
+
+// Renew asks the server that granted lease, an earlier ACK, to extend it.
+//
+// As required in the RENEWING state by RFC 2131, Section 4.3.2, the
+// DHCPREQUEST carries the leased address in ciaddr, has no requested IP
+// address or server identifier option, and is unicast to the server
+// identified in the lease. With WithServerAddrs, it goes to the configured
+// addresses instead.
+func (c *Client) Renew(lease *dhcp4.Packet) (reply *dhcp4.Packet, err error) {
+	defer c.observeHandshake(time.Now(), &err)
+
+	ctx, cancel := c.callContext(context.Background())
+	defer cancel()
+	reply, err = c.sendAndReadOneTo(ctx, c.renewAddrs(lease), c.RenewPacket(lease))
+	if err == nil {
+		err = c.acked(reply, LeaseRenewed)
+	}
+	return reply, err
+}
+
+// renewAddrs returns the addresses renewals of lease are sent to.
+func (c *Client) renewAddrs(lease *dhcp4.Packet) []*net.UDPAddr {
+	if c.servers != nil {
+		return c.servers
+	}
+	if sid := dhcp4opts.GetServerIdentifier(lease.Options); sid != nil {
+		return []*net.UDPAddr{{IP: net.IP(sid), Port: ServerPort}}
+	}
+	return c.serverAddrs()
+}
+
+// RenewPacket returns a RENEWING DHCPRequest packet extending lease, an
+// earlier ACK.
+//
+// It only differs from the packet RebindPacket returns in where it is sent.
+func (c *Client) RenewPacket(lease *dhcp4.Packet) *dhcp4.Packet {
+	return c.extendPacket(lease)
+}
+
+// RenewResult is the outcome of a renewal started with RenewAsync.
+type RenewResult struct {
+	// Reply is the server's reply, or nil if Err is set.
+	Reply *dhcp4.Packet
+
+	// Err is the error Renew returned.
+	Err error
+}
+
+// RenewAsync starts renewing ack as Renew does and returns at once, so that
+// many renewals can be in flight without blocking the caller.
+//
+// The result is sent on the returned channel, which is then closed. The
+// channel is buffered, so the result may be ignored. Renewals on the same
+// Client share its connection, each response being handed to the renewal it
+// answers.
+func (c *Client) RenewAsync(ack *dhcp4.Packet) <-chan RenewResult {
+	ch := make(chan RenewResult, 1)
+	go func() {
+		reply, err := c.Renew(ack)
+		ch <- RenewResult{Reply: reply, Err: err}
+		close(ch)
+	}()
+	return ch
+}
+
+// Rebind asks any server to extend lease, an earlier ACK, once the server
+// that granted it has not answered renewals until the rebinding time T2.
+//
+// Unlike Renew, the DHCPREQUEST is broadcast to any server, as required in
+// the REBINDING state by RFC 2131, Section 4.3.2.
+func (c *Client) Rebind(lease *dhcp4.Packet) (reply *dhcp4.Packet, err error) {
+	defer c.observeHandshake(time.Now(), &err)
+
+	reply, err = c.SendAndReadOne(c.RebindPacket(lease))
+	if err == nil {
+		err = c.acked(reply, LeaseRenewed)
+	}
+	return reply, err
+}
+
+// RebindPacket returns a REBINDING DHCPRequest packet extending lease, an
+// earlier ACK.
+func (c *Client) RebindPacket(lease *dhcp4.Packet) *dhcp4.Packet {
+	return c.extendPacket(lease)
+}
+
+// extendPacket returns a DHCPRequest packet extending lease from the RENEWING
+// or REBINDING state: the client's address is in ciaddr, and there is no
+// requested IP address or server identifier option.
+func (c *Client) extendPacket(lease *dhcp4.Packet) *dhcp4.Packet {
+	packet := dhcp4.NewPacket(dhcp4.BootRequest)
+	packet.TransactionID = c.newXID()
+	c.setHardwareAddr(packet)
+	packet.CIAddr = lease.YIAddr
+	if c.localAddr != nil {
+		packet.CIAddr = c.localAddr
+	}
+	c.setRelay(packet)
+
+	packet.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPRequest)
+	packet.Options.Add(dhcp4.OptionMaximumDHCPMessageSize, dhcp4opts.Uint16(c.maxMessageSize))
+	c.addClientOptions(packet)
+	return packet
+}
+
+// Release gives up the address of lease, an ACK, by sending a DHCPRELEASE to
+// the server that granted it (RFC 2131, Section 4.4.6).
+//
+// Release does not wait for an answer, as servers do not answer
+// DHCPRELEASE messages.
+func (c *Client) Release(lease *dhcp4.Packet) error {
+	if err := c.sendRelease(lease); err != nil {
+		return err
+	}
+	return c.emit(LeaseReleased, lease)
+}
+
+// sendRelease sends the DHCPRELEASE of Release without reporting an event.
+func (c *Client) sendRelease(lease *dhcp4.Packet) error {
+	packet := dhcp4.NewPacket(dhcp4.BootRequest)
+	packet.TransactionID = c.newXID()
+	c.setHardwareAddr(packet)
+	packet.CIAddr = lease.YIAddr
+
+	sid := dhcp4opts.GetServerIdentifier(lease.Options)
+	packet.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPRelease)
+	if sid != nil {
+		packet.Options.Add(dhcp4.OptionServerIdentifier, sid)
+	}
+
+	b, err := packet.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	dest := c.serverAddrs()[0]
+	if sid != nil {
+		dest = &net.UDPAddr{IP: net.IP(sid), Port: ServerPort}
+	}
+	if _, err := c.conn.WriteTo(b, dest); err != nil {
+		return err
+	}
+	c.metrics.PacketSent(dhcp4opts.DHCPRelease)
+	return nil
+}
+
+// Inform asks servers for configuration parameters, such as DNS servers, for
+// a client whose address was configured by other means (RFC 2131, Section
+// 4.4.3). The address must be given with WithLocalAddr.
+func (c *Client) Inform() (ack *dhcp4.Packet, err error) {
+	if c.localAddr == nil {
+		return nil, fmt.Errorf("DHCPINFORM requires a local address; use WithLocalAddr")
+	}
+	return c.SendAndReadOne(c.InformPacket())
+}
+
+// InformPacket returns a DHCPInform packet for the client's local address.
+func (c *Client) InformPacket() *dhcp4.Packet {
+	packet := dhcp4.NewPacket(dhcp4.BootRequest)
+	packet.TransactionID = c.newXID()
+	c.setHardwareAddr(packet)
+	packet.CIAddr = c.localAddr
+	c.setRelay(packet)
 
-	return c.SendAndReadOne(c.RequestPacket(offer))
+	packet.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPInform)
+	packet.Options.Add(dhcp4.OptionMaximumDHCPMessageSize, dhcp4opts.Uint16(c.maxMessageSize))
+	c.addClientOptions(packet)
+	return packet
 }
 
-// Renew sends a renewal request packet and waits for the corresponding response.
-func (c *Client) Renew(ack *dhcp4.Packet) (*dhcp4.Packet, error) {
-	return c.SendAndReadOne(c.RequestPacket(ack))
+// observeHandshake reports a handshake started at start that returned *err.
+func (c *Client) observeHandshake(start time.Time, err *error) {
+	c.metrics.Handshake(time.Since(start), *err)
+}
+
+// broadcastFlag returns the broadcast flag to set in requests.
+func (c *Client) broadcastFlag() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.broadcast
 }
 
 // Close closes the client connection.
+//
+// Exchanges still waiting for responses fail once the connection is closed.
+// Close may be called more than once.
 func (c *Client) Close() error {
+	if c.dispatcher != nil {
+		c.dispatcher.close()
+	}
 	if c.conn != nil {
 		return c.conn.Close()
 	}
@@ -159,8 +854,20 @@ func (c *Client) Close() error {
 // SendAndReadOne sends one packet and returns the first response returned by
 // any server.
 func (c *Client) SendAndReadOne(packet *dhcp4.Packet) (*dhcp4.Packet, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	wg, out, errCh := c.SimpleSendAndRead(ctx, DefaultServers, packet)
+	ctx, cancel := c.callContext(context.Background())
+	defer cancel()
+	return c.sendAndReadOne(ctx, packet)
+}
+
+// sendAndReadOne is SendAndReadOne, within ctx.
+func (c *Client) sendAndReadOne(ctx context.Context, packet *dhcp4.Packet) (*dhcp4.Packet, error) {
+	return c.sendAndReadOneTo(ctx, c.serverAddrs(), packet)
+}
+
+// sendAndReadOneTo is sendAndReadOne, sending packet to each of dests.
+func (c *Client) sendAndReadOneTo(ctx context.Context, dests []*net.UDPAddr, packet *dhcp4.Packet) (*dhcp4.Packet, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	wg, out, errCh := c.simpleSendAndRead(ctx, dests, packet)
 	defer func() {
 		// Explicitly cancel first, then wait.
 		cancel()
@@ -177,36 +884,114 @@ func (c *Client) SendAndReadOne(packet *dhcp4.Packet) (*dhcp4.Packet, error) {
 	return nil, fmt.Errorf("no packet received")
 }
 
+// hardwareAddr returns the hardware address of the client's interface, if
+// any.
+func (c *Client) hardwareAddr() net.HardwareAddr {
+	if c.iface == nil {
+		return nil
+	}
+	return c.iface.HardwareAddr
+}
+
+// infinibandAddrLen is the length of IP over InfiniBand link-layer addresses:
+// 4 bytes of flags and queue pair number followed by the 16-byte port GID,
+// whose last 8 bytes are the port GUID.
+const infinibandAddrLen = 20
+
+// setHardwareAddr sets the hardware type and address of packet to those of
+// the client's interface.
+//
+// InfiniBand addresses do not fit in the chaddr field, so clients on
+// InfiniBand identify themselves with the client identifier RFC 4390, Section
+// 2.1 requires instead: type 255, an IAID of the last 4 bytes of the address,
+// and a DUID-LL of the port GUID, as defined by RFC 4361, Section 6.1.
+func (c *Client) setHardwareAddr(packet *dhcp4.Packet) {
+	addr := c.hardwareAddr()
+	packet.CHAddr = addr
+	if len(addr) != infinibandAddrLen {
+		return
+	}
+	packet.HType = dhcp4.HTypeInfiniBand
+	id := []byte{0xff}
+	id = append(id, addr[16:20]...)
+	id = append(id, 0, 3, 0, dhcp4.HTypeInfiniBand)
+	id = append(id, addr[12:20]...)
+	packet.Options.AddRaw(dhcp4.OptionClientIdentifier, id)
+}
+
 // DiscoverPacket returns a valid Discover packet for this client.
 //
 // TODO: Look at RFC and confirm.
 func (c *Client) DiscoverPacket() *dhcp4.Packet {
 	packet := dhcp4.NewPacket(dhcp4.BootRequest)
-	rand.Read(packet.TransactionID[:])
-	packet.CHAddr = c.iface.Attrs().HardwareAddr
-	packet.Broadcast = true
+	packet.TransactionID = c.newXID()
+	c.setHardwareAddr(packet)
+	packet.SetBroadcast(c.broadcastFlag())
+	c.setRelay(packet)
 
 	packet.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPDiscover)
-	packet.Options.Add(dhcp4.OptionMaximumDHCPMessageSize, dhcp4opts.Uint16(maxMessageSize))
+	packet.Options.Add(dhcp4.OptionMaximumDHCPMessageSize, dhcp4opts.Uint16(c.maxMessageSize))
+	c.addClientOptions(packet)
 	return packet
 }
 
+// addClientOptions adds the options the client was configured to identify
+// itself with to packet.
+func (c *Client) addClientOptions(packet *dhcp4.Packet) {
+	if len(c.requested) > 0 {
+		packet.Options.Add(dhcp4.OptionParameterRequestList, c.requested)
+	}
+	if c.hostname != "" {
+		packet.Options.Add(dhcp4.OptionHostName, dhcp4opts.String(c.hostname))
+	}
+	if c.mudURL != "" {
+		packet.Options.Add(dhcp4.OptionMUDURL, c.mudURL)
+	}
+	if len(c.userClass) > 0 {
+		packet.Options.Add(dhcp4.OptionUserClass, c.userClass)
+	}
+	if c.subnetSelection != nil {
+		packet.Options.Add(dhcp4.OptionSubnetSelection, dhcp4opts.IP(c.subnetSelection))
+	}
+	var agentInfo dhcp4opts.RelayAgentInformation
+	if c.linkSelection != nil {
+		agentInfo = append(agentInfo, dhcp4opts.SubOption{Code: dhcp4opts.AgentLinkSelection, Data: c.linkSelection})
+	}
+	if c.port != 0 && c.gatewayIP != nil {
+		agentInfo = append(agentInfo, dhcp4opts.SubOption{Code: dhcp4opts.AgentRelaySourcePort})
+	}
+	if len(agentInfo) > 0 {
+		packet.Options.Add(dhcp4.OptionRelayAgentInformation, agentInfo)
+	}
+}
+
+// setRelay fills in the relay agent fields of packet if the client poses as
+// a relay agent.
+func (c *Client) setRelay(packet *dhcp4.Packet) {
+	if c.gatewayIP != nil {
+		packet.GIAddr = c.gatewayIP
+		packet.Hops = 1
+	}
+}
+
 // RequestPacket returns a valid DHCPRequest packet for the given offer.
 //
 // TODO: Look at RFC and confirm.
 func (c *Client) RequestPacket(offer *dhcp4.Packet) *dhcp4.Packet {
 	packet := dhcp4.NewPacket(dhcp4.BootRequest)
 
-	packet.CHAddr = c.iface.Attrs().HardwareAddr
+	c.setHardwareAddr(packet)
 	packet.TransactionID = offer.TransactionID
 	packet.CIAddr = offer.CIAddr
 	packet.SIAddr = offer.SIAddr
-	packet.Broadcast = true
+	packet.SetBroadcast(c.broadcastFlag())
+	c.setRelay(packet)
 
 	packet.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPRequest)
-	packet.Options.Add(dhcp4.OptionMaximumDHCPMessageSize, dhcp4opts.Uint16(maxMessageSize))
+	packet.Options.Add(dhcp4.OptionMaximumDHCPMessageSize, dhcp4opts.Uint16(c.maxMessageSize))
 	// Request the offered IP address.
 	packet.Options.Add(dhcp4.OptionRequestedIPAddress, dhcp4opts.IP(offer.YIAddr))
+	c.addClientOptions(packet)
 
 	sid := dhcp4opts.GetServerIdentifier(offer.Options)
 	if sid != nil {
@@ -217,20 +1002,20 @@ func (c *Client) RequestPacket(offer *dhcp4.Packet) *dhcp4.Packet {
 
 // ClientPacket is a DHCP packet and the interface it corresponds to.
 type ClientPacket struct {
-	Interface netlink.Link
+	Interface *Interface
 	Packet    *dhcp4.Packet
 }
 
 // ClientError is an error that occured on the associated interface.
 type ClientError struct {
-	Interface netlink.Link
+	Interface *Interface
 	Err       error
 }
 
 // Error implements error.
 func (ce *ClientError) Error() string {
 	if ce.Interface != nil {
-		return fmt.Sprintf("error on %q: %v", ce.Interface.Attrs().Name, ce.Err)
+		return fmt.Sprintf("error on %q: %v", ce.Interface.Name, ce.Err)
 	}
 	return fmt.Sprintf("error without interface: %v", ce.Err)
 }
@@ -250,9 +1035,12 @@ func (c *Client) newClientErr(err error) *ClientError {
 // returned.
 //
 // Callers must cancel ctx when they have received the packet they are looking
-// for. Otherwise, the spawned goroutine will keep reading until it times out.
-// More importantly, if you send another packet, the spawned goroutine may read
-// the response faster than the one launched for the other packet.
+// for. Otherwise, the spawned goroutine will keep waiting for responses until
+// it times out.
+//
+// Responses are matched to requests by transaction ID, so several exchanges
+// may be in progress on the same Client at once as long as their transaction
+// IDs differ.
 //
 // Callers sending a packet on one interface should use this. Callers intending
 // to send packets on many interface at the same time should look at using
@@ -278,17 +1066,20 @@ func (c *Client) newClientErr(err error) *ClientError {
 //     }
 //     return nil, fmt.Errorf("got no valid responses")
 //   }
-//
-// TODO(hugelgupf): since the client only has one connection, maybe it should
-// just have one dedicated goroutine for reading from the UDP socket, and use a
-// request and response queue.
 func (c *Client) SimpleSendAndRead(ctx context.Context, dest *net.UDPAddr, p *dhcp4.Packet) (*sync.WaitGroup, <-chan *ClientPacket, <-chan *ClientError) {
+	return c.simpleSendAndRead(ctx, []*net.UDPAddr{dest}, p)
+}
+
+// simpleSendAndRead is like SimpleSendAndRead, but sends p to each of dests.
+func (c *Client) simpleSendAndRead(ctx context.Context, dests []*net.UDPAddr, p *dhcp4.Packet) (*sync.WaitGroup, <-chan *ClientPacket, <-chan *ClientError) {
 	out := make(chan *ClientPacket, 10)
 	errOut := make(chan *ClientError, 1)
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
-		c.SendAndRead(ctx, dest, p, out, errOut)
+		if err := c.sendAndRead(ctx, dests, p, out); err != nil {
+			errOut <- err
+		}
 		close(out)
 		close(errOut)
 		wg.Done()
@@ -296,8 +1087,8 @@ func (c *Client) SimpleSendAndRead(ctx context.Context, dest *net.UDPAddr, p *dh
 	return &wg, out, errOut
 }
 
-// SendAndRead sends the given packet `p` to `dest` and reads responses on the
-// UDP connection. Any valid DHCP reply packet is sent to `out`.
+// SendAndRead sends the given packet `p` to `dest` and waits for responses
+// with the same transaction ID. Any valid DHCP reply packet is sent to `out`.
 //
 // SendAndRead blocks reading response packets until either:
 // - `ctx` is canceled; or
@@ -313,26 +1104,47 @@ func (c *Client) SendAndRead(ctx context.Context, dest *net.UDPAddr, p *dhcp4.Pa
 	// - we send at most one error on errCh; and
 	// - we don't forget to send err on errCh in the many return statements
 	//   of sendAndRead.
-	if err := c.sendAndRead(ctx, dest, p, out); err != nil {
+	if err := c.sendAndRead(ctx, []*net.UDPAddr{dest}, p, out); err != nil {
 		errCh <- err
 	}
 }
 
-func (c *Client) sendAndRead(ctx context.Context, dest *net.UDPAddr, p *dhcp4.Packet, out chan<- *ClientPacket) *ClientError {
+// sendAndRead sends p to each of dests and waits for responses, as
+// SendAndRead does.
+func (c *Client) sendAndRead(ctx context.Context, dests []*net.UDPAddr, p *dhcp4.Packet, out chan<- *ClientPacket) *ClientError {
 	pkt, err := p.MarshalBinary()
 	if err != nil {
 		return c.newClientErr(err)
 	}
 
-	return c.newClientErr(c.retryFn(func() error {
-		if _, err := c.conn.WriteTo(pkt, dest); err != nil {
-			return fmt.Errorf("error writing packet to connection: %v", err)
+	in, err := c.dispatcher.register(p.TransactionID)
+	if err != nil {
+		return c.newClientErr(err)
+	}
+	defer c.dispatcher.unregister(p.TransactionID)
+
+	mt := messageType(p)
+	seen := make(map[responseKey]struct{})
+	var attempts int
+	err = c.retryFn(ctx, func() error {
+		if attempts > 0 {
+			c.metrics.Retransmission(mt)
+		}
+		wait := c.retransmitTimeout(attempts)
+		attempts++
+
+		for _, dest := range dests {
+			if _, err := c.conn.WriteTo(pkt, dest); err != nil {
+				return fmt.Errorf("error writing packet to connection: %v", err)
+			}
+			c.metrics.PacketSent(mt)
 		}
 
 		var numPackets int
-		timeoutCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		timeoutCtx, cancel := context.WithTimeout(ctx, wait)
 		defer cancel()
 		for {
+			var pkt *dhcp4.Packet
 			select {
 			case <-timeoutCtx.Done():
 				if numPackets > 0 {
@@ -341,40 +1153,22 @@ func (c *Client) sendAndRead(ctx context.Context, dest *net.UDPAddr, p *dhcp4.Pa
 
 				// No packets received. Sadness.
 				return timeoutCtx.Err()
-			default:
-			}
-
-			// Since a context can be canceled not just because of
-			// a deadline, we must check the context every once in
-			// a while. Use what is (hopefully) a small part of the
-			// context deadline rather than the context's deadline.
-			c.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
-
-			// TODO: Clients can send a "max packet size" option in
-			// their packets, IIRC. Choose a reasonable size and
-			// set it.
-			b := make([]byte, 1500)
-			n, _, err := c.conn.ReadFrom(b)
-			if oerr, ok := err.(net.Error); ok && oerr.Timeout() {
-				// Continue to check ctx.Done() above and
-				// return the appropriate error.
-				continue
-			} else if err != nil {
-				return fmt.Errorf("error reading from UDP connection: %v", err)
-			}
 
-			pkt := &dhcp4.Packet{}
-			if err := pkt.UnmarshalBinary(b[:n]); err != nil {
-				// Not a valid DHCP reply; keep listening.
-				continue
-			}
+			case <-c.dispatcher.dead:
+				return c.dispatcher.err
 
-			if pkt.TransactionID != p.TransactionID {
-				// Not the right response packet.
-				continue
+			case pkt = <-in:
 			}
 
 			numPackets++
+			c.metrics.PacketReceived(messageType(pkt))
+
+			if key, ok := keyOf(pkt); ok && !c.noDedup {
+				if _, dup := seen[key]; dup {
+					continue
+				}
+				seen[key] = struct{}{}
+			}
 
 			clientPkt := &ClientPacket{
 				Packet:    pkt,
@@ -389,20 +1183,48 @@ func (c *Client) sendAndRead(ctx context.Context, dest *net.UDPAddr, p *dhcp4.Pa
 			}
 
 			// We deliberately only check the parent context here.
-			// c.timeout should only apply to reading from the
-			// conn, not sending on out.
+			// The retransmission timeout should only apply to
+			// waiting for responses, not sending on out.
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
 			case out <- clientPkt:
 			}
 		}
-	}))
+	})
+	if err == context.DeadlineExceeded {
+		c.metrics.Timeout(mt)
+	}
+	return c.newClientErr(err)
+}
+
+// responseKey identifies responses that are duplicates of each other.
+type responseKey struct {
+	serverID [4]byte
+	yiaddr   [4]byte
+	xid      [4]byte
 }
 
-func (c *Client) retryFn(fn func() error) error {
+// keyOf returns the key identifying duplicates of p. Responses without a
+// server identifier cannot be told apart from other servers' responses, so
+// they have no key.
+func keyOf(p *dhcp4.Packet) (responseKey, bool) {
+	sid := dhcp4opts.GetServerIdentifier(p.Options)
+	if sid == nil {
+		return responseKey{}, false
+	}
+	k := responseKey{xid: p.TransactionID}
+	copy(k.serverID[:], sid)
+	copy(k.yiaddr[:], p.YIAddr.To4())
+	return k, true
+}
+
+// retryFn calls fn until it succeeds, fails with an error other than a
+// timeout, the retries run out, or ctx is done.
+func (c *Client) retryFn(ctx context.Context, fn func() error) error {
 	// Each retry takes the amount of timeout at worst.
-	for i := 0; i < c.retry || c.retry < 0; i++ {
+	// Under a retry budget, only ctx limits retransmissions.
+	for i := 0; (i < c.retry || c.retry < 0 || c.backoff) && ctx.Err() == nil; i++ {
 		switch err := fn(); err {
 		case nil:
 			// Got it!
@@ -417,5 +1239,8 @@ func (c *Client) retryFn(fn func() error) error {
 		}
 	}
 
+	if ctx.Err() == context.Canceled {
+		return ctx.Err()
+	}
 	return context.DeadlineExceeded
 }
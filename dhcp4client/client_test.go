@@ -9,11 +9,16 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"reflect"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
 )
 
 type timeoutErr struct{}
@@ -86,28 +91,29 @@ func (m *mockUDPConn) Close() error {
 }
 
 // ReadFrom is a mock for PacketConn.ReadFromUDP.
+//
+// Once in is closed, ReadFrom behaves like an idle network and only returns
+// timeouts.
 func (m *mockUDPConn) ReadFrom(b []byte) (int, net.Addr, error) {
 	// Make sure we don't have data waiting.
 	select {
 	case p, ok := <-m.in:
-		if !ok {
-			// Connection was closed.
-			return 0, nil, nil
+		if ok {
+			return copy(b, p.payload), p.source, nil
 		}
-		return copy(b, p.payload), p.source, nil
 	default:
 	}
 
 	select {
 	case p, ok := <-m.in:
-		if !ok {
-			return 0, nil, nil
+		if ok {
+			return copy(b, p.payload), p.source, nil
 		}
-		return copy(b, p.payload), p.source, nil
+		<-m.inTimer.C
 	case <-m.inTimer.C:
-		// This net.OpError will return true for Timeout().
-		return 0, nil, &net.OpError{Err: timeoutErr{}}
 	}
+	// This net.OpError will return true for Timeout().
+	return 0, nil, &net.OpError{Err: timeoutErr{}}
 }
 
 // WriteTo is a mock for PacketConn.WriteTo.
@@ -300,7 +306,7 @@ func TestSimpleSendAndRead(t *testing.T) {
 		defer cancel()
 
 		mc, _ := serveAndClient(ctx, [][]*dhcp4.Packet{tt.server})
-		defer mc.conn.Close()
+		defer mc.Close()
 
 		wg, out, errCh := mc.SimpleSendAndRead(ctx, DefaultServers, tt.send)
 
@@ -340,8 +346,8 @@ func TestSimpleSendAndReadHandleCancel(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	mc, udpConn := serveAndClient(ctx, [][]*dhcp4.Packet{responses})
-	defer mc.conn.Close()
+	mc, _ := serveAndClient(ctx, [][]*dhcp4.Packet{responses})
+	defer mc.Close()
 
 	wg, out, errCh := mc.SimpleSendAndRead(ctx, DefaultServers, pkt)
 
@@ -357,17 +363,56 @@ func TestSimpleSendAndReadHandleCancel(t *testing.T) {
 	if err, ok := <-errCh; ok {
 		t.Errorf("got %v, want nil error", err)
 	}
+	// Responses already queued on out when ctx was canceled are still
+	// delivered; the rest are dropped by the dispatcher.
+	if counter < 2 || counter > len(responses) {
+		t.Errorf("SimpleSendAndRead delivered %d packets, want between 2 and %d", counter, len(responses))
+	}
+}
 
-	// Make sure that two packets are still in the queue to be read.
-	for packet := range udpConn.in {
-		bin, err := responses[counter].MarshalBinary()
-		if err != nil {
-			panic(err)
+func TestConcurrentSendAndRead(t *testing.T) {
+	first := newPacket(dhcp4.BootRequest, [4]byte{0x33, 0x33, 0x33, 0x33})
+	second := newPacket(dhcp4.BootRequest, [4]byte{0x44, 0x44, 0x44, 0x44})
+
+	// Once both requests have arrived, the server answers both in
+	// reverse order.
+	responses := [][]*dhcp4.Packet{
+		{},
+		{
+			newPacket(dhcp4.BootReply, second.TransactionID),
+			newPacket(dhcp4.BootReply, first.TransactionID),
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	mc, _ := serveAndClient(ctx, responses)
+	defer mc.Close()
+
+	wg1, out1, errCh1 := mc.SimpleSendAndRead(ctx, DefaultServers, first)
+	wg2, out2, errCh2 := mc.SimpleSendAndRead(ctx, DefaultServers, second)
+
+	for _, tt := range []struct {
+		sent  *dhcp4.Packet
+		wg    *sync.WaitGroup
+		out   <-chan *ClientPacket
+		errCh <-chan *ClientError
+	}{
+		{first, wg1, out1, errCh1},
+		{second, wg2, out2, errCh2},
+	} {
+		var rcvd []*dhcp4.Packet
+		for packet := range tt.out {
+			rcvd = append(rcvd, packet.Packet)
 		}
-		if bytes.Compare(packet.payload, bin) != 0 {
-			t.Errorf("SimpleSendAndRead read more packets than expected!")
+		tt.wg.Wait()
+		if err, ok := <-tt.errCh; ok {
+			t.Errorf("SimpleSendAndRead(%v): got %v, want nil error", tt.sent, err)
+		}
+		if err := pktsExpected(rcvd, []*dhcp4.Packet{newPacket(dhcp4.BootReply, tt.sent.TransactionID)}); err != nil {
+			t.Errorf("SimpleSendAndRead(%v): %v", tt.sent, err)
 		}
-		counter++
 	}
 }
 
@@ -383,7 +428,7 @@ func TestSimpleSendAndReadDiscardGarbage(t *testing.T) {
 	defer cancel()
 
 	mc, udpConn := serveAndClient(ctx, [][]*dhcp4.Packet{responses})
-	defer mc.conn.Close()
+	defer mc.Close()
 
 	udpConn.in <- udpPacket{
 		payload: []byte{0x01}, // Too short for valid DHCPv4 packet.
@@ -415,8 +460,10 @@ func TestSimpleSendAndReadDiscardGarbageTimeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	mc, udpConn := serveAndClient(ctx, nil)
-	defer mc.conn.Close()
+	// The server must not close the client's queue before the garbage
+	// is in it, so it waits for the request without answering.
+	mc, udpConn := serveAndClient(ctx, [][]*dhcp4.Packet{{}})
+	defer mc.Close()
 
 	udpConn.in <- udpPacket{
 		payload: []byte{0x01}, // Too short for valid DHCPv6 packet.
@@ -470,7 +517,7 @@ func TestMultipleSendAndReadOne(t *testing.T) {
 		defer cancel()
 
 		mc, _ := serveAndClient(ctx, tt.server)
-		defer mc.conn.Close()
+		defer mc.Close()
 
 		for i, send := range tt.send {
 			rcvd, err := mc.SendAndReadOne(send)
@@ -484,3 +531,798 @@ func TestMultipleSendAndReadOne(t *testing.T) {
 		}
 	}
 }
+
+func TestWithMaxMessageSize(t *testing.T) {
+	for _, tt := range []struct {
+		size    int
+		wantErr bool
+	}{
+		{size: 575, wantErr: true},
+		{size: 576},
+		{size: 9000},
+		{size: 65535},
+		{size: 65536, wantErr: true},
+	} {
+		in := make(chan udpPacket)
+		out := make(chan udpPacket)
+		mc, err := New(&Interface{Name: "dummy0"}, WithConn(newMockUDPConn(in, out)), WithMaxMessageSize(tt.size))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(WithMaxMessageSize(%d)) = %v, want error %t", tt.size, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+
+		if got := mc.dispatcher.bufLen; got != tt.size {
+			t.Errorf("WithMaxMessageSize(%d): receive buffer is %d bytes", tt.size, got)
+		}
+		got, err := dhcp4opts.GetMaximumDHCPMessageSize(mc.DiscoverPacket().Options)
+		if err != nil || int(got) != tt.size {
+			t.Errorf("WithMaxMessageSize(%d): advertised %d, %v", tt.size, got, err)
+		}
+		mc.Close()
+	}
+}
+
+func TestWithLocalAddr(t *testing.T) {
+	if _, err := New(&Interface{Name: "dummy0"}, WithConn(newMockUDPConn(nil, nil)), WithLocalAddr(net.ParseIP("fe80::1"))); err == nil {
+		t.Errorf("New(WithLocalAddr(fe80::1)) = nil error, want error")
+	}
+
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	local := net.IPv4(192, 168, 0, 100)
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithLocalAddr(local), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if _, err := mc.Renew(ack); err != nil {
+		t.Fatalf("Renew() = %v", err)
+	}
+
+	got := srv.Received()
+	if len(got) != 3 {
+		t.Fatalf("server received %d packets, want 3", len(got))
+	}
+	// Only the renewal comes from the client's address.
+	for i, want := range []net.IP{net.IPv4zero, net.IPv4zero, local} {
+		if !got[i].CIAddr.Equal(want) {
+			t.Errorf("packet %d: ciaddr = %v, want %v", i, got[i].CIAddr, want)
+		}
+	}
+}
+
+func TestRenewCIAddr(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if _, err := mc.Renew(ack); err != nil {
+		t.Fatalf("Renew() = %v", err)
+	}
+
+	// Without WithLocalAddr, the renewal comes from the leased address.
+	got := srv.Received()
+	if len(got) != 3 {
+		t.Fatalf("server received %d packets, want 3", len(got))
+	}
+	if !got[2].CIAddr.Equal(ack.YIAddr) {
+		t.Errorf("renewal ciaddr = %v, want %v", got[2].CIAddr, ack.YIAddr)
+	}
+}
+
+func TestWithGatewayIP(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	gw := net.IPv4(10, 1, 0, 1)
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithGatewayIP(gw), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if !ack.GIAddr.Equal(gw) {
+		t.Errorf("reply giaddr = %v, want %v", ack.GIAddr, gw)
+	}
+	for i, p := range srv.Received() {
+		if !p.GIAddr.Equal(gw) || p.Hops != 1 {
+			t.Errorf("packet %d: giaddr = %v, hops = %d, want %v and 1", i, p.GIAddr, p.Hops, gw)
+		}
+	}
+}
+
+func TestWithClientPort(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		opts     []ClientOpt
+		wantPort bool
+	}{
+		{
+			desc: "client",
+		},
+		{
+			desc:     "relay agent",
+			opts:     []ClientOpt{WithGatewayIP(net.IPv4(10, 1, 0, 1))},
+			wantPort: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			srv, conn := dhcp4test.Start(dhcp4test.Config{})
+			defer srv.Close()
+			tr := &filterTransport{PacketConn: conn}
+
+			opts := append([]ClientOpt{WithConn(tr), WithClientPort(6767), WithTimeout(time.Second)}, tt.opts...)
+			mc, err := New(&Interface{Name: "dummy0"}, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mc.Close()
+
+			if _, err := mc.Request(); err != nil {
+				t.Fatalf("Request() = %v", err)
+			}
+			if tr.port != 6767 {
+				t.Errorf("filtered port %d, want 6767", tr.port)
+			}
+			for i, p := range srv.Received() {
+				var got bool
+				for _, sub := range dhcp4opts.GetRelayAgentInformation(p.Options) {
+					got = got || sub.Code == dhcp4opts.AgentRelaySourcePort
+				}
+				if got != tt.wantPort {
+					t.Errorf("packet %d carries relay source port = %t, want %t", i, got, tt.wantPort)
+				}
+			}
+		})
+	}
+
+	if _, err := New(&Interface{Name: "dummy0"}, WithClientPort(70000)); err == nil {
+		t.Errorf("WithClientPort(70000) = nil error, want error")
+	}
+}
+
+func TestWithServerAddrs(t *testing.T) {
+	n := dhcp4test.NewNetwork(dhcp4test.NetworkConfig{})
+	servers := make(map[string]*dhcp4test.Server)
+	for _, addr := range []*net.UDPAddr{
+		{IP: net.IP{10, 0, 0, 1}, Port: ServerPort},
+		{IP: net.IP{10, 0, 0, 2}, Port: 6767},
+		{IP: net.IP{10, 0, 0, 3}, Port: ServerPort},
+	} {
+		srv := dhcp4test.NewServer(n.Listen(addr), dhcp4test.Config{ServerID: addr.IP})
+		defer srv.Close()
+		servers[addr.IP.String()] = srv
+	}
+
+	targets := []*net.UDPAddr{
+		{IP: net.IP{10, 0, 0, 1}, Port: ServerPort},
+		{IP: net.IP{10, 0, 0, 2}, Port: 6767},
+	}
+	mc, err := New(&Interface{Name: "dummy0"},
+		WithConn(n.Listen(&net.UDPAddr{IP: net.IPv4zero, Port: ClientPort})),
+		WithServerAddrs(targets),
+		WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	results, err := mc.Probe(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Probe() = %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Probe() got %d offers, want one from each of the 2 targeted servers", len(results))
+	}
+	if !mc.unicastServers() {
+		t.Error("unicastServers() = false, want a UDP socket for unicast servers")
+	}
+	for sid, want := range map[string]int{"10.0.0.1": 1, "10.0.0.2": 1, "10.0.0.3": 0} {
+		if got := len(servers[sid].Received()); got != want {
+			t.Errorf("server %s received %d packets, want %d", sid, got, want)
+		}
+	}
+
+	for _, addrs := range [][]*net.UDPAddr{
+		nil,
+		{{IP: net.ParseIP("fe80::1"), Port: ServerPort}},
+		{{IP: net.IP{10, 0, 0, 1}}},
+	} {
+		if _, err := New(&Interface{Name: "dummy0"}, WithConn(n.Listen(&net.UDPAddr{Port: ClientPort})), WithServerAddrs(addrs)); err == nil {
+			t.Errorf("WithServerAddrs(%v) succeeded", addrs)
+		}
+	}
+
+	// Broadcasting to another port still works on the raw socket.
+	bc, err := New(&Interface{Name: "dummy0"}, WithConn(n.Listen(&net.UDPAddr{Port: ClientPort})), WithServerAddrs([]*net.UDPAddr{{IP: net.IPv4bcast, Port: 6767}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	if bc.unicastServers() {
+		t.Error("unicastServers() = true for a broadcast address")
+	}
+}
+
+func TestWithUserClass(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{
+		Policy: dhcp4.When(dhcp4.UserClass("kiosk"),
+			dhcp4.SetOption(dhcp4.OptionDomainName, []byte("kiosk.example"))),
+	})
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithUserClass("lab", "kiosk"), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if got, want := dhcp4opts.GetDomainName(ack.Options), "kiosk.example"; got != want {
+		t.Errorf("domain name = %q, want %q", got, want)
+	}
+	want := dhcp4opts.UserClass{"lab", "kiosk"}
+	for i, p := range srv.Received() {
+		if got := dhcp4opts.GetUserClass(p.Options); !reflect.DeepEqual(got, want) {
+			t.Errorf("packet %d: user class = %q, want %q", i, got, want)
+		}
+	}
+
+	if _, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithUserClass("")); err == nil {
+		t.Errorf("WithUserClass(\"\") = nil error, want error")
+	}
+}
+
+func TestWithSubnetSelection(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	subnet := net.IPv4(10, 2, 0, 0)
+	link := net.IPv4(10, 3, 0, 0)
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn),
+		WithSubnetSelection(subnet), WithLinkSelection(link), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	for i, p := range srv.Received() {
+		if got := net.IP(dhcp4opts.GetSubnetSelection(p.Options)); !got.Equal(subnet) {
+			t.Errorf("packet %d: subnet selection = %v, want %v", i, got, subnet)
+		}
+		if got := net.IP(dhcp4opts.GetLinkSelection(p.Options)); !got.Equal(link) {
+			t.Errorf("packet %d: link selection = %v, want %v", i, got, link)
+		}
+	}
+}
+
+func TestWithXIDGenerator(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	xids := [][4]byte{{1, 1, 1, 1}, {1, 1, 1, 1}, {2, 2, 2, 2}}
+	var mu sync.Mutex
+	gen := func() [4]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		xid := xids[0]
+		xids = xids[1:]
+		return xid
+	}
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithXIDGenerator(gen), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.DiscoverOffer(); err != nil {
+		t.Fatalf("DiscoverOffer() = %v", err)
+	}
+	if got, want := srv.Received()[0].TransactionID, [4]byte{1, 1, 1, 1}; got != want {
+		t.Errorf("xid = %v, want %v", got, want)
+	}
+
+	// An exchange with xid 1.1.1.1 is in flight; the next one must not
+	// reuse it.
+	if _, err := mc.dispatcher.register([4]byte{1, 1, 1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mc.DiscoverPacket().TransactionID, [4]byte{2, 2, 2, 2}; got != want {
+		t.Errorf("xid = %v, want %v", got, want)
+	}
+}
+
+func TestWithBroadcastFlag(t *testing.T) {
+	// The server only answers requests with the broadcast flag clear.
+	unicastOnly := func(s *dhcp4test.Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		if req.Broadcast() {
+			return nil
+		}
+		return dhcp4test.Auto()(s, req)
+	}
+	srv, conn := dhcp4test.Start(dhcp4test.Config{}, unicastOnly, unicastOnly, unicastOnly)
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithBroadcastFlag(true), WithTimeout(50*time.Millisecond), WithRetry(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	var got []bool
+	for _, p := range srv.Received() {
+		got = append(got, p.Broadcast())
+	}
+	// The broadcast Discover goes unanswered; the unicast one is
+	// answered, and the Request keeps the working setting.
+	if want := []bool{true, false, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("broadcast flags = %v, want %v", got, want)
+	}
+}
+
+func TestWithExchangeTimeout(t *testing.T) {
+	drops := make([]dhcp4test.Action, 100)
+	for i := range drops {
+		drops[i] = dhcp4test.Drop()
+	}
+	srv, conn := dhcp4test.Start(dhcp4test.Config{}, drops...)
+	defer srv.Close()
+
+	// Without the exchange timeout, retransmissions never stop.
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(20*time.Millisecond), WithRetry(-1), WithExchangeTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	start := time.Now()
+	_, err = mc.SendAndReadOne(mc.DiscoverPacket())
+	if !isTimeout(err) {
+		t.Errorf("SendAndReadOne() = %v, want a timeout", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("SendAndReadOne() took %v, want about 100ms", d)
+	}
+	if n := len(srv.Received()); n < 2 {
+		t.Errorf("server received %d requests, want retransmissions", n)
+	}
+
+	if _, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithExchangeTimeout(0)); err == nil {
+		t.Error("New(WithExchangeTimeout(0)) succeeded")
+	}
+	for _, opts := range [][]ClientOpt{
+		{WithExchangeTimeout(time.Second), WithRetryBudget(time.Second)},
+		{WithRetryBudget(time.Second), WithExchangeTimeout(time.Second)},
+	} {
+		if _, err := New(&Interface{Name: "dummy0"}, append(opts, WithConn(conn))...); err != errExchangeTimeoutAndBudget {
+			t.Errorf("New(WithExchangeTimeout, WithRetryBudget) = %v, want %v", err, errExchangeTimeoutAndBudget)
+		}
+	}
+}
+
+func TestWithExchangeTimeoutRequest(t *testing.T) {
+	drops := make([]dhcp4test.Action, 100)
+	for i := range drops {
+		drops[i] = dhcp4test.Drop()
+	}
+	srv, conn := dhcp4test.Start(dhcp4test.Config{}, drops...)
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(20*time.Millisecond), WithRetry(-1), WithExchangeTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	// Both Discovers share the bound, rather than taking 200ms each.
+	start := time.Now()
+	if _, err := mc.Request(); !isTimeout(err) {
+		t.Errorf("Request() = %v, want a timeout", err)
+	}
+	if d := time.Since(start); d > 350*time.Millisecond {
+		t.Errorf("Request() took %v, want about 200ms", d)
+	}
+}
+
+func TestWithDedup(t *testing.T) {
+	for _, tt := range []struct {
+		dedup bool
+		want  int
+	}{
+		{dedup: true, want: 1},
+		{dedup: false, want: 3},
+	} {
+		srv, conn := dhcp4test.Start(dhcp4test.Config{}, dhcp4test.Repeat(3, dhcp4test.Offer()))
+		defer srv.Close()
+
+		mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithDedup(tt.dedup), WithTimeout(100*time.Millisecond), WithRetry(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer mc.Close()
+
+		wg, out, _ := mc.SimpleSendAndRead(context.Background(), DefaultServers, mc.DiscoverPacket())
+		var got int
+		for range out {
+			got++
+		}
+		wg.Wait()
+		if got != tt.want {
+			t.Errorf("WithDedup(%t): got %d offers, want %d", tt.dedup, got, tt.want)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	lease := dhcp4.NewPacket(dhcp4.BootReply)
+	lease.YIAddr = net.IPv4(192, 168, 0, 100)
+	lease.Options.Add(dhcp4.OptionServerIdentifier, dhcp4opts.IP{192, 168, 0, 2})
+
+	ack, err := mc.Rebind(lease)
+	if err != nil {
+		t.Fatalf("Rebind() = %v", err)
+	}
+	if mt := messageType(ack); mt != dhcp4opts.DHCPACK {
+		t.Errorf("message type = %d, want ACK", mt)
+	}
+
+	req := srv.Received()[0]
+	if mt := messageType(req); mt != dhcp4opts.DHCPRequest {
+		t.Errorf("request message type = %d, want Request", mt)
+	}
+	if !req.CIAddr.Equal(lease.YIAddr) {
+		t.Errorf("ciaddr = %v, want %v", req.CIAddr, lease.YIAddr)
+	}
+	for _, code := range []dhcp4.OptionCode{dhcp4.OptionServerIdentifier, dhcp4.OptionRequestedIPAddress} {
+		if req.Options.Has(code) {
+			t.Errorf("rebinding request carries option %d", code)
+		}
+	}
+}
+
+func TestRenew(t *testing.T) {
+	n := dhcp4test.NewNetwork(dhcp4test.NetworkConfig{})
+	servers := make(map[string]*dhcp4test.Server)
+	for _, ip := range []net.IP{{10, 0, 0, 1}, {10, 0, 0, 2}} {
+		srv := dhcp4test.NewServer(n.Listen(&net.UDPAddr{IP: ip, Port: ServerPort}), dhcp4test.Config{ServerID: ip})
+		defer srv.Close()
+		servers[ip.String()] = srv
+	}
+
+	mc, err := New(&Interface{Name: "dummy0"},
+		WithConn(n.Listen(&net.UDPAddr{IP: net.IPv4zero, Port: ClientPort})),
+		WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	lease := dhcp4.NewPacket(dhcp4.BootReply)
+	lease.YIAddr = net.IPv4(10, 0, 0, 100)
+	lease.Options.Add(dhcp4.OptionServerIdentifier, dhcp4opts.IP{10, 0, 0, 2})
+
+	ack, err := mc.Renew(lease)
+	if err != nil {
+		t.Fatalf("Renew() = %v", err)
+	}
+	if mt := messageType(ack); mt != dhcp4opts.DHCPACK {
+		t.Errorf("message type = %d, want ACK", mt)
+	}
+
+	// The renewal is unicast to the server that granted the lease.
+	if got := len(servers["10.0.0.1"].Received()); got != 0 {
+		t.Errorf("other server received %d packets, want 0", got)
+	}
+	got := servers["10.0.0.2"].Received()
+	if len(got) != 1 {
+		t.Fatalf("leasing server received %d packets, want 1", len(got))
+	}
+	req := got[0]
+	if mt := messageType(req); mt != dhcp4opts.DHCPRequest {
+		t.Errorf("request message type = %d, want Request", mt)
+	}
+	if !req.CIAddr.Equal(lease.YIAddr) {
+		t.Errorf("ciaddr = %v, want %v", req.CIAddr, lease.YIAddr)
+	}
+	for _, code := range []dhcp4.OptionCode{dhcp4.OptionServerIdentifier, dhcp4.OptionRequestedIPAddress} {
+		if req.Options.Has(code) {
+			t.Errorf("renewing request carries option %d", code)
+		}
+	}
+}
+
+func TestWithMUDURL(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	const u = "https://things.example.com/lightbulb2000"
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithMUDURL(u), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	for i, p := range srv.Received() {
+		if got := dhcp4opts.GetMUDURL(p.Options); got != u {
+			t.Errorf("packet %d: MUD URL = %q, want %q", i, got, u)
+		}
+	}
+
+	for _, bad := range []string{"http://things.example.com/x", "/lightbulb2000", "https://" + strings.Repeat("a", 250)} {
+		if _, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithMUDURL(bad)); err == nil {
+			t.Errorf("WithMUDURL(%q) = nil error, want error", bad)
+		}
+	}
+}
+
+func TestWithCaptivePortal(t *testing.T) {
+	const portal = "https://portal.example.com/api"
+	srv, conn := dhcp4test.Start(dhcp4test.Config{
+		Policy: dhcp4.When(dhcp4.Requests(dhcp4.OptionCaptivePortal),
+			dhcp4.SetOption(dhcp4.OptionCaptivePortal, []byte(portal))),
+	})
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn),
+		WithRequestedOptions(dhcp4.OptionRouters, dhcp4.OptionDomainNameServers), WithCaptivePortal(true), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if got := dhcp4opts.GetCaptivePortal(ack.Options); got != portal {
+		t.Errorf("captive portal = %q, want %q", got, portal)
+	}
+	want := dhcp4opts.OptionCodes{dhcp4.OptionRouters, dhcp4.OptionDomainNameServers, dhcp4.OptionCaptivePortal}
+	for i, p := range srv.Received() {
+		if got := dhcp4opts.GetParameterRequestList(p.Options); !reflect.DeepEqual(got, want) {
+			t.Errorf("packet %d: parameter request list = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestInform(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{
+		Options: dhcp4.NewOptions(dhcp4.Option{Code: dhcp4.OptionDomainNameServers, Value: []byte{10, 0, 0, 53}}),
+	})
+	defer srv.Close()
+
+	if _, err := (&Client{}).Inform(); err == nil {
+		t.Errorf("Inform() without local address = nil error, want error")
+	}
+
+	laddr := net.IPv4(10, 0, 0, 7)
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithLocalAddr(laddr), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Inform()
+	if err != nil {
+		t.Fatalf("Inform() = %v", err)
+	}
+	if dns := dhcp4opts.GetDomainNameServers(ack.Options); len(dns) != 1 || !dns[0].Equal(net.IPv4(10, 0, 0, 53)) {
+		t.Errorf("DNS servers = %v, want [10.0.0.53]", dns)
+	}
+	req := srv.Received()[0]
+	if mt := messageType(req); mt != dhcp4opts.DHCPInform || !req.CIAddr.Equal(laddr) {
+		t.Errorf("sent message type %d with ciaddr %v, want Inform with %v", mt, req.CIAddr, laddr)
+	}
+}
+
+func TestInfiniBand(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	addr := net.HardwareAddr{
+		0x80, 0x00, 0x02, 0x08, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x02, 0xc9, 0x03, 0x00, 0x01, 0x23, 0x45,
+	}
+	mc, err := New(&Interface{Name: "ib0", HardwareAddr: addr}, WithConn(conn), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	wantID := []byte{0xff, 0x00, 0x01, 0x23, 0x45, 0x00, 0x03, 0x00, 0x20, 0x00, 0x02, 0xc9, 0x03, 0x00, 0x01, 0x23, 0x45}
+	for i, p := range srv.Received() {
+		if p.HType != dhcp4.HTypeInfiniBand || len(p.CHAddr) != 0 {
+			t.Errorf("packet %d: htype %d, chaddr %v, want InfiniBand without chaddr", i, p.HType, p.CHAddr)
+		}
+		if got := p.Options.Get(dhcp4.OptionClientIdentifier); !bytes.Equal(got, wantID) {
+			t.Errorf("packet %d: client identifier = %x, want %x", i, got, wantID)
+		}
+		if err := dhcp4.ValidateRequest(p); err != nil {
+			t.Errorf("packet %d: %v", i, err)
+		}
+	}
+}
+
+func newBenchmarkClient(tb testing.TB) (*Client, func()) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(time.Second))
+	if err != nil {
+		srv.Close()
+		tb.Fatal(err)
+	}
+	return mc, func() {
+		mc.Close()
+		srv.Close()
+	}
+}
+
+// TestRequestAllocs guards against allocation regressions in a full
+// Discover-Offer-Request-Ack exchange, counting the test server's
+// allocations too. Raise the budget only with a good reason.
+func TestRequestAllocs(t *testing.T) {
+	const budget = 200
+
+	mc, done := newBenchmarkClient(t)
+	defer done()
+
+	var err error
+	allocs := testing.AllocsPerRun(20, func() {
+		if _, e := mc.Request(); e != nil {
+			err = e
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocs > budget {
+		t.Errorf("Request allocated %v times, want at most %v", allocs, budget)
+	}
+}
+
+func BenchmarkRequest(b *testing.B) {
+	mc, done := newBenchmarkClient(b)
+	defer done()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mc.Request(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRenewAsync(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{}, dhcp4test.Ack(), dhcp4test.Nak("no"))
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	lease := dhcp4.NewPacket(dhcp4.BootReply)
+	lease.YIAddr = net.IPv4(192, 168, 0, 100)
+	lease.Options.Add(dhcp4.OptionServerIdentifier, dhcp4opts.IP{192, 168, 0, 2})
+
+	var results []<-chan RenewResult
+	for i := 0; i < 10; i++ {
+		results = append(results, mc.RenewAsync(lease))
+	}
+	var acks, naks int
+	for _, ch := range results {
+		r := <-ch
+		if r.Err != nil {
+			t.Errorf("RenewAsync() = %v", r.Err)
+			continue
+		}
+		switch messageType(r.Reply) {
+		case dhcp4opts.DHCPACK:
+			acks++
+		case dhcp4opts.DHCPNAK:
+			naks++
+		}
+		if _, ok := <-ch; ok {
+			t.Errorf("RenewAsync() sent more than one result")
+		}
+	}
+	if acks != 9 || naks != 1 {
+		t.Errorf("RenewAsync() got %d ACKs and %d NAKs, want 9 and 1", acks, naks)
+	}
+}
+
+// TestConcurrentRenewInform runs renewals and informs on one Client from many
+// goroutines. Run it with -race.
+func TestConcurrentRenewInform(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	var events sync.WaitGroup
+	mc, err := New(&Interface{Name: "dummy0"},
+		WithConn(conn),
+		WithLocalAddr(net.IPv4(192, 168, 0, 100)),
+		WithTimeout(time.Second),
+		WithEventHandler(func(Event) { events.Done() }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	lease := dhcp4.NewPacket(dhcp4.BootReply)
+	lease.YIAddr = net.IPv4(192, 168, 0, 100)
+	lease.Options.Add(dhcp4.OptionServerIdentifier, dhcp4opts.IP{192, 168, 0, 2})
+
+	const goroutines, rounds = 8, 10
+	events.Add(goroutines * rounds)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				ack, err := mc.Renew(lease)
+				if err != nil {
+					t.Errorf("Renew() = %v", err)
+					events.Done()
+				} else if mt := messageType(ack); mt != dhcp4opts.DHCPACK {
+					t.Errorf("Renew() message type = %d, want ACK", mt)
+				}
+				if _, err := mc.Inform(); err != nil {
+					t.Errorf("Inform() = %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	events.Wait()
+
+	if got, want := len(srv.Received()), 2*goroutines*rounds; got < want {
+		t.Errorf("server received %d requests, want at least %d", got, want)
+	}
+}
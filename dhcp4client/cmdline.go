@@ -0,0 +1,87 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+	"strings"
+	"unicode"
+)
+
+// KernelCmdline returns the static configuration of l as the ip= parameter
+// of the Linux kernel command line, documented in
+// Documentation/admin-guide/nfs/nfsroot.rst:
+//
+//	ip=<client-ip>:<server-ip>:<gw-ip>:<netmask>:<hostname>:<device>:off:<dns0-ip>:<dns1-ip>
+//
+// The server field, which names an NFS root server, is left empty. Trailing
+// empty fields are omitted. The host name comes from the server, so it is
+// left out unless it is a valid domain name; the interface name is left out
+// if it contains a colon or white space.
+func (l *LeaseConfig) KernelCmdline() string {
+	fields := []string{
+		ipField(l.Address),
+		"",
+		ipField(l.Gateway),
+		ipField(l.Netmask),
+		hostnameField(l.Hostname),
+		textField(l.Interface),
+		"off",
+	}
+	for i := 0; i < len(l.DNS) && i < 2; i++ {
+		fields = append(fields, ipField(l.DNS[i]))
+	}
+	return "ip=" + strings.TrimRight(strings.Join(fields, ":"), ":")
+}
+
+// DracutCmdline returns the static configuration of l in the form dracut
+// understands, documented in dracut.cmdline(7): an ip= parameter followed by
+// a nameserver= parameter for each DNS server.
+//
+//	ip=<client-ip>::<gw-ip>:<netmask>:<hostname>:<interface>:none nameserver=<dns-ip>
+//
+// Host and interface names are filtered as for KernelCmdline.
+func (l *LeaseConfig) DracutCmdline() string {
+	params := []string{"ip=" + strings.Join([]string{
+		ipField(l.Address),
+		"",
+		ipField(l.Gateway),
+		ipField(l.Netmask),
+		hostnameField(l.Hostname),
+		textField(l.Interface),
+		"none",
+	}, ":")}
+	for _, dns := range l.DNS {
+		params = append(params, "nameserver="+ipField(dns))
+	}
+	return strings.Join(params, " ")
+}
+
+// ipField returns ip for a command line field, or "" if it is unset.
+func ipField(ip net.IP) string {
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+// hostnameField returns name for a command line field, or "" if it is not a
+// valid domain name. A name carrying a colon or white space would add fields
+// or parameters to the command line.
+func hostnameField(name string) string {
+	if CheckDomainName(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// textField returns s for a command line field, or "" if it contains a colon
+// or white space.
+func textField(s string) string {
+	if strings.IndexFunc(s, func(r rune) bool { return r == ':' || unicode.IsSpace(r) }) >= 0 {
+		return ""
+	}
+	return s
+}
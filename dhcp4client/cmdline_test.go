@@ -0,0 +1,80 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCmdline(t *testing.T) {
+	for _, tt := range []struct {
+		desc       string
+		lease      *LeaseConfig
+		wantKernel string
+		wantDracut string
+	}{
+		{
+			desc: "full",
+			lease: &LeaseConfig{
+				Interface: "eth0",
+				Address:   net.IP{192, 168, 0, 10},
+				Netmask:   net.IP{255, 255, 255, 0},
+				Gateway:   net.IP{192, 168, 0, 1},
+				DNS:       []net.IP{{192, 168, 0, 53}, {8, 8, 8, 8}, {8, 8, 4, 4}},
+				Hostname:  "node1",
+			},
+			wantKernel: "ip=192.168.0.10::192.168.0.1:255.255.255.0:node1:eth0:off:192.168.0.53:8.8.8.8",
+			wantDracut: "ip=192.168.0.10::192.168.0.1:255.255.255.0:node1:eth0:none nameserver=192.168.0.53 nameserver=8.8.8.8 nameserver=8.8.4.4",
+		},
+		{
+			desc: "minimal",
+			lease: &LeaseConfig{
+				Address: net.IP{10, 0, 0, 2},
+				Netmask: net.IP{255, 0, 0, 0},
+			},
+			wantKernel: "ip=10.0.0.2:::255.0.0.0:::off",
+			wantDracut: "ip=10.0.0.2:::255.0.0.0:::none",
+		},
+		{
+			desc: "hostname with parameters",
+			lease: &LeaseConfig{
+				Interface: "eth0",
+				Address:   net.IP{10, 0, 0, 2},
+				Hostname:  "h init=/bin/sh",
+			},
+			wantKernel: "ip=10.0.0.2:::::eth0:off",
+			wantDracut: "ip=10.0.0.2:::::eth0:none",
+		},
+		{
+			desc: "hostname with fields",
+			lease: &LeaseConfig{
+				Address:  net.IP{10, 0, 0, 2},
+				Hostname: "h:eth1:dhcp",
+			},
+			wantKernel: "ip=10.0.0.2::::::off",
+			wantDracut: "ip=10.0.0.2::::::none",
+		},
+		{
+			desc: "invalid interface",
+			lease: &LeaseConfig{
+				Interface: "eth0:1 rd.break",
+				Address:   net.IP{10, 0, 0, 2},
+				Hostname:  "node1",
+			},
+			wantKernel: "ip=10.0.0.2::::node1::off",
+			wantDracut: "ip=10.0.0.2::::node1::none",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := tt.lease.KernelCmdline(); got != tt.wantKernel {
+				t.Errorf("KernelCmdline() = %q, want %q", got, tt.wantKernel)
+			}
+			if got := tt.lease.DracutCmdline(); got != tt.wantDracut {
+				t.Errorf("DracutCmdline() = %q, want %q", got, tt.wantDracut)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build dragonfly || freebsd || netbsd || openbsd
+// +build dragonfly freebsd netbsd openbsd
+
+package dhcp4client
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// newDefaultConn returns the connection a Client uses when none is
+// configured.
+func newDefaultConn(iface *Interface, laddr *net.UDPAddr, unicast bool) (net.PacketConn, error) {
+	return listenIPv4UDP(iface.Name, laddr)
+}
+
+// NewIPv4UDPConn returns a UDP connection bound to the port given based on a
+// IPv4 DGRAM socket. The UDP connection allows broadcasting.
+//
+// These systems cannot bind a socket to a device. Instead, the receiving
+// interface of each packet is requested with IP_RECVIF, and packets that
+// arrived on other interfaces are discarded. Outgoing broadcasts follow the
+// routing table. The connection is an InfoConn.
+func NewIPv4UDPConn(iface string, port int) (net.PacketConn, error) {
+	return listenIPv4UDP(iface, &net.UDPAddr{Port: port})
+}
+
+// listenIPv4UDP is like NewIPv4UDPConn, but binds the socket to the address
+// laddr.
+func listenIPv4UDP(iface string, laddr *net.UDPAddr) (net.PacketConn, error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, unix.IPPROTO_UDP)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "")
+	// net.FilePacketConn dups the FD, so we have to close this in any case.
+	defer f.Close()
+
+	// Allow broadcasting.
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_BROADCAST, 1); err != nil {
+		return nil, err
+	}
+	// Allow reusing the addr to aid debugging.
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		return nil, err
+	}
+	// Bind to the address and port.
+	if err := unix.Bind(fd, sockaddr(laddr)); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, err
+	}
+	return newInfoConn(conn, ifc.Index, nil)
+}
@@ -9,11 +9,11 @@ import (
 	"net"
 	"os"
 
-	"github.com/google/netstack/tcpip"
 	"github.com/google/netstack/tcpip/header"
 	"github.com/mdlayher/ethernet"
 	"github.com/mdlayher/raw"
-	"github.com/u-root/dhcp4/internal/buffer"
+	"github.com/u-root/dhcp4/internal/udp4"
+	"golang.org/x/net/bpf"
 	"golang.org/x/sys/unix"
 )
 
@@ -21,9 +21,38 @@ var (
 	BroadcastMac = net.HardwareAddr([]byte{255, 255, 255, 255, 255, 255})
 )
 
+// newDefaultConn returns the connection a Client uses when none is
+// configured.
+//
+// On Linux, this is a raw packet socket, which can receive replies before the
+// interface has an IP address. Clients that already have an address use a
+// UDP socket sending from it instead, as do clients sending to unicast server
+// addresses: the raw socket sends every frame to the broadcast MAC address,
+// which routers do not forward.
+func newDefaultConn(iface *Interface, laddr *net.UDPAddr, unicast bool) (net.PacketConn, error) {
+	if laddr.IP != nil || unicast {
+		return listenIPv4UDP(iface.Name, laddr)
+	}
+	return NewPacketUDPConn(iface.Name, laddr.Port)
+}
+
 // NewIPv4UDPConn returns a UDP connection bound to both the interface and port
 // given based on a IPv4 DGRAM socket. The UDP connection allows broadcasting.
+//
+// The socket is bound to the interface using SO_BINDTODEVICE. The connection
+// is an InfoConn, reporting the receiving interface and destination address
+// of each packet from IP_PKTINFO.
 func NewIPv4UDPConn(iface string, port int) (net.PacketConn, error) {
+	return listenIPv4UDP(iface, &net.UDPAddr{Port: port})
+}
+
+// listenIPv4UDP is like NewIPv4UDPConn, but binds the socket to the port of
+// laddr and sends packets from its address, if any.
+//
+// The socket is not bound to the address: Linux does not deliver broadcasts
+// to sockets bound to a unicast address, and servers broadcast DHCPNAKs and
+// replies to requests with the broadcast flag set.
+func listenIPv4UDP(iface string, laddr *net.UDPAddr) (net.PacketConn, error) {
 	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, unix.IPPROTO_UDP)
 	if err != nil {
 		return nil, err
@@ -45,11 +74,17 @@ func NewIPv4UDPConn(iface string, port int) (net.PacketConn, error) {
 		return nil, err
 	}
 	// Bind to the port.
-	if err := unix.Bind(fd, &unix.SockaddrInet4{Port: port}); err != nil {
+	if err := unix.Bind(fd, sockaddr(&net.UDPAddr{Port: laddr.Port})); err != nil {
 		return nil, err
 	}
 
-	return net.FilePacketConn(f)
+	conn, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, err
+	}
+	// The socket only receives on iface, so there is nothing to
+	// discard.
+	return newInfoConn(conn, 0, laddr.IP)
 }
 
 // NewPacketUDPConn returns a UDP connection bound to the interface and port
@@ -75,6 +110,20 @@ type UDPPacketConn struct {
 	//
 	// Calls to ReadFrom will only return packets destined to this address.
 	boundAddr *net.UDPAddr
+
+	// VerifyChecksums makes ReadFrom drop packets whose IPv4 header or
+	// UDP checksum is wrong. The kernel does not check the checksums of
+	// packets received on raw sockets. WithChecksumValidation sets it
+	// for a Client's connection.
+	VerifyChecksums bool
+
+	// AllowZeroUDPChecksum makes ReadFrom accept datagrams without UDP
+	// checksum, which some servers send, when VerifyChecksums is set.
+	AllowZeroUDPChecksum bool
+
+	// frags reassembles fragmented packets, as a raw socket receives
+	// them before the kernel would have.
+	frags udp4.Reassembler
 }
 
 // NewBroadcastUDPConn returns a PacketConn that marshals and unmarshals UDP
@@ -88,6 +137,12 @@ func NewBroadcastUDPConn(rawPacketConn net.PacketConn, boundAddr *net.UDPAddr) n
 	}
 }
 
+// validateChecksums implements checksumValidator.
+func (upc *UDPPacketConn) validateChecksums(allowZeroUDP bool) {
+	upc.VerifyChecksums = true
+	upc.AllowZeroUDPChecksum = allowZeroUDP
+}
+
 func udpMatch(addr *net.UDPAddr, bound *net.UDPAddr) bool {
 	if bound == nil {
 		return true
@@ -102,39 +157,56 @@ func udpMatch(addr *net.UDPAddr, bound *net.UDPAddr) bool {
 //
 // ReadFrom reads raw IP packets and will try to match them against
 // upc.boundAddr. Any matching packets are returned via the given buffer.
+// Fragmented packets are reassembled first, within the limits of
+// udp4.Reassembler's defaults.
+//
+// ReadFrom must not be called concurrently.
 func (upc *UDPPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
-	ipLen := header.IPv4MaximumHeaderSize
-	udpLen := header.UDPMinimumSize
-
 	for {
-		pkt := make([]byte, ipLen+udpLen+len(b))
+		pkt := make([]byte, header.IPv4MaximumHeaderSize+header.UDPMinimumSize+len(b))
 		n, _, err := upc.PacketConn.ReadFrom(pkt)
 		if err != nil {
 			return 0, nil, err
 		}
-		pkt = pkt[:n]
-		buf := buffer.New(pkt)
 
-		// To read the header length, access data directly.
-		ipHdr := header.IPv4(buf.Data())
-		ipHdr = header.IPv4(buf.Consume(int(ipHdr.HeaderLength())))
-
-		if ipHdr.TransportProtocol() != header.UDPProtocolNumber {
+		whole, err := upc.frags.Add(pkt[:n])
+		if err != nil || whole == nil {
+			// Not an IPv4 packet, or waiting for more fragments.
+			continue
+		}
+		if upc.VerifyChecksums && udp4.VerifyChecksums(whole, upc.AllowZeroUDPChecksum) != nil {
 			continue
 		}
-		udpHdr := header.UDP(buf.Consume(udpLen))
 
-		addr := &net.UDPAddr{
-			IP:   net.IP(ipHdr.DestinationAddress()),
-			Port: int(udpHdr.DestinationPort()),
+		payload, _, addr, err := udp4.Unmarshal(whole)
+		if err != nil {
+			// Not a UDP packet.
+			continue
 		}
 		if !udpMatch(addr, upc.boundAddr) {
 			continue
 		}
-		return copy(b, buf.Remaining()), addr, nil
+		return copy(b, payload), addr, nil
 	}
 }
 
+// Filter implements Transport.Filter by attaching a BPF program to the
+// underlying connection, which must deliver packets starting with the IP
+// header, as the raw socket of NewPacketUDPConn does.
+func (upc *UDPPacketConn) Filter(port int, chaddr net.HardwareAddr) error {
+	conn, ok := upc.PacketConn.(interface {
+		SetBPF([]bpf.RawInstruction) error
+	})
+	if !ok {
+		return fmt.Errorf("connection does not support BPF filters")
+	}
+	prog, err := bpf.Assemble(replyFilter(port, chaddr))
+	if err != nil {
+		return err
+	}
+	return conn.SetBPF(prog)
+}
+
 // WriteTo implements net.PacketConn.WriteTo and broadcasts all packets at the
 // raw socket level.
 //
@@ -147,40 +219,6 @@ func (upc *UDPPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
 	}
 
 	// Using the boundAddr is not quite right here, but it works.
-	packet := udp4pkt(b, udpAddr, upc.boundAddr)
+	packet := udp4.Marshal(b, udpAddr, upc.boundAddr)
 	return upc.PacketConn.WriteTo(packet, &raw.Addr{HardwareAddr: BroadcastMac})
 }
-
-func udp4pkt(packet []byte, dest *net.UDPAddr, src *net.UDPAddr) []byte {
-	ipLen := header.IPv4MinimumSize
-	udpLen := header.UDPMinimumSize
-
-	h := make([]byte, 0, ipLen+udpLen+len(packet))
-	hdr := buffer.New(h)
-
-	ipv4fields := &header.IPv4Fields{
-		IHL:         header.IPv4MinimumSize,
-		TotalLength: uint16(ipLen + udpLen + len(packet)),
-		TTL:         30,
-		Protocol:    uint8(header.UDPProtocolNumber),
-		SrcAddr:     tcpip.Address(src.IP.To4()),
-		DstAddr:     tcpip.Address(dest.IP.To4()),
-	}
-	ipv4hdr := header.IPv4(hdr.WriteN(ipLen))
-	ipv4hdr.Encode(ipv4fields)
-	ipv4hdr.SetChecksum(^ipv4hdr.CalculateChecksum())
-
-	udphdr := header.UDP(hdr.WriteN(udpLen))
-	udphdr.Encode(&header.UDPFields{
-		SrcPort: uint16(src.Port),
-		DstPort: uint16(dest.Port),
-		Length:  uint16(udpLen + len(packet)),
-	})
-
-	xsum := header.Checksum(packet, header.PseudoHeaderChecksum(
-		ipv4hdr.TransportProtocol(), ipv4fields.SrcAddr, ipv4fields.DstAddr))
-	udphdr.SetChecksum(^udphdr.CalculateChecksum(xsum, udphdr.Length()))
-
-	hdr.WriteBytes(packet)
-	return hdr.Data()
-}
@@ -0,0 +1,117 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIPv4UDPConnPacketInfo(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip(err)
+	}
+	conn, err := NewIPv4UDPConn("lo", 0)
+	if err != nil {
+		// Binding to a device needs CAP_NET_RAW.
+		t.Skip(err)
+	}
+	defer conn.Close()
+	ic, ok := conn.(InfoConn)
+	if !ok {
+		t.Fatalf("NewIPv4UDPConn returned %T, want an InfoConn", conn)
+	}
+
+	dst := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	sender, err := net.DialUDP("udp4", nil, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	ic.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 16)
+	n, _, info, err := ic.ReadFromInfo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "hello" {
+		t.Errorf("ReadFromInfo read %q, want %q", b[:n], "hello")
+	}
+	if info.IfIndex != lo.Index || !info.Dst.Equal(dst.IP) {
+		t.Errorf("ReadFromInfo info = %+v, want interface %d, destination %v", info, lo.Index, dst.IP)
+	}
+}
+
+func TestListenIPv4UDPLocalAddr(t *testing.T) {
+	local := net.IPv4(127, 0, 0, 2)
+	conn, err := listenIPv4UDP("lo", &net.UDPAddr{IP: local})
+	if err != nil {
+		// Binding to a device needs CAP_NET_RAW.
+		t.Skip(err)
+	}
+	defer conn.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// Packets to other addresses, such as broadcasts, are received.
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.WriteTo([]byte("nak"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 3), Port: port}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 16)
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("ReadFrom() = %v, want packet sent to another address", err)
+	}
+	if string(b[:n]) != "nak" {
+		t.Errorf("ReadFrom read %q, want %q", b[:n], "nak")
+	}
+
+	// Packets are sent from the local address.
+	if _, err := conn.WriteTo([]byte("request"), sender.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	sender.SetReadDeadline(time.Now().Add(time.Second))
+	_, from, err := sender.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip := from.(*net.UDPAddr).IP; !ip.Equal(local) {
+		t.Errorf("packet sent from %v, want %v", ip, local)
+	}
+}
+
+func TestWithChecksumValidation(t *testing.T) {
+	for _, tt := range []struct {
+		opts          []ClientOpt
+		wantVerify    bool
+		wantAllowZero bool
+	}{
+		{},
+		{opts: []ClientOpt{WithChecksumValidation(false)}, wantVerify: true},
+		{opts: []ClientOpt{WithChecksumValidation(true)}, wantVerify: true, wantAllowZero: true},
+	} {
+		upc := NewBroadcastUDPConn(newMockUDPConn(make(chan udpPacket), make(chan udpPacket)), &net.UDPAddr{Port: ClientPort}).(*UDPPacketConn)
+		mc, err := New(&Interface{Name: "dummy0"}, append(tt.opts, WithConn(upc))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mc.Close()
+		if upc.VerifyChecksums != tt.wantVerify || upc.AllowZeroUDPChecksum != tt.wantAllowZero {
+			t.Errorf("%d options: VerifyChecksums = %t, AllowZeroUDPChecksum = %t, want %t, %t",
+				len(tt.opts), upc.VerifyChecksums, upc.AllowZeroUDPChecksum, tt.wantVerify, tt.wantAllowZero)
+		}
+	}
+}
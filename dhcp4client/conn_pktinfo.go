@@ -0,0 +1,75 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build dragonfly || freebsd || linux || netbsd || openbsd
+// +build dragonfly freebsd linux netbsd openbsd
+
+package dhcp4client
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// newInfoConn returns an InfoConn reading the receiving interface and
+// destination address of packets on conn from IP_PKTINFO or, on the BSDs,
+// IP_RECVIF and IP_RECVDSTADDR control messages.
+//
+// If index is not 0, packets received on other interfaces are discarded. If
+// src is not nil, packets are sent from it with IP_PKTINFO.
+func newInfoConn(conn net.PacketConn, index int, src net.IP) (InfoConn, error) {
+	pc := ipv4.NewPacketConn(conn)
+	if err := pc.SetControlMessage(ipv4.FlagInterface|ipv4.FlagDst, true); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &infoConn{
+		PacketConn: pc,
+		index:      index,
+		src:        src,
+	}, nil
+}
+
+// infoConn is an InfoConn using the control messages of an ipv4.PacketConn.
+type infoConn struct {
+	*ipv4.PacketConn
+
+	index int
+	src   net.IP
+}
+
+// ReadFromInfo implements InfoConn.ReadFromInfo.
+func (c *infoConn) ReadFromInfo(b []byte) (int, net.Addr, *PacketInfo, error) {
+	for {
+		n, cm, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		info := &PacketInfo{}
+		if cm != nil {
+			if c.index != 0 && cm.IfIndex != c.index {
+				continue
+			}
+			info.IfIndex = cm.IfIndex
+			info.Dst = cm.Dst
+		}
+		return n, addr, info, nil
+	}
+}
+
+// ReadFrom implements net.PacketConn.ReadFrom.
+func (c *infoConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, _, err := c.ReadFromInfo(b)
+	return n, addr, err
+}
+
+// WriteTo implements net.PacketConn.WriteTo.
+func (c *infoConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	var cm *ipv4.ControlMessage
+	if c.src != nil {
+		cm = &ipv4.ControlMessage{Src: c.src}
+	}
+	return c.PacketConn.WriteTo(b, cm, addr)
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+// ipUnicastIf is the IP_UNICAST_IF socket option, which is missing from the
+// syscall package.
+const ipUnicastIf = 31
+
+// newDefaultConn returns the connection a Client uses when none is
+// configured.
+func newDefaultConn(iface *Interface, laddr *net.UDPAddr, unicast bool) (net.PacketConn, error) {
+	return listenIPv4UDP(iface.Name, laddr)
+}
+
+// NewIPv4UDPConn returns a UDP connection bound to the port given based on a
+// IPv4 DGRAM socket. The UDP connection allows broadcasting.
+//
+// Outgoing packets are sent on the interface using IP_UNICAST_IF. Windows
+// cannot restrict received packets to one interface.
+func NewIPv4UDPConn(iface string, port int) (net.PacketConn, error) {
+	return listenIPv4UDP(iface, &net.UDPAddr{Port: port})
+}
+
+// listenIPv4UDP is like NewIPv4UDPConn, but binds the socket to the address
+// laddr.
+func listenIPv4UDP(iface string, laddr *net.UDPAddr) (net.PacketConn, error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	// IP_UNICAST_IF takes the interface index in network byte order.
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], uint32(ifc.Index))
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				h := syscall.Handle(fd)
+				// Allow broadcasting.
+				if serr = syscall.SetsockoptInt(h, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1); serr != nil {
+					return
+				}
+				serr = syscall.SetsockoptInt(h, syscall.IPPROTO_IP, ipUnicastIf, int(binary.LittleEndian.Uint32(index[:])))
+			}); err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	return lc.ListenPacket(context.Background(), "udp4", laddr.String())
+}
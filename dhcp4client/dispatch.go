@@ -0,0 +1,151 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/u-root/dhcp4"
+)
+
+var (
+	// errXIDInUse is returned when an exchange is started with the
+	// transaction ID of an exchange that is still waiting for responses.
+	errXIDInUse = errors.New("transaction ID already in use on connection")
+
+	// errDispatcherClosed is returned to exchanges waiting on a
+	// connection that has been closed.
+	errDispatcherClosed = errors.New("connection closed")
+)
+
+// waiterQueueLen is the number of responses buffered for a single
+// transaction before further responses are dropped.
+const waiterQueueLen = 10
+
+// dispatcher owns all reads from a connection.
+//
+// A single goroutine reads every packet arriving on conn and hands it to the
+// exchange registered for the packet's transaction ID, so that concurrent
+// exchanges on the same connection never read each other's responses.
+type dispatcher struct {
+	conn net.PacketConn
+
+	// bufLen is the size of the receive buffer.
+	bufLen int
+
+	mu      sync.Mutex
+	waiters map[[4]byte]chan *dhcp4.Packet
+
+	// dead is closed when the reader stops, after err has been set.
+	dead chan struct{}
+	err  error
+
+	// done is closed to ask the reader to stop.
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newDispatcher(conn net.PacketConn, bufLen int) *dispatcher {
+	d := &dispatcher{
+		conn:    conn,
+		bufLen:  bufLen,
+		waiters: make(map[[4]byte]chan *dhcp4.Packet),
+		dead:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.readLoop()
+	return d
+}
+
+// register returns a channel on which all packets with transaction ID xid
+// are delivered until unregister is called.
+func (d *dispatcher) register(xid [4]byte) (<-chan *dhcp4.Packet, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	select {
+	case <-d.dead:
+		return nil, d.err
+	default:
+	}
+	if _, ok := d.waiters[xid]; ok {
+		return nil, errXIDInUse
+	}
+	ch := make(chan *dhcp4.Packet, waiterQueueLen)
+	d.waiters[xid] = ch
+	return ch, nil
+}
+
+// inUse reports whether an exchange with transaction ID xid is registered.
+func (d *dispatcher) inUse(xid [4]byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.waiters[xid]
+	return ok
+}
+
+// unregister stops delivery of packets with transaction ID xid.
+func (d *dispatcher) unregister(xid [4]byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.waiters, xid)
+}
+
+// close stops the reader and waits for it to exit.
+func (d *dispatcher) close() {
+	d.closeOnce.Do(func() { close(d.done) })
+	d.wg.Wait()
+}
+
+func (d *dispatcher) readLoop() {
+	defer d.wg.Done()
+
+	err := d.read()
+
+	d.mu.Lock()
+	d.err = err
+	close(d.dead)
+	d.mu.Unlock()
+}
+
+func (d *dispatcher) read() error {
+	r := &dhcp4.Receiver{
+		Conn:   d.conn,
+		BufLen: d.bufLen,
+		// Wake up every once in a while to check whether we have been
+		// closed.
+		Deadline: dhcp4.PollDeadline(100 * time.Millisecond),
+	}
+	if err := r.Run(d.done, func(pkt *dhcp4.Packet, _ net.Addr) {
+		d.deliver(pkt)
+	}); err != nil {
+		return fmt.Errorf("error reading from UDP connection: %v", err)
+	}
+	return errDispatcherClosed
+}
+
+// deliver hands pkt to the exchange waiting on its transaction ID, if any.
+//
+// Packets nobody is waiting for, or that arrive while the waiter's queue is
+// full, are dropped just as the network might drop them.
+func (d *dispatcher) deliver(pkt *dhcp4.Packet) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch, ok := d.waiters[pkt.TransactionID]
+	if !ok {
+		return
+	}
+	select {
+	case ch <- pkt:
+	default:
+	}
+}
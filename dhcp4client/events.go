@@ -0,0 +1,103 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/u-root/dhcp4"
+)
+
+// EventType is the kind of change to a Client's lease an Event reports.
+type EventType int
+
+// Lease events emitted by a Client.
+const (
+	// LeaseOffered is emitted for the offer DiscoverOffer returns.
+	LeaseOffered EventType = iota + 1
+
+	// LeaseAcked is emitted when a server acknowledges a new lease, in
+	// Request or RequestCached.
+	LeaseAcked
+
+	// LeaseRenewed is emitted when a server extends a lease, in Renew or
+	// Rebind.
+	LeaseRenewed
+
+	// LeaseReleased is emitted when the client gives up a lease.
+	LeaseReleased
+)
+
+var eventTypeNames = map[EventType]string{
+	LeaseOffered:  "offered",
+	LeaseAcked:    "acked",
+	LeaseRenewed:  "renewed",
+	LeaseReleased: "released",
+}
+
+// String implements fmt.Stringer.
+func (t EventType) String() string {
+	if s, ok := eventTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event reports a change to a Client's lease, for integrations such as DNS
+// updates or inventory systems.
+type Event struct {
+	Type EventType
+
+	// Time is when the event happened.
+	Time time.Time
+
+	// Interface is the Client's interface, if it has one.
+	Interface *Interface
+
+	// Packet is the offer or ACK that caused the event, or for
+	// LeaseReleased, the ACK of the lease released.
+	Packet *dhcp4.Packet
+}
+
+// WithEventHandler configures the client to call fn for every lease event.
+// It may be given several times to subscribe several handlers.
+//
+// fn is called synchronously, from the goroutine that called the method
+// causing the event, and must return quickly. As a Client may be used by
+// several goroutines, fn must be safe for concurrent use.
+func WithEventHandler(fn func(Event)) ClientOpt {
+	return func(c *Client) error {
+		c.eventHandlers = append(c.eventHandlers, fn)
+		return nil
+	}
+}
+
+// WithEventChannel configures the client to send every lease event on ch.
+// It may be given several times to subscribe several channels.
+//
+// Events are dropped rather than blocking the client if ch is not ready to
+// receive, so ch should be buffered.
+func WithEventChannel(ch chan<- Event) ClientOpt {
+	return WithEventHandler(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+}
+
+// emit reports an event of type t caused by p to all subscribers, then runs
+// the client's hooks, returning the first hook error.
+func (c *Client) emit(t EventType, p *dhcp4.Packet) error {
+	if len(c.eventHandlers) == 0 && len(c.hooks) == 0 {
+		return nil
+	}
+	e := Event{Type: t, Time: time.Now(), Interface: c.iface, Packet: p}
+	for _, fn := range c.eventHandlers {
+		fn(e)
+	}
+	return c.runHooks(e)
+}
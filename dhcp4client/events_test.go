@@ -0,0 +1,58 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestEvents(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	var got []EventType
+	ch := make(chan Event, 10)
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(time.Second),
+		WithEventHandler(func(e Event) { got = append(got, e.Type) }),
+		WithEventChannel(ch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if _, err := mc.Renew(ack); err != nil {
+		t.Fatalf("Renew() = %v", err)
+	}
+	if _, err := mc.Rebind(ack); err != nil {
+		t.Fatalf("Rebind() = %v", err)
+	}
+	if err := mc.Release(ack); err != nil {
+		t.Fatalf("Release() = %v", err)
+	}
+
+	want := []EventType{LeaseOffered, LeaseAcked, LeaseRenewed, LeaseRenewed, LeaseReleased}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handler got events %v, want %v", got, want)
+	}
+	close(ch)
+	var fromCh []EventType
+	for e := range ch {
+		if e.Packet == nil || e.Time.IsZero() {
+			t.Errorf("event %v has no packet or time", e.Type)
+		}
+		fromCh = append(fromCh, e.Type)
+	}
+	if !reflect.DeepEqual(fromCh, want) {
+		t.Errorf("channel got events %v, want %v", fromCh, want)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// LeaseConfigVersion is the version of the LeaseConfig JSON format written by
+// WriteLeaseConfig.
+const LeaseConfigVersion = 1
+
+// LeaseConfig is the network configuration of a lease, for handing it to
+// another program, such as the next stage booted with kexec by a first-stage
+// bootloader, which can then configure the interface without a DHCP
+// handshake of its own.
+//
+// Its JSON encoding, written by WriteLeaseConfig and read by ReadLeaseConfig,
+// looks like this:
+//
+//	{
+//	  "version": 1,
+//	  "interface": "eth0",
+//	  "hwaddr": "02:00:00:00:00:01",
+//	  "address": "192.168.0.10",
+//	  "netmask": "255.255.255.0",
+//	  "gateway": "192.168.0.1",
+//	  "dns": ["192.168.0.1"],
+//	  "domain": "example.com",
+//	  "search": ["example.com"],
+//	  "hostname": "node1",
+//	  "server_id": "192.168.0.1",
+//	  "server_name": "tftp",
+//	  "boot_file": "pxelinux.0",
+//	  "lease_seconds": 3600,
+//	  "renewal_seconds": 1800,
+//	  "rebinding_seconds": 3150,
+//	  "obtained": "2018-05-01T12:00:00Z",
+//	  "ack": "AgEGAAECAwQ..."
+//	}
+//
+// Fields the lease does not have are omitted. Lease times of 4294967295
+// seconds are infinite. "ack" is the whole ACK in wire format, encoded in
+// base64, from which any option not given a field of its own can be read.
+type LeaseConfig struct {
+	Version       int      `json:"version"`
+	Interface     string   `json:"interface,omitempty"`
+	HardwareAddr  string   `json:"hwaddr,omitempty"`
+	Address       net.IP   `json:"address"`
+	Netmask       net.IP   `json:"netmask,omitempty"`
+	Gateway       net.IP   `json:"gateway,omitempty"`
+	DNS           []net.IP `json:"dns,omitempty"`
+	DomainName    string   `json:"domain,omitempty"`
+	SearchDomains []string `json:"search,omitempty"`
+	Hostname      string   `json:"hostname,omitempty"`
+	ServerID      net.IP   `json:"server_id,omitempty"`
+	ServerName    string   `json:"server_name,omitempty"`
+	BootFile      string   `json:"boot_file,omitempty"`
+
+	LeaseSeconds     uint32 `json:"lease_seconds,omitempty"`
+	RenewalSeconds   uint32 `json:"renewal_seconds,omitempty"`
+	RebindingSeconds uint32 `json:"rebinding_seconds,omitempty"`
+
+	// Obtained is when the ACK was received, from which the lease times
+	// count.
+	Obtained time.Time `json:"obtained"`
+
+	ACK []byte `json:"ack"`
+}
+
+// NewLeaseConfig returns the configuration granted by ack, received on iface
+// at obtained. iface may be nil.
+func NewLeaseConfig(iface *Interface, ack *dhcp4.Packet, obtained time.Time) (*LeaseConfig, error) {
+	b, err := ack.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	l := &LeaseConfig{
+		Version:       LeaseConfigVersion,
+		Address:       ack.YIAddr,
+		DNS:           dhcp4opts.GetDomainNameServers(ack.Options),
+		DomainName:    dhcp4opts.GetDomainName(ack.Options),
+		SearchDomains: dhcp4opts.GetDomainSearch(ack.Options),
+		Hostname:      dhcp4opts.GetHostName(ack.Options),
+		ServerID:      net.IP(dhcp4opts.GetServerIdentifier(ack.Options)),
+		ServerName:    ack.ServerHostName(),
+		BootFile:      ack.BootFileName(),
+		Obtained:      obtained,
+		ACK:           b,
+	}
+	if iface != nil {
+		l.Interface = iface.Name
+		if iface.HardwareAddr != nil {
+			l.HardwareAddr = iface.HardwareAddr.String()
+		}
+	}
+	if mask := dhcp4opts.GetSubnetMask(ack.Options); mask != nil {
+		l.Netmask = net.IP(mask)
+	}
+	if routers := dhcp4opts.GetRouters(ack.Options); len(routers) > 0 {
+		l.Gateway = routers[0]
+	}
+	if t, err := dhcp4opts.GetLeaseTimes(ack.Options); err == nil {
+		l.LeaseSeconds = seconds(t.Lease)
+		l.RenewalSeconds = seconds(t.Renewal)
+		l.RebindingSeconds = seconds(t.Rebinding)
+	}
+	return l, nil
+}
+
+// seconds returns d in whole seconds, with InfiniteLease as 0xffffffff.
+func seconds(d time.Duration) uint32 {
+	if d == dhcp4opts.InfiniteLease {
+		return 0xffffffff
+	}
+	return uint32(d / time.Second)
+}
+
+// Packet returns the ACK the configuration was made from.
+func (l *LeaseConfig) Packet() (*dhcp4.Packet, error) {
+	ack := &dhcp4.Packet{}
+	if err := ack.UnmarshalBinary(l.ACK); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+// WriteLeaseConfig writes l to w as JSON.
+func WriteLeaseConfig(w io.Writer, l *LeaseConfig) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+// ReadLeaseConfig reads a LeaseConfig written by WriteLeaseConfig from r. It
+// returns an error for versions of the format it does not know.
+func ReadLeaseConfig(r io.Reader) (*LeaseConfig, error) {
+	l := &LeaseConfig{}
+	if err := json.NewDecoder(r).Decode(l); err != nil {
+		return nil, err
+	}
+	if l.Version != LeaseConfigVersion {
+		return nil, fmt.Errorf("unsupported lease config version %d, want %d", l.Version, LeaseConfigVersion)
+	}
+	return l, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+func TestLeaseConfig(t *testing.T) {
+	ack := dhcp4.NewPacket(dhcp4.BootReply)
+	ack.YIAddr = net.IP{192, 168, 0, 10}
+	ack.BootFile = "pxelinux.0"
+	ack.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPACK)
+	ack.Options.Add(dhcp4.OptionServerIdentifier, dhcp4opts.IP{192, 168, 0, 1})
+	ack.Options.Add(dhcp4.OptionSubnetMask, dhcp4opts.SubnetMask{255, 255, 255, 0})
+	ack.Options.Add(dhcp4.OptionRouters, dhcp4opts.IPs{{192, 168, 0, 1}, {192, 168, 0, 2}})
+	ack.Options.Add(dhcp4.OptionDomainNameServers, dhcp4opts.IPs{{192, 168, 0, 53}})
+	ack.Options.Add(dhcp4.OptionDomainName, dhcp4opts.String("example.com"))
+	ack.Options.Add(dhcp4.OptionDomainSearch, dhcp4opts.DomainSearch{"example.com", "lab.example.com"})
+	ack.Options.Add(dhcp4.OptionIPAddressLeaseTime, dhcp4opts.Duration(time.Hour))
+	obtained := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	iface := &Interface{Name: "eth0", HardwareAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}}
+
+	l, err := NewLeaseConfig(iface, ack, obtained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteLeaseConfig(&buf, l); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"version": 1`,
+		`"interface": "eth0"`,
+		`"hwaddr": "02:00:00:00:00:01"`,
+		`"address": "192.168.0.10"`,
+		`"netmask": "255.255.255.0"`,
+		`"gateway": "192.168.0.1"`,
+		`"192.168.0.53"`,
+		`"search": [`,
+		`"boot_file": "pxelinux.0"`,
+		`"lease_seconds": 3600`,
+		`"renewal_seconds": 1800`,
+		`"rebinding_seconds": 3150`,
+		`"obtained": "2018-05-01T12:00:00Z"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteLeaseConfig() = %s, want it to contain %s", buf.String(), want)
+		}
+	}
+
+	got, err := ReadLeaseConfig(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadLeaseConfig() = %v", err)
+	}
+	if !got.Address.Equal(l.Address) || !got.Gateway.Equal(l.Gateway) || !got.Obtained.Equal(obtained) || len(got.SearchDomains) != 2 {
+		t.Errorf("ReadLeaseConfig() = %+v, want %+v", got, l)
+	}
+	p, err := got.Packet()
+	if err != nil {
+		t.Fatalf("Packet() = %v", err)
+	}
+	if d := dhcp4.Diff(p, ack); d != nil {
+		t.Errorf("Packet() differs from ACK: %v", d)
+	}
+
+	if _, err := ReadLeaseConfig(strings.NewReader(`{"version": 2}`)); err == nil {
+		t.Errorf("ReadLeaseConfig(version 2) = nil error, want error")
+	}
+}
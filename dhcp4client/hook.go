@@ -0,0 +1,146 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// HookError is returned by the Client method that caused a lease event when
+// one of its hooks fails.
+//
+// The lease change itself has happened: methods returning the packet that
+// caused the event return it together with the HookError. Request fails
+// without requesting the offer if a hook fails for it.
+type HookError struct {
+	Event Event
+	Err   error
+}
+
+// Error implements error.
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook: %v", e.Event.Type, e.Err)
+}
+
+// WithHook configures the client to call fn for every lease event, like the
+// dhclient-script of ISC dhclient, for example to configure DNS or routes
+// once a lease is acked. It may be given several times; hooks run in order,
+// after the handlers of WithEventHandler.
+//
+// Unlike event handlers, hooks may fail. The first error stops the remaining
+// hooks and is returned, as a *HookError, by the method causing the event.
+//
+// The Client has no timers of its own, so there are no events for leases
+// expiring; callers who let a lease run out should undo its configuration
+// themselves.
+func WithHook(fn func(Event) error) ClientOpt {
+	return func(c *Client) error {
+		c.hooks = append(c.hooks, fn)
+		return nil
+	}
+}
+
+// runHooks runs the client's hooks for e.
+func (c *Client) runHooks(e Event) error {
+	for _, fn := range c.hooks {
+		if err := fn(e); err != nil {
+			return &HookError{Event: e, Err: err}
+		}
+	}
+	return nil
+}
+
+// Command returns a hook running the program name with args for every lease
+// event, failing if it exits unsuccessfully.
+//
+// The event is described to the program by environment variables added to
+// those of the current process:
+//
+//	DHCP4_EVENT          offered, acked, renewed, or released
+//	DHCP4_INTERFACE      the interface name
+//	DHCP4_IP_ADDRESS     the leased address
+//	DHCP4_SERVER_ID      the server identifier
+//	DHCP4_SUBNET_MASK    the subnet mask, such as 255.255.255.0
+//	DHCP4_ROUTERS        space-separated routers
+//	DHCP4_DNS_SERVERS    space-separated DNS servers
+//	DHCP4_DOMAIN_NAME    the domain name
+//	DHCP4_DOMAIN_SEARCH  space-separated search domains
+//	DHCP4_LEASE_TIME     the lease duration in seconds, or infinite
+//
+// Variables for values the packet does not carry are left out.
+func Command(name string, args ...string) func(Event) error {
+	return func(e Event) error {
+		cmd := exec.Command(name, args...)
+		cmd.Env = append(os.Environ(), commandEnv(e)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+}
+
+// commandEnv returns the environment variables describing e to Command
+// hooks.
+func commandEnv(e Event) []string {
+	env := []string{"DHCP4_EVENT=" + e.Type.String()}
+	add := func(name, value string) {
+		if value != "" {
+			env = append(env, "DHCP4_"+name+"="+value)
+		}
+	}
+	if e.Interface != nil {
+		add("INTERFACE", e.Interface.Name)
+	}
+	p := e.Packet
+	if p == nil {
+		return env
+	}
+	if p.YIAddr != nil && !p.YIAddr.IsUnspecified() {
+		add("IP_ADDRESS", p.YIAddr.String())
+	}
+	if sid := dhcp4opts.GetServerIdentifier(p.Options); sid != nil {
+		add("SERVER_ID", net.IP(sid).String())
+	}
+	if mask := dhcp4opts.GetSubnetMask(p.Options); mask != nil {
+		add("SUBNET_MASK", net.IP(mask).String())
+	}
+	add("ROUTERS", ipsString(dhcp4opts.GetRouters(p.Options)))
+	add("DNS_SERVERS", ipsString(dhcp4opts.GetDomainNameServers(p.Options)))
+	// Domain names are left out unless valid, as they are chosen by the
+	// server and scripts may not quote them.
+	if name := dhcp4opts.GetDomainName(p.Options); CheckDomainName(name) == nil {
+		add("DOMAIN_NAME", name)
+	}
+	var search []string
+	for _, name := range dhcp4opts.GetDomainSearch(p.Options) {
+		if CheckDomainName(name) == nil {
+			search = append(search, name)
+		}
+	}
+	add("DOMAIN_SEARCH", strings.Join(search, " "))
+	if t, err := dhcp4opts.GetLeaseTimes(p.Options); err == nil {
+		secs := "infinite"
+		if t.Lease != dhcp4opts.InfiniteLease {
+			secs = strconv.Itoa(int(t.Lease.Seconds()))
+		}
+		add("LEASE_TIME", secs)
+	}
+	return env
+}
+
+func ipsString(ips dhcp4opts.IPs) string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	return strings.Join(s, " ")
+}
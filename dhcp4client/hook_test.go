@@ -0,0 +1,118 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestWithHook(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	errHook := errors.New("hook failed")
+	var got []EventType
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(time.Second),
+		WithHook(func(e Event) error {
+			got = append(got, e.Type)
+			if e.Type == LeaseRenewed {
+				return errHook
+			}
+			return nil
+		}),
+		WithHook(func(e Event) error {
+			if e.Type == LeaseRenewed {
+				t.Errorf("hook ran after an earlier hook failed")
+			}
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	reply, err := mc.Renew(ack)
+	if he, ok := err.(*HookError); !ok || he.Err != errHook || he.Event.Type != LeaseRenewed {
+		t.Errorf("Renew() = %v, want a HookError for %v", err, errHook)
+	}
+	if reply == nil {
+		t.Error("Renew() returned no reply with its HookError")
+	}
+	if err := mc.Release(ack); err != nil {
+		t.Errorf("Release() = %v", err)
+	}
+
+	want := []EventType{LeaseOffered, LeaseAcked, LeaseRenewed, LeaseReleased}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("hook got events %v, want %v", got, want)
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	ack := dhcp4.NewPacket(dhcp4.BootReply)
+	ack.YIAddr = net.IP{192, 168, 0, 100}
+	ack.Options.Add(dhcp4.OptionServerIdentifier, dhcp4opts.IP{192, 168, 0, 1})
+	ack.Options.Add(dhcp4.OptionSubnetMask, dhcp4opts.SubnetMask{255, 255, 255, 0})
+	ack.Options.Add(dhcp4.OptionRouters, dhcp4opts.IPs{{192, 168, 0, 1}})
+	ack.Options.Add(dhcp4.OptionDomainNameServers, dhcp4opts.IPs{{8, 8, 8, 8}, {8, 8, 4, 4}})
+	ack.Options.Add(dhcp4.OptionDomainName, dhcp4opts.String("example.com"))
+	ack.Options.Add(dhcp4.OptionIPAddressLeaseTime, dhcp4opts.Duration(time.Hour))
+
+	got := commandEnv(Event{Type: LeaseAcked, Interface: &Interface{Name: "eth0"}, Packet: ack})
+	want := []string{
+		"DHCP4_EVENT=acked",
+		"DHCP4_INTERFACE=eth0",
+		"DHCP4_IP_ADDRESS=192.168.0.100",
+		"DHCP4_SERVER_ID=192.168.0.1",
+		"DHCP4_SUBNET_MASK=255.255.255.0",
+		"DHCP4_ROUTERS=192.168.0.1",
+		"DHCP4_DNS_SERVERS=8.8.8.8 8.8.4.4",
+		"DHCP4_DOMAIN_NAME=example.com",
+		"DHCP4_LEASE_TIME=3600",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commandEnv() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCommandEnvDomainNames(t *testing.T) {
+	ack := dhcp4.NewPacket(dhcp4.BootReply)
+	ack.Options.Add(dhcp4.OptionDomainName, dhcp4opts.String("x\nnameserver 6.6.6.6"))
+	ack.Options.AddRaw(dhcp4.OptionDomainSearch, []byte{
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		5, '$', '(', 'r', 'm', ')', 0,
+	})
+
+	got := commandEnv(Event{Type: LeaseAcked, Packet: ack})
+	want := []string{
+		"DHCP4_EVENT=acked",
+		"DHCP4_DOMAIN_SEARCH=example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commandEnv() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	e := Event{Type: LeaseAcked}
+	if err := Command("sh", "-c", `test "$DHCP4_EVENT" = acked`)(e); err != nil {
+		t.Errorf("Command() = %v", err)
+	}
+	if err := Command("sh", "-c", "echo oops; exit 1")(e); err == nil {
+		t.Error("Command() of a failing program succeeded")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// maxLabelLen and maxHostnameLen are the limits on DNS labels and
+	// names of RFC 1035, Section 2.3.4.
+	maxLabelLen    = 63
+	maxHostnameLen = 255
+)
+
+// WithHostname configures the client to send name in the host name option
+// of its Discover and Request packets, as defined by RFC 2132, Section 3.14.
+// Many networks register the client in DNS under this name.
+//
+// name must be a valid host name according to RFC 1035, Section 2.3.1:
+// dot-separated labels of letters, digits, and hyphens. If name is empty, the
+// system's host name from os.Hostname is used instead, with invalid characters
+// replaced by hyphens. Names longer than 255 bytes are truncated at the last
+// label that fits.
+func WithHostname(name string) ClientOpt {
+	return func(c *Client) error {
+		if name == "" {
+			h, err := os.Hostname()
+			if err != nil {
+				return err
+			}
+			name = sanitizeHostname(h)
+		}
+		if err := checkHostname(name); err != nil {
+			return err
+		}
+		c.hostname = truncateHostname(name)
+		return nil
+	}
+}
+
+// checkHostname returns an error if name is not a valid RFC 1035 host name.
+func checkHostname(name string) error {
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > maxLabelLen {
+			return fmt.Errorf("host name %q: label %q must be 1 to %d characters long", name, label, maxLabelLen)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("host name %q: label %q must not start or end with a hyphen", name, label)
+		}
+		for _, r := range label {
+			if !isHostnameChar(r) {
+				return fmt.Errorf("host name %q: invalid character %q", name, r)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckDomainName returns an error if name is not a valid RFC 1035 host
+// name, optionally ending in a dot, of at most 255 bytes.
+//
+// Domain names in leases, such as those of options 15 and 119, are chosen by
+// the server. They should be checked before they are written to files or
+// handed to scripts, where a name carrying a newline or shell syntax could
+// inject configuration or commands.
+func CheckDomainName(name string) error {
+	if len(name) > maxHostnameLen {
+		return fmt.Errorf("domain name %q is longer than %d bytes", name, maxHostnameLen)
+	}
+	return checkHostname(strings.TrimSuffix(name, "."))
+}
+
+func isHostnameChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-'
+}
+
+// sanitizeHostname turns name into a valid host name by replacing invalid
+// characters with hyphens and dropping what cannot be fixed that way.
+func sanitizeHostname(name string) string {
+	var labels []string
+	for _, label := range strings.Split(name, ".") {
+		label = strings.Map(func(r rune) rune {
+			if isHostnameChar(r) {
+				return r
+			}
+			return '-'
+		}, label)
+		if len(label) > maxLabelLen {
+			label = label[:maxLabelLen]
+		}
+		label = strings.Trim(label, "-")
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return "localhost"
+	}
+	return strings.Join(labels, ".")
+}
+
+// truncateHostname shortens name to at most maxHostnameLen bytes by dropping
+// trailing labels.
+func truncateHostname(name string) string {
+	for len(name) > maxHostnameLen {
+		name = name[:strings.LastIndexByte(name, '.')]
+	}
+	return name
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestWithHostname(t *testing.T) {
+	long := strings.Repeat(strings.Repeat("a", 63)+".", 4) + "b"
+	for _, tt := range []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "laptop", want: "laptop"},
+		{name: "laptop.example.com", want: "laptop.example.com"},
+		{name: "lap-top2", want: "lap-top2"},
+		{name: long, want: long[:4*64-1]},
+		{name: "lap_top", wantErr: true},
+		{name: "-laptop", wantErr: true},
+		{name: "laptop.", wantErr: true},
+		{name: strings.Repeat("a", 64), wantErr: true},
+	} {
+		c := &Client{}
+		err := WithHostname(tt.name)(c)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("WithHostname(%q) = %v, want error %t", tt.name, err, tt.wantErr)
+		} else if c.hostname != tt.want {
+			t.Errorf("WithHostname(%q) sends %q, want %q", tt.name, c.hostname, tt.want)
+		}
+	}
+}
+
+func TestCheckDomainName(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "example.com"},
+		{name: "example.com."},
+		{name: "", wantErr: true},
+		{name: ".", wantErr: true},
+		{name: "example..com", wantErr: true},
+		{name: "x\nnameserver 6.6.6.6", wantErr: true},
+		{name: "$(reboot)", wantErr: true},
+		{name: strings.Repeat("a.", 128), wantErr: true},
+	} {
+		if err := CheckDomainName(tt.name); tt.wantErr != (err != nil) {
+			t.Errorf("CheckDomainName(%q) = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSanitizeHostname(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"laptop", "laptop"},
+		{"my_laptop.local", "my-laptop.local"},
+		{"-x-..y", "x.y"},
+		{"__", "localhost"},
+	} {
+		if got := sanitizeHostname(tt.name); got != tt.want {
+			t.Errorf("sanitizeHostname(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHostnameSent(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithHostname("laptop"), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	for i, p := range srv.Received() {
+		if got := dhcp4opts.GetHostName(p.Options); got != "laptop" {
+			t.Errorf("packet %d: host name = %q, want %q", i, got, "laptop")
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+)
+
+// Interface is the network interface a Client sends and receives packets on.
+//
+// It carries only what DHCP needs, so it can be filled in on any platform.
+type Interface struct {
+	// Name is the name of the interface, such as "eth0" or "en0".
+	Name string
+
+	// HardwareAddr is the link-layer address of the interface. It is used
+	// as the client hardware address of outgoing packets.
+	HardwareAddr net.HardwareAddr
+
+	// Index is the operating system's index of the interface.
+	Index int
+}
+
+// InterfaceByName returns the Interface with the given name.
+func InterfaceByName(name string) (*Interface, error) {
+	ifc, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Interface{
+		Name:         ifc.Name,
+		HardwareAddr: ifc.HardwareAddr,
+		Index:        ifc.Index,
+	}, nil
+}
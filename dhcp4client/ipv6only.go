@@ -0,0 +1,58 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// IPv6OnlyError is returned by Request when the network prefers IPv6-only
+// clients, as described by RFC 8925.
+//
+// The client should configure itself with IPv6 only, and not use DHCPv4 on
+// the interface again before Wait has passed.
+type IPv6OnlyError struct {
+	Wait time.Duration
+}
+
+// Error implements error.
+func (e *IPv6OnlyError) Error() string {
+	return fmt.Sprintf("network prefers IPv6-only clients; retry DHCPv4 in %v", e.Wait)
+}
+
+// WithIPv6OnlyPreferred configures whether the client tells servers that it
+// can operate with IPv6 only, by asking for the IPv6-Only Preferred option
+// of RFC 8925 in its parameter request list.
+//
+// If a server then offers the option, Request does not request the offered
+// address and returns an *IPv6OnlyError. If the option only shows up in the
+// ACK, the address is released before returning the error.
+//
+// Default is false.
+func WithIPv6OnlyPreferred(enable bool) ClientOpt {
+	return func(c *Client) error {
+		if enable {
+			c.requestOption(dhcp4.OptionIPv6OnlyPreferred)
+		}
+		c.ipv6Only = enable
+		return nil
+	}
+}
+
+// ipv6OnlyWait returns an *IPv6OnlyError if the client is IPv6-only capable
+// and reply says the network prefers such clients.
+func (c *Client) ipv6OnlyWait(reply *dhcp4.Packet) error {
+	if !c.ipv6Only {
+		return nil
+	}
+	if wait, ok := dhcp4opts.GetIPv6OnlyPreferred(reply.Options); ok {
+		return &IPv6OnlyError{Wait: wait}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func withIPv6Only(mt dhcp4opts.DHCPMessageType, wait time.Duration) dhcp4test.Action {
+	return func(s *dhcp4test.Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		p := s.Reply(req, mt)
+		p.Options.Add(dhcp4.OptionIPv6OnlyPreferred, dhcp4opts.IPv6OnlyWait(wait))
+		return []*dhcp4.Packet{p}
+	}
+}
+
+func TestIPv6OnlyPreferred(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		enable   bool
+		script   []dhcp4test.Action
+		wantWait time.Duration
+		want     []dhcp4opts.DHCPMessageType
+	}{
+		{
+			desc:     "offer",
+			enable:   true,
+			script:   []dhcp4test.Action{withIPv6Only(dhcp4opts.DHCPOffer, time.Hour)},
+			wantWait: time.Hour,
+			want:     []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPDiscover},
+		},
+		{
+			desc:     "short wait",
+			enable:   true,
+			script:   []dhcp4test.Action{withIPv6Only(dhcp4opts.DHCPOffer, time.Second)},
+			wantWait: dhcp4opts.MinIPv6OnlyWait,
+			want:     []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPDiscover},
+		},
+		{
+			desc:     "ACK only",
+			enable:   true,
+			script:   []dhcp4test.Action{dhcp4test.Offer(), withIPv6Only(dhcp4opts.DHCPACK, time.Hour)},
+			wantWait: time.Hour,
+			want:     []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPDiscover, dhcp4opts.DHCPRequest, dhcp4opts.DHCPRelease},
+		},
+		{
+			desc:   "not IPv6-only capable",
+			script: []dhcp4test.Action{withIPv6Only(dhcp4opts.DHCPOffer, time.Hour)},
+			want:   []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPDiscover, dhcp4opts.DHCPRequest},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			srv, conn := dhcp4test.Start(dhcp4test.Config{}, tt.script...)
+			defer srv.Close()
+
+			mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithIPv6OnlyPreferred(tt.enable), WithTimeout(time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mc.Close()
+
+			_, err = mc.Request()
+			if tt.wantWait == 0 {
+				if err != nil {
+					t.Errorf("Request() = %v, want nil", err)
+				}
+			} else if e, ok := err.(*IPv6OnlyError); !ok || e.Wait != tt.wantWait {
+				t.Errorf("Request() = %v, want IPv6OnlyError waiting %v", err, tt.wantWait)
+			}
+
+			// The Release is not answered; give it time to arrive.
+			deadline := time.Now().Add(time.Second)
+			for len(srv.Received()) < len(tt.want) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			var got []dhcp4opts.DHCPMessageType
+			for _, p := range srv.Received() {
+				got = append(got, messageType(p))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("server received %v, want %v", got, tt.want)
+			}
+			if tt.enable {
+				prl := dhcp4opts.GetParameterRequestList(srv.Received()[0].Options)
+				if !reflect.DeepEqual(prl, dhcp4opts.OptionCodes{dhcp4.OptionIPv6OnlyPreferred}) {
+					t.Errorf("parameter request list = %v, want [108]", prl)
+				}
+			}
+		})
+	}
+}
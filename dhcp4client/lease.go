@@ -0,0 +1,182 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// LeaseStore persists the last lease a Client obtained, so that it can
+// reclaim the lease's address after a restart or reconnect.
+//
+// Implementations must be safe for concurrent use.
+type LeaseStore interface {
+	// Load returns the ACK of the last lease saved, or nil if there is
+	// none.
+	Load() (*dhcp4.Packet, error)
+
+	// Save saves ack as the last lease, replacing any earlier one.
+	Save(ack *dhcp4.Packet) error
+}
+
+// MemoryLeaseStore is a LeaseStore keeping the last lease in memory, for
+// clients that reconnect without restarting.
+type MemoryLeaseStore struct {
+	mu  sync.Mutex
+	ack *dhcp4.Packet
+}
+
+// Load implements LeaseStore.Load.
+func (s *MemoryLeaseStore) Load() (*dhcp4.Packet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ack, nil
+}
+
+// Save implements LeaseStore.Save.
+func (s *MemoryLeaseStore) Save(ack *dhcp4.Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ack = ack
+	return nil
+}
+
+// FileLeaseStore is a LeaseStore keeping the last lease in a file, as the
+// wire format of its ACK.
+type FileLeaseStore struct {
+	// Path is the file the lease is kept in.
+	Path string
+}
+
+// Load implements LeaseStore.Load.
+//
+// Load returns no lease if the file does not exist.
+func (s FileLeaseStore) Load() (*dhcp4.Packet, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	ack := &dhcp4.Packet{}
+	if err := ack.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+// Save implements LeaseStore.Save.
+//
+// The file is replaced atomically, so a crash never leaves a partial lease
+// behind.
+func (s FileLeaseStore) Save(ack *dhcp4.Packet) error {
+	b, err := ack.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+// WithLeaseStore configures the client to save every lease it obtains to s,
+// and to reclaim the saved lease in RequestCached.
+func WithLeaseStore(s LeaseStore) ClientOpt {
+	return func(c *Client) error {
+		c.leaseStore = s
+		return nil
+	}
+}
+
+// acked records reply if it is an ACK: it is saved to the client's lease
+// store, if it has one, and reported as an event of type t. It returns the
+// error of the client's hooks.
+func (c *Client) acked(reply *dhcp4.Packet, t EventType) error {
+	if messageType(reply) != dhcp4opts.DHCPACK {
+		return nil
+	}
+	if c.leaseStore != nil {
+		// The lease is still good; failing to cache it only costs
+		// a slower reconnect.
+		_ = c.leaseStore.Save(reply)
+	}
+	return c.emit(t, reply)
+}
+
+// RequestCached reclaims the address of the lease saved in the client's
+// lease store, falling back to the full handshake of Request.
+//
+// The saved address is requested in the INIT-REBOOT state described by RFC
+// 2131, Section 4.3.2: a single broadcast DHCPREQUEST without a server
+// identifier, which any server responsible for the address can acknowledge.
+// If no lease is saved, or the request is not acknowledged, RequestCached
+// behaves like Request.
+func (c *Client) RequestCached() (*dhcp4.Packet, error) {
+	ctx, cancel := c.callContext(context.Background())
+	defer cancel()
+	ack, err := c.requestCached(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ack, c.acked(ack, LeaseAcked)
+}
+
+// requestCached is RequestCached, within ctx, except that an ACK is not yet
+// recorded with acked.
+func (c *Client) requestCached(ctx context.Context) (*dhcp4.Packet, error) {
+	if c.leaseStore != nil {
+		if lease, err := c.leaseStore.Load(); err == nil && lease != nil {
+			reply, err := c.reboot(ctx, lease)
+			if err == nil && messageType(reply) == dhcp4opts.DHCPACK {
+				return reply, nil
+			}
+		}
+	}
+	return c.request(ctx)
+}
+
+// reboot sends an INIT-REBOOT request for the address of lease.
+func (c *Client) reboot(ctx context.Context, lease *dhcp4.Packet) (reply *dhcp4.Packet, err error) {
+	defer c.observeHandshake(time.Now(), &err)
+
+	return c.sendAndReadOne(ctx, c.RebootPacket(lease))
+}
+
+// RebootPacket returns an INIT-REBOOT DHCPRequest packet asking to reuse the
+// address of lease, an earlier ACK.
+func (c *Client) RebootPacket(lease *dhcp4.Packet) *dhcp4.Packet {
+	packet := dhcp4.NewPacket(dhcp4.BootRequest)
+	packet.TransactionID = c.newXID()
+	c.setHardwareAddr(packet)
+	packet.SetBroadcast(c.broadcastFlag())
+	c.setRelay(packet)
+
+	packet.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPRequest)
+	packet.Options.Add(dhcp4.OptionMaximumDHCPMessageSize, dhcp4opts.Uint16(c.maxMessageSize))
+	packet.Options.Add(dhcp4.OptionRequestedIPAddress, dhcp4opts.IP(lease.YIAddr.To4()))
+	c.addClientOptions(packet)
+	return packet
+}
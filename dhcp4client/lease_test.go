@@ -0,0 +1,115 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestRequestCached(t *testing.T) {
+	store := &MemoryLeaseStore{}
+	iface := &Interface{Name: "dummy0", HardwareAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}}
+	yiaddr := net.IPv4(10, 0, 0, 42)
+
+	for _, tt := range []struct {
+		desc   string
+		script []dhcp4test.Action
+		reboot bool
+		want   []dhcp4opts.DHCPMessageType
+	}{
+		{
+			desc: "no lease saved",
+			want: []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPDiscover, dhcp4opts.DHCPRequest},
+		},
+		{
+			desc:   "reboot",
+			reboot: true,
+			want:   []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPRequest},
+		},
+		{
+			desc:   "reboot NAKed",
+			script: []dhcp4test.Action{dhcp4test.Nak("wrong network")},
+			reboot: true,
+			want:   []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPRequest, dhcp4opts.DHCPDiscover, dhcp4opts.DHCPRequest},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			srv, conn := dhcp4test.Start(dhcp4test.Config{YourIP: yiaddr}, tt.script...)
+			defer srv.Close()
+
+			c, err := New(iface, WithConn(conn), WithLeaseStore(store), WithTimeout(time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			ack, err := c.RequestCached()
+			if err != nil {
+				t.Fatalf("RequestCached() = %v", err)
+			}
+			if !ack.YIAddr.Equal(yiaddr) {
+				t.Errorf("yiaddr = %v, want %v", ack.YIAddr, yiaddr)
+			}
+			if saved, _ := store.Load(); saved != ack {
+				t.Errorf("saved lease = %v, want the ACK", saved)
+			}
+
+			received := srv.Received()
+			if len(received) != len(tt.want) {
+				t.Fatalf("server received %d packets, want %d", len(received), len(tt.want))
+			}
+			for i, p := range received {
+				if mt := messageType(p); mt != tt.want[i] {
+					t.Errorf("packet %d: message type = %d, want %d", i, mt, tt.want[i])
+				}
+			}
+			if first := received[0]; tt.reboot {
+				if first.Options.Has(dhcp4.OptionServerIdentifier) {
+					t.Errorf("INIT-REBOOT request carries a server identifier")
+				}
+				if got := net.IP(dhcp4opts.GetRequestedIPAddress(first.Options)); !got.Equal(yiaddr) {
+					t.Errorf("INIT-REBOOT requested address = %v, want %v", got, yiaddr)
+				}
+			}
+		})
+	}
+}
+
+func TestFileLeaseStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dhcp4client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := FileLeaseStore{Path: filepath.Join(dir, "lease")}
+	if ack, err := s.Load(); ack != nil || err != nil {
+		t.Fatalf("Load() = %v, %v, want no lease", ack, err)
+	}
+
+	ack := dhcp4.NewPacket(dhcp4.BootReply)
+	ack.YIAddr = net.IPv4(10, 0, 0, 42)
+	ack.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPACK)
+	if err := s.Save(ack); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if !got.YIAddr.Equal(ack.YIAddr) || messageType(got) != dhcp4opts.DHCPACK {
+		t.Errorf("Load() = %v, want %v", got, ack)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// LeaseQuery selects the binding a DHCPLEASEQUERY asks about, by exactly one
+// of an address, a hardware address, or a client identifier (RFC 4388,
+// Section 6.1).
+type LeaseQuery struct {
+	// IP asks about the binding of an address.
+	IP net.IP
+
+	// HardwareAddr asks about the most recent binding of the client with
+	// this hardware address.
+	HardwareAddr net.HardwareAddr
+
+	// ClientID asks about the most recent binding of the client with this
+	// client identifier.
+	ClientID []byte
+}
+
+// leaseQueryOptions are the options a leasequery asks for unless others are
+// configured with WithRequestedOptions.
+var leaseQueryOptions = dhcp4opts.OptionCodes{
+	dhcp4.OptionIPAddressLeaseTime,
+	dhcp4.OptionClientLastTransactionTime,
+	dhcp4.OptionAssociatedIP,
+	dhcp4.OptionRelayAgentInformation,
+}
+
+// LeaseQueryPacket returns a DHCPLEASEQUERY packet asking about the binding
+// selected by q.
+//
+// Leasequeries are sent by relay agents, such as access concentrators
+// learning the bindings of their clients, so the client must pose as one
+// with WithGatewayIP. Servers answer to the gateway address on the server
+// port.
+func (c *Client) LeaseQueryPacket(q LeaseQuery) (*dhcp4.Packet, error) {
+	if c.gatewayIP == nil {
+		return nil, fmt.Errorf("DHCPLEASEQUERY requires a relay agent address; use WithGatewayIP")
+	}
+
+	packet := dhcp4.NewPacket(dhcp4.BootRequest)
+	packet.TransactionID = c.newXID()
+	packet.GIAddr = c.gatewayIP
+	var n int
+	if q.IP != nil {
+		n++
+		packet.CIAddr = q.IP.To4()
+		if packet.CIAddr == nil {
+			return nil, fmt.Errorf("leasequery address %v is not an IPv4 address", q.IP)
+		}
+	}
+	if q.HardwareAddr != nil {
+		n++
+		packet.CHAddr = q.HardwareAddr
+	} else {
+		// Queries by address or client identifier carry no hardware
+		// address.
+		packet.HType = 0
+	}
+	if q.ClientID != nil {
+		n++
+		packet.Options.AddRaw(dhcp4.OptionClientIdentifier, q.ClientID)
+	}
+	if n != 1 {
+		return nil, fmt.Errorf("leasequery must select by exactly one of address, hardware address, or client identifier")
+	}
+
+	packet.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPLeaseQuery)
+	requested := c.requested
+	if len(requested) == 0 {
+		requested = leaseQueryOptions
+	}
+	packet.Options.Add(dhcp4.OptionParameterRequestList, requested)
+	return packet, nil
+}
+
+// LeaseQuery asks the servers about the binding selected by q and returns the
+// reply: a DHCPLEASEACTIVE, whose ciaddr is the bound address, a
+// DHCPLEASEUNASSIGNED for addresses the server manages but has not bound, or
+// a DHCPLEASEUNKNOWN. See LeaseQueryPacket.
+//
+// Servers are usually given with WithServerAddrs, as leasequeries are
+// unicast to servers across the network.
+func (c *Client) LeaseQuery(q LeaseQuery) (*dhcp4.Packet, error) {
+	packet, err := c.LeaseQueryPacket(q)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendAndReadOne(packet)
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestLeaseQuery(t *testing.T) {
+	bound := net.IP{192, 168, 0, 42}
+	srv, conn := dhcp4test.Start(dhcp4test.Config{YourIP: bound})
+	defer srv.Close()
+
+	gateway := net.IP{10, 1, 0, 1}
+	mc, err := New(nil, WithConn(conn), WithGatewayIP(gateway), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	for _, tt := range []struct {
+		desc     string
+		q        LeaseQuery
+		wantType dhcp4opts.DHCPMessageType
+		wantIP   net.IP
+	}{
+		{
+			desc:     "bound address",
+			q:        LeaseQuery{IP: bound},
+			wantType: dhcp4opts.DHCPLeaseActive,
+			wantIP:   bound,
+		},
+		{
+			desc:     "other address",
+			q:        LeaseQuery{IP: net.IP{192, 168, 0, 43}},
+			wantType: dhcp4opts.DHCPLeaseUnknown,
+		},
+		{
+			desc:     "hardware address",
+			q:        LeaseQuery{HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}},
+			wantType: dhcp4opts.DHCPLeaseUnknown,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			reply, err := mc.LeaseQuery(tt.q)
+			if err != nil {
+				t.Fatalf("LeaseQuery() = %v", err)
+			}
+			if mt := dhcp4opts.GetDHCPMessageType(reply.Options); mt != tt.wantType {
+				t.Errorf("reply message type = %d, want %d", mt, tt.wantType)
+			}
+			if tt.wantIP != nil && !reply.CIAddr.Equal(tt.wantIP) {
+				t.Errorf("reply ciaddr = %v, want %v", reply.CIAddr, tt.wantIP)
+			}
+
+			received := srv.Received()
+			req := received[len(received)-1]
+			if !req.GIAddr.Equal(gateway) {
+				t.Errorf("query giaddr = %v, want %v", req.GIAddr, gateway)
+			}
+			if got := dhcp4opts.GetParameterRequestList(req.Options); len(got) == 0 {
+				t.Error("query has no parameter request list")
+			}
+			if err := dhcp4.ValidateRequest(req); err != nil {
+				t.Errorf("ValidateRequest(query) = %v", err)
+			}
+		})
+	}
+}
+
+func TestLeaseQueryPacketErrors(t *testing.T) {
+	conn, _ := dhcp4test.NewConnPair(&net.UDPAddr{Port: ServerPort}, &net.UDPAddr{Port: ServerPort})
+	mc, err := New(nil, WithConn(conn), WithGatewayIP(net.IP{10, 1, 0, 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	for _, q := range []LeaseQuery{
+		{},
+		{IP: net.IP{10, 0, 0, 1}, ClientID: []byte{1, 2}},
+		{IP: net.ParseIP("fe80::1")},
+	} {
+		if _, err := mc.LeaseQueryPacket(q); err == nil {
+			t.Errorf("LeaseQueryPacket(%+v) succeeded", q)
+		}
+	}
+
+	p, err := mc.LeaseQueryPacket(LeaseQuery{ClientID: []byte{1, 2, 0, 0, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.HType != 0 || len(p.CHAddr) != 0 || p.Options.Get(dhcp4.OptionClientIdentifier) == nil {
+		t.Errorf("query by client identifier = %v", p)
+	}
+
+	conn2, _ := dhcp4test.NewConnPair(&net.UDPAddr{Port: ClientPort}, &net.UDPAddr{Port: ServerPort})
+	noRelay, err := New(nil, WithConn(conn2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer noRelay.Close()
+	if _, err := noRelay.LeaseQueryPacket(LeaseQuery{IP: net.IP{10, 0, 0, 1}}); err == nil {
+		t.Error("LeaseQueryPacket without gateway address succeeded")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// Metrics receives measurements of a Client's exchanges, for export to a
+// monitoring system.
+//
+// Implementations must be safe for concurrent use, and should return quickly
+// since they are called while packets are being exchanged.
+type Metrics interface {
+	// PacketSent is called for every packet sent, including
+	// retransmissions.
+	PacketSent(mt dhcp4opts.DHCPMessageType)
+
+	// PacketReceived is called for every response received to one of
+	// the Client's requests. NAKs are received with mt DHCPNAK.
+	PacketReceived(mt dhcp4opts.DHCPMessageType)
+
+	// Retransmission is called when a request is sent again because no
+	// response arrived in time.
+	Retransmission(mt dhcp4opts.DHCPMessageType)
+
+	// Timeout is called when all retransmissions of a request went
+	// unanswered.
+	Timeout(mt dhcp4opts.DHCPMessageType)
+
+	// Handshake is called when Request or Renew returns, with how long
+	// it took and the error it returned, if any.
+	Handshake(d time.Duration, err error)
+}
+
+// nopMetrics discards all measurements.
+type nopMetrics struct{}
+
+func (nopMetrics) PacketSent(dhcp4opts.DHCPMessageType)     {}
+func (nopMetrics) PacketReceived(dhcp4opts.DHCPMessageType) {}
+func (nopMetrics) Retransmission(dhcp4opts.DHCPMessageType) {}
+func (nopMetrics) Timeout(dhcp4opts.DHCPMessageType)        {}
+func (nopMetrics) Handshake(time.Duration, error)           {}
+
+// WithMetrics configures the Client to report measurements to m. The
+// dhcp4prom package exports them to Prometheus.
+//
+// By default, or if m is nil, measurements are discarded.
+func WithMetrics(m Metrics) ClientOpt {
+	return func(c *Client) error {
+		if m == nil {
+			m = nopMetrics{}
+		}
+		c.metrics = m
+		return nil
+	}
+}
+
+// messageType returns the DHCP message type of p.
+func messageType(p *dhcp4.Packet) dhcp4opts.DHCPMessageType {
+	return dhcp4opts.GetDHCPMessageType(p.Options)
+}
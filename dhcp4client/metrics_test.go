@@ -0,0 +1,108 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+type countingMetrics struct {
+	mu             sync.Mutex
+	sent           map[dhcp4opts.DHCPMessageType]int
+	received       map[dhcp4opts.DHCPMessageType]int
+	retransmission map[dhcp4opts.DHCPMessageType]int
+	timeout        map[dhcp4opts.DHCPMessageType]int
+	handshakes     []error
+}
+
+func newCountingMetrics() *countingMetrics {
+	return &countingMetrics{
+		sent:           make(map[dhcp4opts.DHCPMessageType]int),
+		received:       make(map[dhcp4opts.DHCPMessageType]int),
+		retransmission: make(map[dhcp4opts.DHCPMessageType]int),
+		timeout:        make(map[dhcp4opts.DHCPMessageType]int),
+	}
+}
+
+func (m *countingMetrics) count(counts map[dhcp4opts.DHCPMessageType]int, mt dhcp4opts.DHCPMessageType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts[mt]++
+}
+
+func (m *countingMetrics) PacketSent(mt dhcp4opts.DHCPMessageType)     { m.count(m.sent, mt) }
+func (m *countingMetrics) PacketReceived(mt dhcp4opts.DHCPMessageType) { m.count(m.received, mt) }
+func (m *countingMetrics) Retransmission(mt dhcp4opts.DHCPMessageType) { m.count(m.retransmission, mt) }
+func (m *countingMetrics) Timeout(mt dhcp4opts.DHCPMessageType)        { m.count(m.timeout, mt) }
+
+func (m *countingMetrics) Handshake(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handshakes = append(m.handshakes, err)
+}
+
+func TestMetrics(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{}, dhcp4test.Drop(), dhcp4test.Offer(), dhcp4test.Nak(""))
+	defer srv.Close()
+
+	m := newCountingMetrics()
+	c, err := New(&Interface{HardwareAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}},
+		WithConn(conn), WithTimeout(50*time.Millisecond), WithRetry(2), WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	// Both attempts of the next Discover go unanswered, and so do both
+	// attempts with the broadcast flag flipped.
+	srv.Script(dhcp4test.Drop(), dhcp4test.Drop(), dhcp4test.Drop(), dhcp4test.Drop())
+	if _, err := c.DiscoverOffer(); err == nil {
+		t.Fatalf("DiscoverOffer() = nil error, want timeout")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tt := range []struct {
+		name string
+		got  map[dhcp4opts.DHCPMessageType]int
+		want map[dhcp4opts.DHCPMessageType]int
+	}{
+		{"sent", m.sent, map[dhcp4opts.DHCPMessageType]int{dhcp4opts.DHCPDiscover: 6, dhcp4opts.DHCPRequest: 1}},
+		{"received", m.received, map[dhcp4opts.DHCPMessageType]int{dhcp4opts.DHCPOffer: 1, dhcp4opts.DHCPNAK: 1}},
+		{"retransmission", m.retransmission, map[dhcp4opts.DHCPMessageType]int{dhcp4opts.DHCPDiscover: 3}},
+		{"timeout", m.timeout, map[dhcp4opts.DHCPMessageType]int{dhcp4opts.DHCPDiscover: 2}},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if len(m.handshakes) != 1 || m.handshakes[0] != nil {
+		t.Errorf("handshakes = %v, want one successful handshake", m.handshakes)
+	}
+}
+
+func TestWithMetricsNil(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{})
+	defer srv.Close()
+
+	c, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithTimeout(time.Second), WithMetrics(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/vishvananda/netlink"
+)
+
+// SetMTU sets the MTU of the link name to mtu and returns the MTU it had.
+//
+// Some drivers accept MTUs they then do not use; if the link reports another
+// MTU afterwards, the old MTU is restored and an error returned.
+func SetMTU(name string, mtu int) (old int, err error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return 0, err
+	}
+	old = link.Attrs().MTU
+	if old == mtu {
+		return old, nil
+	}
+	if err := netlink.LinkSetMTU(link, mtu); err != nil {
+		return old, fmt.Errorf("setting MTU %d on %s: %v", mtu, name, err)
+	}
+	if l, err := netlink.LinkByName(name); err == nil && l.Attrs().MTU == mtu {
+		return old, nil
+	}
+	if err := netlink.LinkSetMTU(link, old); err != nil {
+		return old, fmt.Errorf("%s did not take MTU %d, and restoring MTU %d failed: %v", name, mtu, old, err)
+	}
+	return old, fmt.Errorf("%s did not take MTU %d", name, mtu)
+}
+
+// ApplyMTU sets the MTU of the link name to the interface MTU option of
+// lease, an ACK, if it has a valid one, and returns the MTU the link had.
+//
+// Without a valid option, ApplyMTU leaves the link alone and returns its
+// MTU as 0.
+func ApplyMTU(name string, lease *dhcp4.Packet) (old int, err error) {
+	mtu, err := dhcp4opts.GetInterfaceMTU(lease.Options)
+	if err != nil {
+		return 0, nil
+	}
+	return SetMTU(name, int(mtu))
+}
+
+// MTUHook returns a hook, for WithHook, applying the interface MTU of
+// leases acked or renewed to the client's interface as ApplyMTU does, and
+// restoring the original MTU when the lease is released.
+func MTUHook() func(Event) error {
+	var (
+		mu   sync.Mutex
+		orig int
+	)
+	return func(e Event) error {
+		if e.Interface == nil {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch e.Type {
+		case LeaseAcked, LeaseRenewed:
+			old, err := ApplyMTU(e.Interface.Name, e.Packet)
+			if orig == 0 {
+				orig = old
+			}
+			return err
+		case LeaseReleased:
+			if orig == 0 {
+				return nil
+			}
+			_, err := SetMTU(e.Interface.Name, orig)
+			orig = 0
+			return err
+		}
+		return nil
+	}
+}
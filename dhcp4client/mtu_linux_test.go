@@ -0,0 +1,45 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"testing"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/vishvananda/netlink"
+)
+
+func TestApplyMTU(t *testing.T) {
+	lo, err := netlink.LinkByName("lo")
+	if err != nil {
+		t.Skip("no loopback link")
+	}
+	mtu := lo.Attrs().MTU
+
+	lease := dhcp4.NewPacket(dhcp4.BootReply)
+	if old, err := ApplyMTU("lo", lease); old != 0 || err != nil {
+		t.Errorf("ApplyMTU(no option) = %d, %v, want 0, nil", old, err)
+	}
+	lease.Options.Add(dhcp4.OptionInterfaceMTU, dhcp4opts.Uint16(40))
+	if old, err := ApplyMTU("lo", lease); old != 0 || err != nil {
+		t.Errorf("ApplyMTU(MTU 40) = %d, %v, want 0, nil", old, err)
+	}
+
+	lease.Options.Del(dhcp4.OptionInterfaceMTU)
+	lease.Options.Add(dhcp4.OptionInterfaceMTU, dhcp4opts.Uint16(1500))
+	if _, err := ApplyMTU("nonexistent0", lease); err == nil {
+		t.Error("ApplyMTU(nonexistent0) succeeded")
+	}
+
+	// Setting the MTU the link already has changes nothing.
+	if mtu <= 0xffff {
+		lease.Options.Del(dhcp4.OptionInterfaceMTU)
+		lease.Options.Add(dhcp4.OptionInterfaceMTU, dhcp4opts.Uint16(mtu))
+		if old, err := ApplyMTU("lo", lease); old != mtu || err != nil {
+			t.Errorf("ApplyMTU(MTU %d) = %d, %v, want %d, nil", mtu, old, err, mtu)
+		}
+	}
+}
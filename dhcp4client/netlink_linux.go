@@ -0,0 +1,25 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"github.com/vishvananda/netlink"
+)
+
+// InterfaceFromLink returns the Interface described by a netlink link.
+func InterfaceFromLink(link netlink.Link) *Interface {
+	attrs := link.Attrs()
+	return &Interface{
+		Name:         attrs.Name,
+		HardwareAddr: attrs.HardwareAddr,
+		Index:        attrs.Index,
+	}
+}
+
+// NewFromLink creates a new DHCP client that sends and receives packets on
+// the given netlink link.
+func NewFromLink(link netlink.Link, opts ...ClientOpt) (*Client, error) {
+	return New(InterfaceFromLink(link), opts...)
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"math"
+	"time"
+
+	"github.com/u-root/dhcp4"
+)
+
+// WithOfferCache configures Request to remember the offer of a handshake
+// whose DHCPREQUEST went unanswered, and to request it again on the next call
+// rather than restarting with a Discover. This shortens acquisition on lossy
+// links, where the request or its ACK is easily lost.
+//
+// The repeated request reports the time since the Discover in its secs field.
+// An offer is only requested again once: if that request goes unanswered
+// too, the next call starts over with a Discover, in case the server has
+// given up on the offer.
+func WithOfferCache() ClientOpt {
+	return func(c *Client) error {
+		c.offerCache = true
+		return nil
+	}
+}
+
+// cachedOffer is an offer remembered by WithOfferCache.
+type cachedOffer struct {
+	offer *dhcp4.Packet
+
+	// start is when the handshake of offer began.
+	start time.Time
+
+	// reused is whether offer has been requested again already.
+	reused bool
+}
+
+// secs returns the seconds elapsed since the handshake began, for the secs
+// field of requests.
+func (o *cachedOffer) secs() uint16 {
+	s := time.Since(o.start) / time.Second
+	if s > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(s)
+}
+
+// keepOffer remembers o for the next Request, if the client caches offers.
+func (c *Client) keepOffer(o *cachedOffer) {
+	if !c.offerCache {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastOffer = o
+}
+
+// takeOffer returns and forgets the remembered offer, or nil.
+func (c *Client) takeOffer() *cachedOffer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o := c.lastOffer
+	c.lastOffer = nil
+	if o != nil {
+		o.reused = true
+	}
+	return o
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestWithOfferCache(t *testing.T) {
+	srv, conn := dhcp4test.Start(dhcp4test.Config{},
+		dhcp4test.Offer(),
+		dhcp4test.Drop(),
+		dhcp4test.Ack(),
+		// The cached offer is used only once.
+		dhcp4test.Offer(),
+		dhcp4test.Drop(),
+		dhcp4test.Drop(),
+	)
+	defer srv.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(conn), WithOfferCache(), WithTimeout(time.Second), WithRetry(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if _, err := mc.Request(); !isTimeout(err) {
+		t.Fatalf("Request() with request dropped = %v, want timeout", err)
+	}
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() with cached offer = %v", err)
+	}
+	if mt := messageType(ack); mt != dhcp4opts.DHCPACK {
+		t.Errorf("Request() message type = %d, want ACK", mt)
+	}
+
+	received := srv.Received()
+	if len(received) != 3 {
+		t.Fatalf("server received %d packets, want Discover and two Requests", len(received))
+	}
+	first, again := received[1], received[2]
+	if mt := messageType(again); mt != dhcp4opts.DHCPRequest {
+		t.Errorf("second Request() sent message type %d, want Request", mt)
+	}
+	if again.TransactionID != first.TransactionID {
+		t.Errorf("repeated request has xid %x, want %x of the offer", again.TransactionID, first.TransactionID)
+	}
+	if again.Secs <= first.Secs {
+		t.Errorf("repeated request has secs %d, want more than %d", again.Secs, first.Secs)
+	}
+
+	// A successful handshake leaves nothing cached, so this one starts
+	// with a Discover, and its dropped request is cached.
+	if _, err := mc.Request(); !isTimeout(err) {
+		t.Fatalf("Request() with request dropped = %v, want timeout", err)
+	}
+	if _, err := mc.Request(); !isTimeout(err) {
+		t.Fatalf("Request() with cached offer dropped = %v, want timeout", err)
+	}
+	if mc.takeOffer() != nil {
+		t.Errorf("offer still cached after its repeated request went unanswered")
+	}
+	if mt := messageType(srv.Received()[3]); mt != dhcp4opts.DHCPDiscover {
+		t.Errorf("Request() after success sent message type %d first, want Discover", mt)
+	}
+}
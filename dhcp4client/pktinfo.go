@@ -0,0 +1,33 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+)
+
+// PacketInfo describes how a packet was received.
+type PacketInfo struct {
+	// IfIndex is the index of the interface the packet arrived on, or 0
+	// if unknown.
+	IfIndex int
+
+	// Dst is the destination address of the packet, which tells
+	// broadcast from unicast packets, or nil if unknown.
+	Dst net.IP
+}
+
+// An InfoConn is a connection that reports how each packet was received, for
+// servers and relays sharing one socket between several interfaces or
+// addresses.
+//
+// On Linux and the BSDs, the connections of NewIPv4UDPConn are InfoConns.
+type InfoConn interface {
+	net.PacketConn
+
+	// ReadFromInfo is like ReadFrom, but also returns how the packet was
+	// received.
+	ReadFromInfo(b []byte) (int, net.Addr, *PacketInfo, error)
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// ProbeResult is an offer collected by Probe.
+type ProbeResult struct {
+	// ServerID is the server identifier of the offer, or nil if it has
+	// none.
+	ServerID net.IP
+
+	// Offer is the offer, from which the offered address and parameters
+	// can be read.
+	Offer *dhcp4.Packet
+}
+
+// Probe sends a single Discover and returns every offer received within
+// window, in the order they arrived, to find all DHCP servers answering on
+// the network, such as rogue servers.
+//
+// Probe never requests an offered address. Offers repeated by the same server
+// are reported once, unless disabled with WithDedup. Probe returns no error
+// if no server answers.
+func (c *Client) Probe(window time.Duration) ([]ProbeResult, error) {
+	discover := c.DiscoverPacket()
+	pkt, err := discover.MarshalBinary()
+	if err != nil {
+		return nil, c.newClientErr(err)
+	}
+
+	in, err := c.dispatcher.register(discover.TransactionID)
+	if err != nil {
+		return nil, c.newClientErr(err)
+	}
+	defer c.dispatcher.unregister(discover.TransactionID)
+
+	for _, dest := range c.serverAddrs() {
+		if _, err := c.conn.WriteTo(pkt, dest); err != nil {
+			return nil, c.newClientErr(err)
+		}
+		c.metrics.PacketSent(dhcp4opts.DHCPDiscover)
+	}
+
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+
+	var results []ProbeResult
+	seen := make(map[responseKey]struct{})
+	for {
+		select {
+		case <-timer.C:
+			return results, nil
+
+		case <-c.dispatcher.dead:
+			return results, c.newClientErr(c.dispatcher.err)
+
+		case offer := <-in:
+			mt := messageType(offer)
+			c.metrics.PacketReceived(mt)
+			if mt != dhcp4opts.DHCPOffer {
+				continue
+			}
+			if key, ok := keyOf(offer); ok && !c.noDedup {
+				if _, dup := seen[key]; dup {
+					continue
+				}
+				seen[key] = struct{}{}
+			}
+			results = append(results, ProbeResult{
+				ServerID: net.IP(dhcp4opts.GetServerIdentifier(offer.Options)),
+				Offer:    offer,
+			})
+		}
+	}
+}
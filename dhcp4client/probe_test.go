@@ -0,0 +1,59 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestProbe(t *testing.T) {
+	n := dhcp4test.NewNetwork(dhcp4test.NetworkConfig{})
+	for _, sid := range []net.IP{{10, 0, 0, 1}, {10, 0, 0, 66}} {
+		srv := dhcp4test.NewServer(n.Listen(&net.UDPAddr{IP: sid, Port: ServerPort}), dhcp4test.Config{ServerID: sid},
+			dhcp4test.Repeat(2, dhcp4test.Offer()))
+		defer srv.Close()
+	}
+	// A server without a server identifier cannot be deduplicated.
+	silent := dhcp4test.NewServer(n.Listen(&net.UDPAddr{IP: net.IP{10, 0, 0, 99}, Port: ServerPort}), dhcp4test.Config{},
+		func(s *dhcp4test.Server, req *dhcp4.Packet) []*dhcp4.Packet {
+			p := s.Reply(req, dhcp4opts.DHCPOffer)
+			p.Options.Del(dhcp4.OptionServerIdentifier)
+			return []*dhcp4.Packet{p}
+		})
+	defer silent.Close()
+
+	mc, err := New(&Interface{Name: "dummy0"}, WithConn(n.Listen(&net.UDPAddr{IP: net.IPv4zero, Port: ClientPort})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	results, err := mc.Probe(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Probe() = %v", err)
+	}
+	got := make(map[string]int)
+	for _, r := range results {
+		got[r.ServerID.String()]++
+		if r.Offer == nil {
+			t.Errorf("result for %v has no offer", r.ServerID)
+		}
+	}
+	want := map[string]int{"10.0.0.1": 1, "10.0.0.66": 1, "<nil>": 1}
+	if len(got) != len(want) {
+		t.Fatalf("Probe() found servers %v, want %v", got, want)
+	}
+	for sid, n := range want {
+		if got[sid] != n {
+			t.Errorf("Probe() found %d offers from %s, want %d", got[sid], sid, n)
+		}
+	}
+}
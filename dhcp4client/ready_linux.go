@@ -0,0 +1,75 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// linkPollInterval is how often WaitLinkReady checks the state of a link.
+const linkPollInterval = 100 * time.Millisecond
+
+// WaitLinkReady waits until the link named name is administratively up and,
+// if carrier is set, operationally up, so that packets sent on it are not
+// lost. Links whose driver does not report an operational state, such as
+// loopback and some virtual links, count as operationally up while the
+// kernel marks them running.
+//
+// At boot, links often come up some seconds after they have been configured
+// up, while the switch port negotiates; Discovers sent in the meantime go
+// nowhere. Callers should wait before creating a Client:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	if err := dhcp4client.WaitLinkReady(ctx, "eth0", true); err != nil {
+//		...
+//	}
+//
+// WaitLinkReady returns ctx's error if ctx is done first.
+func WaitLinkReady(ctx context.Context, name string, carrier bool) error {
+	// Link updates cannot be unsubscribed from without leaking a
+	// goroutine blocked on the netlink socket, so poll instead.
+	t := time.NewTicker(linkPollInterval)
+	defer t.Stop()
+	for {
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			return err
+		}
+		if linkReady(link.Attrs(), carrier) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// linkReady reports whether a link with attrs is ready, as WaitLinkReady
+// defines it.
+func linkReady(attrs *netlink.LinkAttrs, carrier bool) bool {
+	if attrs.Flags&net.FlagUp == 0 {
+		return false
+	}
+	if !carrier {
+		return true
+	}
+	switch attrs.OperState {
+	case netlink.OperUp:
+		return true
+	case netlink.OperUnknown:
+		return attrs.RawFlags&unix.IFF_RUNNING != 0
+	default:
+		return false
+	}
+}
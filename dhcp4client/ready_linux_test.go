@@ -0,0 +1,79 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinkReady(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		attrs   netlink.LinkAttrs
+		carrier bool
+		want    bool
+	}{
+		{
+			desc:  "down",
+			attrs: netlink.LinkAttrs{OperState: netlink.OperDown},
+		},
+		{
+			desc:  "up, no carrier wait",
+			attrs: netlink.LinkAttrs{Flags: net.FlagUp, OperState: netlink.OperDown},
+			want:  true,
+		},
+		{
+			desc:    "up, no carrier",
+			attrs:   netlink.LinkAttrs{Flags: net.FlagUp, OperState: netlink.OperDown},
+			carrier: true,
+		},
+		{
+			desc:    "up, dormant",
+			attrs:   netlink.LinkAttrs{Flags: net.FlagUp, OperState: netlink.OperDormant},
+			carrier: true,
+		},
+		{
+			desc:    "up, carrier",
+			attrs:   netlink.LinkAttrs{Flags: net.FlagUp, OperState: netlink.OperUp},
+			carrier: true,
+			want:    true,
+		},
+		{
+			desc:    "up, unknown state, running",
+			attrs:   netlink.LinkAttrs{Flags: net.FlagUp, RawFlags: unix.IFF_UP | unix.IFF_RUNNING, OperState: netlink.OperUnknown},
+			carrier: true,
+			want:    true,
+		},
+		{
+			desc:    "up, unknown state, not running",
+			attrs:   netlink.LinkAttrs{Flags: net.FlagUp, RawFlags: unix.IFF_UP, OperState: netlink.OperUnknown},
+			carrier: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := linkReady(&tt.attrs, tt.carrier); got != tt.want {
+				t.Errorf("linkReady() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitLinkReadyLoopback(t *testing.T) {
+	lo, err := netlink.LinkByName("lo")
+	if err != nil || !linkReady(lo.Attrs(), true) {
+		t.Skip("no loopback link that is up")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := WaitLinkReady(ctx, "lo", true); err != nil {
+		t.Errorf("WaitLinkReady(lo) = %v", err)
+	}
+}
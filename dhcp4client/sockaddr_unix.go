@@ -0,0 +1,24 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package dhcp4client
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// sockaddr converts addr to a socket address for bind(2). A nil IP is the
+// unspecified address.
+func sockaddr(addr *net.UDPAddr) *unix.SockaddrInet4 {
+	sa := &unix.SockaddrInet4{Port: addr.Port}
+	if ip := addr.IP.To4(); ip != nil {
+		copy(sa.Addr[:], ip)
+	}
+	return sa
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"net"
+
+	"github.com/u-root/dhcp4"
+	"golang.org/x/net/bpf"
+)
+
+// A Transport is a connection a Client sends and receives packets on that can
+// drop packets not meant for the Client before they reach it.
+//
+// If the connection of a Client is a Transport, New installs a filter for the
+// Client's port and hardware address. On Linux, the raw packet connection a
+// Client uses by default is a Transport filtering packets in the kernel, so
+// that unrelated broadcast traffic on busy networks does not wake the Client
+// up.
+type Transport interface {
+	net.PacketConn
+
+	// Filter asks the transport to deliver only UDP datagrams to port
+	// carrying DHCP replies for hardware address chaddr, or for any
+	// hardware address if chaddr is nil.
+	//
+	// Filtering only saves work: the Client still checks every packet it
+	// receives.
+	Filter(port int, chaddr net.HardwareAddr) error
+}
+
+// maxIPv4PacketLen is the largest possible IPv4 packet, which replyFilter
+// accepts whole.
+const maxIPv4PacketLen = 0xffff
+
+// replyFilter returns a BPF program accepting IPv4 packets that carry a UDP
+// datagram to port with a DHCP reply for chaddr, or for any hardware address
+// if chaddr is nil. Non-first fragments of UDP datagrams are accepted
+// unchecked, as they carry neither UDP header nor DHCP message, so that large
+// replies can be reassembled.
+//
+// The program expects packets to start with the IPv4 header.
+func replyFilter(port int, chaddr net.HardwareAddr) []bpf.Instruction {
+	// drop and accept are placeholders for the offsets of the final
+	// drop and accept instructions, patched in below.
+	const (
+		drop   = 0xff
+		accept = 0xfe
+	)
+
+	prog := []bpf.Instruction{
+		// IP protocol must be UDP.
+		bpf.LoadAbsolute{Off: 9, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: 17, SkipTrue: drop},
+		// Non-first fragments cannot be checked.
+		bpf.LoadAbsolute{Off: 6, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x1fff, SkipTrue: accept},
+		// X = IP header length.
+		bpf.LoadMemShift{Off: 0},
+		// UDP destination port.
+		bpf.LoadIndirect{Off: 2, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(port), SkipTrue: drop},
+		// DHCP op must be BootReply.
+		bpf.LoadIndirect{Off: 8, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: uint32(dhcp4.BootReply), SkipTrue: drop},
+	}
+
+	// DHCP chaddr, 28 bytes into the message, compared 4, 2, or 1 bytes
+	// at a time.
+	if len(chaddr) > 16 {
+		chaddr = nil
+	}
+	for off := 0; off < len(chaddr); {
+		size := 4
+		for size > len(chaddr)-off {
+			size /= 2
+		}
+		var val uint32
+		for _, b := range chaddr[off : off+size] {
+			val = val<<8 | uint32(b)
+		}
+		prog = append(prog,
+			bpf.LoadIndirect{Off: uint32(8 + 28 + off), Size: size},
+			bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: val, SkipTrue: drop},
+		)
+		off += size
+	}
+
+	prog = append(prog,
+		bpf.RetConstant{Val: maxIPv4PacketLen},
+		bpf.RetConstant{Val: 0},
+	)
+	for i, ins := range prog {
+		j, ok := ins.(bpf.JumpIf)
+		if !ok {
+			continue
+		}
+		switch j.SkipTrue {
+		case drop:
+			j.SkipTrue = uint8(len(prog) - 1 - i - 1)
+		case accept:
+			j.SkipTrue = uint8(len(prog) - 2 - i - 1)
+		}
+		prog[i] = j
+	}
+	return prog
+}
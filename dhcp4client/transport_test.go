@@ -0,0 +1,142 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4test"
+	"github.com/u-root/dhcp4/internal/udp4"
+	"golang.org/x/net/bpf"
+)
+
+func TestReplyFilter(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	otherMAC := net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	server := &net.UDPAddr{IP: net.IP{192, 168, 0, 1}, Port: ServerPort}
+	client := &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}
+
+	packet := func(op dhcp4.OpCode, chaddr net.HardwareAddr, dst *net.UDPAddr) []byte {
+		p := dhcp4.NewPacket(op)
+		p.CHAddr = chaddr
+		b, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return udp4.Marshal(b, dst, server)
+	}
+	reply := packet(dhcp4.BootReply, mac, client)
+	notUDP := append([]byte(nil), reply...)
+	notUDP[9] = 6
+	fragment := append([]byte(nil), reply...)
+	fragment[7] = 1
+	otherFirstFragment := packet(dhcp4.BootReply, otherMAC, client)
+	otherFirstFragment[6] = 0x20
+
+	for _, tt := range []struct {
+		desc   string
+		chaddr net.HardwareAddr
+		pkt    []byte
+		want   bool
+	}{
+		{
+			desc:   "reply",
+			chaddr: mac,
+			pkt:    reply,
+			want:   true,
+		},
+		{
+			desc: "reply, any chaddr",
+			pkt:  packet(dhcp4.BootReply, otherMAC, client),
+			want: true,
+		},
+		{
+			desc:   "other chaddr",
+			chaddr: mac,
+			pkt:    packet(dhcp4.BootReply, otherMAC, client),
+		},
+		{
+			desc:   "request",
+			chaddr: mac,
+			pkt:    packet(dhcp4.BootRequest, mac, client),
+		},
+		{
+			desc:   "other port",
+			chaddr: mac,
+			pkt:    packet(dhcp4.BootReply, mac, &net.UDPAddr{IP: net.IPv4bcast, Port: 137}),
+		},
+		{
+			desc:   "not UDP",
+			chaddr: mac,
+			pkt:    notUDP,
+		},
+		{
+			desc:   "fragment",
+			chaddr: mac,
+			pkt:    fragment,
+			want:   true,
+		},
+		{
+			desc:   "first fragment, other chaddr",
+			chaddr: mac,
+			pkt:    otherFirstFragment,
+		},
+		{
+			desc:   "truncated",
+			chaddr: mac,
+			pkt:    reply[:udp4.HeaderLen+20],
+		},
+		{
+			desc:   "InfiniBand",
+			chaddr: make(net.HardwareAddr, infinibandAddrLen),
+			pkt:    reply,
+			want:   true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			vm, err := bpf.NewVM(replyFilter(ClientPort, tt.chaddr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := vm.Run(tt.pkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := n > 0; got != tt.want {
+				t.Errorf("filter accepted packet = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type filterTransport struct {
+	net.PacketConn
+	port   int
+	chaddr net.HardwareAddr
+}
+
+func (t *filterTransport) Filter(port int, chaddr net.HardwareAddr) error {
+	t.port, t.chaddr = port, chaddr
+	return nil
+}
+
+func TestNewFiltersTransport(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	conn, _ := dhcp4test.NewConnPair(&net.UDPAddr{Port: ClientPort}, &net.UDPAddr{Port: ServerPort})
+	tr := &filterTransport{PacketConn: conn}
+
+	mc, err := New(&Interface{Name: "dummy0", HardwareAddr: mac}, WithConn(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	if tr.port != ClientPort || !bytes.Equal(tr.chaddr, mac) {
+		t.Errorf("Filter(%d, %v), want Filter(%d, %v)", tr.port, tr.chaddr, ClientPort, mac)
+	}
+}
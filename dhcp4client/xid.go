@@ -0,0 +1,47 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4client
+
+import (
+	"crypto/rand"
+)
+
+// maxXIDAttempts bounds how many transaction IDs newXID draws while looking
+// for one that is not in use, so a generator that keeps returning the same ID
+// cannot hang the client.
+const maxXIDAttempts = 16
+
+// WithXIDGenerator configures how the client picks transaction IDs for new
+// exchanges, for example to make them predictable in tests.
+//
+// By default, transaction IDs are read from crypto/rand. Either way, IDs of
+// exchanges still waiting for responses on the client's connection are
+// skipped. gen is never called concurrently.
+func WithXIDGenerator(gen func() [4]byte) ClientOpt {
+	return func(c *Client) error {
+		c.xidGen = gen
+		return nil
+	}
+}
+
+// randomXID returns a random transaction ID.
+func randomXID() [4]byte {
+	var xid [4]byte
+	rand.Read(xid[:])
+	return xid
+}
+
+// newXID returns a transaction ID for a new exchange, avoiding the IDs of
+// exchanges in flight on the client's connection where possible.
+func (c *Client) newXID() [4]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	xid := c.xidGen()
+	for i := 1; i < maxXIDAttempts && c.dispatcher != nil && c.dispatcher.inUse(xid); i++ {
+		xid = c.xidGen()
+	}
+	return xid
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4fp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/u-root/dhcp4"
+)
+
+// Device is a kind of DHCP client.
+type Device struct {
+	// Name names the device, such as "Windows 10" or "iPXE".
+	Name string `json:"name"`
+
+	// Class is the broad category of the device, such as "Windows",
+	// "Printer", or "Network Boot", for policies that need not tell
+	// devices of a category apart.
+	Class string `json:"class,omitempty"`
+}
+
+// A Database identifies devices by their fingerprints.
+//
+// Implementations backed by a local copy of Fingerbank or a similar service
+// can be plugged in wherever a Database is taken.
+type Database interface {
+	// Lookup returns the device fp belongs to, or false if it is not
+	// known.
+	Lookup(fp *Fingerprint) (Device, bool)
+}
+
+// Signature matches the fingerprints of one kind of device.
+//
+// Empty fields match any fingerprint.
+type Signature struct {
+	// PRL is the parameter request list the device sends, in the form
+	// returned by Fingerprint.PRL.
+	PRL string `json:"prl,omitempty"`
+
+	// Options is the list of options the device sends, in the form
+	// returned by Fingerprint.OptionList.
+	Options string `json:"options,omitempty"`
+
+	// VendorClass is a shell pattern, as in path.Match, the vendor class
+	// identifier of the device matches, such as "MSFT 5.*".
+	VendorClass string `json:"vendor,omitempty"`
+
+	Device Device `json:"device"`
+}
+
+// Matches reports whether fp matches s.
+func (s *Signature) Matches(fp *Fingerprint) bool {
+	if s.PRL != "" && s.PRL != fp.PRL() {
+		return false
+	}
+	if s.Options != "" && s.Options != fp.OptionList() {
+		return false
+	}
+	if s.VendorClass != "" {
+		if ok, _ := path.Match(s.VendorClass, fp.VendorClass); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Signatures is a Database trying each of its signatures in order, so more
+// specific signatures must come before more general ones.
+type Signatures []Signature
+
+// Lookup implements Database.Lookup, returning the device of the first
+// signature matching fp.
+func (s Signatures) Lookup(fp *Fingerprint) (Device, bool) {
+	for i := range s {
+		if s[i].Matches(fp) {
+			return s[i].Device, true
+		}
+	}
+	return Device{}, false
+}
+
+// ReadSignatures reads a JSON array of signatures from r, such as
+//
+//	[
+//	  {"prl": "1,3,6,15,31,33,43,44,46,47,119,121,249,252", "vendor": "MSFT 5.0",
+//	   "device": {"name": "Windows 10", "class": "Windows"}},
+//	  {"vendor": "PXEClient:*", "device": {"name": "PXE ROM", "class": "Network Boot"}}
+//	]
+//
+// Vendor class patterns are checked as they are read.
+func ReadSignatures(r io.Reader) (Signatures, error) {
+	var s Signatures
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	for i := range s {
+		if _, err := path.Match(s[i].VendorClass, ""); err != nil {
+			return nil, fmt.Errorf("signature %d: vendor class %q: %v", i, s[i].VendorClass, err)
+		}
+	}
+	return s, nil
+}
+
+// IsClass returns a Matcher matching requests from devices of class class
+// according to db.
+func IsClass(db Database, class string) dhcp4.Matcher {
+	return func(req *dhcp4.Packet) bool {
+		dev, ok := db.Lookup(New(req))
+		return ok && dev.Class == class
+	}
+}
+
+// IsDevice returns a Matcher matching requests from devices named name
+// according to db.
+func IsDevice(db Database, name string) dhcp4.Matcher {
+	return func(req *dhcp4.Packet) bool {
+		dev, ok := db.Lookup(New(req))
+		return ok && dev.Name == name
+	}
+}
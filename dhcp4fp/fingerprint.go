@@ -0,0 +1,78 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dhcp4fp identifies the kind of device sending DHCP requests.
+//
+// DHCP clients differ in which options they send and request and in what
+// order, so that the options of a request say a lot about the operating
+// system or firmware sending it, as tools like Fingerbank exploit. A
+// Fingerprint captures these traits of a request, and a Database maps
+// fingerprints to devices:
+//
+//	db, err := dhcp4fp.ReadSignatures(f)
+//	...
+//	if dev, ok := db.Lookup(dhcp4fp.New(req)); ok {
+//		log.Printf("%v is a %s (%s)", req.CHAddr, dev.Name, dev.Class)
+//	}
+//
+// Classified requests can also be given options with dhcp4.When and IsClass.
+package dhcp4fp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/u-root/dhcp4"
+)
+
+// Fingerprint is the traits of a DHCP request that identify the kind of
+// client sending it.
+type Fingerprint struct {
+	// ParameterRequestList is the list of options requested in option
+	// 55, in the client's order.
+	ParameterRequestList []dhcp4.OptionCode
+
+	// VendorClass is the vendor class identifier of option 60.
+	VendorClass string
+
+	// Options is the list of options present in the request, in the order
+	// the client sent them.
+	Options []dhcp4.OptionCode
+}
+
+// New returns the fingerprint of req.
+func New(req *dhcp4.Packet) *Fingerprint {
+	fp := &Fingerprint{
+		VendorClass: string(req.Options.Get(dhcp4.OptionVendorClassIdentifier)),
+		Options:     req.Options.Codes(),
+	}
+	for _, c := range req.Options.Get(dhcp4.OptionParameterRequestList) {
+		fp.ParameterRequestList = append(fp.ParameterRequestList, dhcp4.OptionCode(c))
+	}
+	return fp
+}
+
+// PRL returns the parameter request list as comma-separated decimal codes,
+// such as "1,3,6,15", the form Fingerbank and most signature databases use.
+func (fp *Fingerprint) PRL() string {
+	return joinCodes(fp.ParameterRequestList)
+}
+
+// OptionList returns the options present as comma-separated decimal codes.
+func (fp *Fingerprint) OptionList() string {
+	return joinCodes(fp.Options)
+}
+
+// String returns a one-line summary of fp, for logs.
+func (fp *Fingerprint) String() string {
+	return "prl=" + fp.PRL() + " options=" + fp.OptionList() + " vendor=" + strconv.Quote(fp.VendorClass)
+}
+
+func joinCodes(codes []dhcp4.OptionCode) string {
+	s := make([]string, len(codes))
+	for i, c := range codes {
+		s[i] = strconv.Itoa(int(c))
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4fp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/u-root/dhcp4"
+)
+
+func request(vendor string, prl ...byte) *dhcp4.Packet {
+	req := dhcp4.NewPacket(dhcp4.BootRequest)
+	req.Options.AddRaw(dhcp4.OptionDHCPMessageType, []byte{1})
+	if vendor != "" {
+		req.Options.AddRaw(dhcp4.OptionVendorClassIdentifier, []byte(vendor))
+	}
+	req.Options.AddRaw(dhcp4.OptionParameterRequestList, prl)
+	return req
+}
+
+func TestNew(t *testing.T) {
+	fp := New(request("MSFT 5.0", 1, 3, 6, 15))
+	if got, want := fp.PRL(), "1,3,6,15"; got != want {
+		t.Errorf("PRL() = %q, want %q", got, want)
+	}
+	if got, want := fp.OptionList(), "53,60,55"; got != want {
+		t.Errorf("OptionList() = %q, want %q", got, want)
+	}
+	if got, want := fp.String(), `prl=1,3,6,15 options=53,60,55 vendor="MSFT 5.0"`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+const testSignatures = `[
+  {"prl": "1,3,6,15,31,33,43,44,46,47,119,121,249,252", "vendor": "MSFT 5.0",
+   "device": {"name": "Windows 10", "class": "Windows"}},
+  {"vendor": "MSFT *", "device": {"name": "Windows", "class": "Windows"}},
+  {"prl": "1,3,6,12,15,28,42", "options": "53,61,57,60,12,55",
+   "device": {"name": "udhcpc", "class": "Linux"}},
+  {"vendor": "PXEClient:*", "device": {"name": "PXE ROM", "class": "Network Boot"}}
+]`
+
+func TestSignatures(t *testing.T) {
+	db, err := ReadSignatures(strings.NewReader(testSignatures))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	udhcpc := dhcp4.NewPacket(dhcp4.BootRequest)
+	udhcpc.Options.AddRaw(dhcp4.OptionDHCPMessageType, []byte{1})
+	udhcpc.Options.AddRaw(dhcp4.OptionClientIdentifier, []byte{1, 2, 0, 0, 0, 0, 1})
+	udhcpc.Options.AddRaw(dhcp4.OptionMaximumDHCPMessageSize, []byte{0x02, 0x40})
+	udhcpc.Options.AddRaw(dhcp4.OptionVendorClassIdentifier, []byte("udhcp 1.30.1"))
+	udhcpc.Options.AddRaw(dhcp4.OptionHostName, []byte("node1"))
+	udhcpc.Options.AddRaw(dhcp4.OptionParameterRequestList, []byte{1, 3, 6, 12, 15, 28, 42})
+
+	for _, tt := range []struct {
+		desc   string
+		req    *dhcp4.Packet
+		want   Device
+		wantOK bool
+	}{
+		{
+			desc:   "Windows 10",
+			req:    request("MSFT 5.0", 1, 3, 6, 15, 31, 33, 43, 44, 46, 47, 119, 121, 249, 252),
+			want:   Device{Name: "Windows 10", Class: "Windows"},
+			wantOK: true,
+		},
+		{
+			desc:   "other Windows",
+			req:    request("MSFT 5.0", 1, 3, 6, 15),
+			want:   Device{Name: "Windows", Class: "Windows"},
+			wantOK: true,
+		},
+		{
+			desc:   "udhcpc",
+			req:    udhcpc,
+			want:   Device{Name: "udhcpc", Class: "Linux"},
+			wantOK: true,
+		},
+		{
+			desc:   "PXE",
+			req:    request("PXEClient:Arch:00007:UNDI:003016", 1, 3, 43, 60, 66, 67),
+			want:   Device{Name: "PXE ROM", Class: "Network Boot"},
+			wantOK: true,
+		},
+		{
+			desc: "unknown",
+			req:  request("", 1, 3, 6, 12, 15, 28, 42),
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, ok := db.Lookup(New(tt.req))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Lookup() = %v, %t, want %v, %t", got, ok, tt.want, tt.wantOK)
+			}
+			if got := IsClass(db, tt.want.Class)(tt.req); got != tt.wantOK {
+				t.Errorf("IsClass(%q) = %t, want %t", tt.want.Class, got, tt.wantOK)
+			}
+			if got := IsDevice(db, tt.want.Name)(tt.req); got != tt.wantOK {
+				t.Errorf("IsDevice(%q) = %t, want %t", tt.want.Name, got, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestReadSignaturesBadPattern(t *testing.T) {
+	if _, err := ReadSignatures(strings.NewReader(`[{"vendor": "[", "device": {"name": "x"}}]`)); err == nil {
+		t.Error("ReadSignatures accepted a malformed vendor class pattern")
+	}
+}
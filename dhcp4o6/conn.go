@@ -0,0 +1,75 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4o6
+
+import (
+	"net"
+)
+
+// AllServers is the All_DHCP_Relay_Agents_and_Servers multicast address on
+// the DHCPv6 server port, where DHCPv4-queries are sent unless the client
+// learned the addresses of DHCP 4o6 servers (RFC 7341, Section 5).
+//
+// It is link-local; the interface must be given as its zone.
+var AllServers = &net.UDPAddr{IP: net.ParseIP("ff02::1:2"), Port: 547}
+
+// Conn is a net.PacketConn sending DHCPv4 messages over DHCPv6, so that a
+// DHCP client can use a DHCPv6 socket as its connection.
+//
+// DHCPv4 messages written to a Conn are sent as DHCPv4-queries to its server
+// address, and the DHCPv4 messages of DHCPv4-responses received are read
+// from it. All other DHCPv6 messages are dropped.
+type Conn struct {
+	net.PacketConn
+
+	server net.Addr
+}
+
+// NewConn returns a Conn sending DHCPv4-queries on conn, a DHCPv6 client
+// socket, to server, such as AllServers.
+func NewConn(conn net.PacketConn, server net.Addr) *Conn {
+	return &Conn{
+		PacketConn: conn,
+		server:     server,
+	}
+}
+
+// WriteTo implements net.PacketConn.WriteTo.
+//
+// b is sent to the Conn's server. addr, the IPv4 destination of b, only
+// decides whether the unicast flag of the query is set.
+func (c *Conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	var flags uint32
+	if u, ok := addr.(*net.UDPAddr); ok && !u.IP.Equal(net.IPv4bcast) && !u.IP.IsUnspecified() && u.IP != nil {
+		flags |= FlagUnicast
+	}
+	q, err := appendMessage(nil, MessageTypeDHCPv4Query, flags, b)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.PacketConn.WriteTo(q, c.server); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// ReadFrom implements net.PacketConn.ReadFrom.
+//
+// It returns the DHCPv4 message of the next DHCPv4-response received, and
+// the DHCPv6 address of the server or relay agent that sent it.
+func (c *Conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	buf := make([]byte, headerLen+4+len(b))
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		_, v4, err := message(buf[:n], MessageTypeDHCPv4Response)
+		if err != nil {
+			continue
+		}
+		return copy(b, v4), addr, nil
+	}
+}
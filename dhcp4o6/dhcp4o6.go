@@ -0,0 +1,137 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dhcp4o6 carries DHCPv4 messages over DHCPv6, as defined by RFC
+// 7341, for clients on IPv6-only networks that still need IPv4
+// configuration, such as the address of a softwire.
+//
+// A DHCPv4 message is sent as the DHCPv4 Message option of a DHCPv4-query
+// DHCPv6 message, without UDP or IPv4 headers, and answered by a
+// DHCPv4-response. Conn does this for a DHCP client:
+//
+//	v6, err := net.ListenPacket("udp6", "[::]:546")
+//	...
+//	conn := dhcp4o6.NewConn(v6, dhcp4o6.AllServers)
+//	client, err := dhcp4client.New(iface, dhcp4client.WithConn(conn))
+package dhcp4o6
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/u-root/dhcp4"
+)
+
+// DHCPv6 message types defined by RFC 7341, Section 6.
+const (
+	MessageTypeDHCPv4Query    = 20
+	MessageTypeDHCPv4Response = 21
+)
+
+// OptionDHCPv4Message is the DHCPv6 option carrying a DHCPv4 message, as
+// defined by RFC 7341, Section 7.1.
+const OptionDHCPv4Message = 87
+
+// FlagUnicast is set in the flags of a DHCPv4-query whose DHCPv4 message
+// would have been unicast over IPv4 (RFC 7341, Section 6.1).
+const FlagUnicast = 0x800000
+
+// headerLen is the length of the message type and flags of a DHCPv4-query
+// or DHCPv4-response.
+const headerLen = 4
+
+var (
+	// ErrWrongMessageType is returned for DHCPv6 messages of another
+	// type than expected.
+	ErrWrongMessageType = errors.New("not a DHCPv4-query or DHCPv4-response of the expected type")
+
+	// ErrNoMessage is returned for DHCPv6 messages without a DHCPv4
+	// Message option.
+	ErrNoMessage = errors.New("no DHCPv4 Message option")
+)
+
+// AppendQuery appends a DHCPv4-query carrying the DHCPv4 message p, with the
+// given flags, to dst and returns the extended slice.
+func AppendQuery(dst []byte, p *dhcp4.Packet, flags uint32) ([]byte, error) {
+	v4, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return appendMessage(dst, MessageTypeDHCPv4Query, flags, v4)
+}
+
+// AppendResponse appends a DHCPv4-response carrying the DHCPv4 message p to
+// dst and returns the extended slice, for servers answering DHCPv4-queries.
+func AppendResponse(dst []byte, p *dhcp4.Packet) ([]byte, error) {
+	v4, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return appendMessage(dst, MessageTypeDHCPv4Response, 0, v4)
+}
+
+// appendMessage appends a message of type mt with flags carrying the DHCPv4
+// message v4.
+func appendMessage(dst []byte, mt uint8, flags uint32, v4 []byte) ([]byte, error) {
+	if len(v4) > math.MaxUint16 {
+		return nil, fmt.Errorf("DHCPv4 message of %d bytes does not fit in a DHCPv6 option", len(v4))
+	}
+	var h [headerLen + 4]byte
+	binary.BigEndian.PutUint32(h[:], uint32(mt)<<24|flags&0xffffff)
+	binary.BigEndian.PutUint16(h[4:], OptionDHCPv4Message)
+	binary.BigEndian.PutUint16(h[6:], uint16(len(v4)))
+	dst = append(dst, h[:]...)
+	return append(dst, v4...), nil
+}
+
+// message returns the flags of the DHCPv6 message b of type mt and the
+// DHCPv4 message it carries, without copying it.
+func message(b []byte, mt uint8) (uint32, []byte, error) {
+	if len(b) < headerLen || b[0] != mt {
+		return 0, nil, ErrWrongMessageType
+	}
+	flags := binary.BigEndian.Uint32(b) & 0xffffff
+	opts := b[headerLen:]
+	for len(opts) >= 4 {
+		code := binary.BigEndian.Uint16(opts)
+		n := int(binary.BigEndian.Uint16(opts[2:]))
+		if len(opts) < 4+n {
+			break
+		}
+		if code == OptionDHCPv4Message {
+			return flags, opts[4 : 4+n], nil
+		}
+		opts = opts[4+n:]
+	}
+	return 0, nil, ErrNoMessage
+}
+
+// ParseQuery returns the DHCPv4 message carried by the DHCPv4-query b and
+// the query's flags.
+func ParseQuery(b []byte) (*dhcp4.Packet, uint32, error) {
+	flags, v4, err := message(b, MessageTypeDHCPv4Query)
+	if err != nil {
+		return nil, 0, err
+	}
+	p := &dhcp4.Packet{}
+	if err := p.UnmarshalBinary(v4); err != nil {
+		return nil, 0, err
+	}
+	return p, flags, nil
+}
+
+// ParseResponse returns the DHCPv4 message carried by the DHCPv4-response b.
+func ParseResponse(b []byte) (*dhcp4.Packet, error) {
+	_, v4, err := message(b, MessageTypeDHCPv4Response)
+	if err != nil {
+		return nil, err
+	}
+	p := &dhcp4.Packet{}
+	if err := p.UnmarshalBinary(v4); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4o6
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4client"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+func TestQueryResponse(t *testing.T) {
+	p := dhcp4.NewPacket(dhcp4.BootRequest)
+	p.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	p.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPDiscover)
+	v4, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := AppendQuery(nil, p, FlagUnicast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{MessageTypeDHCPv4Query, 0x80, 0, 0, 0, OptionDHCPv4Message, byte(len(v4) >> 8), byte(len(v4))}, v4...)
+	if !bytes.Equal(q, want) {
+		t.Errorf("AppendQuery() =\n%x\nwant\n%x", q, want)
+	}
+	got, flags, err := ParseQuery(q)
+	if err != nil || flags != FlagUnicast || !got.Equal(p) {
+		t.Errorf("ParseQuery() = %v, %#x, %v, want %v, %#x", got, flags, err, p, FlagUnicast)
+	}
+	if _, err := ParseResponse(q); err != ErrWrongMessageType {
+		t.Errorf("ParseResponse(query) = %v, want %v", err, ErrWrongMessageType)
+	}
+
+	r, err := AppendResponse(nil, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Other DHCPv6 options may come first.
+	r = append(r[:headerLen:headerLen], append([]byte{0, 1, 0, 2, 0xab, 0xcd}, r[headerLen:]...)...)
+	if got, err := ParseResponse(r); err != nil || !got.Equal(p) {
+		t.Errorf("ParseResponse() = %v, %v, want %v", got, err, p)
+	}
+	if _, err := ParseResponse(r[:headerLen+6]); err != ErrNoMessage {
+		t.Errorf("ParseResponse(no message) = %v, want %v", err, ErrNoMessage)
+	}
+}
+
+// serve answers DHCPv4-queries on conn using a dhcp4test.Server until conn
+// is closed.
+func serve(conn net.PacketConn) {
+	v4srv, v4conn := dhcp4test.Start(dhcp4test.Config{})
+	defer v4srv.Close()
+
+	b := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		q, _, err := ParseQuery(b[:n])
+		if err != nil {
+			continue
+		}
+		qb, _ := q.MarshalBinary()
+		v4conn.WriteTo(qb, dhcp4client.DefaultServers)
+		v4conn.SetReadDeadline(time.Now().Add(time.Second))
+		m, _, err := v4conn.ReadFrom(b)
+		if err != nil {
+			continue
+		}
+		reply := &dhcp4.Packet{}
+		if err := reply.UnmarshalBinary(b[:m]); err != nil {
+			continue
+		}
+		r, _ := AppendResponse(nil, reply)
+		conn.WriteTo(r, addr)
+	}
+}
+
+func TestClientOverConn(t *testing.T) {
+	client, server := dhcp4test.NewConnPair(
+		&net.UDPAddr{IP: net.ParseIP("fe80::2"), Port: 546},
+		&net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 547},
+	)
+	go serve(server)
+	defer server.Close()
+
+	mc, err := dhcp4client.New(&dhcp4client.Interface{Name: "dummy0", HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1}},
+		dhcp4client.WithConn(NewConn(client, AllServers)), dhcp4client.WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+
+	ack, err := mc.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if !ack.YIAddr.Equal(dhcp4test.DefaultYourIP) {
+		t.Errorf("Request() got address %v, want %v", ack.YIAddr, dhcp4test.DefaultYourIP)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4opts
+
+import (
+	"math"
+	"time"
+
+	"github.com/u-root/dhcp4"
+)
+
+// InfiniteLease is the lease duration of leases that never expire, which
+// servers send as a lease time of 0xffffffff seconds (RFC 2131, Section 3.3).
+const InfiniteLease = time.Duration(math.MaxInt64)
+
+// LeaseTimes are the durations of a lease, measured from when the ACK
+// granting it was received.
+type LeaseTimes struct {
+	// Lease is how long the lease lasts, or InfiniteLease.
+	Lease time.Duration
+
+	// Renewal is when the client starts renewing the lease with the
+	// server that granted it (T1).
+	Renewal time.Duration
+
+	// Rebinding is when the client starts asking any server to extend
+	// the lease (T2).
+	Rebinding time.Duration
+}
+
+// GetLeaseTimes returns the lease times in `o`, an ACK's options.
+//
+// If the renewal (T1) or rebinding (T2) time is missing, or they are not
+// ordered T1 < T2 < lease as RFC 2131, Section 4.4.5 requires, the defaults
+// of 0.5 and 0.875 times the lease duration are used instead: T2 is taken
+// from o if it is less than the lease duration, then T1 if it is less than
+// that T2. Both are InfiniteLease for infinite leases.
+//
+// It returns ErrOptionNotPresent if `o` has no lease time.
+func GetLeaseTimes(o dhcp4.Options) (LeaseTimes, error) {
+	lease, err := getSeconds(dhcp4.OptionIPAddressLeaseTime, o)
+	if err != nil {
+		return LeaseTimes{}, err
+	}
+	if lease == InfiniteLease {
+		return LeaseTimes{Lease: lease, Renewal: lease, Rebinding: lease}, nil
+	}
+
+	t := LeaseTimes{
+		Lease:     lease,
+		Renewal:   lease / 2,
+		Rebinding: lease * 7 / 8,
+	}
+	t1, err1 := getSeconds(dhcp4.OptionRenewalTimeValue, o)
+	t2, err2 := getSeconds(dhcp4.OptionRebindingTimeValue, o)
+	if err2 == nil && t2 < lease {
+		t.Rebinding = t2
+	}
+	if err1 == nil && t1 < t.Rebinding {
+		t.Renewal = t1
+	} else if t.Renewal >= t.Rebinding {
+		// T2 comes before the default T1, so neither is usable.
+		t.Rebinding = lease * 7 / 8
+	}
+	return t, nil
+}
+
+// getSeconds returns the option code of `o`, a 32-bit number of seconds, as a
+// duration. 0xffffffff seconds is InfiniteLease.
+func getSeconds(code dhcp4.OptionCode, o dhcp4.Options) (time.Duration, error) {
+	v := o.Get(code)
+	if v == nil {
+		return 0, dhcp4.ErrOptionNotPresent
+	}
+	var d Duration
+	err := (&d).UnmarshalBinary(v)
+	return time.Duration(d), err
+}
+
+// LeaseDeadlines are the absolute times at which a lease must be renewed,
+// rebound, and given up.
+//
+// Deadlines of infinite leases are the zero time.
+type LeaseDeadlines struct {
+	Renewal   time.Time
+	Rebinding time.Time
+	Expiry    time.Time
+}
+
+// Deadlines returns the deadlines of a lease whose ACK was received at
+// received.
+func (t LeaseTimes) Deadlines(received time.Time) LeaseDeadlines {
+	if t.Lease == InfiniteLease {
+		return LeaseDeadlines{}
+	}
+	return LeaseDeadlines{
+		Renewal:   received.Add(t.Renewal),
+		Rebinding: received.Add(t.Rebinding),
+		Expiry:    received.Add(t.Lease),
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4opts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+)
+
+func seconds(s uint32) []byte {
+	return []byte{byte(s >> 24), byte(s >> 16), byte(s >> 8), byte(s)}
+}
+
+func TestGetLeaseTimes(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		opts    []dhcp4.Option
+		want    LeaseTimes
+		wantErr error
+	}{
+		{
+			desc:    "no lease time",
+			wantErr: dhcp4.ErrOptionNotPresent,
+		},
+		{
+			desc: "defaults",
+			opts: []dhcp4.Option{{Code: dhcp4.OptionIPAddressLeaseTime, Value: seconds(3200)}},
+			want: LeaseTimes{Lease: 3200 * time.Second, Renewal: 1600 * time.Second, Rebinding: 2800 * time.Second},
+		},
+		{
+			desc: "explicit",
+			opts: []dhcp4.Option{
+				{Code: dhcp4.OptionIPAddressLeaseTime, Value: seconds(3600)},
+				{Code: dhcp4.OptionRenewalTimeValue, Value: seconds(600)},
+				{Code: dhcp4.OptionRebindingTimeValue, Value: seconds(1200)},
+			},
+			want: LeaseTimes{Lease: time.Hour, Renewal: 10 * time.Minute, Rebinding: 20 * time.Minute},
+		},
+		{
+			desc: "T2 past lease",
+			opts: []dhcp4.Option{
+				{Code: dhcp4.OptionIPAddressLeaseTime, Value: seconds(3200)},
+				{Code: dhcp4.OptionRenewalTimeValue, Value: seconds(600)},
+				{Code: dhcp4.OptionRebindingTimeValue, Value: seconds(4000)},
+			},
+			want: LeaseTimes{Lease: 3200 * time.Second, Renewal: 600 * time.Second, Rebinding: 2800 * time.Second},
+		},
+		{
+			desc: "T1 past T2",
+			opts: []dhcp4.Option{
+				{Code: dhcp4.OptionIPAddressLeaseTime, Value: seconds(3200)},
+				{Code: dhcp4.OptionRenewalTimeValue, Value: seconds(3000)},
+			},
+			want: LeaseTimes{Lease: 3200 * time.Second, Renewal: 1600 * time.Second, Rebinding: 2800 * time.Second},
+		},
+		{
+			desc: "T1 past default T2",
+			opts: []dhcp4.Option{
+				{Code: dhcp4.OptionIPAddressLeaseTime, Value: seconds(1000)},
+				{Code: dhcp4.OptionRenewalTimeValue, Value: seconds(900)},
+				{Code: dhcp4.OptionRebindingTimeValue, Value: seconds(950)},
+			},
+			want: LeaseTimes{Lease: 1000 * time.Second, Renewal: 900 * time.Second, Rebinding: 950 * time.Second},
+		},
+		{
+			desc: "T2 before default T1",
+			opts: []dhcp4.Option{
+				{Code: dhcp4.OptionIPAddressLeaseTime, Value: seconds(3200)},
+				{Code: dhcp4.OptionRebindingTimeValue, Value: seconds(1000)},
+			},
+			want: LeaseTimes{Lease: 3200 * time.Second, Renewal: 1600 * time.Second, Rebinding: 2800 * time.Second},
+		},
+		{
+			desc: "infinite",
+			opts: []dhcp4.Option{{Code: dhcp4.OptionIPAddressLeaseTime, Value: seconds(0xffffffff)}},
+			want: LeaseTimes{Lease: InfiniteLease, Renewal: InfiniteLease, Rebinding: InfiniteLease},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := GetLeaseTimes(dhcp4.NewOptions(tt.opts...))
+			if err != tt.wantErr {
+				t.Fatalf("GetLeaseTimes() = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetLeaseTimes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaseDeadlines(t *testing.T) {
+	received := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	lt := LeaseTimes{Lease: time.Hour, Renewal: 30 * time.Minute, Rebinding: 50 * time.Minute}
+	want := LeaseDeadlines{
+		Renewal:   received.Add(30 * time.Minute),
+		Rebinding: received.Add(50 * time.Minute),
+		Expiry:    received.Add(time.Hour),
+	}
+	if got := lt.Deadlines(received); got != want {
+		t.Errorf("Deadlines() = %+v, want %+v", got, want)
+	}
+
+	inf := LeaseTimes{Lease: InfiniteLease, Renewal: InfiniteLease, Rebinding: InfiniteLease}
+	if got := inf.Deadlines(received); got != (LeaseDeadlines{}) {
+		t.Errorf("Deadlines() of infinite lease = %+v, want zero deadlines", got)
+	}
+}
@@ -8,6 +8,9 @@
 package dhcp4opts
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/u-root/dhcp4"
 )
 
@@ -29,6 +32,19 @@ func GetSubnetMask(o dhcp4.Options) SubnetMask {
 	return s
 }
 
+// GetTimeOffset returns the offset of the client's subnet from UTC in `o`.
+//
+// The time offset option is defined by RFC 2132, Section 3.4.
+func GetTimeOffset(o dhcp4.Options) (time.Duration, error) {
+	v := o.Get(dhcp4.OptionTimeOffset)
+	if v == nil {
+		return 0, dhcp4.ErrOptionNotPresent
+	}
+	var t TimeOffset
+	err := (&t).UnmarshalBinary(v)
+	return time.Duration(t), err
+}
+
 // GetRouters returns the list of router IPs in `o`.
 //
 // This returns nil if the option is not present or did not contain a valid
@@ -39,7 +55,8 @@ func GetRouters(o dhcp4.Options) IPs {
 	return GetIPs(dhcp4.OptionRouters, o)
 }
 
-// GetTimeServers returns the list of time server IPs in `o`.
+// GetTimeServers returns the list of RFC 868 time server IPs in `o`, in
+// order of preference.
 //
 // This returns nil if the option is not present or did not contain a valid
 // value.
@@ -182,6 +199,31 @@ func GetExtensionsPath(o dhcp4.Options) string {
 	return GetString(dhcp4.OptionExtensionsPath, o)
 }
 
+// MinInterfaceMTU is the smallest interface MTU allowed by RFC 2132, Section
+// 5.1.
+const MinInterfaceMTU = 68
+
+// GetInterfaceMTU returns the interface MTU of `o`.
+//
+// It returns ErrOptionNotPresent if `o` has no interface MTU, and an error
+// for MTUs below MinInterfaceMTU.
+//
+// The interface MTU option is defined by RFC 2132, Section 5.1.
+func GetInterfaceMTU(o dhcp4.Options) (uint16, error) {
+	v := o.Get(dhcp4.OptionInterfaceMTU)
+	if v == nil {
+		return 0, dhcp4.ErrOptionNotPresent
+	}
+	var u Uint16
+	if err := (&u).UnmarshalBinary(v); err != nil {
+		return 0, err
+	}
+	if u < MinInterfaceMTU {
+		return 0, fmt.Errorf("interface MTU %d is below the minimum of %d", u, MinInterfaceMTU)
+	}
+	return uint16(u), nil
+}
+
 // GetBroadcastAddress returns the client's subnet broadcast address of `o`.
 //
 // This returns nil if the option is not present or did not contain a valid
@@ -212,7 +254,8 @@ func GetNetworkInformationServers(o dhcp4.Options) IPs {
 	return GetIPs(dhcp4.OptionNetworkInformationServers, o)
 }
 
-// GetNetworkTimeProtocolServers returns the list of NTP server IPs in `o`.
+// GetNetworkTimeProtocolServers returns the list of NTP server IPs in `o`,
+// in order of preference.
 //
 // This returns nil if the option is not present or did not contain a valid
 // value.
@@ -337,3 +380,192 @@ func GetMaximumDHCPMessageSize(o dhcp4.Options) (uint16, error) {
 	var u Uint16
 	return uint16(u), (&u).UnmarshalBinary(v)
 }
+
+// GetUserClass returns the user classes in `o`.
+//
+// This returns nil if the option is not present or did not contain a valid
+// value.
+//
+// The user class option is defined by RFC 3004.
+func GetUserClass(o dhcp4.Options) UserClass {
+	v := o.Get(dhcp4.OptionUserClass)
+	if v == nil {
+		return nil
+	}
+	var u UserClass
+	if err := (&u).UnmarshalBinary(v); err != nil {
+		return nil
+	}
+	return u
+}
+
+// GetRelayAgentInformation returns the relay agent information in `o`.
+//
+// This returns nil if the option is not present or did not contain a valid
+// value.
+//
+// The relay agent information option is defined by RFC 3046.
+func GetRelayAgentInformation(o dhcp4.Options) RelayAgentInformation {
+	v := o.Get(dhcp4.OptionRelayAgentInformation)
+	if v == nil {
+		return nil
+	}
+	var r RelayAgentInformation
+	if err := (&r).UnmarshalBinary(v); err != nil {
+		return nil
+	}
+	return r
+}
+
+// GetClientLastTransactionTime returns how long ago the server last heard
+// from the client of a binding, according to `o`, and whether the option is
+// present and valid.
+//
+// The client last transaction time option is defined by RFC 4388, Section
+// 6.1.
+func GetClientLastTransactionTime(o dhcp4.Options) (time.Duration, bool) {
+	v := o.Get(dhcp4.OptionClientLastTransactionTime)
+	if v == nil {
+		return 0, false
+	}
+	var d Duration
+	if err := (&d).UnmarshalBinary(v); err != nil {
+		return 0, false
+	}
+	return time.Duration(d), true
+}
+
+// GetAssociatedIPs returns the addresses bound to the client a leasequery
+// reply in `o` is about.
+//
+// This returns nil if the option is not present or did not contain a valid
+// value.
+//
+// The associated IP option is defined by RFC 4388, Section 6.1.
+func GetAssociatedIPs(o dhcp4.Options) IPs {
+	return GetIPs(dhcp4.OptionAssociatedIP, o)
+}
+
+// GetSubnetSelection returns the subnet a client asked to be given an address
+// from in `o`.
+//
+// This returns nil if the option is not present or did not contain a valid
+// value.
+//
+// The subnet selection option is defined by RFC 3011.
+func GetSubnetSelection(o dhcp4.Options) IP {
+	return GetIP(dhcp4.OptionSubnetSelection, o)
+}
+
+// GetLinkSelection returns the link selection sub-option of the relay agent
+// information in `o`, naming the subnet the client's link belongs to.
+//
+// This returns nil if the sub-option is not present or did not contain a
+// valid value.
+//
+// The link selection sub-option is defined by RFC 3527.
+func GetLinkSelection(o dhcp4.Options) IP {
+	v := GetRelayAgentInformation(o).Get(AgentLinkSelection)
+	if v == nil {
+		return nil
+	}
+	var ip IP
+	if err := (&ip).UnmarshalBinary(v); err != nil || len(v) != 4 {
+		return nil
+	}
+	return ip
+}
+
+// GetMUDURL returns the Manufacturer Usage Description URL in `o`.
+//
+// This returns "" if the option is not present or did not contain a valid
+// value.
+//
+// The MUD URL option is defined by RFC 8520, Section 10.
+func GetMUDURL(o dhcp4.Options) MUDURL {
+	v := o.Get(dhcp4.OptionMUDURL)
+	if v == nil {
+		return ""
+	}
+	var m MUDURL
+	if err := (&m).UnmarshalBinary(v); err != nil {
+		return ""
+	}
+	return m
+}
+
+// GetCaptivePortal returns the captive portal API URI in `o`.
+//
+// This returns "" if the option is not present or did not contain a valid
+// value, and CaptivePortalUnrestricted if the network has no captive portal.
+//
+// The captive portal option is defined by RFC 8910, Section 2.1.
+func GetCaptivePortal(o dhcp4.Options) CaptivePortal {
+	v := o.Get(dhcp4.OptionCaptivePortal)
+	if v == nil {
+		return ""
+	}
+	var c CaptivePortal
+	if err := (&c).UnmarshalBinary(v); err != nil {
+		return ""
+	}
+	return c
+}
+
+// GetIPv6OnlyPreferred returns how long an IPv6-only capable client should
+// stop using DHCPv4, according to `o`, and whether the option is present.
+//
+// Wait times shorter than MinIPv6OnlyWait are raised to it, as required by
+// RFC 8925, Section 3.2.
+//
+// The IPv6-Only Preferred option is defined by RFC 8925.
+func GetIPv6OnlyPreferred(o dhcp4.Options) (time.Duration, bool) {
+	v := o.Get(dhcp4.OptionIPv6OnlyPreferred)
+	if v == nil {
+		return 0, false
+	}
+	var w IPv6OnlyWait
+	if err := (&w).UnmarshalBinary(v); err != nil {
+		return 0, false
+	}
+	if time.Duration(w) < MinIPv6OnlyWait {
+		return MinIPv6OnlyWait, true
+	}
+	return time.Duration(w), true
+}
+
+// GetDomainSearch returns the domain search list in `o`.
+//
+// This returns nil if the option is not present or did not contain a valid
+// value.
+//
+// The domain search option is defined by RFC 3397.
+func GetDomainSearch(o dhcp4.Options) DomainSearch {
+	v := o.Get(dhcp4.OptionDomainSearch)
+	if v == nil {
+		return nil
+	}
+	var d DomainSearch
+	if err := (&d).UnmarshalBinary(v); err != nil {
+		return nil
+	}
+	return d
+}
+
+// GetClasslessStaticRoutes returns the classless static routes in `o`.
+//
+// This returns nil if the option is not present or did not contain a valid
+// value.
+//
+// The classless static route option is defined by RFC 3442.
+func GetClasslessStaticRoutes(o dhcp4.Options) ClasslessRoutes {
+	v := o.Get(dhcp4.OptionClasslessStaticRoute)
+	if v == nil {
+		return nil
+	}
+	var c ClasslessRoutes
+	if err := (&c).UnmarshalBinary(v); err != nil {
+		return nil
+	}
+	return c
+}
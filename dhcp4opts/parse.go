@@ -0,0 +1,345 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4opts
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/dhcp4"
+)
+
+// valueParser parses the textual form of an option value.
+type valueParser func(s string) ([]byte, error)
+
+// valueParsers parse the options whose value is not an opaque string.
+var valueParsers = map[dhcp4.OptionCode]valueParser{
+	dhcp4.OptionSubnetMask:                                 parseIP,
+	dhcp4.OptionTimeOffset:                                 parseInt32,
+	dhcp4.OptionRouters:                                    parseIPs,
+	dhcp4.OptionTimeServers:                                parseIPs,
+	dhcp4.OptionNameServers:                                parseIPs,
+	dhcp4.OptionDomainNameServers:                          parseIPs,
+	dhcp4.OptionLogServers:                                 parseIPs,
+	dhcp4.OptionCookieServers:                              parseIPs,
+	dhcp4.OptionLPRServers:                                 parseIPs,
+	dhcp4.OptionImpressServers:                             parseIPs,
+	dhcp4.OptionResourceLocationServers:                    parseIPs,
+	dhcp4.OptionBootFileSize:                               parseUint(16),
+	dhcp4.OptionSwapServer:                                 parseIP,
+	dhcp4.OptionIPForwardingEnableDisable:                  parseBool,
+	dhcp4.OptionNonLocalSourceRoutingEnableDisable:         parseBool,
+	dhcp4.OptionPolicyFilter:                               parseIPPairs,
+	dhcp4.OptionMaximumDatagramReassemblySize:              parseUint(16),
+	dhcp4.OptionDefaultIPTimeToLive:                        parseUint(8),
+	dhcp4.OptionPathMTUAgingTimeout:                        parseUint(32),
+	dhcp4.OptionPathMTUPlateauTable:                        parseList(parseUint(16)),
+	dhcp4.OptionInterfaceMTU:                               parseUint(16),
+	dhcp4.OptionAllSubnetsAreLocal:                         parseBool,
+	dhcp4.OptionBroadcastAddress:                           parseIP,
+	dhcp4.OptionPerformMaskDiscovery:                       parseBool,
+	dhcp4.OptionMaskSupplier:                               parseBool,
+	dhcp4.OptionPerformRouterDiscovery:                     parseBool,
+	dhcp4.OptionRouterSolicitationAddress:                  parseIP,
+	dhcp4.OptionStaticRoute:                                parseIPPairs,
+	dhcp4.OptionTrailerEncapsulation:                       parseBool,
+	dhcp4.OptionARPCacheTimeout:                            parseUint(32),
+	dhcp4.OptionEthernetEncapsulation:                      parseBool,
+	dhcp4.OptionTCPDefaultTTL:                              parseUint(8),
+	dhcp4.OptionTCPKeepaliveInterval:                       parseUint(32),
+	dhcp4.OptionTCPKeepaliveGarbage:                        parseBool,
+	dhcp4.OptionNetworkInformationServers:                  parseIPs,
+	dhcp4.OptionNetworkTimeProtocolServers:                 parseIPs,
+	dhcp4.OptionNetBIOSOverTCPIPNameServer:                 parseIPs,
+	dhcp4.OptionNetBIOSOverTCPIPDatagramDistributionServer: parseIPs,
+	dhcp4.OptionNetBIOSOverTCPIPNodeType:                   parseUint(8),
+	dhcp4.OptionXWindowSystemFontServer:                    parseIPs,
+	dhcp4.OptionXWindowSystemDisplayManager:                parseIPs,
+
+	dhcp4.OptionRequestedIPAddress:     parseIP,
+	dhcp4.OptionIPAddressLeaseTime:     parseUint(32),
+	dhcp4.OptionOverload:               parseUint(8),
+	dhcp4.OptionDHCPMessageType:        parseMessageType,
+	dhcp4.OptionServerIdentifier:       parseIP,
+	dhcp4.OptionParameterRequestList:   parseList(parseUint(8)),
+	dhcp4.OptionMaximumDHCPMessageSize: parseUint(16),
+	dhcp4.OptionRenewalTimeValue:       parseUint(32),
+	dhcp4.OptionRebindingTimeValue:     parseUint(32),
+	dhcp4.OptionClientIdentifier:       parseHex,
+
+	dhcp4.OptionUserClass:                 parseUserClass,
+	dhcp4.OptionRelayAgentInformation:     parseHex,
+	dhcp4.OptionClientLastTransactionTime: parseUint(32),
+	dhcp4.OptionAssociatedIP:              parseIPs,
+	dhcp4.OptionIPv6OnlyPreferred:         parseUint(32),
+	dhcp4.OptionSubnetSelection:           parseIP,
+	dhcp4.OptionDomainSearch:              parseDomainSearch,
+	dhcp4.OptionClasslessStaticRoute:      parseList(parseRoute),
+}
+
+// ParseOptionValue parses the textual form of a value of option code into its
+// wire format, in the notation of ISC dhcpd.conf:
+//
+//   - addresses in dotted decimal, lists of them separated by commas, and
+//     pairs of them, as in static routes, separated by spaces:
+//     "192.168.1.1, 192.168.1.2";
+//   - classless static routes as a prefix and router separated by a space:
+//     "10.0.0.0/8 192.168.1.1, 0.0.0.0/0 192.168.1.254";
+//   - integers in decimal, such as "3600", and flags as "true", "false",
+//     "on", or "off";
+//   - message types by number or name, such as "DHCPOFFER";
+//   - strings, quoted in Go syntax or, for options whose value is text,
+//     bare: "\"example.com\"" or "example.com", and user classes as lists
+//     of them;
+//   - and for any option, raw bytes in colon-separated hex: "01:02:0a".
+//
+// Options this package does not know the type of take only quoted strings and
+// hex. The parsed value must be valid according to dhcp4.ValidateOption.
+func ParseOptionValue(code dhcp4.OptionCode, s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	v, err := parseOptionValue(code, s)
+	if err != nil {
+		return nil, fmt.Errorf("option %d: %v", code, err)
+	}
+	if err := dhcp4.ValidateOption(code, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func parseOptionValue(code dhcp4.OptionCode, s string) ([]byte, error) {
+	if isHex(s) {
+		return parseHex(s)
+	}
+	if parse, ok := valueParsers[code]; ok {
+		return parse(s)
+	}
+	if strings.HasPrefix(s, `"`) {
+		u, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", s)
+		}
+		return []byte(u), nil
+	}
+	if _, ok := textOptions[code]; ok {
+		return []byte(s), nil
+	}
+	return nil, fmt.Errorf("%q is neither a quoted string nor colon-separated hex", s)
+}
+
+// textOptions are the options whose value may be given as a bare string.
+var textOptions = map[dhcp4.OptionCode]struct{}{
+	dhcp4.OptionHostName:                        {},
+	dhcp4.OptionMeritDumpFile:                   {},
+	dhcp4.OptionDomainName:                      {},
+	dhcp4.OptionRootPath:                        {},
+	dhcp4.OptionExtensionsPath:                  {},
+	dhcp4.OptionNetworkInformationServiceDomain: {},
+	dhcp4.OptionNetBIOSOverTCPIPScope:           {},
+	dhcp4.OptionMessage:                         {},
+	dhcp4.OptionVendorClassIdentifier:           {},
+	dhcp4.OptionTFTPServerName:                  {},
+	dhcp4.OptionBootFileName:                    {},
+	dhcp4.OptionCaptivePortal:                   {},
+	dhcp4.OptionMUDURL:                          {},
+}
+
+// isHex reports whether s is in colon-separated hex notation, with at least
+// two bytes so as not to be mistaken for a decimal number.
+func isHex(s string) bool {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, p := range parts {
+		if len(p) == 0 || len(p) > 2 {
+			return false
+		}
+		if _, err := strconv.ParseUint(p, 16, 8); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHex parses colon-separated hex, where each byte may be one or two
+// digits.
+func parseHex(s string) ([]byte, error) {
+	var b []byte
+	for _, p := range strings.Split(s, ":") {
+		if len(p) == 1 {
+			p = "0" + p
+		}
+		x, err := hex.DecodeString(p)
+		if err != nil || len(x) != 1 {
+			return nil, fmt.Errorf("invalid hex %q", s)
+		}
+		b = append(b, x...)
+	}
+	return b, nil
+}
+
+// splitList splits a comma-separated list, trimming space around items.
+func splitList(s string) []string {
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// parseList returns a parser of comma-separated lists of items parsed by
+// parse.
+func parseList(parse valueParser) valueParser {
+	return func(s string) ([]byte, error) {
+		var b []byte
+		for _, item := range splitList(s) {
+			v, err := parse(item)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, v...)
+		}
+		return b, nil
+	}
+}
+
+func parseIP(s string) ([]byte, error) {
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IPv4 address %q", s)
+	}
+	return ip, nil
+}
+
+var parseIPs = parseList(parseIP)
+
+// parseIPPairs parses a comma-separated list of space-separated address pairs,
+// such as "10.0.0.0 255.0.0.0, 192.168.0.0 255.255.0.0".
+func parseIPPairs(s string) ([]byte, error) {
+	return parseList(func(pair string) ([]byte, error) {
+		ips := strings.Fields(pair)
+		if len(ips) != 2 {
+			return nil, fmt.Errorf("%q is not a pair of addresses", pair)
+		}
+		return parseIPs(ips[0] + "," + ips[1])
+	})(s)
+}
+
+// parseUint returns a parser of unsigned integers of the given bit size.
+func parseUint(bits int) valueParser {
+	return func(s string) ([]byte, error) {
+		n, err := strconv.ParseUint(s, 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %d-bit unsigned integer %q", bits, s)
+		}
+		return putUint(n, bits/8), nil
+	}
+}
+
+func parseInt32(s string) ([]byte, error) {
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 32-bit integer %q", s)
+	}
+	return putUint(uint64(uint32(int32(n))), 4), nil
+}
+
+// putUint returns the last size bytes of n in network byte order.
+func putUint(n uint64, size int) []byte {
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
+
+func parseBool(s string) ([]byte, error) {
+	switch strings.ToLower(s) {
+	case "true", "on", "1":
+		return []byte{1}, nil
+	case "false", "off", "0":
+		return []byte{0}, nil
+	}
+	return nil, fmt.Errorf("invalid flag %q", s)
+}
+
+var messageTypeNames = map[string]DHCPMessageType{
+	"DHCPDISCOVER": DHCPDiscover,
+	"DHCPOFFER":    DHCPOffer,
+	"DHCPREQUEST":  DHCPRequest,
+	"DHCPDECLINE":  DHCPDecline,
+	"DHCPACK":      DHCPACK,
+	"DHCPNAK":      DHCPNAK,
+	"DHCPRELEASE":  DHCPRelease,
+	"DHCPINFORM":   DHCPInform,
+
+	"DHCPFORCERENEW": DHCPForceRenew,
+
+	"DHCPLEASEQUERY":      DHCPLeaseQuery,
+	"DHCPLEASEUNASSIGNED": DHCPLeaseUnassigned,
+	"DHCPLEASEUNKNOWN":    DHCPLeaseUnknown,
+	"DHCPLEASEACTIVE":     DHCPLeaseActive,
+}
+
+func parseMessageType(s string) ([]byte, error) {
+	if t, ok := messageTypeNames[strings.ToUpper(s)]; ok {
+		return []byte{byte(t)}, nil
+	}
+	return parseUint(8)(s)
+}
+
+// parseUserClass parses a comma-separated list of classes, each quoted or
+// bare.
+func parseUserClass(s string) ([]byte, error) {
+	var u UserClass
+	for _, item := range splitList(s) {
+		if strings.HasPrefix(item, `"`) {
+			var err error
+			if item, err = strconv.Unquote(item); err != nil {
+				return nil, fmt.Errorf("invalid quoted string %s", item)
+			}
+		}
+		u = append(u, item)
+	}
+	return u.MarshalBinary()
+}
+
+// parseDomainSearch parses a comma-separated list of domains, each quoted or
+// bare.
+func parseDomainSearch(s string) ([]byte, error) {
+	var d DomainSearch
+	for _, item := range splitList(s) {
+		if strings.HasPrefix(item, `"`) {
+			var err error
+			if item, err = strconv.Unquote(item); err != nil {
+				return nil, fmt.Errorf("invalid quoted string %s", item)
+			}
+		}
+		d = append(d, item)
+	}
+	return d.MarshalBinary()
+}
+
+// parseRoute parses a classless static route such as "10.0.0.0/8 10.0.0.1".
+func parseRoute(s string) ([]byte, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("%q is not a prefix and a router", s)
+	}
+	_, dest, err := net.ParseCIDR(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	router, err := parseIP(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	return ClasslessRoutes{{Dest: dest, Router: router}}.MarshalBinary()
+}
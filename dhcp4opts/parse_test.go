@@ -0,0 +1,61 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4opts
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/u-root/dhcp4"
+)
+
+func TestParseOptionValue(t *testing.T) {
+	for _, tt := range []struct {
+		code    dhcp4.OptionCode
+		in      string
+		want    []byte
+		wantErr bool
+	}{
+		{code: dhcp4.OptionRouters, in: "192.168.1.1, 192.168.1.2", want: []byte{192, 168, 1, 1, 192, 168, 1, 2}},
+		{code: dhcp4.OptionSubnetMask, in: "255.255.255.0", want: []byte{255, 255, 255, 0}},
+		{code: dhcp4.OptionSubnetMask, in: "255.255.255.0, 255.0.0.0", wantErr: true},
+		{code: dhcp4.OptionRouters, in: "::1", wantErr: true},
+		{code: dhcp4.OptionStaticRoute, in: "10.0.0.0 10.0.0.1, 10.1.0.0 10.0.0.2", want: []byte{10, 0, 0, 0, 10, 0, 0, 1, 10, 1, 0, 0, 10, 0, 0, 2}},
+		{code: dhcp4.OptionStaticRoute, in: "10.0.0.0", wantErr: true},
+		{code: dhcp4.OptionIPAddressLeaseTime, in: "3600", want: []byte{0, 0, 0x0e, 0x10}},
+		{code: dhcp4.OptionInterfaceMTU, in: "1500", want: []byte{0x05, 0xdc}},
+		{code: dhcp4.OptionInterfaceMTU, in: "70000", wantErr: true},
+		{code: dhcp4.OptionTimeOffset, in: "-3600", want: []byte{0xff, 0xff, 0xf1, 0xf0}},
+		{code: dhcp4.OptionIPForwardingEnableDisable, in: "on", want: []byte{1}},
+		{code: dhcp4.OptionIPForwardingEnableDisable, in: "maybe", wantErr: true},
+		{code: dhcp4.OptionDHCPMessageType, in: "DHCPOFFER", want: []byte{2}},
+		{code: dhcp4.OptionDHCPMessageType, in: "5", want: []byte{5}},
+		{code: dhcp4.OptionDHCPMessageType, in: "DHCPFORCERENEW", want: []byte{9}},
+		{code: dhcp4.OptionDHCPMessageType, in: "14", wantErr: true},
+		{code: dhcp4.OptionParameterRequestList, in: "1, 3, 6", want: []byte{1, 3, 6}},
+		{code: dhcp4.OptionDomainName, in: `"example.com"`, want: []byte("example.com")},
+		{code: dhcp4.OptionDomainName, in: "example.com", want: []byte("example.com")},
+		{code: dhcp4.OptionDomainName, in: `"example.com`, wantErr: true},
+		{code: dhcp4.OptionUserClass, in: `"foo", bar`, want: []byte{3, 'f', 'o', 'o', 3, 'b', 'a', 'r'}},
+		{code: dhcp4.OptionClientIdentifier, in: "1:2:0a:ff", want: []byte{1, 2, 10, 255}},
+		{code: dhcp4.OptionRouters, in: "0a:00:00:01", want: []byte{10, 0, 0, 1}},
+		{code: 224, in: `"opaque"`, want: []byte("opaque")},
+		{code: 224, in: "de:ad", want: []byte{0xde, 0xad}},
+		{code: 224, in: "opaque", wantErr: true},
+		{code: dhcp4.OptionDomainSearch, in: "eng.example.com, example.com", want: []byte("\x03eng\x07example\x03com\x00\x07example\x03com\x00")},
+		{code: dhcp4.OptionClasslessStaticRoute, in: "10.0.0.0/8 192.168.1.1, 0.0.0.0/0 192.168.1.254", want: []byte{8, 10, 192, 168, 1, 1, 0, 192, 168, 1, 254}},
+		{code: dhcp4.OptionClasslessStaticRoute, in: "10.0.0.0/8", wantErr: true},
+		{code: dhcp4.OptionMUDURL, in: "http://example.com", wantErr: true},
+	} {
+		got, err := ParseOptionValue(tt.code, tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOptionValue(%d, %q) = %v, want error %t", tt.code, tt.in, err, tt.wantErr)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("ParseOptionValue(%d, %q) = %v, want %v", tt.code, tt.in, got, tt.want)
+		}
+	}
+}
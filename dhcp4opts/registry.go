@@ -0,0 +1,96 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4opts
+
+import (
+	"github.com/u-root/dhcp4"
+)
+
+// optionTypes are the types of the options this package implements, as
+// registered with dhcp4.RegisterOptionValue.
+var optionTypes = map[dhcp4.OptionCode]func() dhcp4.OptionValue{
+	dhcp4.OptionSubnetMask:                                 func() dhcp4.OptionValue { return new(SubnetMask) },
+	dhcp4.OptionTimeOffset:                                 func() dhcp4.OptionValue { return new(TimeOffset) },
+	dhcp4.OptionRouters:                                    newIPs,
+	dhcp4.OptionTimeServers:                                newIPs,
+	dhcp4.OptionNameServers:                                newIPs,
+	dhcp4.OptionDomainNameServers:                          newIPs,
+	dhcp4.OptionLogServers:                                 newIPs,
+	dhcp4.OptionCookieServers:                              newIPs,
+	dhcp4.OptionLPRServers:                                 newIPs,
+	dhcp4.OptionImpressServers:                             newIPs,
+	dhcp4.OptionResourceLocationServers:                    newIPs,
+	dhcp4.OptionHostName:                                   newString,
+	dhcp4.OptionBootFileSize:                               newUint16,
+	dhcp4.OptionMeritDumpFile:                              newString,
+	dhcp4.OptionDomainName:                                 newString,
+	dhcp4.OptionSwapServer:                                 newIP,
+	dhcp4.OptionRootPath:                                   newString,
+	dhcp4.OptionExtensionsPath:                             newString,
+	dhcp4.OptionIPForwardingEnableDisable:                  newBool,
+	dhcp4.OptionNonLocalSourceRoutingEnableDisable:         newBool,
+	dhcp4.OptionMaximumDatagramReassemblySize:              newUint16,
+	dhcp4.OptionDefaultIPTimeToLive:                        newUint8,
+	dhcp4.OptionPathMTUAgingTimeout:                        newDuration,
+	dhcp4.OptionInterfaceMTU:                               newUint16,
+	dhcp4.OptionAllSubnetsAreLocal:                         newBool,
+	dhcp4.OptionBroadcastAddress:                           newIP,
+	dhcp4.OptionPerformMaskDiscovery:                       newBool,
+	dhcp4.OptionMaskSupplier:                               newBool,
+	dhcp4.OptionPerformRouterDiscovery:                     newBool,
+	dhcp4.OptionRouterSolicitationAddress:                  newIP,
+	dhcp4.OptionTrailerEncapsulation:                       newBool,
+	dhcp4.OptionARPCacheTimeout:                            newDuration,
+	dhcp4.OptionEthernetEncapsulation:                      newBool,
+	dhcp4.OptionTCPDefaultTTL:                              newUint8,
+	dhcp4.OptionTCPKeepaliveInterval:                       newDuration,
+	dhcp4.OptionTCPKeepaliveGarbage:                        newBool,
+	dhcp4.OptionNetworkInformationServiceDomain:            newString,
+	dhcp4.OptionNetworkInformationServers:                  newIPs,
+	dhcp4.OptionNetworkTimeProtocolServers:                 newIPs,
+	dhcp4.OptionNetBIOSOverTCPIPNameServer:                 newIPs,
+	dhcp4.OptionNetBIOSOverTCPIPDatagramDistributionServer: newIPs,
+	dhcp4.OptionNetBIOSOverTCPIPScope:                      newString,
+	dhcp4.OptionXWindowSystemFontServer:                    newIPs,
+	dhcp4.OptionXWindowSystemDisplayManager:                newIPs,
+
+	dhcp4.OptionRequestedIPAddress:     newIP,
+	dhcp4.OptionIPAddressLeaseTime:     newDuration,
+	dhcp4.OptionDHCPMessageType:        func() dhcp4.OptionValue { return new(DHCPMessageType) },
+	dhcp4.OptionServerIdentifier:       newIP,
+	dhcp4.OptionParameterRequestList:   func() dhcp4.OptionValue { return new(OptionCodes) },
+	dhcp4.OptionMessage:                newString,
+	dhcp4.OptionMaximumDHCPMessageSize: newUint16,
+	dhcp4.OptionRenewalTimeValue:       newDuration,
+	dhcp4.OptionRebindingTimeValue:     newDuration,
+	dhcp4.OptionVendorClassIdentifier:  newString,
+	dhcp4.OptionTFTPServerName:         newString,
+	dhcp4.OptionBootFileName:           newString,
+
+	dhcp4.OptionUserClass:                 func() dhcp4.OptionValue { return new(UserClass) },
+	dhcp4.OptionRelayAgentInformation:     func() dhcp4.OptionValue { return new(RelayAgentInformation) },
+	dhcp4.OptionClientLastTransactionTime: newDuration,
+	dhcp4.OptionAssociatedIP:              newIPs,
+	dhcp4.OptionIPv6OnlyPreferred:         func() dhcp4.OptionValue { return new(IPv6OnlyWait) },
+	dhcp4.OptionCaptivePortal:             func() dhcp4.OptionValue { return new(CaptivePortal) },
+	dhcp4.OptionSubnetSelection:           newIP,
+	dhcp4.OptionDomainSearch:              func() dhcp4.OptionValue { return new(DomainSearch) },
+	dhcp4.OptionClasslessStaticRoute:      func() dhcp4.OptionValue { return new(ClasslessRoutes) },
+	dhcp4.OptionMUDURL:                    func() dhcp4.OptionValue { return new(MUDURL) },
+}
+
+func newIP() dhcp4.OptionValue       { return new(IP) }
+func newIPs() dhcp4.OptionValue      { return new(IPs) }
+func newString() dhcp4.OptionValue   { return new(String) }
+func newUint16() dhcp4.OptionValue   { return new(Uint16) }
+func newUint8() dhcp4.OptionValue    { return new(Uint8) }
+func newBool() dhcp4.OptionValue     { return new(Bool) }
+func newDuration() dhcp4.OptionValue { return new(Duration) }
+
+func init() {
+	for code, newValue := range optionTypes {
+		dhcp4.RegisterOptionValue(code, newValue)
+	}
+}
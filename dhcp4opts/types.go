@@ -5,8 +5,13 @@
 package dhcp4opts
 
 import (
+	"fmt"
 	"io"
+	"math"
 	"net"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/u-root/dhcp4"
 	"github.com/u-root/dhcp4/internal/buffer"
@@ -15,6 +20,9 @@ import (
 // DHCPMessageType implements encoding.BinaryMarshaler and encapsulates binary
 // encoding and decoding methods for DHCP message types as specified by RFC
 // 2132, Section 9.6.
+//
+// It converts to and from dhcp4.MessageType, which Packet.MessageType
+// returns.
 type DHCPMessageType uint8
 
 // Legal values of DHCP message types as per RFC 2132, Section 9.6.
@@ -29,6 +37,18 @@ const (
 	DHCPInform   DHCPMessageType = 8
 )
 
+// DHCPForceRenew is the DHCPFORCERENEW message type defined by RFC 3203,
+// Section 4.
+const DHCPForceRenew DHCPMessageType = 9
+
+// Leasequery message types as defined by RFC 4388, Section 6.1.
+const (
+	DHCPLeaseQuery      DHCPMessageType = 10
+	DHCPLeaseUnassigned DHCPMessageType = 11
+	DHCPLeaseUnknown    DHCPMessageType = 12
+	DHCPLeaseActive     DHCPMessageType = 13
+)
+
 // MarshalBinary marshals the DHCP message type option to binary.
 func (d DHCPMessageType) MarshalBinary() ([]byte, error) {
 	return []byte{byte(d)}, nil
@@ -71,8 +91,14 @@ func (s *SubnetMask) UnmarshalBinary(p []byte) error {
 type IP net.IP
 
 // MarshalBinary writes the IP address to binary.
+//
+// It returns an error if the address is not an IPv4 address.
 func (i IP) MarshalBinary() ([]byte, error) {
-	return []byte(i[:net.IPv4len]), nil
+	ip := net.IP(i).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("%v is not an IPv4 address", net.IP(i))
+	}
+	return []byte(ip), nil
 }
 
 // UnmarshalBinary reads the IP address from binary.
@@ -105,15 +131,23 @@ func GetIP(code dhcp4.OptionCode, o dhcp4.Options) IP {
 type IPs []net.IP
 
 // MarshalBinary writes the list of IPs to binary.
+//
+// It returns an error if any address is not an IPv4 address.
 func (i IPs) MarshalBinary() ([]byte, error) {
 	b := buffer.New(make([]byte, 0, net.IPv4len*len(i)))
 	for _, ip := range i {
-		b.WriteBytes(ip.To4())
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("%v is not an IPv4 address", ip)
+		}
+		b.WriteBytes(ip4)
 	}
 	return b.Data(), nil
 }
 
 // UnmarshalBinary reads a list of IPs from binary.
+//
+// It returns an error unless the length of p is a non-zero multiple of 4.
 func (i *IPs) UnmarshalBinary(p []byte) error {
 	b := buffer.New(p)
 	if b.Len() == 0 || b.Len()%net.IPv4len != 0 {
@@ -146,20 +180,72 @@ func GetIPs(code dhcp4.OptionCode, o dhcp4.Options) IPs {
 // String implements encoding.BinaryMarshaler and encapsulates binary encoding
 // and decoding of strings as specified by RFC 2132 in Sections 3.14, 3.16,
 // 3.17, 3.19, and 3.20.
+//
+// RFC 2132 says strings are not NUL-terminated, but many implementations pad
+// or terminate them with NULs anyway; String drops trailing NULs when
+// decoding. Use RawString to keep the value exactly as sent.
 type String string
 
 // MarshalBinary writes the string to binary.
+//
+// It returns an error for the empty string, as options must carry at least
+// one byte of text.
 func (s String) MarshalBinary() ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("string option must not be empty")
+	}
 	return []byte(s), nil
 }
 
-// GetString returns the string encoded in the `code` option of `o`.
+// UnmarshalBinary reads the string from binary, dropping trailing NULs.
+func (s *String) UnmarshalBinary(p []byte) error {
+	*s = String(trimNULs(p))
+	return nil
+}
+
+// HasTrailingNULs reports whether p, an option value, ends with NULs that
+// String drops when decoding it.
+func HasTrailingNULs(p []byte) bool {
+	return len(p) > 0 && p[len(p)-1] == 0
+}
+
+func trimNULs(p []byte) []byte {
+	for len(p) > 0 && p[len(p)-1] == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// GetString returns the string encoded in the `code` option of `o`, without
+// trailing NULs.
 func GetString(code dhcp4.OptionCode, o dhcp4.Options) string {
 	v := o.Get(code)
 	if v == nil {
 		return ""
 	}
-	return string(v)
+	return string(trimNULs(v))
+}
+
+// RawString implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding of strings kept exactly as sent, including NULs and
+// empty values, for tools that must reproduce or inspect them.
+type RawString string
+
+// MarshalBinary writes the string to binary.
+func (s RawString) MarshalBinary() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalBinary reads the string from binary.
+func (s *RawString) UnmarshalBinary(p []byte) error {
+	*s = RawString(p)
+	return nil
+}
+
+// GetRawString returns the string encoded in the `code` option of `o`,
+// exactly as sent.
+func GetRawString(code dhcp4.OptionCode, o dhcp4.Options) string {
+	return string(o.Get(code))
 }
 
 // OptionCodes implements encoding.BinaryMarshaler and encapsulates binary
@@ -206,3 +292,532 @@ func (u *Uint16) UnmarshalBinary(p []byte) error {
 	*u = Uint16(b.Read16())
 	return nil
 }
+
+// Uint8 implements encoding.BinaryMarshaler and encapsulates binary encoding
+// and decoding methods of single-byte numbers, such as the default TTLs of
+// RFC 2132, Sections 4.5 and 6.1.
+type Uint8 uint8
+
+// MarshalBinary writes the uint8 to binary.
+func (u Uint8) MarshalBinary() ([]byte, error) {
+	return []byte{byte(u)}, nil
+}
+
+// UnmarshalBinary reads the uint8 from binary.
+//
+// It returns an error unless p is exactly 1 byte long.
+func (u *Uint8) UnmarshalBinary(p []byte) error {
+	if len(p) != 1 {
+		return fmt.Errorf("uint8 option is %d bytes long, want 1", len(p))
+	}
+	*u = Uint8(p[0])
+	return nil
+}
+
+// Uint32 implements encoding.BinaryMarshaler and encapsulates binary encoding
+// and decoding methods of uint32s in network byte order.
+type Uint32 uint32
+
+// MarshalBinary writes the uint32 to binary.
+func (u Uint32) MarshalBinary() ([]byte, error) {
+	b := buffer.New(nil)
+	b.Write32(uint32(u))
+	return b.Data(), nil
+}
+
+// UnmarshalBinary reads the uint32 from binary.
+//
+// It returns an error unless p is exactly 4 bytes long.
+func (u *Uint32) UnmarshalBinary(p []byte) error {
+	b := buffer.New(p)
+	if b.Len() != 4 {
+		return fmt.Errorf("uint32 option is %d bytes long, want 4", b.Len())
+	}
+	*u = Uint32(b.Read32())
+	return nil
+}
+
+// Int32 implements encoding.BinaryMarshaler and encapsulates binary encoding
+// and decoding methods of two's complement int32s in network byte order.
+type Int32 int32
+
+// MarshalBinary writes the int32 to binary.
+func (i Int32) MarshalBinary() ([]byte, error) {
+	return Uint32(i).MarshalBinary()
+}
+
+// UnmarshalBinary reads the int32 from binary.
+//
+// It returns an error unless p is exactly 4 bytes long.
+func (i *Int32) UnmarshalBinary(p []byte) error {
+	var u Uint32
+	if err := u.UnmarshalBinary(p); err != nil {
+		return err
+	}
+	*i = Int32(u)
+	return nil
+}
+
+// Bool implements encoding.BinaryMarshaler and encapsulates binary encoding
+// and decoding methods of the flag options of RFC 2132, such as IP forwarding
+// (Section 4.1), sent as a single byte of 0 or 1.
+type Bool bool
+
+// MarshalBinary writes the flag to binary.
+func (f Bool) MarshalBinary() ([]byte, error) {
+	if f {
+		return []byte{1}, nil
+	}
+	return []byte{0}, nil
+}
+
+// UnmarshalBinary reads the flag from binary.
+//
+// It returns an error unless p is a single byte of 0 or 1.
+func (f *Bool) UnmarshalBinary(p []byte) error {
+	if len(p) != 1 || p[0] > 1 {
+		return fmt.Errorf("flag option %v is not a single byte of 0 or 1", p)
+	}
+	*f = p[0] == 1
+	return nil
+}
+
+// Duration implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the durations of RFC 2132, such as lease
+// times (Section 9.2) and timeouts (Sections 4.6 and 6.2), sent as an
+// unsigned 32-bit number of seconds.
+//
+// 0xffffffff seconds means infinity, and is decoded as InfiniteLease.
+// Durations are truncated to whole seconds.
+type Duration time.Duration
+
+// MarshalBinary writes the duration to binary.
+//
+// It returns an error for negative durations and durations too long to
+// represent, other than InfiniteLease.
+func (d Duration) MarshalBinary() ([]byte, error) {
+	if time.Duration(d) == InfiniteLease {
+		return Uint32(math.MaxUint32).MarshalBinary()
+	}
+	secs := time.Duration(d) / time.Second
+	if secs < 0 || secs >= math.MaxUint32 {
+		return nil, fmt.Errorf("duration %v out of range", time.Duration(d))
+	}
+	return Uint32(secs).MarshalBinary()
+}
+
+// UnmarshalBinary reads the duration from binary.
+//
+// It returns an error unless p is exactly 4 bytes long.
+func (d *Duration) UnmarshalBinary(p []byte) error {
+	var secs Uint32
+	if err := secs.UnmarshalBinary(p); err != nil {
+		return err
+	}
+	if secs == math.MaxUint32 {
+		*d = Duration(InfiniteLease)
+	} else {
+		*d = Duration(time.Duration(secs) * time.Second)
+	}
+	return nil
+}
+
+// UserClass implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the user classes a client belongs to, as
+// defined by RFC 3004, Section 4.
+//
+// Each class is sent as a length-prefixed item of opaque data, usually text.
+type UserClass []string
+
+// MarshalBinary writes the user class list to binary.
+func (u UserClass) MarshalBinary() ([]byte, error) {
+	b := buffer.New(nil)
+	for _, class := range u {
+		if len(class) == 0 || len(class) > 255 {
+			return nil, fmt.Errorf("user class %q must be 1 to 255 bytes long", class)
+		}
+		b.Write8(uint8(len(class)))
+		b.WriteBytes([]byte(class))
+	}
+	return b.Data(), nil
+}
+
+// UnmarshalBinary reads the user class list from binary.
+func (u *UserClass) UnmarshalBinary(p []byte) error {
+	b := buffer.New(p)
+	if b.Len() == 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	*u = nil
+	for b.Len() > 0 {
+		n := int(b.Read8())
+		if n == 0 {
+			return fmt.Errorf("empty user class")
+		}
+		if b.Len() < n {
+			return io.ErrUnexpectedEOF
+		}
+		*u = append(*u, string(b.Consume(n)))
+	}
+	return nil
+}
+
+// Relay agent information sub-option codes, as assigned by RFC 3046, RFC
+// 3527, and RFC 8357.
+const (
+	AgentCircuitID       uint8 = 1
+	AgentRemoteID        uint8 = 2
+	AgentLinkSelection   uint8 = 5
+	AgentRelaySourcePort uint8 = 19
+)
+
+// SubOption is a sub-option of the relay agent information option.
+type SubOption struct {
+	Code uint8
+	Data []byte
+}
+
+// RelayAgentInformation implements encoding.BinaryMarshaler and encapsulates
+// binary encoding and decoding methods of the relay agent information option
+// defined by RFC 3046, Section 2.0: a list of sub-options, in order.
+type RelayAgentInformation []SubOption
+
+// MarshalBinary writes the relay agent information to binary.
+func (r RelayAgentInformation) MarshalBinary() ([]byte, error) {
+	b := buffer.New(nil)
+	for _, sub := range r {
+		if len(sub.Data) > 255 {
+			return nil, fmt.Errorf("relay agent sub-option %d is %d bytes long, more than 255", sub.Code, len(sub.Data))
+		}
+		b.Write8(sub.Code)
+		b.Write8(uint8(len(sub.Data)))
+		b.WriteBytes(sub.Data)
+	}
+	return b.Data(), nil
+}
+
+// UnmarshalBinary reads the relay agent information from binary.
+func (r *RelayAgentInformation) UnmarshalBinary(p []byte) error {
+	b := buffer.New(p)
+	*r = nil
+	for b.Len() > 0 {
+		if b.Len() < 2 {
+			return io.ErrUnexpectedEOF
+		}
+		code, n := b.Read8(), int(b.Read8())
+		if b.Len() < n {
+			return io.ErrUnexpectedEOF
+		}
+		*r = append(*r, SubOption{Code: code, Data: b.Consume(n)})
+	}
+	return nil
+}
+
+// Get returns the data of the first sub-option with the given code, or nil.
+func (r RelayAgentInformation) Get(code uint8) []byte {
+	for _, sub := range r {
+		if sub.Code == code {
+			return sub.Data
+		}
+	}
+	return nil
+}
+
+// MUDURL implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the Manufacturer Usage Description URL
+// defined by RFC 8520, Section 10, which points to a description of the
+// network access an IoT device needs.
+type MUDURL string
+
+// MarshalBinary writes the MUD URL to binary.
+//
+// It returns an error unless the URL is an absolute https URL, as RFC 8520
+// requires.
+func (m MUDURL) MarshalBinary() ([]byte, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return []byte(m), nil
+}
+
+// UnmarshalBinary reads the MUD URL from binary.
+func (m *MUDURL) UnmarshalBinary(p []byte) error {
+	u := MUDURL(p)
+	if err := u.check(); err != nil {
+		return err
+	}
+	*m = u
+	return nil
+}
+
+func (m MUDURL) check() error {
+	u, err := url.Parse(string(m))
+	if err != nil {
+		return fmt.Errorf("invalid MUD URL: %v", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("MUD URL %q is not an absolute https URL", string(m))
+	}
+	if len(m) > 255 {
+		return fmt.Errorf("MUD URL is %d bytes long, more than 255", len(m))
+	}
+	return nil
+}
+
+// CaptivePortalUnrestricted is the captive portal URI servers send to say
+// that the network has no captive portal, as defined by RFC 8910, Section 2.
+const CaptivePortalUnrestricted CaptivePortal = "urn:ietf:params:capport:unrestricted"
+
+// CaptivePortal implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the captive portal API URI defined by RFC
+// 8910, Section 2.1.
+type CaptivePortal string
+
+// MarshalBinary writes the captive portal URI to binary.
+//
+// It returns an error unless the URI is CaptivePortalUnrestricted or an
+// absolute https URL, as RFC 8908 requires of captive portal APIs.
+func (c CaptivePortal) MarshalBinary() ([]byte, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return []byte(c), nil
+}
+
+// UnmarshalBinary reads the captive portal URI from binary.
+func (c *CaptivePortal) UnmarshalBinary(p []byte) error {
+	u := CaptivePortal(p)
+	if err := u.check(); err != nil {
+		return err
+	}
+	*c = u
+	return nil
+}
+
+func (c CaptivePortal) check() error {
+	if c == CaptivePortalUnrestricted {
+		return nil
+	}
+	u, err := url.Parse(string(c))
+	if err != nil {
+		return fmt.Errorf("invalid captive portal URI: %v", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("captive portal URI %q is not an absolute https URL", string(c))
+	}
+	if len(c) > 255 {
+		return fmt.Errorf("captive portal URI is %d bytes long, more than 255", len(c))
+	}
+	return nil
+}
+
+// MinIPv6OnlyWait is the shortest time clients stop DHCPv4 for when a
+// network prefers IPv6-only clients, as defined by RFC 8925, Section 4.
+const MinIPv6OnlyWait = 300 * time.Second
+
+// IPv6OnlyWait implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the IPv6-Only Preferred option defined by
+// RFC 8925, Section 3.4: how long an IPv6-only capable client should stop
+// using DHCPv4, in whole seconds.
+type IPv6OnlyWait time.Duration
+
+// MarshalBinary writes the wait time to binary.
+//
+// It returns an error if the wait time is negative or does not fit in 32
+// bits of seconds.
+func (w IPv6OnlyWait) MarshalBinary() ([]byte, error) {
+	secs := time.Duration(w) / time.Second
+	if secs < 0 || secs > math.MaxUint32 {
+		return nil, fmt.Errorf("IPv6-only wait %v out of range", time.Duration(w))
+	}
+	return Uint32(secs).MarshalBinary()
+}
+
+// UnmarshalBinary reads the wait time from binary.
+//
+// It returns an error unless p is exactly 4 bytes long.
+func (w *IPv6OnlyWait) UnmarshalBinary(p []byte) error {
+	var secs Uint32
+	if err := secs.UnmarshalBinary(p); err != nil {
+		return err
+	}
+	*w = IPv6OnlyWait(time.Duration(secs) * time.Second)
+	return nil
+}
+
+// TimeOffset implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the offset of the client's subnet from
+// UTC, a signed number of seconds, as defined by RFC 2132, Section 3.4.
+//
+// Offsets east of UTC are positive; offsets are truncated to whole seconds.
+type TimeOffset time.Duration
+
+// MarshalBinary writes the time offset to binary.
+func (t TimeOffset) MarshalBinary() ([]byte, error) {
+	secs := time.Duration(t) / time.Second
+	if secs < math.MinInt32 || secs > math.MaxInt32 {
+		return nil, fmt.Errorf("time offset %v does not fit in 32 bits", time.Duration(t))
+	}
+	b := buffer.New(nil)
+	b.Write32(uint32(int32(secs)))
+	return b.Data(), nil
+}
+
+// UnmarshalBinary reads the time offset from binary.
+func (t *TimeOffset) UnmarshalBinary(p []byte) error {
+	b := buffer.New(p)
+	if b.Len() != 4 {
+		return io.ErrUnexpectedEOF
+	}
+	*t = TimeOffset(time.Duration(int32(b.Read32())) * time.Second)
+	return nil
+}
+
+// DomainSearch implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the domain search list defined by RFC
+// 3397: the domains a client appends to unqualified host names, in order.
+//
+// Lists are often longer than 255 bytes; they are decoded from the
+// concatenation of all instances of the option, as RFC 3396 and RFC 3397,
+// Section 2 require, so compression pointers may cross instances.
+type DomainSearch []string
+
+// MarshalBinary writes the domain search list to binary, as uncompressed
+// RFC 1035 names.
+func (d DomainSearch) MarshalBinary() ([]byte, error) {
+	b := buffer.New(nil)
+	for _, domain := range d {
+		for _, label := range strings.Split(strings.TrimSuffix(domain, "."), ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("domain %q: label %q must be 1 to 63 bytes long", domain, label)
+			}
+			b.Write8(uint8(len(label)))
+			b.WriteBytes([]byte(label))
+		}
+		b.Write8(0)
+	}
+	return b.Data(), nil
+}
+
+// UnmarshalBinary reads the domain search list from binary, following
+// compression pointers.
+func (d *DomainSearch) UnmarshalBinary(p []byte) error {
+	*d = nil
+	for off := 0; off < len(p); {
+		name, next, err := readName(p, off)
+		if err != nil {
+			return err
+		}
+		*d = append(*d, name)
+		off = next
+	}
+	return nil
+}
+
+// readName reads the RFC 1035 name starting at off in p and returns it along
+// with the offset following it. Compression pointers must point before the
+// labels read so far, so that decoding always ends.
+func readName(p []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	limit := off
+	for {
+		if off >= len(p) {
+			return "", 0, io.ErrUnexpectedEOF
+		}
+		n := int(p[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, "."), next, nil
+
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(p) {
+				return "", 0, io.ErrUnexpectedEOF
+			}
+			ptr := (n&0x3f)<<8 | int(p[off+1])
+			if ptr >= limit {
+				return "", 0, fmt.Errorf("domain search compression pointer %d does not point backwards", ptr)
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off, limit = ptr, ptr
+
+		case n > 63:
+			return "", 0, fmt.Errorf("domain search label length %d is invalid", n)
+
+		default:
+			if off+1+n > len(p) {
+				return "", 0, io.ErrUnexpectedEOF
+			}
+			labels = append(labels, string(p[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// Route is a classless static route.
+type Route struct {
+	// Dest is the destination network.
+	Dest *net.IPNet
+
+	// Router is the next hop, or 0.0.0.0 for a destination on the link.
+	Router net.IP
+}
+
+// ClasslessRoutes implements encoding.BinaryMarshaler and encapsulates binary
+// encoding and decoding methods of the classless static route option defined
+// by RFC 3442.
+//
+// Like DomainSearch, it is decoded from the concatenation of all instances of
+// the option.
+type ClasslessRoutes []Route
+
+// MarshalBinary writes the routes to binary.
+func (c ClasslessRoutes) MarshalBinary() ([]byte, error) {
+	b := buffer.New(nil)
+	for _, r := range c {
+		if r.Dest == nil {
+			return nil, fmt.Errorf("route %v via %v is not IPv4", r.Dest, r.Router)
+		}
+		width, bits := r.Dest.Mask.Size()
+		dest := r.Dest.IP.To4()
+		router := r.Router.To4()
+		if bits != 32 || dest == nil || router == nil {
+			return nil, fmt.Errorf("route %v via %v is not IPv4", r.Dest, r.Router)
+		}
+		b.Write8(uint8(width))
+		b.WriteBytes(dest[:(width+7)/8])
+		b.WriteBytes(router)
+	}
+	return b.Data(), nil
+}
+
+// UnmarshalBinary reads the routes from binary.
+func (c *ClasslessRoutes) UnmarshalBinary(p []byte) error {
+	b := buffer.New(p)
+	*c = nil
+	for b.Len() > 0 {
+		width := int(b.Read8())
+		if width > 32 {
+			return fmt.Errorf("classless route prefix length %d is more than 32", width)
+		}
+		n := (width + 7) / 8
+		if b.Len() < n+net.IPv4len {
+			return io.ErrUnexpectedEOF
+		}
+		dest := make(net.IP, net.IPv4len)
+		b.ReadBytes(dest[:n])
+		router := make(net.IP, net.IPv4len)
+		b.ReadBytes(router)
+		*c = append(*c, Route{
+			Dest:   &net.IPNet{IP: dest, Mask: net.CIDRMask(width, 32)},
+			Router: router,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4opts
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+)
+
+type codec interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		value codec
+		wire  []byte
+	}{
+		{
+			desc:  "user class",
+			value: &UserClass{"foo", "ab"},
+			wire:  []byte{3, 'f', 'o', 'o', 2, 'a', 'b'},
+		},
+		{
+			desc:  "relay agent information",
+			value: &RelayAgentInformation{{Code: AgentCircuitID, Data: []byte("ge0")}, {Code: AgentLinkSelection, Data: []byte{10, 0, 0, 0}}},
+			wire:  []byte{1, 3, 'g', 'e', '0', 5, 4, 10, 0, 0, 0},
+		},
+		{
+			desc:  "time offset east",
+			value: func() *TimeOffset { t := TimeOffset(2 * time.Hour); return &t }(),
+			wire:  []byte{0, 0, 0x1c, 0x20},
+		},
+		{
+			desc:  "time offset west",
+			value: func() *TimeOffset { t := TimeOffset(-5 * time.Hour); return &t }(),
+			wire:  []byte{0xff, 0xff, 0xb9, 0xb0},
+		},
+		{
+			desc:  "uint8",
+			value: func() *Uint8 { u := Uint8(64); return &u }(),
+			wire:  []byte{64},
+		},
+		{
+			desc:  "uint32",
+			value: func() *Uint32 { u := Uint32(0xdeadbeef); return &u }(),
+			wire:  []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		{
+			desc:  "int32",
+			value: func() *Int32 { i := Int32(-2); return &i }(),
+			wire:  []byte{0xff, 0xff, 0xff, 0xfe},
+		},
+		{
+			desc:  "bool",
+			value: func() *Bool { f := Bool(true); return &f }(),
+			wire:  []byte{1},
+		},
+		{
+			desc:  "duration",
+			value: func() *Duration { d := Duration(time.Hour); return &d }(),
+			wire:  []byte{0, 0, 0x0e, 0x10},
+		},
+		{
+			desc:  "infinite duration",
+			value: func() *Duration { d := Duration(InfiniteLease); return &d }(),
+			wire:  []byte{0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			desc:  "domain search",
+			value: &DomainSearch{"eng.example.com", "example.com"},
+			wire:  []byte("\x03eng\x07example\x03com\x00\x07example\x03com\x00"),
+		},
+		{
+			desc: "classless routes",
+			value: &ClasslessRoutes{
+				{Dest: &net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}, Router: net.IP{10, 0, 0, 1}},
+				{Dest: &net.IPNet{IP: net.IP{0, 0, 0, 0}, Mask: net.CIDRMask(0, 32)}, Router: net.IP{10, 0, 0, 254}},
+			},
+			wire: []byte{8, 10, 10, 0, 0, 1, 0, 10, 0, 0, 254},
+		},
+		{
+			desc:  "IPv6-only wait",
+			value: func() *IPv6OnlyWait { w := IPv6OnlyWait(30 * time.Minute); return &w }(),
+			wire:  []byte{0, 0, 0x07, 0x08},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := tt.value.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() = %v", err)
+			}
+			if !bytes.Equal(got, tt.wire) {
+				t.Errorf("MarshalBinary() = %v, want %v", got, tt.wire)
+			}
+
+			v := reflect.New(reflect.TypeOf(tt.value).Elem()).Interface().(codec)
+			if err := v.UnmarshalBinary(tt.wire); err != nil {
+				t.Fatalf("UnmarshalBinary() = %v", err)
+			}
+			if !reflect.DeepEqual(v, tt.value) {
+				t.Errorf("UnmarshalBinary() = %v, want %v", v, tt.value)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		value codec
+		wire  []byte
+	}{
+		{"empty user class", new(UserClass), []byte{}},
+		{"zero-length user class", new(UserClass), []byte{0}},
+		{"truncated user class", new(UserClass), []byte{3, 'f', 'o'}},
+		{"truncated sub-option", new(RelayAgentInformation), []byte{1, 3, 'g'}},
+		{"short time offset", new(TimeOffset), []byte{0, 0, 1}},
+		{"MUD URL over http", new(MUDURL), []byte("http://example.com/mud")},
+		{"relative captive portal", new(CaptivePortal), []byte("/portal")},
+		{"empty IPs", new(IPs), []byte{}},
+		{"partial IPs", new(IPs), []byte{10, 0, 0, 1, 10, 0}},
+		{"long uint8", new(Uint8), []byte{1, 2}},
+		{"short uint32", new(Uint32), []byte{1, 2, 3}},
+		{"long int32", new(Int32), []byte{1, 2, 3, 4, 5}},
+		{"bool 2", new(Bool), []byte{2}},
+		{"empty bool", new(Bool), []byte{}},
+		{"long duration", new(Duration), []byte{0, 0, 0, 1, 0}},
+		{"short IPv6-only wait", new(IPv6OnlyWait), []byte{0, 0, 1}},
+		{"long IPv6-only wait", new(IPv6OnlyWait), []byte{0, 0, 1, 44, 0, 0}},
+	} {
+		if err := tt.value.UnmarshalBinary(tt.wire); err == nil {
+			t.Errorf("%s: UnmarshalBinary(%v) = nil error, want error", tt.desc, tt.wire)
+		}
+	}
+}
+
+func TestMarshalInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		value encoding.BinaryMarshaler
+	}{
+		{"IPv6 IP", IP(net.ParseIP("2001:db8::1"))},
+		{"nil IP", IP(nil)},
+		{"IPv6 in IPs", IPs{net.IPv4(10, 0, 0, 1), net.ParseIP("2001:db8::1")}},
+		{"short IP in IPs", IPs{net.IP{10, 0}}},
+		{"negative duration", Duration(-time.Second)},
+		{"duration too long", Duration(math.MaxUint32 * time.Second)},
+		{"empty string", String("")},
+		{"negative IPv6-only wait", IPv6OnlyWait(-5 * time.Second)},
+		{"IPv6-only wait too long", IPv6OnlyWait((math.MaxUint32 + 1) * time.Second)},
+		{"route without destination", ClasslessRoutes{{Router: net.IP{10, 0, 0, 1}}}},
+	} {
+		if b, err := tt.value.MarshalBinary(); err == nil {
+			t.Errorf("%s: MarshalBinary() = %v, want error", tt.desc, b)
+		}
+	}
+}
+
+func TestMarshalIPv4In16Bytes(t *testing.T) {
+	want := []byte{10, 0, 0, 1, 10, 0, 0, 2}
+	if got, err := (IPs{net.IPv4(10, 0, 0, 1), net.IP{10, 0, 0, 2}}).MarshalBinary(); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("IPs.MarshalBinary() = %v, %v, want %v", got, err, want)
+	}
+	if got, err := IP(net.IPv4(10, 0, 0, 1)).MarshalBinary(); err != nil || !bytes.Equal(got, want[:4]) {
+		t.Errorf("IP.MarshalBinary() = %v, %v, want %v", got, err, want[:4])
+	}
+}
+
+func TestGetTyped(t *testing.T) {
+	o := dhcp4.NewOptions(
+		dhcp4.Option{Code: dhcp4.OptionRouters, Value: []byte{10, 0, 0, 1, 10, 0, 0, 2}},
+		dhcp4.Option{Code: dhcp4.OptionDHCPMessageType, Value: []byte{5}},
+		dhcp4.Option{Code: dhcp4.OptionDomainName, Value: []byte("example.com")},
+	)
+	for _, tt := range []struct {
+		code dhcp4.OptionCode
+		want dhcp4.OptionValue
+	}{
+		{dhcp4.OptionRouters, &IPs{net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}}},
+		{dhcp4.OptionDHCPMessageType, func() *DHCPMessageType { t := DHCPACK; return &t }()},
+		{dhcp4.OptionDomainName, func() *String { s := String("example.com"); return &s }()},
+	} {
+		got, err := o.GetTyped(tt.code)
+		if err != nil {
+			t.Errorf("GetTyped(%d) = %v", tt.code, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetTyped(%d) = %#v, want %#v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestGetInterfaceMTU(t *testing.T) {
+	for _, tt := range []struct {
+		value   []byte
+		want    uint16
+		wantErr bool
+	}{
+		{value: []byte{0x05, 0xdc}, want: 1500},
+		{value: []byte{0, 68}, want: 68},
+		{value: []byte{0, 67}, wantErr: true},
+		{value: []byte{0x05}, wantErr: true},
+		{wantErr: true},
+	} {
+		o := dhcp4.Options{}
+		if tt.value != nil {
+			o.AddRaw(dhcp4.OptionInterfaceMTU, tt.value)
+		}
+		got, err := GetInterfaceMTU(o)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("GetInterfaceMTU(%v) = %d, %v, want %d, error %t", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestSplitOptions checks that values longer than 255 bytes survive being
+// split into several instances of their option on the wire.
+func TestSplitOptions(t *testing.T) {
+	var search DomainSearch
+	var routes ClasslessRoutes
+	for i := 0; i < 40; i++ {
+		search = append(search, fmt.Sprintf("subdomain%d.example.com", i))
+		routes = append(routes, Route{
+			Dest:   &net.IPNet{IP: net.IP{10, 0, byte(i), 0}, Mask: net.CIDRMask(24, 32)},
+			Router: net.IP{192, 168, 0, byte(i)},
+		})
+	}
+
+	p := dhcp4.NewPacket(dhcp4.BootReply)
+	if err := p.Options.Add(dhcp4.OptionDomainSearch, search); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Options.Add(dhcp4.OptionClasslessStaticRoute, routes); err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got dhcp4.Packet
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		code dhcp4.OptionCode
+		want dhcp4.OptionValue
+	}{
+		{dhcp4.OptionDomainSearch, &search},
+		{dhcp4.OptionClasslessStaticRoute, &routes},
+	} {
+		if n := len(got.Options.GetAll(tt.code)); n < 2 {
+			t.Errorf("option %d sent as %d instances, want several", tt.code, n)
+		}
+		v, err := got.Options.GetTyped(tt.code)
+		if err != nil {
+			t.Errorf("GetTyped(%d) = %v", tt.code, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.want) {
+			t.Errorf("GetTyped(%d) = %v, want %v", tt.code, v, tt.want)
+		}
+	}
+}
+
+func TestDomainSearchCompression(t *testing.T) {
+	// The second instance of the option points back into the first,
+	// as RFC 3397, Section 2 allows.
+	o := dhcp4.NewOptions(
+		dhcp4.Option{Code: dhcp4.OptionDomainSearch, Value: []byte("\x03eng\x07example\x03com\x00")},
+		dhcp4.Option{Code: dhcp4.OptionDomainSearch, Value: []byte("\x05sales\xc0\x04\xc0\x04")},
+	)
+	want := DomainSearch{"eng.example.com", "sales.example.com", "example.com"}
+	if got := GetDomainSearch(o); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDomainSearch() = %v, want %v", got, want)
+	}
+
+	var d DomainSearch
+	if err := d.UnmarshalBinary([]byte("\x03foo\xc0\x00")); err == nil {
+		t.Errorf("UnmarshalBinary(pointer loop) = nil, want error")
+	}
+}
+
+func TestStringNULs(t *testing.T) {
+	o := dhcp4.NewOptions(
+		dhcp4.Option{Code: dhcp4.OptionHostName, Value: []byte("host\x00\x00")},
+		dhcp4.Option{Code: dhcp4.OptionDomainName, Value: []byte("example.com")},
+	)
+	if got, want := GetHostName(o), "host"; got != want {
+		t.Errorf("GetHostName() = %q, want %q", got, want)
+	}
+	if got, want := GetRawString(dhcp4.OptionHostName, o), "host\x00\x00"; got != want {
+		t.Errorf("GetRawString() = %q, want %q", got, want)
+	}
+	if !HasTrailingNULs(o.Get(dhcp4.OptionHostName)) {
+		t.Errorf("HasTrailingNULs(host name) = false, want true")
+	}
+	if HasTrailingNULs(o.Get(dhcp4.OptionDomainName)) {
+		t.Errorf("HasTrailingNULs(domain name) = true, want false")
+	}
+
+	v, err := o.GetTyped(dhcp4.OptionHostName)
+	if err != nil {
+		t.Fatalf("GetTyped() = %v", err)
+	}
+	if got := *v.(*String); got != "host" {
+		t.Errorf("GetTyped() = %q, want %q", got, "host")
+	}
+
+	if b, err := RawString("").MarshalBinary(); err != nil || len(b) != 0 {
+		t.Errorf("RawString(\"\").MarshalBinary() = %v, %v, want empty", b, err)
+	}
+}
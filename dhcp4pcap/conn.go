@@ -0,0 +1,70 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4pcap
+
+import (
+	"net"
+	"time"
+)
+
+// Conn is a net.PacketConn that records every packet it sends and receives
+// to a capture.
+//
+// A Conn can be given to a DHCP client with dhcp4client.WithConn:
+//
+//	conn, err := dhcp4client.NewIPv4UDPConn("eth0", dhcp4client.ClientPort)
+//	...
+//	w, err := dhcp4pcap.NewWriter(f)
+//	...
+//	client, err := dhcp4client.New(iface, dhcp4client.WithConn(dhcp4pcap.NewConn(conn, w)))
+type Conn struct {
+	net.PacketConn
+
+	w *Writer
+}
+
+// NewConn returns a Conn that records packets sent and received on conn to w.
+func NewConn(conn net.PacketConn, w *Writer) *Conn {
+	return &Conn{
+		PacketConn: conn,
+		w:          w,
+	}
+}
+
+// localAddr returns the local UDP address of the connection, or the zero
+// address if it is unknown.
+func (c *Conn) localAddr() *net.UDPAddr {
+	if addr, ok := c.PacketConn.LocalAddr().(*net.UDPAddr); ok && addr.IP != nil {
+		return addr
+	}
+	return &net.UDPAddr{IP: net.IPv4zero}
+}
+
+// ReadFrom implements net.PacketConn.ReadFrom.
+//
+// Errors writing to the capture are ignored so that capturing never breaks
+// the exchange being captured.
+func (c *Conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err != nil {
+		return n, addr, err
+	}
+	if src, ok := addr.(*net.UDPAddr); ok {
+		c.w.WriteUDP(time.Now(), src, c.localAddr(), b[:n])
+	}
+	return n, addr, err
+}
+
+// WriteTo implements net.PacketConn.WriteTo.
+func (c *Conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if err != nil {
+		return n, err
+	}
+	if dst, ok := addr.(*net.UDPAddr); ok {
+		c.w.WriteUDP(time.Now(), c.localAddr(), dst, b[:n])
+	}
+	return n, err
+}
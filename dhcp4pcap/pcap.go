@@ -0,0 +1,154 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dhcp4pcap writes DHCP packets to packet captures and reads them
+// back.
+//
+// Captures are written in the pcapng format with Ethernet framing. When
+// packets come from a UDP socket, which does not expose link-layer or network
+// headers, the Ethernet, IPv4, and UDP headers are synthesized so that tools
+// like Wireshark can decode the capture.
+//
+// Captures are read from either pcapng or classic pcap files, as written by
+// tcpdump, Wireshark, and this package.
+package dhcp4pcap
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/mdlayher/ethernet"
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/internal/udp4"
+)
+
+// Link-layer header types as assigned in the tcpdump.org registry.
+const (
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+)
+
+// linuxSLLLen is the length of the Linux "cooked" capture header.
+const linuxSLLLen = 16
+
+var (
+	// ErrInvalidCapture is returned when a capture file is malformed.
+	ErrInvalidCapture = errors.New("invalid capture file")
+
+	// errNotDHCP is returned for captured frames that are not DHCP
+	// packets.
+	errNotDHCP = errors.New("not a DHCP packet")
+)
+
+// Packet is a DHCP message and the UDP addresses it was sent between.
+type Packet struct {
+	// Timestamp is the time the packet was captured.
+	//
+	// Packets read from simple packet blocks, which carry no timestamp,
+	// have a zero Timestamp.
+	Timestamp time.Time
+
+	// Src is the source address of the packet.
+	Src *net.UDPAddr
+
+	// Dst is the destination address of the packet.
+	Dst *net.UDPAddr
+
+	// Payload is the UDP payload of the packet.
+	Payload []byte
+}
+
+// DHCP parses the packet's payload as a DHCP packet.
+func (p *Packet) DHCP() (*dhcp4.Packet, error) {
+	pkt := &dhcp4.Packet{}
+	if err := pkt.UnmarshalBinary(p.Payload); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+func isDHCPPort(port int) bool {
+	return port == dhcp4.ServerPort || port == dhcp4.ClientPort
+}
+
+// decode extracts a DHCP packet from a captured frame of the given link type.
+func decode(linkType int, ts time.Time, frame []byte) (*Packet, error) {
+	var ip []byte
+	switch linkType {
+	case linkTypeEthernet:
+		var f ethernet.Frame
+		if err := f.UnmarshalBinary(frame); err != nil || f.EtherType != ethernet.EtherTypeIPv4 {
+			return nil, errNotDHCP
+		}
+		ip = f.Payload
+
+	case linkTypeLinuxSLL:
+		if len(frame) < linuxSLLLen {
+			return nil, errNotDHCP
+		}
+		if ethernet.EtherType(uint16(frame[14])<<8|uint16(frame[15])) != ethernet.EtherTypeIPv4 {
+			return nil, errNotDHCP
+		}
+		ip = frame[linuxSLLLen:]
+
+	case linkTypeRaw, linkTypeIPv4:
+		ip = frame
+
+	default:
+		return nil, errNotDHCP
+	}
+
+	payload, src, dst, err := udp4.Unmarshal(ip)
+	if err != nil || !isDHCPPort(dst.Port) {
+		return nil, errNotDHCP
+	}
+	return &Packet{
+		Timestamp: ts,
+		Src:       src,
+		Dst:       dst,
+		Payload:   payload,
+	}, nil
+}
+
+// Offsets into the fixed DHCP header used to pick Ethernet addresses.
+const (
+	opOffset     = 0
+	hlenOffset   = 2
+	chaddrOffset = 28
+)
+
+// encode synthesizes an Ethernet frame carrying payload from src to dst.
+//
+// Ethernet addresses are not known to a UDP socket. The client hardware
+// address in the DHCP header stands in for the client's side; the server's
+// side and any broadcast destination use the zero and broadcast addresses.
+func encode(payload []byte, src, dst *net.UDPAddr) ([]byte, error) {
+	srcMAC := make(net.HardwareAddr, 6)
+	dstMAC := make(net.HardwareAddr, 6)
+	if dst.IP.Equal(net.IPv4bcast) {
+		copy(dstMAC, ethernet.Broadcast)
+	}
+	if len(payload) > chaddrOffset+6 && payload[hlenOffset] == 6 {
+		chaddr := payload[chaddrOffset : chaddrOffset+6]
+		switch dhcp4.OpCode(payload[opOffset]) {
+		case dhcp4.BootRequest:
+			copy(srcMAC, chaddr)
+		case dhcp4.BootReply:
+			if !dst.IP.Equal(net.IPv4bcast) {
+				copy(dstMAC, chaddr)
+			}
+		}
+	}
+
+	f := &ethernet.Frame{
+		Destination: dstMAC,
+		Source:      srcMAC,
+		EtherType:   ethernet.EtherTypeIPv4,
+		Payload:     udp4.Marshal(payload, dst, src),
+	}
+	return f.MarshalBinary()
+}
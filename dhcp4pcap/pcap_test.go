@@ -0,0 +1,139 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/internal/udp4"
+)
+
+func testPacket(t *testing.T) []byte {
+	p := dhcp4.NewPacket(dhcp4.BootRequest)
+	p.TransactionID = [4]byte{1, 2, 3, 4}
+	p.CHAddr = net.HardwareAddr{2, 3, 4, 5, 6, 7}
+	p.Options.AddRaw(dhcp4.OptionDHCPMessageType, []byte{1})
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestWriteRead(t *testing.T) {
+	payload := testPacket(t)
+	want := []*Packet{
+		{
+			Timestamp: time.Unix(1527000000, 123000),
+			Src:       &net.UDPAddr{IP: net.IPv4zero.To4(), Port: dhcp4.ClientPort},
+			Dst:       &net.UDPAddr{IP: net.IPv4bcast.To4(), Port: dhcp4.ServerPort},
+			Payload:   payload,
+		},
+		{
+			Timestamp: time.Unix(1527000001, 0),
+			Src:       &net.UDPAddr{IP: net.IP{192, 168, 0, 1}, Port: dhcp4.ServerPort},
+			Dst:       &net.UDPAddr{IP: net.IP{192, 168, 0, 10}, Port: dhcp4.ClientPort},
+			Payload:   payload,
+		},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range want {
+		if err := w.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Frames that are not DHCP are skipped when reading.
+	if err := w.WriteUDP(time.Now(), &net.UDPAddr{IP: net.IPv4zero, Port: 53}, &net.UDPAddr{IP: net.IPv4bcast, Port: 53}, []byte("dns")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wantP := range want {
+		got, err := r.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket() = %v", err)
+		}
+		if !got.Timestamp.Equal(wantP.Timestamp) {
+			t.Errorf("ReadPacket() timestamp = %v, want %v", got.Timestamp, wantP.Timestamp)
+		}
+		got.Timestamp = wantP.Timestamp
+		if !reflect.DeepEqual(got, wantP) {
+			t.Errorf("ReadPacket() = %#v, want %#v", got, wantP)
+		}
+		if _, err := got.DHCP(); err != nil {
+			t.Errorf("DHCP() = %v", err)
+		}
+	}
+	if _, err := r.ReadPacket(); err != io.EOF {
+		t.Errorf("ReadPacket() at end = %v, want io.EOF", err)
+	}
+}
+
+func TestReadClassicPcap(t *testing.T) {
+	payload := testPacket(t)
+	src := &net.UDPAddr{IP: net.IPv4zero.To4(), Port: dhcp4.ClientPort}
+	dst := &net.UDPAddr{IP: net.IPv4bcast.To4(), Port: dhcp4.ServerPort}
+	ip := udp4.Marshal(payload, dst, src)
+
+	// A big-endian, nanosecond-resolution capture of raw IP packets.
+	var buf bytes.Buffer
+	for _, v := range []interface{}{
+		uint32(pcapMagicNanos), uint16(2), uint16(4), int32(0), uint32(0), uint32(65535), uint32(linkTypeRaw),
+		uint32(1527000000), uint32(42), uint32(len(ip)), uint32(len(ip)),
+	} {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	buf.Write(ip)
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket() = %v", err)
+	}
+	want := &Packet{
+		Timestamp: time.Unix(1527000000, 42),
+		Src:       src,
+		Dst:       dst,
+		Payload:   payload,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPacket() = %#v, want %#v", got, want)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for _, input := range [][]byte{
+		nil,
+		{0x0a, 0x0d, 0x0d},
+		// Section header with a bogus byte order magic.
+		{0x0a, 0x0d, 0x0d, 0x0a, 28, 0, 0, 0, 1, 2, 3, 4},
+	} {
+		r, err := NewReader(bytes.NewReader(input))
+		if err != nil {
+			continue
+		}
+		if _, err := r.ReadPacket(); err != ErrInvalidCapture {
+			t.Errorf("ReadPacket(%v) = %v, want %v", input, err, ErrInvalidCapture)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// Magic numbers of classic pcap files, with microsecond and nanosecond
+// timestamps.
+const (
+	pcapMagicMicros = 0xa1b2c3d4
+	pcapMagicNanos  = 0xa1b23c4d
+)
+
+// maxBlockLen bounds the size of blocks and records read from a capture, so
+// that a corrupt length cannot make the Reader allocate without limit.
+const maxBlockLen = 1 << 24
+
+// ifaceDesc is what the Reader remembers about a pcapng interface.
+type ifaceDesc struct {
+	linkType int
+
+	// ticksPerSec is the number of timestamp ticks per second.
+	ticksPerSec uint64
+}
+
+// Reader reads DHCP packets from a pcapng or classic pcap capture.
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+
+	// pcapng is true when reading a pcapng capture.
+	pcapng bool
+
+	// ifaces are the interfaces described in the current pcapng section.
+	ifaces []ifaceDesc
+
+	// For classic pcap captures, the link type and timestamp unit of
+	// all records.
+	linkType int
+	tsUnit   time.Duration
+}
+
+// NewReader reads the header of a capture from r and returns a Reader for its
+// packets.
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: bufio.NewReader(r)}
+
+	magic, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, ErrInvalidCapture
+	}
+	if binary.LittleEndian.Uint32(magic) == blockSectionHeader {
+		pr.pcapng = true
+		return pr, nil
+	}
+
+	var hdr [24]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		return nil, ErrInvalidCapture
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(hdr[0:]) {
+		case pcapMagicMicros:
+			pr.tsUnit = time.Microsecond
+		case pcapMagicNanos:
+			pr.tsUnit = time.Nanosecond
+		default:
+			continue
+		}
+		pr.order = order
+		pr.linkType = int(order.Uint32(hdr[20:]))
+		return pr, nil
+	}
+	return nil, ErrInvalidCapture
+}
+
+// ReadPacket returns the next DHCP packet in the capture.
+//
+// Frames that do not contain a UDP datagram to a DHCP port are skipped.
+// ReadPacket returns io.EOF at the end of the capture.
+func (r *Reader) ReadPacket() (*Packet, error) {
+	for {
+		linkType, ts, frame, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if p, err := decode(linkType, ts, frame); err == nil {
+			return p, nil
+		}
+	}
+}
+
+// next returns the next captured frame.
+func (r *Reader) next() (int, time.Time, []byte, error) {
+	if r.pcapng {
+		return r.nextBlock()
+	}
+	return r.nextRecord()
+}
+
+// readFull reads exactly len(b) bytes, treating a clean end of input as
+// io.EOF and a partial read as a corrupt capture.
+func (r *Reader) readFull(b []byte) error {
+	n, err := io.ReadFull(r.r, b)
+	if err == io.EOF && n == 0 {
+		return io.EOF
+	} else if err != nil {
+		return ErrInvalidCapture
+	}
+	return nil
+}
+
+func (r *Reader) nextRecord() (int, time.Time, []byte, error) {
+	var hdr [16]byte
+	if err := r.readFull(hdr[:]); err != nil {
+		return 0, time.Time{}, nil, err
+	}
+	sec := r.order.Uint32(hdr[0:])
+	frac := r.order.Uint32(hdr[4:])
+	capLen := r.order.Uint32(hdr[8:])
+	if capLen > maxBlockLen {
+		return 0, time.Time{}, nil, ErrInvalidCapture
+	}
+
+	frame := make([]byte, capLen)
+	if _, err := io.ReadFull(r.r, frame); err != nil {
+		return 0, time.Time{}, nil, ErrInvalidCapture
+	}
+	ts := time.Unix(int64(sec), int64(frac)*int64(r.tsUnit))
+	return r.linkType, ts, frame, nil
+}
+
+func (r *Reader) nextBlock() (int, time.Time, []byte, error) {
+	for {
+		typ, body, err := r.readBlock()
+		if err != nil {
+			return 0, time.Time{}, nil, err
+		}
+
+		switch typ {
+		case blockInterfaceDescription:
+			if len(body) < 8 {
+				return 0, time.Time{}, nil, ErrInvalidCapture
+			}
+			r.ifaces = append(r.ifaces, ifaceDesc{
+				linkType:    int(r.order.Uint16(body[0:])),
+				ticksPerSec: r.tsResolution(body[8:]),
+			})
+
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return 0, time.Time{}, nil, ErrInvalidCapture
+			}
+			id := r.order.Uint32(body[0:])
+			capLen := r.order.Uint32(body[12:])
+			if int(id) >= len(r.ifaces) || capLen > uint32(len(body)-20) {
+				return 0, time.Time{}, nil, ErrInvalidCapture
+			}
+			iface := r.ifaces[id]
+
+			ticks := uint64(r.order.Uint32(body[4:]))<<32 | uint64(r.order.Uint32(body[8:]))
+			return iface.linkType, ticksToTime(ticks, iface.ticksPerSec), body[20 : 20+capLen], nil
+
+		case blockSimplePacket:
+			if len(body) < 4 || len(r.ifaces) == 0 {
+				return 0, time.Time{}, nil, ErrInvalidCapture
+			}
+			// Simple packets belong to the first interface and
+			// fill the block up to its padding.
+			capLen := r.order.Uint32(body[0:])
+			if capLen > uint32(len(body)-4) {
+				capLen = uint32(len(body) - 4)
+			}
+			return r.ifaces[0].linkType, time.Time{}, body[4 : 4+capLen], nil
+		}
+	}
+}
+
+// readBlock reads the next pcapng block and returns its type and body.
+//
+// Section header blocks are handled here: they set the byte order and reset
+// the interfaces for the following blocks.
+func (r *Reader) readBlock() (uint32, []byte, error) {
+	var hdr [12]byte
+	if err := r.readFull(hdr[:8]); err != nil {
+		return 0, nil, err
+	}
+
+	typ := binary.LittleEndian.Uint32(hdr[0:])
+	if typ == blockSectionHeader {
+		// The byte order magic follows the length and determines
+		// how to read the length.
+		if err := r.readFull(hdr[8:12]); err != nil {
+			return 0, nil, ErrInvalidCapture
+		}
+		switch {
+		case binary.LittleEndian.Uint32(hdr[8:]) == byteOrderMagic:
+			r.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(hdr[8:]) == byteOrderMagic:
+			r.order = binary.BigEndian
+		default:
+			return 0, nil, ErrInvalidCapture
+		}
+		r.ifaces = nil
+	} else if r.order == nil {
+		return 0, nil, ErrInvalidCapture
+	} else {
+		typ = r.order.Uint32(hdr[0:])
+	}
+
+	total := r.order.Uint32(hdr[4:])
+	if total < 12 || total%4 != 0 || total > maxBlockLen {
+		return 0, nil, ErrInvalidCapture
+	}
+
+	// Read the rest of the block: the body and the trailing length.
+	rest := make([]byte, total-8)
+	read := rest
+	if typ == blockSectionHeader {
+		copy(rest, hdr[8:12])
+		read = rest[4:]
+	}
+	if _, err := io.ReadFull(r.r, read); err != nil {
+		return 0, nil, ErrInvalidCapture
+	}
+	if r.order.Uint32(rest[len(rest)-4:]) != total {
+		return 0, nil, ErrInvalidCapture
+	}
+	return typ, rest[:len(rest)-4], nil
+}
+
+// tsResolution returns the number of timestamp ticks per second given the
+// options of an interface description block.
+func (r *Reader) tsResolution(opts []byte) uint64 {
+	const optEnd, optTSResol = 0, 9
+
+	for len(opts) >= 4 {
+		code := r.order.Uint16(opts[0:])
+		length := int(r.order.Uint16(opts[2:]))
+		next := 4 + (length+3)&^3
+		if code == optEnd || next > len(opts) {
+			break
+		}
+		if code == optTSResol && length >= 1 {
+			v := opts[4]
+			if v&0x80 != 0 && v&0x7f < 64 {
+				return 1 << (v & 0x7f)
+			} else if v <= 19 {
+				perSec := uint64(1)
+				for ; v > 0; v-- {
+					perSec *= 10
+				}
+				return perSec
+			}
+			break
+		}
+		opts = opts[next:]
+	}
+	// Microseconds by default.
+	return 1e6
+}
+
+// ticksToTime converts a timestamp counted in ticks since the Unix epoch.
+func ticksToTime(ticks, perSec uint64) time.Time {
+	sec := ticks / perSec
+	// The remainder is less than perSec, so the division cannot
+	// overflow.
+	hi, lo := bits.Mul64(ticks%perSec, uint64(time.Second))
+	ns, _ := bits.Div64(hi, lo, perSec)
+	return time.Unix(int64(sec), int64(ns))
+}
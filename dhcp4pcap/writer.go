@@ -0,0 +1,108 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// pcapng block types.
+const (
+	blockSectionHeader        = 0x0a0d0d0a
+	blockInterfaceDescription = 0x00000001
+	blockSimplePacket         = 0x00000003
+	blockEnhancedPacket       = 0x00000006
+)
+
+// byteOrderMagic is written in the section header block so that readers can
+// detect the byte order of the section.
+const byteOrderMagic = 0x1a2b3c4d
+
+// Writer writes packets to a pcapng capture.
+//
+// A Writer is safe for concurrent use.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter writes a pcapng section header and a single Ethernet interface
+// description to w and returns a Writer for packets on that interface.
+func NewWriter(w io.Writer) (*Writer, error) {
+	pw := &Writer{w: w}
+
+	var shb [16]byte
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	// Version 1.0.
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint16(shb[6:], 0)
+	// Section length is unspecified.
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+	if err := pw.writeBlock(blockSectionHeader, shb[:]); err != nil {
+		return nil, err
+	}
+
+	var idb [8]byte
+	binary.LittleEndian.PutUint16(idb[0:], linkTypeEthernet)
+	// Reserved 16 bits, then a snap length of 0 meaning unlimited.
+	if err := pw.writeBlock(blockInterfaceDescription, idb[:]); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// writeBlock writes a block of the given type and body, padding the body to
+// 32 bits.
+func (w *Writer) writeBlock(typ uint32, body []byte) error {
+	padded := (len(body) + 3) &^ 3
+	total := 12 + padded
+
+	b := make([]byte, total)
+	binary.LittleEndian.PutUint32(b[0:], typ)
+	binary.LittleEndian.PutUint32(b[4:], uint32(total))
+	copy(b[8:], body)
+	binary.LittleEndian.PutUint32(b[total-4:], uint32(total))
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+// WriteFrame writes a captured Ethernet frame.
+func (w *Writer) WriteFrame(ts time.Time, frame []byte) error {
+	body := make([]byte, 20+len(frame))
+	// Interface 0, as written by NewWriter.
+	binary.LittleEndian.PutUint32(body[0:], 0)
+
+	// Timestamps are in microseconds, the default resolution.
+	us := uint64(ts.UnixNano() / int64(time.Microsecond))
+	binary.LittleEndian.PutUint32(body[4:], uint32(us>>32))
+	binary.LittleEndian.PutUint32(body[8:], uint32(us))
+	binary.LittleEndian.PutUint32(body[12:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(body[16:], uint32(len(frame)))
+	copy(body[20:], frame)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeBlock(blockEnhancedPacket, body)
+}
+
+// WriteUDP writes a UDP payload sent from src to dst, synthesizing the
+// Ethernet, IPv4, and UDP headers.
+func (w *Writer) WriteUDP(ts time.Time, src, dst *net.UDPAddr, payload []byte) error {
+	frame, err := encode(payload, src, dst)
+	if err != nil {
+		return err
+	}
+	return w.WriteFrame(ts, frame)
+}
+
+// WritePacket writes p, synthesizing the Ethernet, IPv4, and UDP headers.
+func (w *Writer) WritePacket(p *Packet) error {
+	return w.WriteUDP(p.Timestamp, p.Src, p.Dst, p.Payload)
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dhcp4prom exports the measurements of DHCP clients and servers to
+// Prometheus.
+//
+// An Exporter counts what a dhcp4client.Client or dhcp4test.Server reports to
+// it, and serves the counts over HTTP in the Prometheus text exposition
+// format:
+//
+//	e := dhcp4prom.New("dhcp4client")
+//	http.Handle("/metrics", e)
+//	client, err := dhcp4client.New(iface, dhcp4client.WithMetrics(e))
+//
+// The Prometheus client library is not needed.
+package dhcp4prom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the handshake duration
+// histogram. Retransmissions make handshakes last from milliseconds to
+// minutes.
+var DefaultBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Exporter is a dhcp4client.Metrics and dhcp4test.Metrics serving its
+// measurements to Prometheus. It is safe for concurrent use.
+type Exporter struct {
+	namespace string
+	buckets   []float64
+
+	mu              sync.Mutex
+	sent            map[dhcp4opts.DHCPMessageType]uint64
+	received        map[dhcp4opts.DHCPMessageType]uint64
+	retransmissions map[dhcp4opts.DHCPMessageType]uint64
+	timeouts        map[dhcp4opts.DHCPMessageType]uint64
+
+	// bucketCounts[i] counts handshakes that took at most buckets[i]
+	// but longer than buckets[i-1].
+	bucketCounts []uint64
+	handshakes   uint64
+	seconds      float64
+	failures     uint64
+}
+
+// New returns an Exporter whose metric names start with namespace, such as
+// "dhcp4client" for dhcp4client_packets_sent_total.
+func New(namespace string) *Exporter {
+	return &Exporter{
+		namespace:       namespace,
+		buckets:         append([]float64(nil), DefaultBuckets...),
+		sent:            make(map[dhcp4opts.DHCPMessageType]uint64),
+		received:        make(map[dhcp4opts.DHCPMessageType]uint64),
+		retransmissions: make(map[dhcp4opts.DHCPMessageType]uint64),
+		timeouts:        make(map[dhcp4opts.DHCPMessageType]uint64),
+		bucketCounts:    make([]uint64, len(DefaultBuckets)),
+	}
+}
+
+func (e *Exporter) count(counts map[dhcp4opts.DHCPMessageType]uint64, mt dhcp4opts.DHCPMessageType) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	counts[mt]++
+}
+
+// PacketSent implements dhcp4client.Metrics.PacketSent.
+func (e *Exporter) PacketSent(mt dhcp4opts.DHCPMessageType) { e.count(e.sent, mt) }
+
+// PacketReceived implements dhcp4client.Metrics.PacketReceived.
+func (e *Exporter) PacketReceived(mt dhcp4opts.DHCPMessageType) { e.count(e.received, mt) }
+
+// Retransmission implements dhcp4client.Metrics.Retransmission.
+func (e *Exporter) Retransmission(mt dhcp4opts.DHCPMessageType) { e.count(e.retransmissions, mt) }
+
+// Timeout implements dhcp4client.Metrics.Timeout.
+func (e *Exporter) Timeout(mt dhcp4opts.DHCPMessageType) { e.count(e.timeouts, mt) }
+
+// Handshake implements dhcp4client.Metrics.Handshake.
+func (e *Exporter) Handshake(d time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.failures++
+	}
+	e.handshakes++
+	e.seconds += d.Seconds()
+	if i := sort.SearchFloat64s(e.buckets, d.Seconds()); i < len(e.buckets) {
+		e.bucketCounts[i]++
+	}
+}
+
+// ServeHTTP serves the measurements in the Prometheus text exposition
+// format, version 0.0.4.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteTo(w)
+}
+
+// WriteTo writes the measurements to w in the Prometheus text exposition
+// format, such as for the textfile collector of the node exporter.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	e.write(&b)
+	return b.WriteTo(w)
+}
+
+// write writes the measurements to b.
+func (e *Exporter) write(b *bytes.Buffer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.writeCounters(b, "packets_sent_total", "DHCP packets sent, by message type.", e.sent)
+	e.writeCounters(b, "packets_received_total", "DHCP packets received, by message type.", e.received)
+	e.writeCounters(b, "retransmissions_total", "Requests sent again for want of a response, by message type.", e.retransmissions)
+	e.writeCounters(b, "timeouts_total", "Requests whose retransmissions all went unanswered, by message type.", e.timeouts)
+
+	name := e.namespace + "_handshake_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s Duration of handshakes obtaining or extending a lease.\n", name)
+	fmt.Fprintf(b, "# TYPE %s histogram\n", name)
+	var cumulative uint64
+	for i, le := range e.buckets {
+		cumulative += e.bucketCounts[i]
+		fmt.Fprintf(b, "%s_bucket{le=%q} %d\n", name, formatFloat(le), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, e.handshakes)
+	fmt.Fprintf(b, "%s_sum %s\n", name, formatFloat(e.seconds))
+	fmt.Fprintf(b, "%s_count %d\n", name, e.handshakes)
+
+	name = e.namespace + "_handshake_failures_total"
+	fmt.Fprintf(b, "# HELP %s Handshakes that returned an error.\n", name)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	fmt.Fprintf(b, "%s %d\n", name, e.failures)
+}
+
+// writeCounters writes a counter labeled with message types. e.mu must be
+// held.
+func (e *Exporter) writeCounters(w io.Writer, name, help string, counts map[dhcp4opts.DHCPMessageType]uint64) {
+	name = e.namespace + "_" + name
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	types := make([]dhcp4opts.DHCPMessageType, 0, len(counts))
+	for mt := range counts {
+		types = append(types, mt)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	for _, mt := range types {
+		fmt.Fprintf(w, "%s{type=%q} %d\n", name, dhcp4.MessageType(mt).String(), counts[mt])
+	}
+}
+
+// formatFloat formats f as Prometheus expects.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4prom
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4client"
+	"github.com/u-root/dhcp4/dhcp4opts"
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+var (
+	_ dhcp4client.Metrics = (*Exporter)(nil)
+	_ dhcp4test.Metrics   = (*Exporter)(nil)
+)
+
+func TestExporter(t *testing.T) {
+	server := New("dhcp4server")
+	srv, conn := dhcp4test.Start(dhcp4test.Config{Metrics: server}, dhcp4test.Drop(), dhcp4test.Offer(), dhcp4test.Nak(""))
+
+	client := New("dhcp4client")
+	c, err := dhcp4client.New(&dhcp4client.Interface{HardwareAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}},
+		dhcp4client.WithConn(conn), dhcp4client.WithTimeout(100*time.Millisecond), dhcp4client.WithRetry(1), dhcp4client.WithMetrics(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	srv.Close()
+
+	for _, tt := range []struct {
+		e    *Exporter
+		want []string
+	}{
+		{client, []string{
+			"# TYPE dhcp4client_packets_sent_total counter\n",
+			`dhcp4client_packets_sent_total{type="DHCPDISCOVER"} 2` + "\n",
+			`dhcp4client_packets_sent_total{type="DHCPREQUEST"} 1` + "\n",
+			`dhcp4client_packets_received_total{type="DHCPNAK"} 1` + "\n",
+			`dhcp4client_timeouts_total{type="DHCPDISCOVER"} 1` + "\n",
+			"# TYPE dhcp4client_handshake_duration_seconds histogram\n",
+			`dhcp4client_handshake_duration_seconds_bucket{le="+Inf"} 1` + "\n",
+			"dhcp4client_handshake_duration_seconds_count 1\n",
+			"dhcp4client_handshake_failures_total 0\n",
+		}},
+		{server, []string{
+			`dhcp4server_packets_received_total{type="DHCPDISCOVER"} 2` + "\n",
+			`dhcp4server_packets_sent_total{type="DHCPOFFER"} 1` + "\n",
+			`dhcp4server_packets_sent_total{type="DHCPNAK"} 1` + "\n",
+		}},
+	} {
+		rec := httptest.NewRecorder()
+		tt.e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+		}
+		body := rec.Body.String()
+		for _, line := range tt.want {
+			if !strings.Contains(body, line) {
+				t.Errorf("metrics do not contain %q:\n%s", line, body)
+			}
+		}
+	}
+}
+
+func TestHandshakeBuckets(t *testing.T) {
+	e := New("test")
+	e.Handshake(20*time.Millisecond, nil)
+	e.Handshake(3*time.Second, errors.New("timeout"))
+	e.Handshake(2*time.Minute, nil)
+	e.PacketSent(dhcp4opts.DHCPDiscover)
+
+	var b strings.Builder
+	if _, err := e.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`test_handshake_duration_seconds_bucket{le="0.01"} 0`,
+		`test_handshake_duration_seconds_bucket{le="0.05"} 1`,
+		`test_handshake_duration_seconds_bucket{le="5"} 2`,
+		`test_handshake_duration_seconds_bucket{le="60"} 2`,
+		`test_handshake_duration_seconds_bucket{le="+Inf"} 3`,
+		`test_handshake_duration_seconds_sum 123.02`,
+		`test_handshake_failures_total 1`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4resolv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultPath is where resolv.conf usually lives.
+const DefaultPath = "/etc/resolv.conf"
+
+// backupSuffix is appended to the path of a File's backup.
+const backupSuffix = ".dhcp4resolv"
+
+// File is a Resolver writing resolv.conf itself.
+//
+// The first Apply moves the existing file aside, to Path with the suffix
+// ".dhcp4resolv", and Revert moves it back. Writes are atomic, so resolvers
+// never see a partial file.
+type File struct {
+	// Path is the resolv.conf file, such as DefaultPath.
+	Path string
+
+	mu sync.Mutex
+}
+
+// Apply implements Resolver.Apply.
+func (f *File) Apply(c Config) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := c.MarshalText()
+	if err != nil {
+		return err
+	}
+	backup := f.Path + backupSuffix
+	if _, err := os.Lstat(backup); os.IsNotExist(err) {
+		// Keep the original, even if it is a symlink, as is common
+		// for resolv.conf.
+		if err := os.Rename(f.Path, backup); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return writeFile(f.Path, b)
+}
+
+// Revert implements Resolver.Revert.
+//
+// Revert does nothing if Apply has not changed the file.
+func (f *File) Revert() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Rename(f.Path+backupSuffix, f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// writeFile atomically replaces the file at path with b.
+func writeFile(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,129 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dhcp4resolv configures DNS resolution from DHCP leases, as the
+// dhclient-script of ISC dhclient does.
+//
+// A Resolver is typically installed as a client hook, configuring DNS when a
+// lease is acked or renewed and reverting it when the lease is released:
+//
+//	r := &dhcp4resolv.File{Path: dhcp4resolv.DefaultPath}
+//	client, err := dhcp4client.New(iface, dhcp4client.WithHook(dhcp4resolv.Hook(r)))
+package dhcp4resolv
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4client"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// Config is the DNS configuration of a lease.
+type Config struct {
+	// Nameservers are the DNS servers, from option 6.
+	Nameservers []net.IP
+
+	// Domain is the local domain name, from option 15.
+	Domain string
+
+	// Search is the search list, from option 119, or if the lease has
+	// none, Domain.
+	Search []string
+}
+
+// FromLease returns the DNS configuration of ack.
+//
+// Domain names that are not valid RFC 1035 host names are dropped, as ISC
+// dhclient does, since they are chosen by the server and could otherwise
+// inject lines into resolv.conf.
+func FromLease(ack *dhcp4.Packet) Config {
+	c := Config{
+		Nameservers: dhcp4opts.GetDomainNameServers(ack.Options),
+	}
+	if name := dhcp4opts.GetDomainName(ack.Options); dhcp4client.CheckDomainName(name) == nil {
+		c.Domain = name
+	}
+	for _, name := range dhcp4opts.GetDomainSearch(ack.Options) {
+		if dhcp4client.CheckDomainName(name) == nil {
+			c.Search = append(c.Search, name)
+		}
+	}
+	if len(c.Search) == 0 && c.Domain != "" {
+		c.Search = []string{c.Domain}
+	}
+	return c
+}
+
+// check returns an error if a domain name of c is not a valid host name.
+func (c Config) check() error {
+	if c.Domain != "" {
+		if err := dhcp4client.CheckDomainName(c.Domain); err != nil {
+			return err
+		}
+	}
+	for _, name := range c.Search {
+		if err := dhcp4client.CheckDomainName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalText returns c in resolv.conf(5) format. It returns an error if a
+// domain name of c is not a valid host name.
+func (c Config) MarshalText() ([]byte, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString("# Generated by dhcp4resolv.\n")
+	if c.Domain != "" {
+		fmt.Fprintf(&b, "domain %s\n", c.Domain)
+	}
+	if len(c.Search) > 0 {
+		b.WriteString("search")
+		for _, s := range c.Search {
+			fmt.Fprintf(&b, " %s", s)
+		}
+		b.WriteString("\n")
+	}
+	for _, ns := range c.Nameservers {
+		fmt.Fprintf(&b, "nameserver %s\n", ns)
+	}
+	return b.Bytes(), nil
+}
+
+// Resolver is a system resolver configuration that DHCP leases can update.
+type Resolver interface {
+	// Apply replaces the configuration with c.
+	Apply(c Config) error
+
+	// Revert undoes all Apply calls, restoring the configuration the
+	// system had before.
+	Revert() error
+}
+
+// Hook returns a client hook, for dhcp4client.WithHook, applying the DNS
+// configuration of leases acked or renewed to r and reverting r when a lease
+// is released.
+//
+// Leases without DNS servers leave r alone.
+func Hook(r Resolver) func(dhcp4client.Event) error {
+	return func(e dhcp4client.Event) error {
+		switch e.Type {
+		case dhcp4client.LeaseAcked, dhcp4client.LeaseRenewed:
+			c := FromLease(e.Packet)
+			if len(c.Nameservers) == 0 {
+				return nil
+			}
+			return r.Apply(c)
+		case dhcp4client.LeaseReleased:
+			return r.Revert()
+		}
+		return nil
+	}
+}
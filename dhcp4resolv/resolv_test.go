@@ -0,0 +1,134 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4resolv
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4client"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+func testLease() *dhcp4.Packet {
+	ack := dhcp4.NewPacket(dhcp4.BootReply)
+	ack.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPACK)
+	ack.Options.Add(dhcp4.OptionDomainNameServers, dhcp4opts.IPs{{192, 168, 0, 1}, {8, 8, 8, 8}})
+	ack.Options.Add(dhcp4.OptionDomainName, dhcp4opts.String("example.com"))
+	return ack
+}
+
+func TestFromLease(t *testing.T) {
+	got := FromLease(testLease())
+	want := Config{
+		Nameservers: []net.IP{{192, 168, 0, 1}, {8, 8, 8, 8}},
+		Domain:      "example.com",
+		Search:      []string{"example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromLease() = %+v, want %+v", got, want)
+	}
+
+	b, _ := got.MarshalText()
+	wantText := "# Generated by dhcp4resolv.\ndomain example.com\nsearch example.com\nnameserver 192.168.0.1\nnameserver 8.8.8.8\n"
+	if string(b) != wantText {
+		t.Errorf("MarshalText() = %q, want %q", b, wantText)
+	}
+}
+
+func TestFromLeaseInvalidNames(t *testing.T) {
+	ack := testLease()
+	ack.Options.Del(dhcp4.OptionDomainName)
+	ack.Options.Add(dhcp4.OptionDomainName, dhcp4opts.String("x\nnameserver 6.6.6.6"))
+	ack.Options.Add(dhcp4.OptionDomainSearch, dhcp4opts.DomainSearch{"example.com", "options ndots:15"})
+
+	got := FromLease(ack)
+	want := Config{
+		Nameservers: []net.IP{{192, 168, 0, 1}, {8, 8, 8, 8}},
+		Search:      []string{"example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromLease() = %+v, want %+v", got, want)
+	}
+
+	for _, c := range []Config{
+		{Domain: "x\noptions rotate"},
+		{Search: []string{"example.com", "x\nnameserver 6.6.6.6"}},
+	} {
+		if b, err := c.MarshalText(); err == nil {
+			t.Errorf("MarshalText(%+v) = %q, want error", c, b)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dhcp4resolv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "resolv.conf")
+	const original = "nameserver 127.0.0.53\n"
+	if err := ioutil.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := Hook(&File{Path: path})
+	for _, typ := range []dhcp4client.EventType{dhcp4client.LeaseAcked, dhcp4client.LeaseRenewed} {
+		if err := hook(dhcp4client.Event{Type: typ, Packet: testLease()}); err != nil {
+			t.Fatalf("hook(%v) = %v", typ, err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "nameserver 8.8.8.8\n") {
+			t.Errorf("after %v, resolv.conf is %q", typ, b)
+		}
+	}
+
+	if err := hook(dhcp4client.Event{Type: dhcp4client.LeaseReleased, Packet: testLease()}); err != nil {
+		t.Fatalf("hook(released) = %v", err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != original {
+		t.Errorf("after release, resolv.conf is %q, %v, want %q", b, err, original)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("%d files left behind, want 1", len(files))
+	}
+}
+
+func TestResolved(t *testing.T) {
+	var got [][]string
+	r := &Resolved{
+		Interface: "eth0",
+		run: func(name string, args ...string) error {
+			got = append(got, append([]string{name}, args...))
+			return nil
+		},
+	}
+	if err := r.Apply(FromLease(testLease())); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Revert(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"resolvectl", "dns", "eth0", "192.168.0.1", "8.8.8.8"},
+		{"resolvectl", "domain", "eth0", "example.com"},
+		{"resolvectl", "revert", "eth0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4resolv
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Resolved is a Resolver configuring the DNS servers and search domains of
+// an interface in systemd-resolved, for systems where resolv.conf is
+// managed by it.
+//
+// It runs resolvectl(1), which talks to systemd-resolved over D-Bus.
+type Resolved struct {
+	// Interface is the name of the interface the lease is for.
+	Interface string
+
+	// run runs a command, for tests.
+	run func(name string, args ...string) error
+}
+
+// Apply implements Resolver.Apply.
+func (r *Resolved) Apply(c Config) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	dns := []string{"dns", r.Interface}
+	for _, ns := range c.Nameservers {
+		dns = append(dns, ns.String())
+	}
+	if err := r.resolvectl(dns...); err != nil {
+		return err
+	}
+	return r.resolvectl(append([]string{"domain", r.Interface}, c.Search...)...)
+}
+
+// Revert implements Resolver.Revert.
+func (r *Resolved) Revert() error {
+	return r.resolvectl("revert", r.Interface)
+}
+
+func (r *Resolved) resolvectl(args ...string) error {
+	if r.run != nil {
+		return r.run("resolvectl", args...)
+	}
+	if out, err := exec.Command("resolvectl", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("resolvectl %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package conformance holds wire-format test vectors for DHCPv4 packets and a
+// test helper running them against a packet decoder.
+//
+// The vectors cover well-formed packets, with the fields and options they must
+// parse to, and malformed packets that must be rejected. They can be run
+// against this package's own decoder or any other implementation:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, func(wire []byte) (*dhcp4.Packet, error) {
+//			return myimpl.Parse(wire) // converted to a *dhcp4.Packet
+//		})
+//	}
+//
+// Vectors are JSON files in the vectors directory, with the expected packet in
+// the encoding of dhcp4.Packet.MarshalJSON. Captures from other
+// implementations are welcome as additional vectors.
+package conformance
+
+import (
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/u-root/dhcp4"
+)
+
+//go:embed vectors/*.json
+var vectorFS embed.FS
+
+// Vector is a packet in wire format and how it must be parsed.
+type Vector struct {
+	// Name identifies the vector.
+	Name string `json:"name"`
+
+	// Description says what the vector exercises and, for captures,
+	// which implementation sent it.
+	Description string `json:"description"`
+
+	// Wire is the hex-encoded packet, from the op field to the end of
+	// the UDP payload.
+	Wire string `json:"wire"`
+
+	// Error is true if the packet is malformed and must be rejected.
+	Error bool `json:"error,omitempty"`
+
+	// Packet is what the packet must parse to, unless Error is set.
+	Packet *dhcp4.Packet `json:"packet,omitempty"`
+}
+
+// Bytes returns the packet in wire format.
+func (v Vector) Bytes() ([]byte, error) {
+	return hex.DecodeString(v.Wire)
+}
+
+// Vectors returns the test vectors, sorted by name.
+func Vectors() ([]Vector, error) {
+	files, err := vectorFS.ReadDir("vectors")
+	if err != nil {
+		return nil, err
+	}
+
+	vs := make([]Vector, 0, len(files))
+	for _, f := range files {
+		b, err := vectorFS.ReadFile(path.Join("vectors", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var v Vector
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("vector %s: %v", f.Name(), err)
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// A Decoder parses a packet in wire format.
+type Decoder func(wire []byte) (*dhcp4.Packet, error)
+
+// Run runs every vector against decode as a subtest of t.
+//
+// Decoded packets are compared to the expected ones with dhcp4.Diff, so
+// option order and unset addresses do not matter.
+func Run(t *testing.T, decode Decoder) {
+	vs, err := Vectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vs {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			wire, err := v.Bytes()
+			if err != nil {
+				t.Fatalf("invalid vector: %v", err)
+			}
+
+			p, err := decode(wire)
+			if v.Error {
+				if err == nil {
+					t.Errorf("%s: decode() = nil error, want error", v.Description)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: decode() = %v", v.Description, err)
+			}
+			for _, d := range dhcp4.Diff(p, v.Packet) {
+				t.Errorf("%s: %v", v.Description, d)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package conformance
+
+import (
+	"testing"
+
+	"github.com/u-root/dhcp4"
+)
+
+func TestUnmarshalBinary(t *testing.T) {
+	Run(t, func(wire []byte) (*dhcp4.Packet, error) {
+		p := &dhcp4.Packet{}
+		if err := p.UnmarshalBinary(wire); err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
+}
+
+// TestRoundTrip checks that the packets the vectors parse to survive
+// marshaling, even though padding and option splitting may differ on the
+// wire.
+func TestRoundTrip(t *testing.T) {
+	vs, err := Vectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vs {
+		if v.Error {
+			continue
+		}
+		b, err := v.Packet.MarshalBinary()
+		if err != nil {
+			t.Errorf("%s: MarshalBinary() = %v", v.Name, err)
+			continue
+		}
+		var got dhcp4.Packet
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Errorf("%s: UnmarshalBinary() = %v", v.Name, err)
+			continue
+		}
+		for _, d := range dhcp4.Diff(&got, v.Packet) {
+			t.Errorf("%s: %v", v.Name, d)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var errClosed = errors.New("use of closed connection")
+
+// defaultQueueLen is how many packets an endpoint queues for reading before
+// dropping new ones.
+const defaultQueueLen = 64
+
+// timeoutError is returned by ReadFrom when the read deadline passes. Like
+// the errors of real connections, it reports true for Timeout().
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type datagram struct {
+	from    net.Addr
+	payload []byte
+}
+
+// endpoint is an in-memory packet connection. Packets written to it are
+// handed to send; packets delivered to it are queued for ReadFrom.
+type endpoint struct {
+	local net.Addr
+	in    chan datagram
+	send  func(p datagram, to net.Addr)
+
+	mu       sync.Mutex
+	deadline time.Time
+	done     chan struct{}
+	closed   bool
+}
+
+func newEndpoint(local net.Addr, queueLen int) *endpoint {
+	return &endpoint{
+		local: local,
+		in:    make(chan datagram, queueLen),
+		done:  make(chan struct{}),
+	}
+}
+
+// deliver queues p for reading. Packets delivered to a closed endpoint or to
+// a full queue are dropped, just as a network would drop them.
+func (c *endpoint) deliver(p datagram) {
+	select {
+	case <-c.done:
+		// Nobody is listening any more.
+	case c.in <- p:
+	default:
+		// Queue full; drop it.
+	}
+}
+
+// NewConnPair returns two connected in-memory packet connections with the
+// given local addresses.
+//
+// Every packet written to one end, regardless of destination address, is read
+// from the other end with the writer's local address as source. Writes never
+// block: packets that do not fit in the receive queue are dropped.
+func NewConnPair(aAddr, bAddr net.Addr) (net.PacketConn, net.PacketConn) {
+	a := newEndpoint(aAddr, defaultQueueLen)
+	b := newEndpoint(bAddr, defaultQueueLen)
+	a.send = func(p datagram, _ net.Addr) { b.deliver(p) }
+	b.send = func(p datagram, _ net.Addr) { a.deliver(p) }
+	return a, b
+}
+
+// ReadFrom implements net.PacketConn.ReadFrom.
+func (c *endpoint) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, nil, &net.OpError{Op: "read", Err: timeoutError{}}
+		}
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case p := <-c.in:
+		return copy(b, p.payload), p.from, nil
+	case <-c.done:
+		return 0, nil, &net.OpError{Op: "read", Err: errClosed}
+	case <-timeout:
+		return 0, nil, &net.OpError{Op: "read", Err: timeoutError{}}
+	}
+}
+
+// WriteTo implements net.PacketConn.WriteTo.
+func (c *endpoint) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.done:
+		return 0, &net.OpError{Op: "write", Err: errClosed}
+	default:
+	}
+
+	c.send(datagram{
+		from:    c.local,
+		payload: append([]byte(nil), b...),
+	}, addr)
+	return len(b), nil
+}
+
+// Close implements net.PacketConn.Close.
+func (c *endpoint) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClosed
+	}
+	c.closed = true
+	close(c.done)
+	return nil
+}
+
+// LocalAddr implements net.PacketConn.LocalAddr.
+func (c *endpoint) LocalAddr() net.Addr {
+	return c.local
+}
+
+// SetDeadline implements net.PacketConn.SetDeadline.
+func (c *endpoint) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline implements net.PacketConn.SetReadDeadline.
+func (c *endpoint) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+// SetWriteDeadline implements net.PacketConn.SetWriteDeadline.
+//
+// Writes never block, so write deadlines are ignored.
+func (c *endpoint) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4test
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// NetworkConfig describes the impairments of a simulated Network.
+type NetworkConfig struct {
+	// Latency is how long packets take to be delivered.
+	Latency time.Duration
+
+	// Jitter is the largest random delay added to Latency. Packets
+	// delayed by different amounts may be delivered out of order.
+	Jitter time.Duration
+
+	// Loss is the probability, from 0 to 1, that a packet is dropped.
+	Loss float64
+
+	// Duplicate is the probability, from 0 to 1, that a packet is
+	// delivered twice.
+	Duplicate float64
+
+	// Seed seeds the random choices of the Network. Runs with the same
+	// seed that write packets in the same order drop, duplicate, and
+	// delay the same packets.
+	Seed int64
+
+	// QueueLen is how many packets each connection queues for reading.
+	// Packets arriving at a full queue are dropped. If zero, 64 is used.
+	QueueLen int
+}
+
+// Network is a simulated broadcast network segment connecting in-memory
+// packet connections, such as many DHCP clients and a server.
+//
+// A packet sent to a broadcast or unspecified IP address is delivered to
+// every other connection listening on the destination port. A packet sent to
+// any other address is delivered to the connections listening on exactly that
+// address.
+type Network struct {
+	cfg NetworkConfig
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	conns []*endpoint
+}
+
+// NewNetwork returns an empty Network with the given impairments.
+func NewNetwork(cfg NetworkConfig) *Network {
+	if cfg.QueueLen == 0 {
+		cfg.QueueLen = defaultQueueLen
+	}
+	return &Network{
+		cfg:  cfg,
+		rand: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+// Listen returns a connection on the Network with local address addr.
+//
+// Several connections may listen on the same address; DHCP clients that have
+// no address yet all listen on 0.0.0.0:68.
+func (n *Network) Listen(addr *net.UDPAddr) net.PacketConn {
+	c := newEndpoint(addr, n.cfg.QueueLen)
+	c.send = func(p datagram, to net.Addr) {
+		n.send(c, p, to)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.conns = append(n.conns, c)
+	return c
+}
+
+// receives reports whether a packet sent to dst is delivered to c.
+func receives(c *endpoint, dst *net.UDPAddr) bool {
+	local, ok := c.local.(*net.UDPAddr)
+	if !ok || local.Port != dst.Port {
+		return false
+	}
+	if dst.IP == nil || dst.IP.IsUnspecified() || dst.IP.Equal(net.IPv4bcast) {
+		return true
+	}
+	return local.IP.Equal(dst.IP)
+}
+
+// send delivers p from c to every connection that receives packets sent to
+// to, subject to the Network's impairments.
+func (n *Network) send(from *endpoint, p datagram, to net.Addr) {
+	dst, ok := to.(*net.UDPAddr)
+	if !ok {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, c := range n.conns {
+		if c == from || !receives(c, dst) {
+			continue
+		}
+
+		copies := 1
+		if n.chance(n.cfg.Loss) {
+			copies = 0
+		} else if n.chance(n.cfg.Duplicate) {
+			copies = 2
+		}
+		for i := 0; i < copies; i++ {
+			n.deliverAfter(c, p, n.delay())
+		}
+	}
+}
+
+// chance returns true with probability p. n.mu must be held.
+func (n *Network) chance(p float64) bool {
+	return p > 0 && n.rand.Float64() < p
+}
+
+// delay returns the delay of the next packet. n.mu must be held.
+func (n *Network) delay() time.Duration {
+	d := n.cfg.Latency
+	if n.cfg.Jitter > 0 {
+		d += time.Duration(n.rand.Int63n(int64(n.cfg.Jitter) + 1))
+	}
+	return d
+}
+
+func (n *Network) deliverAfter(c *endpoint, p datagram, d time.Duration) {
+	if d <= 0 {
+		c.deliver(p)
+		return
+	}
+	time.AfterFunc(d, func() {
+		c.deliver(p)
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4client"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+var clientAddr = &net.UDPAddr{IP: net.IPv4zero, Port: dhcp4.ClientPort}
+
+func serveOn(n *Network, script ...Action) *Server {
+	return NewServer(n.Listen(&net.UDPAddr{IP: DefaultServerID, Port: dhcp4.ServerPort}), Config{}, script...)
+}
+
+func TestNetworkManyClients(t *testing.T) {
+	n := NewNetwork(NetworkConfig{
+		Latency:  time.Millisecond,
+		Jitter:   time.Millisecond,
+		QueueLen: 1024,
+	})
+	srv := serveOn(n)
+	defer srv.Close()
+
+	const clients = 20
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			iface := &dhcp4client.Interface{
+				Name:         "test0",
+				HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, byte(i)},
+			}
+			c, err := dhcp4client.New(iface, dhcp4client.WithConn(n.Listen(clientAddr)), dhcp4client.WithTimeout(time.Second))
+			if err != nil {
+				t.Errorf("New() = %v", err)
+				return
+			}
+			defer c.Close()
+
+			ack, err := c.Request()
+			if err != nil {
+				t.Errorf("client %d: Request() = %v", i, err)
+				return
+			}
+			if mt := dhcp4opts.GetDHCPMessageType(ack.Options); mt != dhcp4opts.DHCPACK {
+				t.Errorf("client %d: message type = %d, want ACK", i, mt)
+			}
+			if got, want := ack.CHAddr, iface.HardwareAddr; got.String() != want.String() {
+				t.Errorf("client %d: got reply for %v, want %v", i, got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNetworkLoss(t *testing.T) {
+	n := NewNetwork(NetworkConfig{Loss: 1})
+	srv := serveOn(n)
+	defer srv.Close()
+
+	c := newClient(t, n.Listen(clientAddr), dhcp4client.WithTimeout(50*time.Millisecond), dhcp4client.WithRetry(2))
+	defer c.Close()
+
+	if _, err := c.DiscoverOffer(); err == nil {
+		t.Errorf("DiscoverOffer() = nil error, want timeout")
+	}
+	if got := len(srv.Received()); got != 0 {
+		t.Errorf("server received %d requests, want 0", got)
+	}
+}
+
+func TestNetworkDuplicate(t *testing.T) {
+	n := NewNetwork(NetworkConfig{Duplicate: 1})
+	srv := serveOn(n, Drop(), Drop())
+	defer srv.Close()
+
+	conn := n.Listen(clientAddr)
+	defer conn.Close()
+
+	p := dhcp4.NewPacket(dhcp4.BootRequest)
+	p.Options.Add(dhcp4.OptionDHCPMessageType, dhcp4opts.DHCPDiscover)
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteTo(b, &net.UDPAddr{IP: net.IPv4bcast, Port: dhcp4.ServerPort}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(srv.Received()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := len(srv.Received()); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+func TestNetworkUnicast(t *testing.T) {
+	n := NewNetwork(NetworkConfig{})
+	a := n.Listen(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 68})
+	b := n.Listen(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 68})
+	defer a.Close()
+	defer b.Close()
+
+	sender := n.Listen(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 3), Port: 67})
+	defer sender.Close()
+	if _, err := sender.WriteTo([]byte("hi"), &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 68}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 10)
+	b.SetReadDeadline(time.Now().Add(time.Second))
+	if n, from, err := b.ReadFrom(buf); err != nil || string(buf[:n]) != "hi" || from.String() != "10.0.0.3:67" {
+		t.Errorf("ReadFrom() = %q, %v, %v, want \"hi\" from 10.0.0.3:67", buf[:n], from, err)
+	}
+	a.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, _, err := a.ReadFrom(buf); err == nil {
+		t.Errorf("packet for 10.0.0.2 was delivered to 10.0.0.1")
+	}
+}
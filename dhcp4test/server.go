@@ -0,0 +1,321 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dhcp4test provides an in-memory DHCP server for testing DHCP
+// clients without root privileges or a real network.
+//
+// The server speaks over one end of an in-memory connection pair; the other
+// end can be given to a client with dhcp4client.WithConn:
+//
+//	srv, conn := dhcp4test.Start(dhcp4test.Config{}, dhcp4test.Drop(), dhcp4test.Offer(), dhcp4test.Nak("no"))
+//	defer srv.Close()
+//
+//	client, err := dhcp4client.New(iface, dhcp4client.WithConn(conn))
+//	...
+//
+// Each request the server receives is answered by the next Action of its
+// script. Once the script is exhausted, the server answers Discovers with an
+// Offer and Requests with an ACK.
+//
+// To test many clients against one server, or clients on a lossy network,
+// connect them through a Network instead.
+package dhcp4test
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+// Default addresses used when the corresponding Config field is unset.
+var (
+	DefaultServerID = net.IPv4(192, 168, 0, 1)
+	DefaultYourIP   = net.IPv4(192, 168, 0, 100)
+)
+
+// Config configures the replies of a Server.
+type Config struct {
+	// ServerID is the server identifier and server address sent in
+	// replies. If nil, DefaultServerID is used.
+	ServerID net.IP
+
+	// YourIP is the address offered and acknowledged to clients. If nil,
+	// DefaultYourIP is used.
+	YourIP net.IP
+
+	// Options are added to every Offer and ACK.
+	Options dhcp4.Options
+
+	// Policy, if not nil, is applied to every Offer and ACK after Options
+	// have been added.
+	Policy dhcp4.OptionPolicy
+
+	// Metrics, if not nil, is told about every request received and
+	// reply sent.
+	Metrics Metrics
+}
+
+// Metrics receives measurements of a Server's traffic, for export to a
+// monitoring system. Implementations of dhcp4client.Metrics, such as those
+// of the dhcp4prom package, satisfy it.
+//
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// PacketReceived is called for every request received.
+	PacketReceived(mt dhcp4opts.DHCPMessageType)
+
+	// PacketSent is called for every reply sent.
+	PacketSent(mt dhcp4opts.DHCPMessageType)
+}
+
+// An Action is how a Server answers a single request.
+//
+// It returns the replies to send, in order. Returning no replies drops the
+// request.
+type Action func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet
+
+// Auto answers Discovers with an Offer, and Requests and Informs with an ACK.
+// Leasequeries for the YourIP address are answered as LeaseActive does, and
+// other leasequeries with a DHCPLEASEUNKNOWN. All other requests are dropped.
+// It is what the Server does once its script is exhausted.
+func Auto() Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		switch dhcp4opts.GetDHCPMessageType(req.Options) {
+		case dhcp4opts.DHCPDiscover:
+			return []*dhcp4.Packet{s.Reply(req, dhcp4opts.DHCPOffer)}
+		case dhcp4opts.DHCPRequest:
+			return []*dhcp4.Packet{s.Reply(req, dhcp4opts.DHCPACK)}
+		case dhcp4opts.DHCPInform:
+			// Informing clients already have an address; RFC 2131,
+			// Section 4.3.5 has the server leave yiaddr empty.
+			p := s.Reply(req, dhcp4opts.DHCPACK)
+			p.YIAddr = nil
+			return []*dhcp4.Packet{p}
+		case dhcp4opts.DHCPLeaseQuery:
+			if req.CIAddr.Equal(s.yourIP()) {
+				return LeaseActive()(s, req)
+			}
+			return []*dhcp4.Packet{s.Reply(req, dhcp4opts.DHCPLeaseUnknown)}
+		}
+		return nil
+	}
+}
+
+// LeaseActive answers a DHCPLEASEQUERY with a DHCPLEASEACTIVE for the
+// Server's YourIP address, as if it were bound to the client the query asks
+// about (RFC 4388, Section 6.4.2).
+func LeaseActive() Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		p := s.Reply(req, dhcp4opts.DHCPLeaseActive)
+		p.CIAddr = s.yourIP()
+		p.YIAddr = nil
+		p.Options.Add(dhcp4.OptionClientLastTransactionTime, dhcp4opts.Duration(0))
+		return []*dhcp4.Packet{p}
+	}
+}
+
+// Offer answers the request with a DHCPOffer.
+func Offer() Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		return []*dhcp4.Packet{s.Reply(req, dhcp4opts.DHCPOffer)}
+	}
+}
+
+// Ack answers the request with a DHCPACK.
+func Ack() Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		return []*dhcp4.Packet{s.Reply(req, dhcp4opts.DHCPACK)}
+	}
+}
+
+// Nak answers the request with a DHCPNAK carrying msg as its message option,
+// if msg is not empty.
+func Nak(msg string) Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		p := s.Reply(req, dhcp4opts.DHCPNAK)
+		if msg != "" {
+			p.Options.Add(dhcp4.OptionMessage, dhcp4opts.String(msg))
+		}
+		return []*dhcp4.Packet{p}
+	}
+}
+
+// Drop ignores the request.
+func Drop() Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		return nil
+	}
+}
+
+// Delay waits for d before answering the request as a does.
+//
+// The Server keeps receiving requests while a reply is delayed.
+func Delay(d time.Duration, a Action) Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		select {
+		case <-time.After(d):
+		case <-s.done:
+			return nil
+		}
+		return a(s, req)
+	}
+}
+
+// Repeat answers the request with each reply of a sent n times.
+func Repeat(n int, a Action) Action {
+	return func(s *Server, req *dhcp4.Packet) []*dhcp4.Packet {
+		var out []*dhcp4.Packet
+		for _, p := range a(s, req) {
+			for i := 0; i < n; i++ {
+				out = append(out, p)
+			}
+		}
+		return out
+	}
+}
+
+// Server is a fake DHCP server answering requests received on a
+// net.PacketConn.
+type Server struct {
+	conn net.PacketConn
+	cfg  Config
+
+	mu       sync.Mutex
+	script   []Action
+	received []*dhcp4.Packet
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Start starts a Server following script and returns it together with the
+// client's end of its in-memory connection.
+func Start(cfg Config, script ...Action) (*Server, net.PacketConn) {
+	client, server := NewConnPair(
+		&net.UDPAddr{IP: net.IPv4zero, Port: dhcp4.ClientPort},
+		&net.UDPAddr{IP: serverID(cfg), Port: dhcp4.ServerPort},
+	)
+	return NewServer(server, cfg, script...), client
+}
+
+// NewServer starts a Server following script on conn.
+//
+// The Server owns conn and closes it when the Server is closed.
+func NewServer(conn net.PacketConn, cfg Config, script ...Action) *Server {
+	s := &Server{
+		conn:   conn,
+		cfg:    cfg,
+		script: script,
+		done:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func serverID(cfg Config) net.IP {
+	if cfg.ServerID != nil {
+		return cfg.ServerID
+	}
+	return DefaultServerID
+}
+
+// Script appends actions to the Server's script.
+func (s *Server) Script(actions ...Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, actions...)
+}
+
+// Received returns the requests received so far, in order.
+func (s *Server) Received() []*dhcp4.Packet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*dhcp4.Packet(nil), s.received...)
+}
+
+// Close stops the Server, closes its connection, and waits for pending
+// replies to finish.
+func (s *Server) Close() error {
+	close(s.done)
+	err := s.conn.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Reply returns a reply of type mt to req, filled in from the Server's
+// Config.
+func (s *Server) Reply(req *dhcp4.Packet, mt dhcp4opts.DHCPMessageType) *dhcp4.Packet {
+	sid := serverID(s.cfg)
+	p := dhcp4.BuildReplySkeleton(req, dhcp4.MessageType(mt), sid)
+	switch mt {
+	case dhcp4opts.DHCPNAK, dhcp4opts.DHCPLeaseUnassigned, dhcp4opts.DHCPLeaseUnknown:
+		// These carry no configuration.
+		return p
+	}
+
+	p.SIAddr = sid
+	p.YIAddr = s.yourIP()
+	for _, code := range s.cfg.Options.Codes() {
+		p.Options.AddRaw(code, s.cfg.Options.Get(code))
+	}
+	if s.cfg.Policy != nil {
+		s.cfg.Policy.Apply(req, p)
+	}
+	return p
+}
+
+// yourIP returns the address the Server hands out.
+func (s *Server) yourIP() net.IP {
+	if s.cfg.YourIP != nil {
+		return s.cfg.YourIP
+	}
+	return DefaultYourIP
+}
+
+// next records req and returns the Action that answers it.
+func (s *Server) next(req *dhcp4.Packet) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, req)
+	if len(s.script) == 0 {
+		return Auto()
+	}
+	a := s.script[0]
+	s.script = s.script[1:]
+	return a
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	r := &dhcp4.Receiver{
+		Conn: s.conn,
+		Accept: func(req *dhcp4.Packet, _ net.Addr) bool {
+			return req.Op == dhcp4.BootRequest
+		},
+	}
+	r.Run(s.done, func(req *dhcp4.Packet, addr net.Addr) {
+		if s.cfg.Metrics != nil {
+			s.cfg.Metrics.PacketReceived(dhcp4opts.GetDHCPMessageType(req.Options))
+		}
+		a := s.next(req)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for _, p := range a(s, req) {
+				pkt, err := p.MarshalBinary()
+				if err != nil {
+					continue
+				}
+				if _, err := s.conn.WriteTo(pkt, addr); err == nil && s.cfg.Metrics != nil {
+					s.cfg.Metrics.PacketSent(dhcp4opts.GetDHCPMessageType(p.Options))
+				}
+			}
+		}()
+	})
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4test
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4"
+	"github.com/u-root/dhcp4/dhcp4client"
+	"github.com/u-root/dhcp4/dhcp4opts"
+)
+
+var testIface = &dhcp4client.Interface{
+	Name:         "test0",
+	HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+}
+
+func newClient(t *testing.T, conn net.PacketConn, opts ...dhcp4client.ClientOpt) *dhcp4client.Client {
+	opts = append([]dhcp4client.ClientOpt{dhcp4client.WithConn(conn)}, opts...)
+	c, err := dhcp4client.New(testIface, opts...)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	return c
+}
+
+func TestRequest(t *testing.T) {
+	srv, conn := Start(Config{
+		YourIP: net.IPv4(10, 0, 0, 42),
+		Options: dhcp4.NewOptions(
+			dhcp4.Option{Code: dhcp4.OptionRouters, Value: []byte{10, 0, 0, 1}},
+		),
+	})
+	defer srv.Close()
+
+	c := newClient(t, conn, dhcp4client.WithTimeout(time.Second))
+	defer c.Close()
+
+	ack, err := c.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if mt := dhcp4opts.GetDHCPMessageType(ack.Options); mt != dhcp4opts.DHCPACK {
+		t.Errorf("message type = %d, want ACK", mt)
+	}
+	if want := net.IPv4(10, 0, 0, 42); !ack.YIAddr.Equal(want) {
+		t.Errorf("yiaddr = %v, want %v", ack.YIAddr, want)
+	}
+	if r := dhcp4opts.GetRouters(ack.Options); len(r) != 1 || !r[0].Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("routers = %v, want [10.0.0.1]", r)
+	}
+	if sid := dhcp4opts.GetServerIdentifier(ack.Options); !net.IP(sid).Equal(DefaultServerID) {
+		t.Errorf("server identifier = %v, want %v", sid, DefaultServerID)
+	}
+
+	got := srv.Received()
+	if len(got) != 2 {
+		t.Fatalf("server received %d requests, want 2", len(got))
+	}
+	for i, want := range []dhcp4opts.DHCPMessageType{dhcp4opts.DHCPDiscover, dhcp4opts.DHCPRequest} {
+		if mt := dhcp4opts.GetDHCPMessageType(got[i].Options); mt != want {
+			t.Errorf("request %d: message type = %d, want %d", i, mt, want)
+		}
+	}
+}
+
+func TestNak(t *testing.T) {
+	srv, conn := Start(Config{}, Offer(), Nak("address in use"))
+	defer srv.Close()
+
+	c := newClient(t, conn, dhcp4client.WithTimeout(time.Second))
+	defer c.Close()
+
+	nak, err := c.Request()
+	if err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	if mt := dhcp4opts.GetDHCPMessageType(nak.Options); mt != dhcp4opts.DHCPNAK {
+		t.Errorf("message type = %d, want NAK", mt)
+	}
+	if msg := dhcp4opts.GetString(dhcp4.OptionMessage, nak.Options); msg != "address in use" {
+		t.Errorf("message = %q, want %q", msg, "address in use")
+	}
+	if nak.YIAddr != nil && !nak.YIAddr.IsUnspecified() {
+		t.Errorf("yiaddr = %v, want unspecified", nak.YIAddr)
+	}
+}
+
+func TestDropRetransmit(t *testing.T) {
+	srv, conn := Start(Config{}, Drop(), Delay(10*time.Millisecond, Offer()))
+	defer srv.Close()
+
+	c := newClient(t, conn, dhcp4client.WithTimeout(100*time.Millisecond), dhcp4client.WithRetry(3))
+	defer c.Close()
+
+	offer, err := c.DiscoverOffer()
+	if err != nil {
+		t.Fatalf("DiscoverOffer() = %v", err)
+	}
+	if !offer.YIAddr.Equal(DefaultYourIP) {
+		t.Errorf("yiaddr = %v, want %v", offer.YIAddr, DefaultYourIP)
+	}
+	if n := len(srv.Received()); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
+
+func TestDropAll(t *testing.T) {
+	// Two attempts, and two more with the broadcast flag flipped.
+	srv, conn := Start(Config{}, Drop(), Drop(), Drop(), Drop())
+	defer srv.Close()
+
+	c := newClient(t, conn, dhcp4client.WithTimeout(50*time.Millisecond), dhcp4client.WithRetry(2))
+	defer c.Close()
+
+	if _, err := c.DiscoverOffer(); err == nil {
+		t.Errorf("DiscoverOffer() = nil error, want timeout")
+	}
+}
+
+type countingMetrics struct {
+	mu       sync.Mutex
+	received map[dhcp4opts.DHCPMessageType]int
+	sent     map[dhcp4opts.DHCPMessageType]int
+}
+
+func (m *countingMetrics) PacketReceived(mt dhcp4opts.DHCPMessageType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received[mt]++
+}
+
+func (m *countingMetrics) PacketSent(mt dhcp4opts.DHCPMessageType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent[mt]++
+}
+
+func TestMetrics(t *testing.T) {
+	m := &countingMetrics{
+		received: make(map[dhcp4opts.DHCPMessageType]int),
+		sent:     make(map[dhcp4opts.DHCPMessageType]int),
+	}
+	srv, conn := Start(Config{Metrics: m}, Drop(), Offer(), Nak(""))
+
+	c := newClient(t, conn, dhcp4client.WithTimeout(100*time.Millisecond), dhcp4client.WithRetry(1))
+	defer c.Close()
+	if _, err := c.Request(); err != nil {
+		t.Fatalf("Request() = %v", err)
+	}
+	// Closing the Server waits for its replies to be counted.
+	srv.Close()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if want := map[dhcp4opts.DHCPMessageType]int{dhcp4opts.DHCPDiscover: 2, dhcp4opts.DHCPRequest: 1}; !reflect.DeepEqual(m.received, want) {
+		t.Errorf("received = %v, want %v", m.received, want)
+	}
+	if want := map[dhcp4opts.DHCPMessageType]int{dhcp4opts.DHCPOffer: 1, dhcp4opts.DHCPNAK: 1}; !reflect.DeepEqual(m.sent, want) {
+		t.Errorf("sent = %v, want %v", m.sent, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// FieldDiff is a header field or option that differs between two packets.
+type FieldDiff struct {
+	// Field names the header field, such as "yiaddr", or the option, such
+	// as "option 51".
+	Field string
+
+	// A and B are the values of the field in each packet, or "<none>" for
+	// an option missing from one of them.
+	A, B string
+}
+
+// String implements fmt.Stringer.
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Field, d.A, d.B)
+}
+
+// Equal reports whether p and q have the same header fields and options.
+//
+// Packets are compared as they would be on the wire: nil addresses equal
+// 0.0.0.0, and options are compared by value regardless of their order or of
+// how they were split. See Diff for what differs between unequal packets.
+func (p *Packet) Equal(q *Packet) bool {
+	return len(Diff(p, q)) == 0
+}
+
+// Diff returns the header fields and options that differ between a and b,
+// header fields first in wire order, then options by code.
+func Diff(a, b *Packet) []FieldDiff {
+	var diffs []FieldDiff
+	field := func(name string, x, y interface{}) {
+		if xs, ys := fmt.Sprint(x), fmt.Sprint(y); xs != ys {
+			diffs = append(diffs, FieldDiff{Field: name, A: xs, B: ys})
+		}
+	}
+
+	field("op", a.Op, b.Op)
+	field("htype", a.HType, b.HType)
+	field("hops", a.Hops, b.Hops)
+	field("xid", fmt.Sprintf("%x", a.TransactionID), fmt.Sprintf("%x", b.TransactionID))
+	field("secs", a.Secs, b.Secs)
+	field("flags", fmt.Sprintf("%04x", a.Flags), fmt.Sprintf("%04x", b.Flags))
+	field("ciaddr", wireIP(a.CIAddr), wireIP(b.CIAddr))
+	field("yiaddr", wireIP(a.YIAddr), wireIP(b.YIAddr))
+	field("siaddr", wireIP(a.SIAddr), wireIP(b.SIAddr))
+	field("giaddr", wireIP(a.GIAddr), wireIP(b.GIAddr))
+	field("chaddr", a.wireCHAddr(), b.wireCHAddr())
+	field("sname", a.ServerName, b.ServerName)
+	field("file", a.BootFile, b.BootFile)
+
+	for _, code := range unionCodes(a.Options, b.Options) {
+		x, y := a.Options.Get(code), b.Options.Get(code)
+		if a.Options.Has(code) && b.Options.Has(code) && bytes.Equal(x, y) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{
+			Field: fmt.Sprintf("option %d", code),
+			A:     optionString(a.Options, code),
+			B:     optionString(b.Options, code),
+		})
+	}
+	return diffs
+}
+
+// wireIP returns ip as it is sent in a packet header.
+func wireIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return net.IPv4zero.To4()
+}
+
+func optionString(o Options, code OptionCode) string {
+	if !o.Has(code) {
+		return "<none>"
+	}
+	return fmt.Sprintf("%x", o.Get(code))
+}
+
+// unionCodes returns the codes of the options in either a or b, sorted.
+func unionCodes(a, b Options) []OptionCode {
+	seen := make(map[OptionCode]bool)
+	var codes []OptionCode
+	for _, o := range []Options{a, b} {
+		for _, code := range o.Codes() {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
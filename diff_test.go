@@ -0,0 +1,57 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := NewPacket(BootReply)
+	a.TransactionID = [4]byte{1, 2, 3, 4}
+	a.YIAddr = net.IPv4(10, 0, 0, 42)
+	a.CHAddr = net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	a.Options = NewOptions(
+		Option{Code: OptionHostName, Value: []byte("foo")},
+		Option{Code: OptionRouters, Value: []byte{10, 0, 0, 1}},
+	)
+
+	// The same packet, with options in another order and nil addresses
+	// where a has none.
+	b := NewPacket(BootReply)
+	b.TransactionID = [4]byte{1, 2, 3, 4}
+	b.CIAddr = net.IPv4zero
+	b.YIAddr = net.IP{10, 0, 0, 42}
+	b.CHAddr = net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	b.Options = NewOptions(
+		Option{Code: OptionRouters, Value: []byte{10, 0, 0, 1}},
+		Option{Code: OptionHostName, Value: []byte("foo")},
+	)
+	if diffs := Diff(a, b); len(diffs) != 0 || !a.Equal(b) {
+		t.Errorf("Diff() = %v, want no differences", diffs)
+	}
+
+	b.YIAddr = net.IPv4(10, 0, 0, 43)
+	b.SetBroadcast(true)
+	b.Options.ReplaceRaw(OptionHostName, []byte("bar"))
+	b.Options.Del(OptionRouters)
+	b.Options.AddRaw(OptionDomainName, []byte("x"))
+
+	want := []FieldDiff{
+		{Field: "flags", A: "0000", B: "8000"},
+		{Field: "yiaddr", A: "10.0.0.42", B: "10.0.0.43"},
+		{Field: "option 3", A: "0a000001", B: "<none>"},
+		{Field: "option 12", A: "666f6f", B: "626172"},
+		{Field: "option 15", A: "<none>", B: "78"},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+	if a.Equal(b) {
+		t.Errorf("Equal() = true, want false")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/u-root/dhcp4/internal/buffer"
+)
+
+// Regression inputs found by fuzzing live in testdata/fuzz. These seeds only
+// give the fuzzer a sensible place to start.
+
+func FuzzPacketUnmarshalBinary(f *testing.F) {
+	for _, p := range []*Packet{
+		NewPacket(BootRequest),
+		{
+			Op:            BootReply,
+			HType:         1,
+			TransactionID: [4]byte{1, 2, 3, 4},
+			Flags:         FlagBroadcast,
+			YIAddr:        []byte{192, 168, 0, 1},
+			CHAddr:        []byte{1, 2, 3, 4, 5, 6},
+			ServerName:    "server",
+			BootFile:      "pxelinux.0",
+			Options: NewOptions(
+				Option{OptionDHCPMessageType, []byte{2}},
+				Option{OptionRouters, []byte{192, 168, 0, 254}},
+			),
+		},
+	} {
+		b, err := p.MarshalBinary()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var p Packet
+		if err := p.UnmarshalBinary(data); err != nil {
+			return
+		}
+
+		// Whatever we could parse, we must be able to write back out
+		// and read again without losing information.
+		b, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(%#v) = %v", p, err)
+		}
+		var q Packet
+		if err := q.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary(MarshalBinary(%#v)) = %v", p, err)
+		}
+		if !reflect.DeepEqual(p, q) {
+			t.Fatalf("round trip got %#v, want %#v", q, p)
+		}
+	})
+}
+
+func FuzzOptionsUnmarshal(f *testing.F) {
+	f.Add([]byte{byte(End)})
+	f.Add([]byte{3, 2, 5, 6, byte(End)})
+	f.Add([]byte{3, 1, 5, 3, 1, 6, byte(Pad), byte(End), byte(Pad)})
+	// Parsed options keep their wire order, even for DefaultPriority codes.
+	f.Add([]byte{48, 1, 0, byte(OptionDHCPMessageType), 1, 1, byte(End)})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var o Options
+		if err := o.Unmarshal(buffer.New(data)); err != nil {
+			return
+		}
+
+		b := buffer.New(nil)
+		o.Marshal(b)
+		if n := len(b.Data()); n == 0 || b.Data()[n-1] != byte(End) {
+			t.Fatalf("Marshal(%v) = %v, does not end with End option", o, b.Data())
+		}
+
+		var got Options
+		if err := got.Unmarshal(buffer.New(b.Data())); err != nil {
+			t.Fatalf("Unmarshal(%v) = %v", b.Data(), err)
+		}
+		// Options keep their order, so the round trip must be exact.
+		if !reflect.DeepEqual(got, o) {
+			t.Fatalf("round trip got %v, want %v", got, o)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udp4
+
+import (
+	"errors"
+
+	"github.com/google/netstack/tcpip"
+	"github.com/google/netstack/tcpip/header"
+)
+
+var (
+	// ErrIPv4Checksum is returned by VerifyChecksums for packets whose
+	// IPv4 header checksum is wrong.
+	ErrIPv4Checksum = errors.New("bad IPv4 header checksum")
+
+	// ErrUDPChecksum is returned by VerifyChecksums for datagrams whose
+	// UDP checksum is wrong, or missing when it is required.
+	ErrUDPChecksum = errors.New("bad UDP checksum")
+)
+
+// udpChecksum returns the UDP checksum of the datagram udp sent from src to
+// dst, with the checksum field counted as it is.
+func udpChecksum(udp []byte, src, dst tcpip.Address) uint16 {
+	xsum := header.PseudoHeaderChecksum(header.UDPProtocolNumber, src, dst)
+	xsum = header.Checksum([]byte{byte(len(udp) >> 8), byte(len(udp))}, xsum)
+	return header.Checksum(udp, xsum)
+}
+
+// VerifyChecksums checks the IPv4 header checksum and UDP checksum of the
+// IPv4 packet pkt carrying a UDP datagram.
+//
+// A UDP checksum of zero means the sender computed none, which RFC 768
+// allows and some DHCP servers and relays do. Such datagrams are accepted if
+// allowZeroUDP is set, and rejected otherwise.
+func VerifyChecksums(pkt []byte, allowZeroUDP bool) error {
+	if len(pkt) < header.IPv4MinimumSize || header.IPVersion(pkt) != header.IPv4Version {
+		return ErrNotIPv4
+	}
+	ip := header.IPv4(pkt)
+	hlen := int(ip.HeaderLength())
+	total := int(ip.TotalLength())
+	if hlen < header.IPv4MinimumSize || total < hlen || total > len(pkt) {
+		return ErrNotIPv4
+	}
+	if header.Checksum(pkt[:hlen], 0) != 0xffff {
+		return ErrIPv4Checksum
+	}
+	if ip.TransportProtocol() != header.UDPProtocolNumber || total-hlen < header.UDPMinimumSize {
+		return ErrNotUDP
+	}
+
+	udp := header.UDP(pkt[hlen:total])
+	ulen := int(udp.Length())
+	if ulen < header.UDPMinimumSize || ulen > len(udp) {
+		return ErrNotUDP
+	}
+	if udp.Checksum() == 0 {
+		if allowZeroUDP {
+			return nil
+		}
+		return ErrUDPChecksum
+	}
+	if udpChecksum(udp[:ulen], ip.SourceAddress(), ip.DestinationAddress()) != 0xffff {
+		return ErrUDPChecksum
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udp4
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+)
+
+// goodPacket is an IPv4 packet from 192.168.1.1:67 to 192.168.1.100:68
+// carrying the start of a BOOTP reply, with IPv4 header checksum 0xf7fb and
+// UDP checksum 0xc4ee.
+const goodPacket = "4510003cbeef40004011f7fbc0a80101c0a80164004300440028c4ee" +
+	"0201060039a1b2c3000000000000000000000000c0a801640000000000000000"
+
+func TestVerifyChecksums(t *testing.T) {
+	good, err := hex.DecodeString(goodPacket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modify := func(fn func(b []byte)) []byte {
+		b := append([]byte(nil), good...)
+		fn(b)
+		return b
+	}
+
+	for _, tt := range []struct {
+		desc         string
+		pkt          []byte
+		allowZeroUDP bool
+		want         error
+	}{
+		{
+			desc: "known good",
+			pkt:  good,
+		},
+		{
+			desc: "known good with link-layer padding",
+			pkt:  append(append([]byte(nil), good...), 0, 0, 0, 0),
+		},
+		{
+			desc: "marshaled",
+			pkt:  Marshal([]byte("odd length"), &net.UDPAddr{IP: net.IPv4bcast, Port: 68}, &net.UDPAddr{IP: net.IP{10, 0, 0, 1}, Port: 67}),
+		},
+		{
+			desc: "bad IPv4 checksum",
+			pkt:  modify(func(b []byte) { b[11]++ }),
+			want: ErrIPv4Checksum,
+		},
+		{
+			desc: "corrupted payload",
+			pkt:  modify(func(b []byte) { b[40] ^= 0xff }),
+			want: ErrUDPChecksum,
+		},
+		{
+			desc:         "zero UDP checksum allowed",
+			pkt:          modify(func(b []byte) { b[26], b[27] = 0, 0 }),
+			allowZeroUDP: true,
+		},
+		{
+			desc: "zero UDP checksum rejected",
+			pkt:  modify(func(b []byte) { b[26], b[27] = 0, 0 }),
+			want: ErrUDPChecksum,
+		},
+		{
+			desc: "not UDP",
+			pkt:  modify(func(b []byte) { b[9] = 6; b[10], b[11] = 0xf8, 0x06 }),
+			want: ErrNotUDP,
+		},
+		{
+			desc: "truncated",
+			pkt:  good[:30],
+			want: ErrNotIPv4,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := VerifyChecksums(tt.pkt, tt.allowZeroUDP); err != tt.want {
+				t.Errorf("VerifyChecksums() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udp4
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/netstack/tcpip/header"
+)
+
+// Defaults for the limits of a Reassembler.
+const (
+	// DefaultReassemblyTimeout is how long the fragments of a datagram
+	// are kept waiting for the rest. DHCP clients retransmit after a few
+	// seconds, so there is no point in waiting as long as the 15 seconds
+	// RFC 791 suggests.
+	DefaultReassemblyTimeout = 5 * time.Second
+
+	// DefaultMaxDatagrams is how many datagrams may be reassembled at
+	// once.
+	DefaultMaxDatagrams = 16
+)
+
+// maxIPv4Len is the largest length of an IPv4 packet.
+const maxIPv4Len = 0xffff
+
+// errBadFragment is returned for fragments that cannot be part of a valid
+// datagram.
+var errBadFragment = errors.New("invalid IPv4 fragment")
+
+// Reassembler reassembles fragmented IPv4 packets, as large DHCP replies
+// carrying many options may arrive on raw sockets, which see packets before
+// the kernel would have reassembled them.
+//
+// Datagrams whose fragments overlap are dropped, as RFC 5722 requires of
+// IPv6 and most IPv4 stacks do too. The zero value is ready to use; a
+// Reassembler is not safe for concurrent use.
+type Reassembler struct {
+	// Timeout is how long fragments are kept. If zero,
+	// DefaultReassemblyTimeout is used.
+	Timeout time.Duration
+
+	// MaxDatagrams limits how many datagrams are reassembled at once.
+	// When a fragment of another datagram arrives, the oldest one is
+	// dropped. If zero, DefaultMaxDatagrams is used.
+	MaxDatagrams int
+
+	// now returns the current time, for tests.
+	now func() time.Time
+
+	pending map[fragmentKey]*datagram
+}
+
+// fragmentKey identifies the fragments of one datagram, as in RFC 791.
+type fragmentKey struct {
+	src, dst [4]byte
+	protocol uint8
+	id       uint16
+}
+
+// datagram is a datagram being reassembled.
+type datagram struct {
+	started time.Time
+
+	// header is the IPv4 header of the first fragment.
+	header []byte
+
+	// fragments are the payloads received so far.
+	fragments []fragment
+
+	// length is the payload length of the datagram, known once its last
+	// fragment has arrived, or -1.
+	length int
+}
+
+type fragment struct {
+	offset int
+	data   []byte
+}
+
+// Add adds the IPv4 packet pkt.
+//
+// If pkt is not a fragment, Add returns it as is. If it completes a datagram,
+// Add returns the reassembled packet, which has no fragmentation flags.
+// Otherwise, it returns nil until the rest of the datagram arrives.
+//
+// Add copies what it keeps of pkt.
+func (r *Reassembler) Add(pkt []byte) ([]byte, error) {
+	if len(pkt) < header.IPv4MinimumSize || header.IPVersion(pkt) != header.IPv4Version {
+		return nil, ErrNotIPv4
+	}
+	ip := header.IPv4(pkt)
+	hlen := int(ip.HeaderLength())
+	total := int(ip.TotalLength())
+	if hlen < header.IPv4MinimumSize || total < hlen || total > len(pkt) {
+		return nil, ErrNotIPv4
+	}
+	more := ip.Flags()&header.IPv4FlagMoreFragments != 0
+	offset := int(ip.FragmentOffset())
+	if !more && offset == 0 {
+		return pkt, nil
+	}
+
+	data := pkt[hlen:total]
+	if (more && len(data)%8 != 0) || offset+len(data) > maxIPv4Len-hlen {
+		return nil, errBadFragment
+	}
+
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	t := now()
+	r.expire(t)
+
+	k := fragmentKey{protocol: ip.Protocol(), id: ip.ID()}
+	copy(k.src[:], ip.SourceAddress())
+	copy(k.dst[:], ip.DestinationAddress())
+	d, ok := r.pending[k]
+	if !ok {
+		if r.pending == nil {
+			r.pending = make(map[fragmentKey]*datagram)
+		}
+		r.evict()
+		d = &datagram{started: t, length: -1}
+		r.pending[k] = d
+	}
+
+	for _, f := range d.fragments {
+		if offset < f.offset+len(f.data) && f.offset < offset+len(data) {
+			delete(r.pending, k)
+			return nil, errBadFragment
+		}
+	}
+	if !more {
+		if d.length >= 0 || offset+len(data) < d.maxEnd() {
+			delete(r.pending, k)
+			return nil, errBadFragment
+		}
+		d.length = offset + len(data)
+	} else if d.length >= 0 && offset+len(data) > d.length {
+		delete(r.pending, k)
+		return nil, errBadFragment
+	}
+	if offset == 0 {
+		d.header = append([]byte(nil), pkt[:hlen]...)
+	}
+	d.fragments = append(d.fragments, fragment{offset, append([]byte(nil), data...)})
+
+	if !d.complete() {
+		return nil, nil
+	}
+	delete(r.pending, k)
+	return d.assemble(), nil
+}
+
+// expire drops datagrams that have waited longer than the timeout.
+func (r *Reassembler) expire(now time.Time) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultReassemblyTimeout
+	}
+	for k, d := range r.pending {
+		if now.Sub(d.started) > timeout {
+			delete(r.pending, k)
+		}
+	}
+}
+
+// evict drops the oldest datagrams until there is room for another.
+func (r *Reassembler) evict() {
+	max := r.MaxDatagrams
+	if max <= 0 {
+		max = DefaultMaxDatagrams
+	}
+	for len(r.pending) >= max {
+		var oldest fragmentKey
+		var oldestTime time.Time
+		for k, d := range r.pending {
+			if oldestTime.IsZero() || d.started.Before(oldestTime) {
+				oldest, oldestTime = k, d.started
+			}
+		}
+		delete(r.pending, oldest)
+	}
+}
+
+// maxEnd returns the end of the furthest fragment received.
+func (d *datagram) maxEnd() int {
+	var end int
+	for _, f := range d.fragments {
+		if e := f.offset + len(f.data); e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+// complete reports whether every byte of the datagram has arrived.
+func (d *datagram) complete() bool {
+	if d.length < 0 || d.header == nil {
+		return false
+	}
+	var n int
+	for _, f := range d.fragments {
+		n += len(f.data)
+	}
+	// Fragments do not overlap and all end before length, so they
+	// cover the datagram exactly if their lengths add up.
+	return n == d.length
+}
+
+// assemble returns the reassembled packet.
+func (d *datagram) assemble() []byte {
+	sort.Slice(d.fragments, func(i, j int) bool {
+		return d.fragments[i].offset < d.fragments[j].offset
+	})
+	pkt := make([]byte, len(d.header), len(d.header)+d.length)
+	copy(pkt, d.header)
+	for _, f := range d.fragments {
+		pkt = append(pkt, f.data...)
+	}
+
+	ip := header.IPv4(pkt)
+	ip.SetTotalLength(uint16(len(pkt)))
+	ip.SetFlagsFragmentOffset(ip.Flags()&^header.IPv4FlagMoreFragments, 0)
+	ip.SetChecksum(0)
+	ip.SetChecksum(^ip.CalculateChecksum())
+	return pkt
+}
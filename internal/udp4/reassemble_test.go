@@ -0,0 +1,140 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package udp4
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/netstack/tcpip/header"
+)
+
+// fragmentPacket splits the IPv4 packet pkt into fragments carrying at most
+// size bytes of payload each, size being a multiple of 8.
+func fragmentPacket(pkt []byte, size int) [][]byte {
+	hlen := int(header.IPv4(pkt).HeaderLength())
+	payload := pkt[hlen:]
+	var frags [][]byte
+	for off := 0; off < len(payload); off += size {
+		end := off + size
+		var flags uint8 = header.IPv4FlagMoreFragments
+		if end >= len(payload) {
+			end, flags = len(payload), 0
+		}
+		f := append(append([]byte(nil), pkt[:hlen]...), payload[off:end]...)
+		ip := header.IPv4(f)
+		ip.SetTotalLength(uint16(len(f)))
+		ip.SetFlagsFragmentOffset(flags, uint16(off))
+		ip.SetChecksum(0)
+		ip.SetChecksum(^ip.CalculateChecksum())
+		frags = append(frags, f)
+	}
+	return frags
+}
+
+func TestReassembler(t *testing.T) {
+	payload := make([]byte, 1000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	src := &net.UDPAddr{IP: net.IP{192, 168, 0, 1}, Port: 67}
+	dst := &net.UDPAddr{IP: net.IPv4bcast, Port: 68}
+	pkt := Marshal(payload, dst, src)
+	frags := fragmentPacket(pkt, 256)
+	if len(frags) != 4 {
+		t.Fatalf("got %d fragments, want 4", len(frags))
+	}
+
+	for _, tt := range []struct {
+		desc  string
+		order []int
+	}{
+		{desc: "in order", order: []int{0, 1, 2, 3}},
+		{desc: "reversed", order: []int{3, 2, 1, 0}},
+		{desc: "shuffled", order: []int{2, 0, 3, 1}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var r Reassembler
+			var got []byte
+			for i, n := range tt.order {
+				whole, err := r.Add(frags[n])
+				if err != nil {
+					t.Fatalf("Add(fragment %d) = %v", n, err)
+				}
+				if i < len(tt.order)-1 && whole != nil {
+					t.Fatalf("Add(fragment %d) returned a packet before all fragments arrived", n)
+				}
+				got = whole
+			}
+			if got == nil {
+				t.Fatal("no packet after all fragments arrived")
+			}
+			if ip := header.IPv4(got); ip.Flags() != 0 || ip.FragmentOffset() != 0 || ip.CalculateChecksum() != 0xffff {
+				t.Errorf("reassembled header flags %d, offset %d, checksum %#x", ip.Flags(), ip.FragmentOffset(), ip.Checksum())
+			}
+			gotPayload, _, _, err := Unmarshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotPayload, payload) {
+				t.Error("reassembled payload differs")
+			}
+		})
+	}
+}
+
+func TestReassemblerUnfragmented(t *testing.T) {
+	var r Reassembler
+	pkt := Marshal([]byte("hello"), &net.UDPAddr{IP: net.IPv4bcast, Port: 68}, &net.UDPAddr{IP: net.IPv4zero, Port: 67})
+	got, err := r.Add(pkt)
+	if err != nil || !bytes.Equal(got, pkt) {
+		t.Errorf("Add(unfragmented) = %v, %v, want the packet", got, err)
+	}
+	if _, err := r.Add([]byte{1, 2, 3}); err != ErrNotIPv4 {
+		t.Errorf("Add(garbage) = %v, want %v", err, ErrNotIPv4)
+	}
+}
+
+func TestReassemblerLimits(t *testing.T) {
+	payload := make([]byte, 64)
+	pkt := Marshal(payload, &net.UDPAddr{IP: net.IPv4bcast, Port: 68}, &net.UDPAddr{IP: net.IPv4zero, Port: 67})
+	frags := fragmentPacket(pkt, 40)
+
+	t.Run("timeout", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		r := Reassembler{Timeout: time.Second, now: func() time.Time { return now }}
+		r.Add(frags[0])
+		now = now.Add(2 * time.Second)
+		if got, err := r.Add(frags[1]); got != nil || err != nil {
+			t.Errorf("Add(last fragment) after timeout = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	t.Run("max datagrams", func(t *testing.T) {
+		r := Reassembler{MaxDatagrams: 1}
+		r.Add(frags[0])
+		other := fragmentPacket(Marshal(payload, &net.UDPAddr{IP: net.IPv4bcast, Port: 68}, &net.UDPAddr{IP: net.IP{10, 0, 0, 1}, Port: 67}), 40)
+		r.Add(other[0])
+		if got, _ := r.Add(other[1]); got == nil {
+			t.Error("Add did not complete the newest datagram")
+		}
+		if got, _ := r.Add(frags[1]); got != nil {
+			t.Error("Add returned a datagram that should have been evicted")
+		}
+	})
+
+	t.Run("overlap", func(t *testing.T) {
+		var r Reassembler
+		r.Add(frags[0])
+		if _, err := r.Add(frags[0]); err == nil {
+			t.Error("Add(duplicate fragment) succeeded")
+		}
+		if got, _ := r.Add(frags[1]); got != nil {
+			t.Error("Add completed a datagram with overlapping fragments")
+		}
+	})
+}
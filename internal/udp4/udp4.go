@@ -0,0 +1,111 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package udp4 marshals and unmarshals UDP datagrams carried in IPv4 packets.
+//
+// It is used by transports that send DHCP messages on raw sockets and by
+// tools that synthesize or parse captured packets.
+package udp4
+
+import (
+	"errors"
+	"net"
+
+	"github.com/google/netstack/tcpip"
+	"github.com/google/netstack/tcpip/header"
+	"github.com/u-root/dhcp4/internal/buffer"
+)
+
+var (
+	// ErrNotIPv4 is returned when a packet is not a valid IPv4 packet.
+	ErrNotIPv4 = errors.New("not a valid IPv4 packet")
+
+	// ErrNotUDP is returned when an IPv4 packet does not carry a valid
+	// UDP datagram.
+	ErrNotUDP = errors.New("not a valid UDP datagram")
+)
+
+// HeaderLen is the length of the IPv4 and UDP headers written by Marshal.
+const HeaderLen = header.IPv4MinimumSize + header.UDPMinimumSize
+
+// Marshal wraps payload in UDP and IPv4 headers addressed from src to dst.
+func Marshal(payload []byte, dst *net.UDPAddr, src *net.UDPAddr) []byte {
+	ipLen := header.IPv4MinimumSize
+	udpLen := header.UDPMinimumSize
+
+	h := make([]byte, 0, ipLen+udpLen+len(payload))
+	hdr := buffer.New(h)
+
+	ipv4fields := &header.IPv4Fields{
+		IHL:         header.IPv4MinimumSize,
+		TotalLength: uint16(ipLen + udpLen + len(payload)),
+		TTL:         30,
+		Protocol:    uint8(header.UDPProtocolNumber),
+		SrcAddr:     tcpip.Address(src.IP.To4()),
+		DstAddr:     tcpip.Address(dst.IP.To4()),
+	}
+	ipv4hdr := header.IPv4(hdr.WriteN(ipLen))
+	ipv4hdr.Encode(ipv4fields)
+	ipv4hdr.SetChecksum(^ipv4hdr.CalculateChecksum())
+
+	udphdr := header.UDP(hdr.WriteN(udpLen))
+	udphdr.Encode(&header.UDPFields{
+		SrcPort: uint16(src.Port),
+		DstPort: uint16(dst.Port),
+		Length:  uint16(udpLen + len(payload)),
+	})
+
+	xsum := header.Checksum(payload, header.PseudoHeaderChecksum(
+		ipv4hdr.TransportProtocol(), ipv4fields.SrcAddr, ipv4fields.DstAddr))
+	xsum = ^udphdr.CalculateChecksum(xsum, udphdr.Length())
+	// A computed checksum of zero is sent as all ones, since zero means
+	// no checksum (RFC 768).
+	if xsum == 0 {
+		xsum = 0xffff
+	}
+	udphdr.SetChecksum(xsum)
+
+	hdr.WriteBytes(payload)
+	return hdr.Data()
+}
+
+// Unmarshal parses an IPv4 packet containing a UDP datagram and returns the
+// UDP payload and the source and destination addresses.
+//
+// The returned payload aliases pkt. Any bytes past the lengths given in the
+// IPv4 and UDP headers, such as link-layer padding, are ignored.
+func Unmarshal(pkt []byte) (payload []byte, src *net.UDPAddr, dst *net.UDPAddr, err error) {
+	if len(pkt) < header.IPv4MinimumSize || header.IPVersion(pkt) != header.IPv4Version {
+		return nil, nil, nil, ErrNotIPv4
+	}
+	ipHdr := header.IPv4(pkt)
+	hlen := int(ipHdr.HeaderLength())
+	total := int(ipHdr.TotalLength())
+	if hlen < header.IPv4MinimumSize || total < hlen || total > len(pkt) {
+		return nil, nil, nil, ErrNotIPv4
+	}
+	if ipHdr.TransportProtocol() != header.UDPProtocolNumber {
+		return nil, nil, nil, ErrNotUDP
+	}
+
+	udp := pkt[hlen:total]
+	if len(udp) < header.UDPMinimumSize {
+		return nil, nil, nil, ErrNotUDP
+	}
+	udpHdr := header.UDP(udp)
+	ulen := int(udpHdr.Length())
+	if ulen < header.UDPMinimumSize || ulen > len(udp) {
+		return nil, nil, nil, ErrNotUDP
+	}
+
+	src = &net.UDPAddr{
+		IP:   net.IP(ipHdr.SourceAddress()),
+		Port: int(udpHdr.SourcePort()),
+	}
+	dst = &net.UDPAddr{
+		IP:   net.IP(ipHdr.DestinationAddress()),
+		Port: int(udpHdr.DestinationPort()),
+	}
+	return udp[header.UDPMinimumSize:ulen], src, dst, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipv4ll
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// ARP operations.
+const (
+	arpRequest = 1
+	arpReply   = 2
+)
+
+// arpLen is the length of an ARP packet for IPv4 over Ethernet.
+const arpLen = 28
+
+// errNotARP is returned for packets that are not ARP packets for IPv4 over
+// Ethernet.
+var errNotARP = errors.New("not an Ethernet IPv4 ARP packet")
+
+// arpPacket is an ARP packet for IPv4 over Ethernet, as defined by RFC 826.
+type arpPacket struct {
+	op       uint16
+	senderHW net.HardwareAddr
+	senderIP net.IP
+	targetHW net.HardwareAddr
+	targetIP net.IP
+}
+
+// marshal returns the wire format of p.
+func (p *arpPacket) marshal() []byte {
+	b := make([]byte, arpLen)
+	binary.BigEndian.PutUint16(b[0:], 1)      // Ethernet
+	binary.BigEndian.PutUint16(b[2:], 0x0800) // IPv4
+	b[4], b[5] = 6, 4
+	binary.BigEndian.PutUint16(b[6:], p.op)
+	copy(b[8:14], p.senderHW)
+	copy(b[14:18], p.senderIP.To4())
+	copy(b[18:24], p.targetHW)
+	copy(b[24:28], p.targetIP.To4())
+	return b
+}
+
+// parseARP parses the ARP packet b. The packet returned does not refer to b.
+func parseARP(b []byte) (*arpPacket, error) {
+	if len(b) < arpLen ||
+		binary.BigEndian.Uint16(b[0:]) != 1 ||
+		binary.BigEndian.Uint16(b[2:]) != 0x0800 ||
+		b[4] != 6 || b[5] != 4 {
+		return nil, errNotARP
+	}
+	b = append([]byte(nil), b[:arpLen]...)
+	return &arpPacket{
+		op:       binary.BigEndian.Uint16(b[6:]),
+		senderHW: net.HardwareAddr(b[8:14]),
+		senderIP: net.IP(b[14:18]),
+		targetHW: net.HardwareAddr(b[18:24]),
+		targetIP: net.IP(b[24:28]),
+	}, nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ipv4ll claims IPv4 link-local addresses in 169.254.0.0/16, as
+// defined by RFC 3927, for devices that cannot get an address with DHCP.
+//
+// A Claimer probes for an unused address with ARP, announces it, and
+// defends it against other hosts claiming it later. Configuring the address
+// on the interface is up to the caller:
+//
+//	conn, err := ipv4ll.Listen(ifi)
+//	...
+//	c, err := ipv4ll.NewClaimer(conn, ifi.HardwareAddr)
+//	...
+//	ip, err := c.Claim(ctx)
+//	// Configure ip/16 on ifi, then:
+//	err = c.Defend(ctx, ip)
+//
+// Fallback races claiming an address against DHCP with
+// dhcp4client.Client.Acquire.
+package ipv4ll
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4client"
+)
+
+// ErrConflict is returned by Defend when another host keeps claiming the
+// address, which must then be given up.
+var ErrConflict = errors.New("address conflict")
+
+// Mask is the netmask of link-local addresses.
+var Mask = net.CIDRMask(16, 32)
+
+// timing holds the protocol constants of RFC 3927, Section 9.
+type timing struct {
+	probeWait         time.Duration
+	probeNum          int
+	probeMin          time.Duration
+	probeMax          time.Duration
+	announceWait      time.Duration
+	announceNum       int
+	announceInterval  time.Duration
+	maxConflicts      int
+	rateLimitInterval time.Duration
+	defendInterval    time.Duration
+}
+
+var defaultTiming = timing{
+	probeWait:         time.Second,
+	probeNum:          3,
+	probeMin:          time.Second,
+	probeMax:          2 * time.Second,
+	announceWait:      2 * time.Second,
+	announceNum:       2,
+	announceInterval:  2 * time.Second,
+	maxConflicts:      10,
+	rateLimitInterval: 60 * time.Second,
+	defendInterval:    10 * time.Second,
+}
+
+// pollInterval is how often blocked reads check whether their context is
+// done.
+const pollInterval = 100 * time.Millisecond
+
+// Claimer claims a link-local address on one link.
+//
+// A Claimer is not safe for concurrent use.
+type Claimer struct {
+	conn net.PacketConn
+	hw   net.HardwareAddr
+
+	// dst is the address ARP packets are written to.
+	dst net.Addr
+
+	rand   *rand.Rand
+	timing timing
+
+	// lastDefense is when Defend last announced the address.
+	lastDefense time.Time
+}
+
+// NewClaimer returns a Claimer sending and receiving ARP packets on conn, as
+// returned by Listen, for the link with the Ethernet address hw.
+//
+// Candidate addresses are drawn from a generator seeded with hw, so that a
+// device picks the same address every time unless it conflicts, as RFC
+// 3927, Section 2.1 recommends.
+func NewClaimer(conn net.PacketConn, hw net.HardwareAddr) (*Claimer, error) {
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("hardware address %v is not an Ethernet address", hw)
+	}
+	h := fnv.New64a()
+	h.Write(hw)
+	return &Claimer{
+		conn:   conn,
+		hw:     hw,
+		dst:    broadcastAddr(),
+		rand:   rand.New(rand.NewSource(int64(h.Sum64()))),
+		timing: defaultTiming,
+	}, nil
+}
+
+// candidate returns a random link-local address, outside the first and last
+// 256 addresses reserved by RFC 3927, Section 2.1.
+func (c *Claimer) candidate() net.IP {
+	n := 0x0100 + c.rand.Intn(0xfe00)
+	return net.IPv4(169, 254, byte(n>>8), byte(n)).To4()
+}
+
+// Claim picks a link-local address that no other host on the link uses,
+// announces it, and returns it.
+//
+// Addresses are probed for as RFC 3927, Section 2.2 describes, which takes
+// several seconds; after too many conflicts, Claim slows down to one probe
+// a minute. Claim gives up when ctx is done.
+func (c *Claimer) Claim(ctx context.Context) (net.IP, error) {
+	for conflicts := 0; ; conflicts++ {
+		if conflicts >= c.timing.maxConflicts {
+			if _, err := c.watch(ctx, time.Now().Add(c.timing.rateLimitInterval), nil, false); err != nil {
+				return nil, err
+			}
+		}
+		ip := c.candidate()
+		conflict, err := c.probe(ctx, ip)
+		if err != nil {
+			return nil, err
+		}
+		if !conflict {
+			return ip, c.announce(ctx, ip)
+		}
+	}
+}
+
+// probe probes for ip and reports whether another host uses it or is
+// probing for it.
+func (c *Claimer) probe(ctx context.Context, ip net.IP) (bool, error) {
+	wait := c.randDuration(0, c.timing.probeWait)
+	if conflict, err := c.watch(ctx, time.Now().Add(wait), ip, true); conflict || err != nil {
+		return conflict, err
+	}
+	for i := 0; i < c.timing.probeNum; i++ {
+		if err := c.send(&arpPacket{
+			op:       arpRequest,
+			senderHW: c.hw,
+			senderIP: net.IPv4zero,
+			targetHW: make(net.HardwareAddr, 6),
+			targetIP: ip,
+		}); err != nil {
+			return false, err
+		}
+		wait := c.randDuration(c.timing.probeMin, c.timing.probeMax)
+		if i == c.timing.probeNum-1 {
+			wait = c.timing.announceWait
+		}
+		if conflict, err := c.watch(ctx, time.Now().Add(wait), ip, true); conflict || err != nil {
+			return conflict, err
+		}
+	}
+	return false, nil
+}
+
+// announce announces that the Claimer uses ip.
+func (c *Claimer) announce(ctx context.Context, ip net.IP) error {
+	for i := 0; i < c.timing.announceNum; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(c.timing.announceInterval):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := c.sendAnnouncement(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Claimer) sendAnnouncement(ip net.IP) error {
+	return c.send(&arpPacket{
+		op:       arpRequest,
+		senderHW: c.hw,
+		senderIP: ip,
+		targetHW: make(net.HardwareAddr, 6),
+		targetIP: ip,
+	})
+}
+
+// Defend watches for other hosts using ip, an address claimed with Claim,
+// until ctx is done.
+//
+// As RFC 3927, Section 2.5 allows, Defend answers a conflict by announcing
+// ip again, unless it did so recently; then it returns ErrConflict, and the
+// caller must stop using ip and claim another address.
+func (c *Claimer) Defend(ctx context.Context, ip net.IP) error {
+	for {
+		conflict, err := c.watch(ctx, time.Time{}, ip, false)
+		if err != nil {
+			return err
+		}
+		if !conflict {
+			continue
+		}
+		now := time.Now()
+		if !c.lastDefense.IsZero() && now.Sub(c.lastDefense) < c.timing.defendInterval {
+			return ErrConflict
+		}
+		c.lastDefense = now
+		if err := c.sendAnnouncement(ip); err != nil {
+			return err
+		}
+	}
+}
+
+// watch reads ARP packets until the deadline, or forever if it is zero, and
+// reports whether another host uses ip. When probing, another host probing
+// for ip is a conflict too.
+func (c *Claimer) watch(ctx context.Context, deadline time.Time, ip net.IP, probing bool) (bool, error) {
+	b := make([]byte, 1500)
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		now := time.Now()
+		if !deadline.IsZero() && !now.Before(deadline) {
+			return false, nil
+		}
+		d := now.Add(pollInterval)
+		if !deadline.IsZero() && deadline.Before(d) {
+			d = deadline
+		}
+		if err := c.conn.SetReadDeadline(d); err != nil {
+			return false, err
+		}
+		n, _, err := c.conn.ReadFrom(b)
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+
+		p, err := parseARP(b[:n])
+		if err != nil || ip == nil || bytes.Equal(p.senderHW, c.hw) {
+			continue
+		}
+		if p.senderIP.Equal(ip) {
+			return true, nil
+		}
+		if probing && p.op == arpRequest && p.senderIP.Equal(net.IPv4zero) && p.targetIP.Equal(ip) {
+			return true, nil
+		}
+	}
+}
+
+func (c *Claimer) send(p *arpPacket) error {
+	_, err := c.conn.WriteTo(p.marshal(), c.dst)
+	return err
+}
+
+// randDuration returns a random duration in [min, max].
+func (c *Claimer) randDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(c.rand.Int63n(int64(max-min)+1))
+}
+
+// Fallback returns a dhcp4client.Fallback claiming a link-local address with
+// c, after DHCP has had delay to succeed.
+//
+// Only the claim is made: configuring the address and defending it with
+// Defend is up to the caller.
+func Fallback(c *Claimer, delay time.Duration) dhcp4client.Fallback {
+	return dhcp4client.Fallback{
+		Delay: delay,
+		Claim: func(ctx context.Context) (*net.IPNet, error) {
+			ip, err := c.Claim(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &net.IPNet{IP: ip, Mask: Mask}, nil
+		},
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipv4ll
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/dhcp4/dhcp4test"
+)
+
+var (
+	ourHW   = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	otherHW = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+)
+
+var fastTiming = timing{
+	probeWait:         10 * time.Millisecond,
+	probeNum:          3,
+	probeMin:          10 * time.Millisecond,
+	probeMax:          20 * time.Millisecond,
+	announceWait:      20 * time.Millisecond,
+	announceNum:       2,
+	announceInterval:  10 * time.Millisecond,
+	maxConflicts:      10,
+	rateLimitInterval: time.Second,
+	defendInterval:    time.Second,
+}
+
+func TestARPRoundTrip(t *testing.T) {
+	p := &arpPacket{
+		op:       arpReply,
+		senderHW: ourHW,
+		senderIP: net.IP{169, 254, 1, 2},
+		targetHW: otherHW,
+		targetIP: net.IP{169, 254, 3, 4},
+	}
+	got, err := parseARP(p.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("parseARP(marshal()) = %+v, want %+v", got, p)
+	}
+	if _, err := parseARP(p.marshal()[:20]); err != errNotARP {
+		t.Errorf("parseARP(short) = %v, want %v", err, errNotARP)
+	}
+}
+
+// peer pretends to be a host using the address inUse, answering probes for
+// it, and sends every ARP packet it receives on seen.
+func peer(conn net.PacketConn, inUse net.IP, seen chan<- *arpPacket) {
+	b := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			close(seen)
+			return
+		}
+		p, err := parseARP(b[:n])
+		if err != nil {
+			continue
+		}
+		seen <- p
+		if p.targetIP.Equal(inUse) {
+			reply := &arpPacket{op: arpReply, senderHW: otherHW, senderIP: inUse, targetHW: p.senderHW, targetIP: p.senderIP}
+			conn.WriteTo(reply.marshal(), nil)
+		}
+	}
+}
+
+func newTestClaimer(t *testing.T) (*Claimer, net.PacketConn) {
+	ours, theirs := dhcp4test.NewConnPair(&net.UDPAddr{}, &net.UDPAddr{})
+	c, err := NewClaimer(ours, ourHW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.timing = fastTiming
+	return c, theirs
+}
+
+func TestClaim(t *testing.T) {
+	c, theirs := newTestClaimer(t)
+	defer c.conn.Close()
+
+	// The first candidate is taken.
+	first, _ := NewClaimer(nil, ourHW)
+	inUse := first.candidate()
+
+	seen := make(chan *arpPacket, 100)
+	go peer(theirs, inUse, seen)
+	defer theirs.Close()
+
+	ip, err := c.Claim(context.Background())
+	if err != nil {
+		t.Fatalf("Claim() = %v", err)
+	}
+	if ip.Equal(inUse) {
+		t.Errorf("Claim() = %v, which is in use", ip)
+	}
+	if !(&net.IPNet{IP: net.IP{169, 254, 0, 0}, Mask: Mask}).Contains(ip) {
+		t.Errorf("Claim() = %v, not a link-local address", ip)
+	}
+
+	// The peer may still be reading the last announcement.
+	var probes, announcements int
+	timeout := time.After(time.Second)
+	for probes < 3 || announcements < 2 {
+		var p *arpPacket
+		select {
+		case p = <-seen:
+		case <-timeout:
+			t.Fatalf("sent %d probes and %d announcements for %v, want 3 and 2", probes, announcements, ip)
+		}
+		switch {
+		case p.targetIP.Equal(ip) && p.senderIP.Equal(net.IPv4zero):
+			probes++
+		case p.targetIP.Equal(ip) && p.senderIP.Equal(ip):
+			announcements++
+		}
+	}
+	if len(seen) > 0 {
+		t.Errorf("sent %d more packets after announcing", len(seen))
+	}
+}
+
+func TestDefend(t *testing.T) {
+	c, theirs := newTestClaimer(t)
+	defer c.conn.Close()
+	defer theirs.Close()
+	ip := net.IP{169, 254, 10, 20}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Defend(context.Background(), ip) }()
+
+	conflict := &arpPacket{op: arpRequest, senderHW: otherHW, senderIP: ip, targetHW: make(net.HardwareAddr, 6), targetIP: ip}
+	// The first conflict is defended with an announcement.
+	theirs.WriteTo(conflict.marshal(), nil)
+	b := make([]byte, 1500)
+	theirs.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := theirs.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("no announcement defending the address: %v", err)
+	}
+	if p, err := parseARP(b[:n]); err != nil || !p.senderIP.Equal(ip) || p.senderHW.String() != ourHW.String() {
+		t.Errorf("defending with %+v, %v, want an announcement of %v", p, err, ip)
+	}
+
+	// A second one within the defend interval is not.
+	theirs.WriteTo(conflict.marshal(), nil)
+	select {
+	case err := <-errCh:
+		if err != ErrConflict {
+			t.Errorf("Defend() = %v, want %v", err, ErrConflict)
+		}
+	case <-time.After(time.Second):
+		t.Error("Defend() did not return after a second conflict")
+	}
+}
+
+func TestClaimCanceled(t *testing.T) {
+	c, theirs := newTestClaimer(t)
+	defer c.conn.Close()
+	defer theirs.Close()
+	c.timing = defaultTiming
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Claim(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Claim() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipv4ll
+
+import (
+	"net"
+
+	"github.com/mdlayher/ethernet"
+	"github.com/mdlayher/raw"
+)
+
+// Listen returns a connection sending and receiving ARP packets on ifi.
+func Listen(ifi *net.Interface) (net.PacketConn, error) {
+	return raw.ListenPacket(ifi, uint16(ethernet.EtherTypeARP), &raw.Config{LinuxSockDGRAM: true})
+}
+
+// broadcastAddr returns the address that broadcasts ARP packets on a
+// connection returned by Listen.
+func broadcastAddr() net.Addr {
+	return &raw.Addr{HardwareAddr: ethernet.Broadcast}
+}
@@ -0,0 +1,25 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package ipv4ll
+
+import (
+	"fmt"
+	"net"
+)
+
+// Listen returns a connection sending and receiving ARP packets on ifi.
+func Listen(ifi *net.Interface) (net.PacketConn, error) {
+	return nil, fmt.Errorf("ARP is only supported on Linux")
+}
+
+// broadcastAddr returns the address that broadcasts ARP packets. Listen is
+// not supported here, so connections passed to NewClaimer decide where a
+// nil address sends packets.
+func broadcastAddr() net.Addr {
+	return nil
+}
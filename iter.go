@@ -0,0 +1,102 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"bytes"
+	"io"
+)
+
+// Iter calls fn with each option in the order Marshal writes them, until fn
+// returns false.
+//
+// Values are those Get returns; fn must not modify them.
+func (o Options) Iter(fn func(code OptionCode, value []byte) bool) {
+	more := true
+	o.each(func(e *entry) {
+		if more {
+			more = fn(e.Code, o.Get(e.Code))
+		}
+	})
+}
+
+// OptionIterator iterates over the options of a packet in its wire format,
+// for tools that look for a few options in many or large packets without
+// parsing them:
+//
+//	it := dhcp4.IterOptions(pkt)
+//	for it.Next() {
+//		if it.Code() == dhcp4.OptionHostName {
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// It does not allocate. Options are returned as they appear on the wire:
+// the instances of options split as RFC 3396 describes are returned one by
+// one, and options in the sname and file fields, which the option overload
+// option of RFC 2132, Section 9.3 may hold, are not returned.
+type OptionIterator struct {
+	b     []byte
+	code  OptionCode
+	value []byte
+	end   bool
+	err   error
+}
+
+// IterOptions returns an iterator over the options of pkt, the wire format
+// of a DHCP packet.
+func IterOptions(pkt []byte) OptionIterator {
+	if len(pkt) < headerLen || !bytes.Equal(pkt[headerLen-len(magicCookie):headerLen], magicCookie[:]) {
+		return OptionIterator{err: ErrInvalidPacket}
+	}
+	return OptionIterator{b: pkt[headerLen:]}
+}
+
+// Next advances to the next option, and reports whether there is one. It
+// returns false at the End option and on malformed options.
+func (it *OptionIterator) Next() bool {
+	for !it.end && it.err == nil {
+		if len(it.b) == 0 {
+			// Options must be terminated by End.
+			it.err = io.ErrUnexpectedEOF
+			break
+		}
+		switch code := OptionCode(it.b[0]); code {
+		case Pad:
+			it.b = it.b[1:]
+		case End:
+			it.end = true
+		default:
+			if len(it.b) < 2 || len(it.b) < 2+int(it.b[1]) {
+				it.err = io.ErrUnexpectedEOF
+				break
+			}
+			n := 2 + int(it.b[1])
+			it.code, it.value = code, it.b[2:n:n]
+			it.b = it.b[n:]
+			return true
+		}
+	}
+	it.code, it.value = 0, nil
+	return false
+}
+
+// Code returns the code of the current option.
+func (it *OptionIterator) Code() OptionCode {
+	return it.code
+}
+
+// Value returns the value of the current option, a view into the packet.
+func (it *OptionIterator) Value() []byte {
+	return it.value
+}
+
+// Err returns the error that stopped iteration, if any.
+func (it *OptionIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestOptionsIter(t *testing.T) {
+	o := NewOptions(
+		Option{OptionHostName, []byte("host")},
+		Option{OptionDHCPMessageType, []byte{1}},
+		Option{OptionDomainName, nil},
+	)
+
+	var got []Option
+	o.Iter(func(code OptionCode, value []byte) bool {
+		got = append(got, Option{code, value})
+		return true
+	})
+	want := []Option{
+		{OptionDHCPMessageType, []byte{1}},
+		{OptionHostName, []byte("host")},
+		{OptionDomainName, []byte{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Iter() visited %v, want %v", got, want)
+	}
+
+	var n int
+	o.Iter(func(OptionCode, []byte) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("Iter() visited %d options after fn returned false, want 1", n)
+	}
+}
+
+func TestIterOptions(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.SetMessageType(MessageTypeDiscover)
+	p.Options.AddRaw(OptionHostName, []byte("host"))
+	pkt, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := IterOptions(pkt)
+	var got []Option
+	for it.Next() {
+		got = append(got, Option{it.Code(), it.Value()})
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	want := []Option{
+		{OptionDHCPMessageType, []byte{1}},
+		{OptionHostName, []byte("host")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("iterated %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		pkt  []byte
+		want error
+	}{
+		{desc: "short", pkt: pkt[:100], want: ErrInvalidPacket},
+		{desc: "no End", pkt: pkt[:len(pkt)-1], want: io.ErrUnexpectedEOF},
+		{desc: "truncated option", pkt: append(pkt[:headerLen:headerLen], byte(OptionHostName), 10, 'h'), want: io.ErrUnexpectedEOF},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			it := IterOptions(tt.pkt)
+			for it.Next() {
+			}
+			if err := it.Err(); err != tt.want {
+				t.Errorf("Err() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		it := IterOptions(pkt)
+		for it.Next() {
+		}
+	}); allocs != 0 {
+		t.Errorf("iterating allocated %v times, want 0", allocs)
+	}
+}
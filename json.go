@@ -0,0 +1,261 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// optionNames are the names of known options in JSON, the names of their
+// constants without the Option prefix.
+var optionNames = map[OptionCode]string{
+	OptionSubnetMask:                                 "SubnetMask",
+	OptionTimeOffset:                                 "TimeOffset",
+	OptionRouters:                                    "Routers",
+	OptionTimeServers:                                "TimeServers",
+	OptionNameServers:                                "NameServers",
+	OptionDomainNameServers:                          "DomainNameServers",
+	OptionLogServers:                                 "LogServers",
+	OptionCookieServers:                              "CookieServers",
+	OptionLPRServers:                                 "LPRServers",
+	OptionImpressServers:                             "ImpressServers",
+	OptionResourceLocationServers:                    "ResourceLocationServers",
+	OptionHostName:                                   "HostName",
+	OptionBootFileSize:                               "BootFileSize",
+	OptionMeritDumpFile:                              "MeritDumpFile",
+	OptionDomainName:                                 "DomainName",
+	OptionSwapServer:                                 "SwapServer",
+	OptionRootPath:                                   "RootPath",
+	OptionExtensionsPath:                             "ExtensionsPath",
+	OptionIPForwardingEnableDisable:                  "IPForwardingEnableDisable",
+	OptionNonLocalSourceRoutingEnableDisable:         "NonLocalSourceRoutingEnableDisable",
+	OptionPolicyFilter:                               "PolicyFilter",
+	OptionMaximumDatagramReassemblySize:              "MaximumDatagramReassemblySize",
+	OptionDefaultIPTimeToLive:                        "DefaultIPTimeToLive",
+	OptionPathMTUAgingTimeout:                        "PathMTUAgingTimeout",
+	OptionPathMTUPlateauTable:                        "PathMTUPlateauTable",
+	OptionInterfaceMTU:                               "InterfaceMTU",
+	OptionAllSubnetsAreLocal:                         "AllSubnetsAreLocal",
+	OptionBroadcastAddress:                           "BroadcastAddress",
+	OptionPerformMaskDiscovery:                       "PerformMaskDiscovery",
+	OptionMaskSupplier:                               "MaskSupplier",
+	OptionPerformRouterDiscovery:                     "PerformRouterDiscovery",
+	OptionRouterSolicitationAddress:                  "RouterSolicitationAddress",
+	OptionStaticRoute:                                "StaticRoute",
+	OptionTrailerEncapsulation:                       "TrailerEncapsulation",
+	OptionARPCacheTimeout:                            "ARPCacheTimeout",
+	OptionEthernetEncapsulation:                      "EthernetEncapsulation",
+	OptionTCPDefaultTTL:                              "TCPDefaultTTL",
+	OptionTCPKeepaliveInterval:                       "TCPKeepaliveInterval",
+	OptionTCPKeepaliveGarbage:                        "TCPKeepaliveGarbage",
+	OptionNetworkInformationServiceDomain:            "NetworkInformationServiceDomain",
+	OptionNetworkInformationServers:                  "NetworkInformationServers",
+	OptionNetworkTimeProtocolServers:                 "NetworkTimeProtocolServers",
+	OptionVendorSpecificInformation:                  "VendorSpecificInformation",
+	OptionNetBIOSOverTCPIPNameServer:                 "NetBIOSOverTCPIPNameServer",
+	OptionNetBIOSOverTCPIPDatagramDistributionServer: "NetBIOSOverTCPIPDatagramDistributionServer",
+	OptionNetBIOSOverTCPIPNodeType:                   "NetBIOSOverTCPIPNodeType",
+	OptionNetBIOSOverTCPIPScope:                      "NetBIOSOverTCPIPScope",
+	OptionXWindowSystemFontServer:                    "XWindowSystemFontServer",
+	OptionXWindowSystemDisplayManager:                "XWindowSystemDisplayManager",
+	OptionRequestedIPAddress:                         "RequestedIPAddress",
+	OptionIPAddressLeaseTime:                         "IPAddressLeaseTime",
+	OptionOverload:                                   "Overload",
+	OptionDHCPMessageType:                            "DHCPMessageType",
+	OptionServerIdentifier:                           "ServerIdentifier",
+	OptionParameterRequestList:                       "ParameterRequestList",
+	OptionMessage:                                    "Message",
+	OptionMaximumDHCPMessageSize:                     "MaximumDHCPMessageSize",
+	OptionRenewalTimeValue:                           "RenewalTimeValue",
+	OptionRebindingTimeValue:                         "RebindingTimeValue",
+	OptionVendorClassIdentifier:                      "VendorClassIdentifier",
+	OptionClientIdentifier:                           "ClientIdentifier",
+	OptionTFTPServerName:                             "TFTPServerName",
+	OptionBootFileName:                               "BootFileName",
+	OptionUserClass:                                  "UserClass",
+	OptionRelayAgentInformation:                      "RelayAgentInformation",
+	OptionClientLastTransactionTime:                  "ClientLastTransactionTime",
+	OptionAssociatedIP:                               "AssociatedIP",
+	OptionClientSystemArch:                           "ClientSystemArch",
+	OptionIPv6OnlyPreferred:                          "IPv6OnlyPreferred",
+	OptionCaptivePortal:                              "CaptivePortal",
+	OptionSubnetSelection:                            "SubnetSelection",
+	OptionDomainSearch:                               "DomainSearch",
+	OptionClasslessStaticRoute:                       "ClasslessStaticRoute",
+	OptionMUDURL:                                     "MUDURL",
+}
+
+// jsonOption is the JSON form of an Option.
+type jsonOption struct {
+	// Code may be omitted when unmarshaling if Name is known.
+	Code *OptionCode `json:"code,omitempty"`
+
+	// Name is informational when marshaling; Code takes precedence
+	// when unmarshaling.
+	Name string `json:"name,omitempty"`
+
+	// Value is hex encoded.
+	Value string `json:"value"`
+}
+
+// MarshalJSON implements json.Marshaler.
+//
+// Options are a JSON array of objects holding the code, the name of known
+// options such as "DHCPMessageType", and the hex-encoded value of each
+// option, in order.
+func (o Options) MarshalJSON() ([]byte, error) {
+	opts := make([]jsonOption, 0, len(o.list))
+	for _, e := range o.list {
+		code := e.Code
+		opts = append(opts, jsonOption{
+			Code:  &code,
+			Name:  optionNames[code],
+			Value: hex.EncodeToString(e.Value),
+		})
+	}
+	return json.Marshal(opts)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+//
+// Each option must have a code or the name of a known option, and a value
+// encoded as by MarshalJSON. The options replace the contents of o.
+func (o *Options) UnmarshalJSON(b []byte) error {
+	var opts []jsonOption
+	if err := json.Unmarshal(b, &opts); err != nil {
+		return err
+	}
+
+	*o = Options{Sorted: o.Sorted, Priority: o.Priority}
+	for _, opt := range opts {
+		code, err := opt.code()
+		if err != nil {
+			return err
+		}
+		v, err := hex.DecodeString(opt.Value)
+		if err != nil {
+			return fmt.Errorf("option %d: %v", code, err)
+		}
+		o.AddRaw(code, v)
+	}
+	return nil
+}
+
+func (opt jsonOption) code() (OptionCode, error) {
+	if opt.Code != nil {
+		return *opt.Code, nil
+	}
+	for code, name := range optionNames {
+		if strings.EqualFold(name, opt.Name) {
+			return code, nil
+		}
+	}
+	if opt.Name == "" {
+		return 0, fmt.Errorf("option has neither code nor name")
+	}
+	return 0, fmt.Errorf("unknown option name %q", opt.Name)
+}
+
+// jsonPacket is the JSON form of a Packet.
+type jsonPacket struct {
+	Op            OpCode  `json:"op"`
+	HType         uint8   `json:"htype"`
+	Hops          uint8   `json:"hops"`
+	TransactionID string  `json:"xid"`
+	Secs          uint16  `json:"secs"`
+	Broadcast     bool    `json:"broadcast"`
+	ReservedFlags uint16  `json:"reserved_flags,omitempty"`
+	CIAddr        net.IP  `json:"ciaddr,omitempty"`
+	YIAddr        net.IP  `json:"yiaddr,omitempty"`
+	SIAddr        net.IP  `json:"siaddr,omitempty"`
+	GIAddr        net.IP  `json:"giaddr,omitempty"`
+	CHAddr        string  `json:"chaddr"`
+	ServerName    string  `json:"sname,omitempty"`
+	BootFile      string  `json:"file,omitempty"`
+	Options       Options `json:"options"`
+}
+
+// MarshalJSON implements json.Marshaler.
+//
+// The header fields are named as in RFC 2131, Section 2, except that the
+// flags field is split into the broadcast flag and any reserved bits. The
+// transaction ID is hex encoded, addresses are in dotted decimal, and the client hardware
+// address is colon-separated hex. Options are encoded as by
+// Options.MarshalJSON.
+func (p *Packet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonPacket{
+		Op:            p.Op,
+		HType:         p.HType,
+		Hops:          p.Hops,
+		TransactionID: hex.EncodeToString(p.TransactionID[:]),
+		Secs:          p.Secs,
+		Broadcast:     p.Broadcast(),
+		ReservedFlags: p.Flags &^ FlagBroadcast,
+		CIAddr:        p.CIAddr,
+		YIAddr:        p.YIAddr,
+		SIAddr:        p.SIAddr,
+		GIAddr:        p.GIAddr,
+		CHAddr:        p.CHAddr.String(),
+		ServerName:    p.ServerName,
+		BootFile:      p.BootFile,
+		Options:       p.Options,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Packet) UnmarshalJSON(b []byte) error {
+	var j jsonPacket
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	xid, err := hex.DecodeString(j.TransactionID)
+	if err != nil || len(xid) != len(p.TransactionID) {
+		return fmt.Errorf("invalid transaction ID %q", j.TransactionID)
+	}
+	chaddr, err := parseHardwareAddr(j.CHAddr)
+	if err != nil {
+		return err
+	}
+
+	*p = Packet{
+		Op:         j.Op,
+		HType:      j.HType,
+		Hops:       j.Hops,
+		Secs:       j.Secs,
+		Flags:      j.ReservedFlags &^ FlagBroadcast,
+		CIAddr:     j.CIAddr,
+		YIAddr:     j.YIAddr,
+		SIAddr:     j.SIAddr,
+		GIAddr:     j.GIAddr,
+		CHAddr:     chaddr,
+		ServerName: j.ServerName,
+		BootFile:   j.BootFile,
+		Options:    j.Options,
+	}
+	copy(p.TransactionID[:], xid)
+	if j.Broadcast {
+		p.SetBroadcast(true)
+	}
+	return nil
+}
+
+// parseHardwareAddr parses colon-separated hex of any length, unlike
+// net.ParseMAC, as non-Ethernet hardware addresses may be up to 16 bytes, or
+// 20 bytes for InfiniBand.
+func parseHardwareAddr(s string) (net.HardwareAddr, error) {
+	if s == "" {
+		return nil, nil
+	}
+	addr, err := hex.DecodeString(strings.Replace(s, ":", "", -1))
+	if err != nil || len(addr) > infinibandAddrLen || len(s) != 3*len(addr)-1 {
+		return nil, fmt.Errorf("invalid hardware address %q", s)
+	}
+	return addr, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestPacketJSON(t *testing.T) {
+	p := NewPacket(BootReply)
+	p.TransactionID = [4]byte{0xde, 0xad, 0xbe, 0xef}
+	p.Flags = FlagBroadcast | 1
+	p.YIAddr = net.IPv4(10, 0, 0, 42)
+	p.CHAddr = net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	p.BootFile = "pxelinux.0"
+	p.Options = NewOptions(
+		Option{Code: OptionDHCPMessageType, Value: []byte{2}},
+		Option{Code: 224, Value: []byte{1, 2}},
+	)
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	want := `{"op":2,"htype":1,"hops":0,"xid":"deadbeef","secs":0,"broadcast":true,"reserved_flags":1,` +
+		`"yiaddr":"10.0.0.42","chaddr":"02:00:00:00:00:01","file":"pxelinux.0",` +
+		`"options":[{"code":53,"name":"DHCPMessageType","value":"02"},{"code":224,"value":"0102"}]}`
+	if string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+
+	var got Packet
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if diffs := Diff(p, &got); len(diffs) != 0 {
+		t.Errorf("Unmarshal(Marshal()) differs: %v", diffs)
+	}
+}
+
+func TestPacketJSONInfiniBand(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.HType = HTypeInfiniBand
+	p.CHAddr = make(net.HardwareAddr, infinibandAddrLen)
+	for i := range p.CHAddr {
+		p.CHAddr[i] = byte(i)
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	var got Packet
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal(%s) = %v", b, err)
+	}
+	if diffs := Diff(p, &got); len(diffs) != 0 {
+		t.Errorf("Unmarshal(Marshal()) differs: %v", diffs)
+	}
+
+	long := `{"op":1,"htype":32,"chaddr":"` + net.HardwareAddr(make([]byte, infinibandAddrLen+1)).String() + `"}`
+	if err := json.Unmarshal([]byte(long), &got); err == nil {
+		t.Errorf("Unmarshal() of a 21-byte hardware address = nil error, want error")
+	}
+}
+
+func TestOptionsUnmarshalJSON(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		in      string
+		want    Options
+		wantErr bool
+	}{
+		{
+			desc: "by name",
+			in:   `[{"name":"hostname","value":"666f6f"},{"code":3,"value":"0a000001"}]`,
+			want: NewOptions(
+				Option{Code: OptionHostName, Value: []byte("foo")},
+				Option{Code: OptionRouters, Value: []byte{10, 0, 0, 1}},
+			),
+		},
+		{
+			desc: "code wins over name",
+			in:   `[{"code":15,"name":"HostName","value":"78"}]`,
+			want: NewOptions(Option{Code: OptionDomainName, Value: []byte("x")}),
+		},
+		{
+			desc:    "unknown name",
+			in:      `[{"name":"Foo","value":""}]`,
+			wantErr: true,
+		},
+		{
+			desc:    "no code",
+			in:      `[{"value":""}]`,
+			wantErr: true,
+		},
+		{
+			desc:    "bad hex",
+			in:      `[{"code":1,"value":"zz"}]`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var o Options
+			err := json.Unmarshal([]byte(tt.in), &o)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := &Packet{Options: o}
+			if diffs := Diff(got, &Packet{Options: tt.want}); len(diffs) != 0 {
+				t.Errorf("Unmarshal() differs: %v", diffs)
+			}
+		})
+	}
+}
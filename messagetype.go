@@ -0,0 +1,79 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"fmt"
+)
+
+// MessageType is a DHCP message type, the value of the DHCP message type
+// option.
+//
+// dhcp4opts.DHCPMessageType is the same type with the methods of an option
+// value; the two convert to each other.
+type MessageType uint8
+
+// DHCP message types as defined by RFC 2132, Section 9.6.
+const (
+	MessageTypeDiscover MessageType = 1
+	MessageTypeOffer    MessageType = 2
+	MessageTypeRequest  MessageType = 3
+	MessageTypeDecline  MessageType = 4
+	MessageTypeACK      MessageType = 5
+	MessageTypeNAK      MessageType = 6
+	MessageTypeRelease  MessageType = 7
+	MessageTypeInform   MessageType = 8
+)
+
+// MessageTypeForceRenew is the DHCPFORCERENEW message type defined by RFC
+// 3203, Section 4.
+const MessageTypeForceRenew MessageType = 9
+
+// Leasequery message types as defined by RFC 4388, Section 6.1.
+const (
+	MessageTypeLeaseQuery      MessageType = 10
+	MessageTypeLeaseUnassigned MessageType = 11
+	MessageTypeLeaseUnknown    MessageType = 12
+	MessageTypeLeaseActive     MessageType = 13
+)
+
+var messageTypeNames = map[MessageType]string{
+	MessageTypeDiscover:        "DHCPDISCOVER",
+	MessageTypeOffer:           "DHCPOFFER",
+	MessageTypeRequest:         "DHCPREQUEST",
+	MessageTypeDecline:         "DHCPDECLINE",
+	MessageTypeACK:             "DHCPACK",
+	MessageTypeNAK:             "DHCPNAK",
+	MessageTypeRelease:         "DHCPRELEASE",
+	MessageTypeInform:          "DHCPINFORM",
+	MessageTypeForceRenew:      "DHCPFORCERENEW",
+	MessageTypeLeaseQuery:      "DHCPLEASEQUERY",
+	MessageTypeLeaseUnassigned: "DHCPLEASEUNASSIGNED",
+	MessageTypeLeaseUnknown:    "DHCPLEASEUNKNOWN",
+	MessageTypeLeaseActive:     "DHCPLEASEACTIVE",
+}
+
+// String implements fmt.Stringer.
+func (mt MessageType) String() string {
+	if s, ok := messageTypeNames[mt]; ok {
+		return s
+	}
+	return fmt.Sprintf("MessageType(%d)", uint8(mt))
+}
+
+// MessageType returns the DHCP message type of p, or 0 if p has no valid
+// DHCP message type option, as BOOTP messages do not.
+func (p *Packet) MessageType() MessageType {
+	if mt := p.Options.Get(OptionDHCPMessageType); len(mt) == 1 {
+		return MessageType(mt[0])
+	}
+	return 0
+}
+
+// SetMessageType sets the DHCP message type option of p to mt, replacing
+// any earlier one.
+func (p *Packet) SetMessageType(mt MessageType) {
+	p.Options.ReplaceRaw(OptionDHCPMessageType, []byte{byte(mt)})
+}
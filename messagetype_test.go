@@ -0,0 +1,37 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"testing"
+)
+
+func TestMessageType(t *testing.T) {
+	p := NewPacket(BootRequest)
+	if mt := p.MessageType(); mt != 0 {
+		t.Errorf("MessageType() of BOOTP packet = %v, want 0", mt)
+	}
+	p.SetMessageType(MessageTypeDiscover)
+	p.SetMessageType(MessageTypeRequest)
+	if mt := p.MessageType(); mt != MessageTypeRequest {
+		t.Errorf("MessageType() = %v, want %v", mt, MessageTypeRequest)
+	}
+
+	p.Options.ReplaceRaw(OptionDHCPMessageType, []byte{1, 2})
+	if mt := p.MessageType(); mt != 0 {
+		t.Errorf("MessageType() of invalid option = %v, want 0", mt)
+	}
+
+	for mt, want := range map[MessageType]string{
+		MessageTypeACK:         "DHCPACK",
+		MessageTypeForceRenew:  "DHCPFORCERENEW",
+		MessageTypeLeaseActive: "DHCPLEASEACTIVE",
+		42:                     "MessageType(42)",
+	} {
+		if got := mt.String(); got != want {
+			t.Errorf("MessageType(%d).String() = %q, want %q", uint8(mt), got, want)
+		}
+	}
+}
@@ -8,20 +8,97 @@ import (
 	"encoding"
 	"io"
 	"math"
-	"sort"
 
 	"github.com/u-root/dhcp4/internal/buffer"
 )
 
-// Options is a map of OptionCode keys with a slice of byte values.
+// Option is a single DHCP option.
+type Option struct {
+	Code  OptionCode
+	Value []byte
+}
+
+// Options is a list of DHCP options that remembers the order options were
+// added in.
+//
+// Each OptionCode appears at most once; values added for a code already
+// present are appended to its value, as RFC 3396 requires of options split
+// across several instances.
 //
 // Its methods can be used to easily check for additional information from a
-// packet. Get should be used to access data from Options.
-type Options map[OptionCode][]byte
+// packet. Get should be used to access data from Options. The zero value is
+// an empty list ready to use. Options must not be copied after first use;
+// use Clone instead.
+type Options struct {
+	// Sorted makes Marshal write options in ascending order of their
+	// codes, as earlier versions of this package always did, rather
+	// than in the order they were added.
+	Sorted bool
+
+	// Priority lists the codes Marshal writes before all other options,
+	// in order, since some clients and PXE ROMs misbehave unless the
+	// message type comes first. If nil, DefaultPriority is used; set it
+	// to an empty non-nil slice to write options strictly in insertion
+	// or sorted order. Unmarshal does so when Priority is nil, so that
+	// parsed options are written back in the order they were received.
+	Priority []OptionCode
+
+	list []entry
 
-// Add adds a new OptionCode key and BinaryMarshaler's bytes to the Options
-// map.
-func (o Options) Add(key OptionCode, value encoding.BinaryMarshaler) error {
+	// index maps each code in list to its position. Shallow copies of
+	// Options share it, so it is only a hint; see find.
+	index map[OptionCode]int
+}
+
+// find returns the position of key in o.list.
+//
+// A copy of o may have changed the index it shares with o, so the position
+// the index gives is checked, and list searched if it is wrong.
+func (o Options) find(key OptionCode) (int, bool) {
+	if i, ok := o.index[key]; ok && i < len(o.list) && o.list[i].Code == key {
+		return i, true
+	}
+	for i := range o.list {
+		if o.list[i].Code == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// entry is an option and how its value is split on the wire.
+type entry struct {
+	Option
+
+	// chunks are the lengths of the instances the value was parsed from,
+	// so that Marshal can split it the same way. nil means the value is
+	// split only as needed to fit the option length.
+	chunks []int
+}
+
+// DefaultPriority is the order of the options Marshal writes first unless
+// Options.Priority says otherwise: the DHCP message type, then the server
+// identifier and requested IP address. With DefaultPriority, the relay agent
+// information option is also written last, as RFC 3046 requires.
+var DefaultPriority = []OptionCode{
+	OptionDHCPMessageType,
+	OptionServerIdentifier,
+	OptionRequestedIPAddress,
+}
+
+// NewOptions returns Options holding opts in order.
+//
+// The values of codes given more than once are concatenated.
+func NewOptions(opts ...Option) Options {
+	var o Options
+	for _, opt := range opts {
+		o.AddRaw(opt.Code, opt.Value)
+	}
+	return o
+}
+
+// Add adds a new OptionCode key and BinaryMarshaler's bytes to the Options.
+func (o *Options) Add(key OptionCode, value encoding.BinaryMarshaler) error {
 	if value == nil {
 		o.AddRaw(key, []byte{})
 		return nil
@@ -36,10 +113,242 @@ func (o Options) Add(key OptionCode, value encoding.BinaryMarshaler) error {
 	return nil
 }
 
-// AddRaw adds a new OptionCode key and raw value byte slice to the Options
-// map.
-func (o Options) AddRaw(key OptionCode, value []byte) {
-	o[key] = append(o[key], value...)
+// AddRaw adds a new OptionCode key and raw value byte slice to the Options.
+func (o *Options) AddRaw(key OptionCode, value []byte) {
+	if i, ok := o.find(key); ok {
+		e := &o.list[i]
+		e.Value = append(e.Value, value...)
+		if len(e.chunks) > 0 {
+			e.chunks[len(e.chunks)-1] += len(value)
+		}
+		return
+	}
+	if o.index == nil {
+		o.index = make(map[OptionCode]int)
+	}
+	o.index[key] = len(o.list)
+	o.list = append(o.list, entry{Option: Option{
+		Code:  key,
+		Value: append([]byte(nil), value...),
+	}})
+}
+
+// addChunk adds a value parsed from one instance of an option, remembering
+// where the instance ends.
+func (o *Options) addChunk(key OptionCode, value []byte) {
+	i, ok := o.find(key)
+	if !ok {
+		o.AddRaw(key, value)
+		return
+	}
+	e := &o.list[i]
+	if e.chunks == nil {
+		e.chunks = []int{len(e.Value)}
+	}
+	e.Value = append(e.Value, value...)
+	e.chunks = append(e.chunks, len(value))
+}
+
+// addRef is like addChunk, but keeps value itself rather than a copy as the
+// value of an option seen for the first time. value's capacity must not
+// exceed its length, so that later chunks are appended to a copy.
+func (o *Options) addRef(key OptionCode, value []byte) {
+	if _, ok := o.find(key); ok {
+		o.addChunk(key, value)
+		return
+	}
+	if o.index == nil {
+		o.index = make(map[OptionCode]int)
+	}
+	o.index[key] = len(o.list)
+	o.list = append(o.list, entry{Option: Option{Code: key, Value: value}})
+}
+
+// addField appends the options held in field, the sname or file field of an
+// overloaded packet, as further instances of the options already present.
+//
+// Fields should end with the End option, but a field running out is taken as
+// its end too.
+func (o *Options) addField(field []byte) error {
+	var f Options
+	if err := f.Unmarshal(buffer.New(append(field[:len(field):len(field)], byte(End)))); err != nil {
+		return err
+	}
+	for _, e := range f.list {
+		for _, chunk := range f.GetAll(e.Code) {
+			o.addChunk(e.Code, chunk)
+		}
+	}
+	return nil
+}
+
+// ReplaceRaw sets the value of an OptionCode key to value, discarding any
+// previous value. A key already present keeps its position.
+func (o *Options) ReplaceRaw(key OptionCode, value []byte) {
+	if i, ok := o.find(key); ok {
+		o.list[i].Value = append([]byte{}, value...)
+		o.list[i].chunks = nil
+		return
+	}
+	o.AddRaw(key, value)
+}
+
+// Replace sets the value of an OptionCode key to the BinaryMarshaler's
+// bytes, discarding any previous value. A key already present keeps its
+// position.
+func (o *Options) Replace(key OptionCode, value encoding.BinaryMarshaler) error {
+	if value == nil {
+		o.ReplaceRaw(key, nil)
+		return nil
+	}
+
+	b, err := value.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	o.ReplaceRaw(key, b)
+	return nil
+}
+
+// Del removes an OptionCode key and its value. Deleting a key that is not
+// present does nothing.
+func (o *Options) Del(key OptionCode) {
+	i, ok := o.find(key)
+	if !ok {
+		return
+	}
+	delete(o.index, key)
+	// Shallow copies of o may share list, so it is copied rather than
+	// shifted in place.
+	o.list = append(o.list[:i:i], o.list[i+1:]...)
+	for ; i < len(o.list); i++ {
+		o.index[o.list[i].Code] = i
+	}
+}
+
+// Has reports whether an OptionCode key is present, even with an empty value.
+func (o Options) Has(key OptionCode) bool {
+	_, ok := o.find(key)
+	return ok
+}
+
+// Len returns the number of OptionCode keys present.
+func (o Options) Len() int {
+	return len(o.list)
+}
+
+// Codes returns the OptionCode keys present in the order Marshal writes
+// them.
+func (o Options) Codes() []OptionCode {
+	codes := make([]OptionCode, 0, len(o.list))
+	o.each(func(e *entry) {
+		codes = append(codes, e.Code)
+	})
+	return codes
+}
+
+// Clone returns a deep copy of o, sharing no memory with it, so that the
+// copy can be changed or kept after o's packet buffer is reused, as is the
+// case for options parsed with UnmarshalNoCopy.
+func (o Options) Clone() Options {
+	c := Options{Sorted: o.Sorted}
+	if o.Priority != nil {
+		c.Priority = append([]OptionCode{}, o.Priority...)
+	}
+	if o.list == nil {
+		return c
+	}
+	c.list = make([]entry, len(o.list))
+	c.index = make(map[OptionCode]int, len(o.list))
+	for i, e := range o.list {
+		c.list[i] = entry{Option: Option{Code: e.Code, Value: append([]byte{}, e.Value...)}}
+		if e.chunks != nil {
+			c.list[i].chunks = append([]int(nil), e.chunks...)
+		}
+		c.index[e.Code] = i
+	}
+	return c
+}
+
+// priority returns the codes written first.
+func (o Options) priority() []OptionCode {
+	if o.Priority == nil {
+		return DefaultPriority
+	}
+	return o.Priority
+}
+
+// each calls fn with each entry in the order Marshal writes them: the
+// priority codes first, then the rest in insertion or sorted order, and, if
+// Priority is nil, the relay agent information option last.
+func (o Options) each(fn func(e *entry)) {
+	prio := o.priority()
+	for _, c := range prio {
+		if i, ok := o.find(c); ok {
+			fn(&o.list[i])
+		}
+	}
+	// RFC 3046, Section 2.2 has servers echo the relay agent
+	// information as the last option of replies, whatever options are
+	// added after it.
+	last, hasLast := 0, false
+	if o.Priority == nil {
+		last, hasLast = o.find(OptionRelayAgentInformation)
+	}
+	skip := func(c OptionCode) bool {
+		if hasLast && c == OptionRelayAgentInformation {
+			return true
+		}
+		for _, p := range prio {
+			if p == c {
+				return true
+			}
+		}
+		return false
+	}
+
+	if o.Sorted {
+		// Mark the codes present rather than sorting a slice of
+		// keys, so that marshaling does not allocate.
+		var present [math.MaxUint8 + 1]bool
+		for _, e := range o.list {
+			present[e.Code] = true
+		}
+		for c, ok := range present {
+			if ok && !skip(OptionCode(c)) {
+				i, _ := o.find(OptionCode(c))
+				fn(&o.list[i])
+			}
+		}
+	} else {
+		for i := range o.list {
+			if !skip(o.list[i].Code) {
+				fn(&o.list[i])
+			}
+		}
+	}
+	if hasLast {
+		fn(&o.list[last])
+	}
+}
+
+// GetAll returns the value of an OptionCode key as the separate instances of
+// the option written on the wire, as described by RFC 3396.
+//
+// For options parsed from a packet, these are the instances the option was
+// parsed from. If the key is not found, GetAll returns nil.
+func (o Options) GetAll(key OptionCode) [][]byte {
+	i, ok := o.find(key)
+	if !ok {
+		return nil
+	}
+
+	all := [][]byte{}
+	o.list[i].eachChunk(func(chunk []byte) {
+		all = append(all, chunk)
+	})
+	return all
 }
 
 // Get attempts to retrieve the value specified by an OptionCode key.
@@ -48,27 +357,65 @@ func (o Options) AddRaw(key OptionCode, value []byte) {
 // Get returns nil.
 func (o Options) Get(key OptionCode) []byte {
 	// Check for value by key.
-	v, ok := o[key]
+	i, ok := o.find(key)
 	if !ok {
 		return nil
 	}
 
 	// Some options can actually have zero length (OptionRapidCommit), so
 	// just return an empty byte slice if this is the case.
+	v := o.list[i].Value
 	if len(v) == 0 {
 		return []byte{}
 	}
 	return v
 }
 
+// GetRef is like Get, but is meant for callers that only inspect a value and
+// want to avoid copying it.
+//
+// The slice returned is the value stored in o, not a copy. For options parsed
+// by Packet.UnmarshalBinaryNoCopy or UnmarshalNoCopy, it is a view into the
+// receive buffer, unless the option was split into several instances and had
+// to be concatenated. The caller must not modify the slice, and must not use
+// it after the receive buffer is reused or after the option is changed.
+func (o Options) GetRef(key OptionCode) []byte {
+	return o.Get(key)
+}
+
 // Unmarshal fills opts with option codes and corresponding values from an
 // input byte slice.
 //
 // It is used with various different types to enable parsing of both top-level
 // options. If options data is malformed, it returns ErrInvalidOptions or
 // io.ErrUnexpectedEOF.
+//
+// Parsing stops at the End option. Whatever follows it, usually padding but
+// sometimes junk sent by buggy servers, is ignored; see UnmarshalStrict.
 func (o *Options) Unmarshal(buf *buffer.Buffer) error {
-	*o = make(Options)
+	return o.unmarshal(buf, false, false)
+}
+
+// UnmarshalStrict is like Unmarshal, but returns ErrInvalidOptions if
+// anything other than Pad options follows the End option.
+func (o *Options) UnmarshalStrict(buf *buffer.Buffer) error {
+	return o.unmarshal(buf, true, false)
+}
+
+// UnmarshalNoCopy is like Unmarshal, but option values refer to the bytes of
+// buf rather than copies of them. See GetRef for the rules this imposes.
+func (o *Options) UnmarshalNoCopy(buf *buffer.Buffer) error {
+	return o.unmarshal(buf, false, true)
+}
+
+func (o *Options) unmarshal(buf *buffer.Buffer, strict, noCopy bool) error {
+	// Parsed options keep their wire order unless the caller asked for
+	// another, so that marshaling them again gives the same bytes.
+	prio := o.Priority
+	if prio == nil {
+		prio = []OptionCode{}
+	}
+	*o = Options{Sorted: o.Sorted, Priority: prio}
 
 	var end bool
 	for buf.Len() >= 1 {
@@ -105,12 +452,19 @@ func (o *Options) Unmarshal(buf *buffer.Buffer) error {
 
 		// RFC 3396: Just concatenate the data if the option code was
 		// specified multiple times.
-		o.AddRaw(code, data)
+		if noCopy {
+			o.addRef(code, data)
+		} else {
+			o.addChunk(code, data)
+		}
 	}
 
 	if !end {
 		return io.ErrUnexpectedEOF
 	}
+	if !strict {
+		return nil
+	}
 
 	// Any bytes left must be padding.
 	for buf.Len() >= 1 {
@@ -121,53 +475,69 @@ func (o *Options) Unmarshal(buf *buffer.Buffer) error {
 	return nil
 }
 
-// Marshal writes options into the provided Buffer sorted by option codes.
+// Marshal writes options into the provided Buffer in the order they were
+// added, or sorted by option codes if o.Sorted is set, after the options
+// listed in o.Priority.
 func (o Options) Marshal(b *buffer.Buffer) {
-	for _, c := range o.sortedKeys() {
-		code := OptionCode(c)
-		data := o[code]
-
-		// RFC 3396: If more than 256 bytes of data are given, the
-		// option is simply listed multiple times.
-		for len(data) > 0 {
-			// 1 byte: option code
-			b.Write8(uint8(code))
-
-			// Some DHCPv4 options have fixed length and do not put
-			// length on the wire.
-			if code == End || code == Pad {
-				continue
-			}
+	o.each(func(e *entry) {
+		e.marshal(b)
+	})
+	b.Write8(uint8(End))
+}
 
-			n := len(data)
-			if n > math.MaxUint8 {
-				n = math.MaxUint8
-			}
+// marshal writes the option as one or more instances.
+func (e *entry) marshal(b *buffer.Buffer) {
+	e.eachChunk(func(chunk []byte) {
+		// 1 byte: option code
+		b.Write8(uint8(e.Code))
+		// 1 byte: option length
+		b.Write8(uint8(len(chunk)))
+		// N bytes: option data
+		b.WriteBytes(chunk)
+	})
+}
 
-			// 1 byte: option length
-			b.Write8(uint8(n))
+// eachChunk calls fn with the value of each instance of the option as it is
+// written on the wire.
+//
+// RFC 3396: If more than 255 bytes of data are given, the option is simply
+// listed multiple times. Values parsed from several instances keep their
+// original split. Otherwise, values of options that are lists of records,
+// such as addresses or classless static routes, are split between records,
+// since some receivers parse each instance on its own.
+func (e *entry) eachChunk(fn func(chunk []byte)) {
+	// Pad and End have fixed length and carry no data. End is always
+	// written last by Marshal, and padding is never needed.
+	if e.Code == End || e.Code == Pad {
+		return
+	}
 
-			// N bytes: option data
-			b.WriteBytes(data[:n])
-			data = data[n:]
+	data, chunks := e.Value, e.chunks
+	for len(data) > 0 {
+		n := len(data)
+		if len(chunks) > 0 && chunks[0] <= n {
+			n, chunks = chunks[0], chunks[1:]
 		}
-	}
+		chunk := data[:n]
+		data = data[n:]
 
-	// If "End" option is not in map, marshal it manually.
-	if _, ok := o[End]; !ok {
-		b.Write8(uint8(End))
+		for len(chunk) > 0 {
+			m := len(chunk)
+			if m > math.MaxUint8 {
+				m = splitLen(e.Code, chunk)
+			}
+			fn(chunk[:m])
+			chunk = chunk[m:]
+		}
 	}
 }
 
-// sortedKeys returns an ordered slice of option keys from the Options map, for
-// use in serializing options to binary.
-func (o Options) sortedKeys() []int {
-	// Send all values for a given key
-	var codes []int
-	for k := range o {
-		codes = append(codes, int(k))
-	}
-
-	sort.Sort(sort.IntSlice(codes))
-	return codes
+// AppendBinary appends the wire format of the options, terminated by the End
+// option, to dst and returns the extended slice.
+//
+// If dst has enough capacity, AppendBinary does not allocate.
+func (o Options) AppendBinary(dst []byte) ([]byte, error) {
+	b := buffer.New(dst)
+	o.Marshal(b)
+	return b.Data(), nil
 }
@@ -21,24 +21,41 @@ func TestOptionsMarshal(t *testing.T) {
 		want []byte
 	}{
 		{
-			opts: nil,
+			opts: Options{},
 			want: []byte{255},
 		},
 		{
-			opts: Options{
-				5: []byte{1, 2, 3, 4},
-			},
+			opts: NewOptions(
+				Option{5, []byte{1, 2, 3, 4}},
+			),
 			want: []byte{
 				5 /* key */, 4 /* length */, 1, 2, 3, 4,
 				255, /* end key */
 			},
 		},
 		{
-			// Test sorted key order.
-			opts: Options{
-				5:   []byte{1, 2, 3},
-				100: []byte{101, 102, 103},
+			// Test insertion order.
+			opts: NewOptions(
+				Option{100, []byte{101, 102, 103}},
+				Option{5, []byte{1, 2, 3}},
+				Option{100, []byte{104}},
+			),
+			want: []byte{
+				100, 4, 101, 102, 103, 104,
+				5, 3, 1, 2, 3,
+				255,
 			},
+		},
+		{
+			// Test sorted key order.
+			opts: func() Options {
+				o := NewOptions(
+					Option{100, []byte{101, 102, 103}},
+					Option{5, []byte{1, 2, 3}},
+				)
+				o.Sorted = true
+				return o
+			}(),
 			want: []byte{
 				5, 3, 1, 2, 3,
 				100, 3, 101, 102, 103,
@@ -46,16 +63,128 @@ func TestOptionsMarshal(t *testing.T) {
 			},
 		},
 		{
-			// Test RFC 3396.
-			opts: Options{
-				5: bytes.Repeat([]byte{10}, math.MaxUint8+1),
+			// The message type, server identifier, and requested
+			// IP address come first.
+			opts: NewOptions(
+				Option{OptionHostName, []byte{'h'}},
+				Option{OptionRequestedIPAddress, []byte{1, 2, 3, 4}},
+				Option{OptionDHCPMessageType, []byte{3}},
+				Option{OptionServerIdentifier, []byte{5, 6, 7, 8}},
+			),
+			want: []byte{
+				53, 1, 3,
+				54, 4, 5, 6, 7, 8,
+				50, 4, 1, 2, 3, 4,
+				12, 1, 'h',
+				255,
 			},
+		},
+		{
+			// Custom priority, sorted otherwise.
+			opts: func() Options {
+				o := NewOptions(
+					Option{100, []byte{1}},
+					Option{OptionDHCPMessageType, []byte{3}},
+					Option{5, []byte{1, 2, 3, 4}},
+					Option{200, []byte{2}},
+				)
+				o.Sorted = true
+				o.Priority = []OptionCode{200}
+				return o
+			}(),
+			want: []byte{
+				200, 1, 2,
+				5, 4, 1, 2, 3, 4,
+				53, 1, 3,
+				100, 1, 1,
+				255,
+			},
+		},
+		{
+			// No priority.
+			opts: func() Options {
+				o := NewOptions(
+					Option{OptionHostName, []byte{'h'}},
+					Option{OptionDHCPMessageType, []byte{3}},
+				)
+				o.Priority = []OptionCode{}
+				return o
+			}(),
+			want: []byte{
+				12, 1, 'h',
+				53, 1, 3,
+				255,
+			},
+		},
+		{
+			// Test RFC 3396.
+			opts: NewOptions(
+				Option{224, bytes.Repeat([]byte{10}, math.MaxUint8+1)},
+			),
 			want: append(append(
-				[]byte{5, math.MaxUint8}, bytes.Repeat([]byte{10}, math.MaxUint8)...),
-				5, 1, 10,
+				[]byte{224, math.MaxUint8}, bytes.Repeat([]byte{10}, math.MaxUint8)...),
+				224, 1, 10,
 				255,
 			),
 		},
+		{
+			// Lists of addresses are split between addresses.
+			opts: NewOptions(
+				Option{OptionNameServers, bytes.Repeat([]byte{10}, 64*4)},
+			),
+			want: bytes.Join([][]byte{
+				{5, 252}, bytes.Repeat([]byte{10}, 252),
+				{5, 4}, bytes.Repeat([]byte{10}, 4),
+				{255},
+			}, nil),
+		},
+		{
+			// Static routes are split between routes.
+			opts: NewOptions(
+				Option{OptionStaticRoute, bytes.Repeat([]byte{10}, 32*8)},
+			),
+			want: bytes.Join([][]byte{
+				{33, 248}, bytes.Repeat([]byte{10}, 248),
+				{33, 8}, bytes.Repeat([]byte{10}, 8),
+				{255},
+			}, nil),
+		},
+		{
+			// Classless static routes are split between routes
+			// of varying length.
+			opts: NewOptions(
+				Option{OptionClasslessStaticRoute, bytes.Repeat([]byte{32, 10, 0, 0, 1, 192, 168, 0, 1}, 30)},
+			),
+			want: bytes.Join([][]byte{
+				{121, 252}, bytes.Repeat([]byte{32, 10, 0, 0, 1, 192, 168, 0, 1}, 28),
+				{121, 18}, bytes.Repeat([]byte{32, 10, 0, 0, 1, 192, 168, 0, 1}, 2),
+				{255},
+			}, nil),
+		},
+		{
+			// Parsed instances keep their split.
+			opts: func() Options {
+				var o Options
+				o.addChunk(OptionRouters, []byte{1, 1, 1, 1})
+				o.addChunk(OptionRouters, []byte{2, 2, 2, 2})
+				return o
+			}(),
+			want: []byte{
+				3, 4, 1, 1, 1, 1,
+				3, 4, 2, 2, 2, 2,
+				255,
+			},
+		},
+		{
+			// Pad and End carry no data and must not be
+			// written from the map.
+			opts: NewOptions(
+				Option{Pad, []byte{1}},
+				Option{5, []byte{1}},
+				Option{End, []byte{2}},
+			),
+			want: []byte{5, 1, 1, 255},
+		},
 	} {
 		t.Run(fmt.Sprintf("Test %02d", i), func(t *testing.T) {
 			b := buffer.New(nil)
@@ -102,8 +231,9 @@ func TestOptionsUnmarshal(t *testing.T) {
 			err: io.ErrUnexpectedEOF,
 		},
 		{
-			input: []byte{byte(End), 3},
-			err:   ErrInvalidOptions,
+			// Junk after End is ignored.
+			input: []byte{3, 1, 5, byte(End), 3},
+			want:  NewOptions(Option{3, []byte{5}}),
 		},
 		{
 			input: []byte{byte(End)},
@@ -114,9 +244,9 @@ func TestOptionsUnmarshal(t *testing.T) {
 				3, 2, 5, 6,
 				byte(End),
 			},
-			want: Options{
-				3: []byte{5, 6},
-			},
+			want: NewOptions(
+				Option{3, []byte{5, 6}},
+			),
 		},
 		{
 			// Test RFC 3396.
@@ -125,9 +255,12 @@ func TestOptionsUnmarshal(t *testing.T) {
 				3, 5, 10, 10, 10, 10, 10,
 				byte(End),
 			),
-			want: Options{
-				3: bytes.Repeat([]byte{10}, math.MaxUint8+5),
-			},
+			want: func() Options {
+				var o Options
+				o.addChunk(3, bytes.Repeat([]byte{10}, math.MaxUint8))
+				o.addChunk(3, bytes.Repeat([]byte{10}, 5))
+				return o
+			}(),
 		},
 		{
 			input: []byte{
@@ -135,19 +268,19 @@ func TestOptionsUnmarshal(t *testing.T) {
 				11, 3, 5, 5, 5,
 				byte(End),
 			},
-			want: Options{
-				10: []byte{255, 254},
-				11: []byte{5, 5, 5},
-			},
+			want: NewOptions(
+				Option{10, []byte{255, 254}},
+				Option{11, []byte{5, 5, 5}},
+			),
 		},
 		{
 			input: append(
 				append([]byte{10, 2, 255, 254}, bytes.Repeat([]byte{byte(Pad)}, 255)...),
 				byte(End),
 			),
-			want: Options{
-				10: []byte{255, 254},
-			},
+			want: NewOptions(
+				Option{10, []byte{255, 254}},
+			),
 		},
 	} {
 		t.Run(fmt.Sprintf("Test %02d", i), func(t *testing.T) {
@@ -157,9 +290,209 @@ func TestOptionsUnmarshal(t *testing.T) {
 			} else if err != nil {
 				return
 			}
+			// Parsed options are written back in wire order.
+			tt.want.Priority = []OptionCode{}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("got %v want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestOptionsUnmarshalStrict(t *testing.T) {
+	for _, tt := range []struct {
+		input []byte
+		err   error
+	}{
+		{input: []byte{3, 1, 5, byte(End)}},
+		{input: []byte{3, 1, 5, byte(End), byte(Pad), byte(Pad)}},
+		{input: []byte{3, 1, 5, byte(End), 3}, err: ErrInvalidOptions},
+		{input: []byte{3, 1, 5, byte(End), byte(End)}, err: ErrInvalidOptions},
+	} {
+		var o Options
+		if err := o.UnmarshalStrict(buffer.New(tt.input)); err != tt.err {
+			t.Errorf("UnmarshalStrict(%v) = %v, want %v", tt.input, err, tt.err)
+		}
+	}
+
+	p := NewPacket(BootRequest)
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, "junk"...)
+	if err := (&Packet{}).UnmarshalBinary(b); err != nil {
+		t.Errorf("UnmarshalBinary(junk after End) = %v, want nil", err)
+	}
+	if err := (&Packet{}).UnmarshalBinaryStrict(b); err != ErrInvalidOptions {
+		t.Errorf("UnmarshalBinaryStrict(junk after End) = %v, want %v", err, ErrInvalidOptions)
+	}
+}
+
+func TestOptionsEdit(t *testing.T) {
+	o := Options{}
+	o.AddRaw(OptionRouters, []byte{10, 0, 0, 1})
+	o.AddRaw(OptionRouters, []byte{10, 0, 0, 2})
+	o.AddRaw(80 /* rapid commit */, nil)
+	o.AddRaw(OptionHostName, []byte("foo"))
+
+	if got, want := o.Codes(), []OptionCode{OptionRouters, 80, OptionHostName}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Codes() = %v, want %v", got, want)
+	}
+	if !o.Has(80) {
+		t.Errorf("Has(80) = false, want true for empty value")
+	}
+
+	// Replacing keeps the option in place.
+	o.ReplaceRaw(OptionRouters, []byte{10, 0, 0, 3})
+	if got, want := o.Codes(), []OptionCode{OptionRouters, 80, OptionHostName}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Codes() after ReplaceRaw = %v, want %v", got, want)
+	}
+	if got, want := o.Get(OptionRouters), []byte{10, 0, 0, 3}; !bytes.Equal(got, want) {
+		t.Errorf("Get(OptionRouters) after ReplaceRaw = %v, want %v", got, want)
+	}
+	if err := o.Replace(OptionHostName, rawValue("bar")); err != nil {
+		t.Fatalf("Replace() = %v", err)
+	}
+	if got, want := o.Get(OptionHostName), []byte("bar"); !bytes.Equal(got, want) {
+		t.Errorf("Get(OptionHostName) after Replace = %q, want %q", got, want)
+	}
+
+	o.Del(80)
+	o.Del(OptionDomainName)
+	if o.Has(80) {
+		t.Errorf("Has(80) = true after Del")
+	}
+	if got, want := o.Codes(), []OptionCode{OptionRouters, OptionHostName}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Codes() after Del = %v, want %v", got, want)
+	}
+	if got, want := o.Get(OptionHostName), []byte("bar"); !bytes.Equal(got, want) {
+		t.Errorf("Get(OptionHostName) after Del = %q, want %q", got, want)
+	}
+}
+
+func TestOptionsGetAll(t *testing.T) {
+	var o Options
+	if err := o.Unmarshal(buffer.New([]byte{
+		3, 4, 10, 0, 0, 1,
+		12, 3, 'f', 'o', 'o',
+		3, 4, 10, 0, 0, 2,
+		byte(End),
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]byte{{10, 0, 0, 1}, {10, 0, 0, 2}}
+	if got := o.GetAll(OptionRouters); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll(OptionRouters) = %v, want %v", got, want)
+	}
+	if got := o.Get(OptionRouters); !bytes.Equal(got, []byte{10, 0, 0, 1, 10, 0, 0, 2}) {
+		t.Errorf("Get(OptionRouters) = %v, want both routers", got)
+	}
+	if got := o.GetAll(OptionDomainName); got != nil {
+		t.Errorf("GetAll(OptionDomainName) = %v, want nil", got)
+	}
+
+	// Adding to a parsed option extends its last instance.
+	o.AddRaw(OptionRouters, []byte{10, 0, 0, 3})
+	want = [][]byte{{10, 0, 0, 1}, {10, 0, 0, 2, 10, 0, 0, 3}}
+	if got := o.GetAll(OptionRouters); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll(OptionRouters) after AddRaw = %v, want %v", got, want)
+	}
+
+	// Long values are split as Marshal would.
+	o.ReplaceRaw(OptionHostName, bytes.Repeat([]byte{'a'}, 300))
+	if got := o.GetAll(OptionHostName); len(got) != 2 || len(got[0]) != 255 || len(got[1]) != 45 {
+		t.Errorf("GetAll(OptionHostName) split into %d chunks, want 255 and 45 bytes", len(got))
+	}
+}
+
+func TestOptionsClone(t *testing.T) {
+	var o Options
+	if err := o.Unmarshal(buffer.New([]byte{
+		3, 4, 10, 0, 0, 1,
+		12, 3, 'f', 'o', 'o',
+		3, 4, 10, 0, 0, 2,
+		byte(End),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	o.Sorted = true
+	o.Priority = []OptionCode{OptionHostName}
+
+	c := o.Clone()
+	if !reflect.DeepEqual(c, o) {
+		t.Fatalf("Clone() = %v, want %v", c, o)
+	}
+
+	// Changing the clone leaves the original alone.
+	c.Get(OptionHostName)[0] = 'F'
+	c.AddRaw(OptionRouters, []byte{10, 0, 0, 3})
+	c.Del(OptionHostName)
+	c.Priority[0] = OptionRouters
+	if got := o.Get(OptionHostName); string(got) != "foo" {
+		t.Errorf("Get(OptionHostName) after changing clone = %q, want %q", got, "foo")
+	}
+	want := [][]byte{{10, 0, 0, 1}, {10, 0, 0, 2}}
+	if got := o.GetAll(OptionRouters); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll(OptionRouters) after changing clone = %v, want %v", got, want)
+	}
+	if o.Priority[0] != OptionHostName {
+		t.Errorf("Priority after changing clone = %v, want [%v]", o.Priority, OptionHostName)
+	}
+
+	var empty Options
+	if c := empty.Clone(); c.Len() != 0 || c.Priority != nil {
+		t.Errorf("Clone() of empty Options = %v, want empty", c)
+	}
+}
+
+func TestOptionsShallowCopy(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.Options.AddRaw(OptionHostName, []byte("foo"))
+	p.Options.AddRaw(OptionDomainName, []byte("example.com"))
+
+	// The copy shares the index of p, and changes it.
+	q := *p
+	q.Options.AddRaw(OptionRouters, []byte{10, 0, 0, 1})
+	q.Options.Del(OptionHostName)
+
+	if p.Options.Has(OptionRouters) {
+		t.Errorf("Has(OptionRouters) = true for an option added to a copy")
+	}
+	if got := p.Options.Get(OptionRouters); got != nil {
+		t.Errorf("Get(OptionRouters) = %v, want nil", got)
+	}
+	if got := p.Options.Get(OptionHostName); string(got) != "foo" {
+		t.Errorf("Get(OptionHostName) = %q, want %q", got, "foo")
+	}
+	if got := p.Options.Get(OptionDomainName); string(got) != "example.com" {
+		t.Errorf("Get(OptionDomainName) = %q, want %q", got, "example.com")
+	}
+	want := []OptionCode{OptionHostName, OptionDomainName}
+	if got := p.Options.Codes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Codes() = %v, want %v", got, want)
+	}
+	p.Options.ReplaceRaw(OptionRouters, []byte{10, 0, 0, 2})
+	if got := q.Options.Get(OptionRouters); !bytes.Equal(got, []byte{10, 0, 0, 1}) {
+		t.Errorf("copy's Get(OptionRouters) = %v, want 10.0.0.1", got)
+	}
+}
+
+func BenchmarkOptionsAppendBinary(b *testing.B) {
+	o := NewOptions(
+		Option{OptionDHCPMessageType, []byte{5}},
+		Option{OptionSubnetMask, []byte{255, 255, 255, 0}},
+		Option{OptionRouters, []byte{192, 168, 0, 1}},
+		Option{OptionDomainNameServers, []byte{8, 8, 8, 8, 8, 8, 4, 4}},
+		Option{OptionDomainName, []byte("example.com")},
+	)
+	buf := make([]byte, 0, 1500)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = o.AppendBinary(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
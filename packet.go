@@ -20,11 +20,25 @@ const (
 	// must send responses to.
 	chaddrLen = 16
 
-	// flagBroadcast is the broadcast bit in the flag field as defined by
-	// RFC 2131, Section 2, Figure 2.
-	flagBroadcast = 1 << 15
+	// Length of IP over InfiniBand link-layer addresses, which do not fit
+	// in chaddr and are sent with hlen 0 (RFC 4390, Section 2.1).
+	infinibandAddrLen = 20
+
+	// Lengths of the sname and file fields according to RFC 2131, Section
+	// 2.
+	snameLen = 64
+	fileLen  = 128
+
+	// minBOOTPLen is the length of a BOOTP message with the 64-byte
+	// vendor area of RFC 951, which some relays and old servers expect
+	// every message to have at least (RFC 1542, Section 2.1).
+	minBOOTPLen = 300
 )
 
+// FlagBroadcast is the broadcast bit of the flags field, as defined by RFC
+// 2131, Section 2, Figure 2. The other bits are reserved.
+const FlagBroadcast uint16 = 1 << 15
+
 var (
 	// This is the magic cookie for BOOTP/DHCP packets as defined in RFC
 	// 1497 and RFC 2131, Section 3.
@@ -39,7 +53,7 @@ type Packet struct {
 	// defined as an option value.
 	Op OpCode
 
-	// HType is the hardware type.
+	// HType is the hardware type, such as HTypeEthernet.
 	//
 	// The possible values are listed in the IANA ARP assigned numbers.
 	HType uint8
@@ -55,8 +69,9 @@ type Packet struct {
 	// acquisition or renewal process.
 	Secs uint16
 
-	// Broadcast is the broadcast flag of the flags field.
-	Broadcast bool
+	// Flags is the flags field. Only FlagBroadcast is defined; reserved
+	// bits are kept as received. See Broadcast and SetBroadcast.
+	Flags uint16
 
 	// Client IP address.
 	CIAddr net.IP
@@ -70,7 +85,14 @@ type Packet struct {
 	// Gateway IP address.
 	GIAddr net.IP
 
-	// Client hardware address.
+	// CHAddr is the client hardware address. Its length is the hlen
+	// field, and must not exceed 16 bytes.
+	//
+	// The 20-byte addresses of InfiniBand interfaces do not fit; as RFC
+	// 4390, Section 2.1 requires, packets of HType HTypeInfiniBand with
+	// such an address are sent with an hlen of 0 and a zeroed chaddr
+	// field, and identify the client by the client identifier option
+	// instead.
 	CHAddr net.HardwareAddr
 
 	// ServerName is an optional server host name.
@@ -83,70 +105,152 @@ type Packet struct {
 	Options Options
 }
 
+// wireCHAddr returns the hardware address as it is sent in the chaddr field.
+func (p *Packet) wireCHAddr() net.HardwareAddr {
+	if p.HType == HTypeInfiniBand && len(p.CHAddr) > chaddrLen {
+		return nil
+	}
+	return p.CHAddr
+}
+
+// Broadcast reports whether the broadcast flag is set, asking servers and
+// relays to broadcast replies to the client.
+func (p *Packet) Broadcast() bool {
+	return p.Flags&FlagBroadcast != 0
+}
+
+// SetBroadcast sets or clears the broadcast flag, leaving the other bits of
+// the flags field alone.
+func (p *Packet) SetBroadcast(broadcast bool) {
+	if broadcast {
+		p.Flags |= FlagBroadcast
+	} else {
+		p.Flags &^= FlagBroadcast
+	}
+}
+
 // NewPacket returns a new DHCP packet with the given op code.
 func NewPacket(op OpCode) *Packet {
 	return &Packet{
-		Op:      op,
-		HType:   1, /* ethernet */
-		Options: make(Options),
+		Op:    op,
+		HType: HTypeEthernet,
 	}
 }
 
-func writeIP(b *buffer.Buffer, ip net.IP) {
-	var zeros [net.IPv4len]byte
+// Clone returns a deep copy of p, sharing no memory with it, such as a reply
+// kept as a template and changed for each client.
+func (p *Packet) Clone() *Packet {
+	c := *p
+	c.CIAddr = cloneIP(p.CIAddr)
+	c.YIAddr = cloneIP(p.YIAddr)
+	c.SIAddr = cloneIP(p.SIAddr)
+	c.GIAddr = cloneIP(p.GIAddr)
+	if p.CHAddr != nil {
+		c.CHAddr = append(net.HardwareAddr{}, p.CHAddr...)
+	}
+	c.Options = p.Options.Clone()
+	return &c
+}
+
+// cloneIP returns a copy of ip, or nil if ip is nil.
+func cloneIP(ip net.IP) net.IP {
 	if ip == nil {
-		b.WriteBytes(zeros[:])
-	} else {
-		b.WriteBytes(ip[:net.IPv4len])
+		return nil
 	}
+	return append(net.IP{}, ip...)
+}
+
+func writeIP(b *buffer.Buffer, ip net.IP) {
+	// To4 returns nil for nil, short, and non-IPv4 addresses, in which
+	// case the field is left zeroed.
+	copy(b.WriteN(net.IPv4len), ip.To4())
 }
 
 // MarshalBinary writes the packet to binary.
 func (p *Packet) MarshalBinary() ([]byte, error) {
-	b := buffer.New(make([]byte, 0, minPacketLen))
+	return p.AppendBinary(make([]byte, 0, minPacketLen))
+}
+
+// AppendBinary appends the binary form of the packet to dst and returns the
+// extended slice.
+//
+// If dst has enough capacity, AppendBinary does not allocate. Use GetBuffer
+// and PutBuffer to reuse buffers across packets.
+func (p *Packet) AppendBinary(dst []byte) ([]byte, error) {
+	chaddr := p.wireCHAddr()
+	if len(chaddr) > chaddrLen {
+		return nil, fmt.Errorf("hardware address %v is longer than %d bytes", chaddr, chaddrLen)
+	}
+
+	b := buffer.New(dst)
 	b.Write8(uint8(p.Op))
 	b.Write8(p.HType)
 
 	// HLen
-	b.Write8(uint8(len(p.CHAddr)))
+	b.Write8(uint8(len(chaddr)))
 	b.Write8(p.Hops)
 	b.WriteBytes(p.TransactionID[:])
 	b.Write16(p.Secs)
 
-	var flags uint16
-	if p.Broadcast {
-		flags |= flagBroadcast
-	}
-	b.Write16(flags)
+	b.Write16(p.Flags)
 
 	writeIP(b, p.CIAddr)
 	writeIP(b, p.YIAddr)
 	writeIP(b, p.SIAddr)
 	writeIP(b, p.GIAddr)
-	copy(b.WriteN(chaddrLen), p.CHAddr)
-
-	var sname [64]byte
-	copy(sname[:], []byte(p.ServerName))
-	sname[len(p.ServerName)] = 0
-	b.WriteBytes(sname[:])
+	copy(b.WriteN(chaddrLen), chaddr)
 
-	var file [128]byte
-	copy(file[:], []byte(p.BootFile))
-	file[len(p.BootFile)] = 0
-	b.WriteBytes(file[:])
+	// Names that fill the entire field are not NUL-terminated; longer
+	// names are truncated.
+	copy(b.WriteN(snameLen), p.ServerName)
+	copy(b.WriteN(fileLen), p.BootFile)
 
 	// The magic cookie.
 	b.WriteBytes(magicCookie[:])
 
 	p.Options.Marshal(b)
-	// TODO pad to 272 bytes for really old crap.
 	return b.Data(), nil
 }
 
+// MarshalBinaryPadded is like MarshalBinary, but pads the packet with Pad
+// options after the End option to the 300-byte minimum length of a BOOTP
+// message, for relays and servers that drop shorter ones.
+func (p *Packet) MarshalBinaryPadded() ([]byte, error) {
+	b, err := p.AppendBinary(make([]byte, 0, minBOOTPLen))
+	if err != nil {
+		return nil, err
+	}
+	for len(b) < minBOOTPLen {
+		b = append(b, byte(Pad))
+	}
+	return b, nil
+}
+
 // UnmarshalBinary reads the packet from binary.
+//
+// Bytes after the End option are ignored.
 func (p *Packet) UnmarshalBinary(q []byte) error {
+	return p.unmarshal(q, false, false)
+}
+
+// UnmarshalBinaryNoCopy is like UnmarshalBinary, but the addresses and option
+// values of p refer to the bytes of q rather than copies of them. It is meant
+// for servers and relays handling many packets that only inspect a few
+// options of each.
+//
+// q must not be modified or reused, for example for receiving the next packet,
+// while p or any slice obtained from it is in use. Options split into several
+// instances are still concatenated into new slices. Changing p, such as
+// adding to an option with AddRaw, never writes to q.
+func (p *Packet) UnmarshalBinaryNoCopy(q []byte) error {
+	return p.unmarshal(q, false, true)
+}
+
+func (p *Packet) unmarshal(q []byte, strict, noCopy bool) error {
 	b := buffer.New(q)
-	if b.Len() < minPacketLen {
+	// The fixed-length header is followed by the magic cookie; anything
+	// shorter than both is truncated.
+	if b.Len() < minPacketLen+len(magicCookie) {
 		return ErrInvalidPacket
 	}
 
@@ -157,41 +261,46 @@ func (p *Packet) UnmarshalBinary(q []byte) error {
 	b.ReadBytes(p.TransactionID[:])
 	p.Secs = b.Read16()
 
-	flags := b.Read16()
-	if flags&flagBroadcast != 0 {
-		p.Broadcast = true
-	}
-
-	p.CIAddr = make(net.IP, net.IPv4len)
-	b.ReadBytes(p.CIAddr)
-	p.YIAddr = make(net.IP, net.IPv4len)
-	b.ReadBytes(p.YIAddr)
-	p.SIAddr = make(net.IP, net.IPv4len)
-	b.ReadBytes(p.SIAddr)
-	p.GIAddr = make(net.IP, net.IPv4len)
-	b.ReadBytes(p.GIAddr)
+	p.Flags = b.Read16()
 
 	if hlen > chaddrLen {
 		hlen = chaddrLen
 	}
-	// Always read 16 bytes, but only use hlen of them.
-	p.CHAddr = make(net.HardwareAddr, chaddrLen)
-	b.ReadBytes(p.CHAddr)
-	p.CHAddr = p.CHAddr[:hlen]
+	if noCopy {
+		p.CIAddr = net.IP(b.Consume(net.IPv4len)[:net.IPv4len:net.IPv4len])
+		p.YIAddr = net.IP(b.Consume(net.IPv4len)[:net.IPv4len:net.IPv4len])
+		p.SIAddr = net.IP(b.Consume(net.IPv4len)[:net.IPv4len:net.IPv4len])
+		p.GIAddr = net.IP(b.Consume(net.IPv4len)[:net.IPv4len:net.IPv4len])
+		p.CHAddr = net.HardwareAddr(b.Consume(chaddrLen)[:hlen:hlen])
+	} else {
+		p.CIAddr = make(net.IP, net.IPv4len)
+		b.ReadBytes(p.CIAddr)
+		p.YIAddr = make(net.IP, net.IPv4len)
+		b.ReadBytes(p.YIAddr)
+		p.SIAddr = make(net.IP, net.IPv4len)
+		b.ReadBytes(p.SIAddr)
+		p.GIAddr = make(net.IP, net.IPv4len)
+		b.ReadBytes(p.GIAddr)
+
+		// Always read 16 bytes, but only use hlen of them.
+		p.CHAddr = make(net.HardwareAddr, chaddrLen)
+		b.ReadBytes(p.CHAddr)
+		p.CHAddr = p.CHAddr[:hlen]
+	}
 
-	var sname [64]byte
+	var sname [snameLen]byte
 	b.ReadBytes(sname[:])
 	length := strings.Index(string(sname[:]), "\x00")
 	if length == -1 {
-		length = 64
+		length = snameLen
 	}
 	p.ServerName = string(sname[:length])
 
-	var file [128]byte
+	var file [fileLen]byte
 	b.ReadBytes(file[:])
 	length = strings.Index(string(file[:]), "\x00")
 	if length == -1 {
-		length = 128
+		length = fileLen
 	}
 	p.BootFile = string(file[:length])
 
@@ -201,5 +310,108 @@ func (p *Packet) UnmarshalBinary(q []byte) error {
 		return fmt.Errorf("malformed DHCP packet: got magic cookie %v, want %v", cookie[:], magicCookie[:])
 	}
 
-	return (&p.Options).Unmarshal(b)
+	if err := p.Options.unmarshal(b, strict, noCopy); err != nil {
+		return err
+	}
+	return p.unmarshalOverload(sname[:], file[:])
+}
+
+// Values of the option overload option defined by RFC 2132, Section 9.3.
+const (
+	overloadFile  = 1
+	overloadSName = 2
+)
+
+// overload returns the option overload value of p, or 0.
+func (p *Packet) overload() byte {
+	if v := p.Options.Get(OptionOverload); len(v) == 1 {
+		return v[0]
+	}
+	return 0
+}
+
+// unmarshalOverload reads the options the file and sname fields hold if the
+// option overload option says so, appending them to p.Options in the order
+// RFC 3396, Section 5 gives: file, then sname. The fields are cleared and the
+// overload option removed, so that p marshals to an equivalent packet.
+func (p *Packet) unmarshalOverload(sname, file []byte) error {
+	overload := p.overload()
+	if overload == 0 {
+		return nil
+	}
+	if overload&overloadFile != 0 {
+		if err := p.Options.addField(file); err != nil {
+			return err
+		}
+		p.BootFile = ""
+	}
+	if overload&overloadSName != 0 {
+		if err := p.Options.addField(sname); err != nil {
+			return err
+		}
+		p.ServerName = ""
+	}
+	p.Options.Del(OptionOverload)
+	return nil
+}
+
+// ServerHostName returns the name of the server to boot from: the TFTP server
+// name option (66) if present, or else the sname field unless it holds
+// options.
+func (p *Packet) ServerHostName() string {
+	if v := p.Options.Get(OptionTFTPServerName); v != nil {
+		return string(v)
+	}
+	if p.overload()&overloadSName != 0 {
+		return ""
+	}
+	return p.ServerName
+}
+
+// BootFileName returns the name of the file to boot: the boot file name option
+// (67) if present, or else the file field unless it holds options.
+func (p *Packet) BootFileName() string {
+	if v := p.Options.Get(OptionBootFileName); v != nil {
+		return string(v)
+	}
+	if p.overload()&overloadFile != 0 {
+		return ""
+	}
+	return p.BootFile
+}
+
+// SetServerHostName sets the name of the server to boot from. Names that fit
+// are put in the sname field, where every client looks; longer names are put
+// in the TFTP server name option (66) with the field left empty.
+func (p *Packet) SetServerHostName(name string) {
+	p.Options.Del(OptionTFTPServerName)
+	if len(name) <= snameLen && p.overload()&overloadSName == 0 {
+		p.ServerName = name
+		return
+	}
+	p.ServerName = ""
+	p.Options.AddRaw(OptionTFTPServerName, []byte(name))
+}
+
+// SetBootFileName sets the name of the file to boot. Names that fit are put
+// in the file field, where every client looks; longer names are put in the
+// boot file name option (67) with the field left empty.
+func (p *Packet) SetBootFileName(name string) {
+	p.Options.Del(OptionBootFileName)
+	if len(name) <= fileLen && p.overload()&overloadFile == 0 {
+		p.BootFile = name
+		return
+	}
+	p.BootFile = ""
+	p.Options.AddRaw(OptionBootFileName, []byte(name))
+}
+
+// UnmarshalBinaryStrict is like UnmarshalBinary, but also rejects packets
+// with anything but padding after the End option, as Options.UnmarshalStrict
+// does, and packets whose options are not valid according to ValidateOption.
+func (p *Packet) UnmarshalBinaryStrict(q []byte) error {
+	if err := p.unmarshal(q, true, false); err != nil {
+		return err
+	}
+	return p.Options.Validate()
 }
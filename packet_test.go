@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/krolaw/dhcp4"
@@ -33,7 +34,7 @@ func TestPacketMarshalBinary(t *testing.T) {
 				HType:         1,
 				Hops:          2,
 				TransactionID: [4]byte{0xa, 0xb, 0xc, 0xd},
-				Broadcast:     true,
+				Flags:         FlagBroadcast,
 				CIAddr:        net.IP{0xff, 0xee, 0xdd, 0xcc},
 				YIAddr:        net.IP{192, 168, 0, 1},
 				SIAddr:        net.IP{2, 3, 4, 5},
@@ -53,6 +54,27 @@ func TestPacketMarshalBinary(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			// 16-byte IPv4 addresses and names filling their
+			// entire fields.
+			packet: &Packet{
+				Op:         BootReply,
+				HType:      1,
+				YIAddr:     net.ParseIP("192.168.0.1"),
+				GIAddr:     net.ParseIP("10.0.0.1"),
+				ServerName: strings.Repeat("s", 64),
+				BootFile:   strings.Repeat("f", 128),
+			},
+			wantD2G: func() dhcp4.Packet {
+				p := dhcp4.NewPacket(dhcp4.BootReply)
+				p.SetYIAddr(net.IP{192, 168, 0, 1})
+				p.SetGIAddr(net.IP{10, 0, 0, 1})
+				p.SetSName([]byte(strings.Repeat("s", 64)))
+				p.SetFile([]byte(strings.Repeat("f", 128)))
+				return p
+			},
+			err: nil,
+		},
 	} {
 		t.Run(fmt.Sprintf("Test [%02d]", i), func(t *testing.T) {
 			got, err := tt.packet.MarshalBinary()
@@ -86,15 +108,15 @@ func TestPacketUnmarshalBinary(t *testing.T) {
 				return p
 			},
 			want: &Packet{
-				Op:        BootRequest,
-				HType:     1,
-				Broadcast: true,
-				CIAddr:    net.IP{0, 0, 0, 0},
-				YIAddr:    net.IP{0, 0, 0, 0},
-				SIAddr:    net.IP{0, 0, 0, 0},
-				GIAddr:    net.IP{0, 0, 0, 0},
-				CHAddr:    net.HardwareAddr{},
-				Options:   Options{},
+				Op:      BootRequest,
+				HType:   1,
+				Flags:   FlagBroadcast,
+				CIAddr:  net.IP{0, 0, 0, 0},
+				YIAddr:  net.IP{0, 0, 0, 0},
+				SIAddr:  net.IP{0, 0, 0, 0},
+				GIAddr:  net.IP{0, 0, 0, 0},
+				CHAddr:  net.HardwareAddr{},
+				Options: Options{Priority: []OptionCode{}},
 			},
 		},
 		{
@@ -115,15 +137,22 @@ func TestPacketUnmarshalBinary(t *testing.T) {
 				HType:         1,
 				Hops:          2,
 				TransactionID: [4]byte{0xa, 0xb, 0xc, 0xd},
-				Broadcast:     true,
+				Flags:         FlagBroadcast,
 				CIAddr:        net.IP{0xff, 0xee, 0xdd, 0xcc},
 				YIAddr:        net.IP{192, 168, 0, 1},
 				SIAddr:        net.IP{2, 3, 4, 5},
 				GIAddr:        net.IP{0, 0, 0, 0},
 				CHAddr:        net.HardwareAddr{0xfe, 0xab, 0x67},
-				Options:       Options{},
+				Options:       Options{Priority: []OptionCode{}},
 			},
 		},
+		{
+			// Fixed-length header without the magic cookie.
+			packet: func() dhcp4.Packet {
+				return dhcp4.NewPacket(dhcp4.BootRequest)[:minPacketLen+2]
+			},
+			err: ErrInvalidPacket,
+		},
 	} {
 		t.Run(fmt.Sprintf("Test %02d", i), func(t *testing.T) {
 			input := tt.packet()
@@ -140,3 +169,416 @@ func TestPacketUnmarshalBinary(t *testing.T) {
 		})
 	}
 }
+
+func benchmarkPacket() *Packet {
+	p := NewPacket(BootReply)
+	p.TransactionID = [4]byte{1, 2, 3, 4}
+	p.YIAddr = net.IP{192, 168, 0, 10}
+	p.SIAddr = net.IP{192, 168, 0, 1}
+	p.CHAddr = net.HardwareAddr{1, 2, 3, 4, 5, 6}
+	p.Options.AddRaw(OptionDHCPMessageType, []byte{5})
+	p.Options.AddRaw(OptionServerIdentifier, []byte{192, 168, 0, 1})
+	p.Options.AddRaw(OptionIPAddressLeaseTime, []byte{0, 0, 0x0e, 0x10})
+	p.Options.AddRaw(OptionSubnetMask, []byte{255, 255, 255, 0})
+	p.Options.AddRaw(OptionRouters, []byte{192, 168, 0, 1})
+	p.Options.AddRaw(OptionDomainNameServers, []byte{8, 8, 8, 8, 8, 8, 4, 4})
+	p.Options.AddRaw(OptionDomainName, []byte("example.com"))
+	return p
+}
+
+func TestPacketAppendBinaryAllocs(t *testing.T) {
+	p := benchmarkPacket()
+	want, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	allocs := testing.AllocsPerRun(100, func() {
+		*buf, err = p.AppendBinary((*buf)[:0])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(*buf, want) {
+		t.Errorf("AppendBinary got %v, want %v", *buf, want)
+	}
+	if allocs != 0 {
+		t.Errorf("AppendBinary allocated %v times, want 0", allocs)
+	}
+}
+
+// TestPacketAllocs guards against allocation regressions in the hot paths of
+// servers and clients. Raise a budget only with a good reason.
+func TestPacketAllocs(t *testing.T) {
+	p := benchmarkPacket()
+	q, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc   string
+		budget float64
+		f      func() error
+	}{
+		{
+			desc:   "MarshalBinary",
+			budget: 2,
+			f: func() error {
+				_, err := p.MarshalBinary()
+				return err
+			},
+		},
+		{
+			desc:   "UnmarshalBinary",
+			budget: 23,
+			f: func() error {
+				var p Packet
+				return p.UnmarshalBinary(q)
+			},
+		},
+		{
+			desc:   "UnmarshalBinaryNoCopy",
+			budget: 11,
+			f: func() error {
+				var p Packet
+				return p.UnmarshalBinaryNoCopy(q)
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var err error
+			allocs := testing.AllocsPerRun(100, func() {
+				if e := tt.f(); e != nil {
+					err = e
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allocs > tt.budget {
+				t.Errorf("%s allocated %v times, want at most %v", tt.desc, allocs, tt.budget)
+			}
+		})
+	}
+}
+
+func TestPacketHardwareTypes(t *testing.T) {
+	ib := net.HardwareAddr{
+		0x80, 0x00, 0x02, 0x08, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x02, 0xc9, 0x03, 0x00, 0x01, 0x23, 0x45,
+	}
+	for _, tt := range []struct {
+		desc     string
+		htype    uint8
+		chaddr   net.HardwareAddr
+		wantHLen byte
+		wantErr  bool
+	}{
+		{desc: "Ethernet", htype: HTypeEthernet, chaddr: ib[:6], wantHLen: 6},
+		{desc: "EUI-64", htype: HTypeEUI64, chaddr: ib[:8], wantHLen: 8},
+		{desc: "InfiniBand", htype: HTypeInfiniBand, chaddr: ib, wantHLen: 0},
+		{desc: "too long", htype: HTypeEthernet, chaddr: ib, wantErr: true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			p := NewPacket(BootRequest)
+			p.HType = tt.htype
+			p.CHAddr = tt.chaddr
+			b, err := p.MarshalBinary()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MarshalBinary() = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if b[1] != tt.htype || b[2] != tt.wantHLen {
+				t.Errorf("htype, hlen = %d, %d, want %d, %d", b[1], b[2], tt.htype, tt.wantHLen)
+			}
+			chaddr := b[28 : 28+chaddrLen]
+			if !bytes.Equal(chaddr[:tt.wantHLen], tt.chaddr[:tt.wantHLen]) || bytes.Count(chaddr[tt.wantHLen:], []byte{0}) != chaddrLen-int(tt.wantHLen) {
+				t.Errorf("chaddr = %v, want %v zero-padded", chaddr, tt.chaddr[:tt.wantHLen])
+			}
+		})
+	}
+}
+
+func TestPacketFlags(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.Flags = 0x0101
+	p.SetBroadcast(true)
+	if !p.Broadcast() || p.Flags != 0x8101 {
+		t.Errorf("SetBroadcast(true): Flags = %#04x, want %#04x", p.Flags, 0x8101)
+	}
+
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b[10:12]; !bytes.Equal(got, []byte{0x81, 0x01}) {
+		t.Errorf("flags field = %v, want reserved bits kept", got)
+	}
+	var got Packet
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if got.Flags != 0x8101 {
+		t.Errorf("UnmarshalBinary: Flags = %#04x, want %#04x", got.Flags, 0x8101)
+	}
+
+	got.SetBroadcast(false)
+	if got.Broadcast() || got.Flags != 0x0101 {
+		t.Errorf("SetBroadcast(false): Flags = %#04x, want %#04x", got.Flags, 0x0101)
+	}
+}
+
+func TestPacketMarshalBinaryPadded(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.Options.AddRaw(OptionDHCPMessageType, []byte{1})
+	b, err := p.MarshalBinaryPadded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != minBOOTPLen {
+		t.Errorf("MarshalBinaryPadded() is %d bytes long, want %d", len(b), minBOOTPLen)
+	}
+	unpadded, _ := p.MarshalBinary()
+	if !bytes.HasPrefix(b, unpadded) || bytes.Count(b[len(unpadded):], []byte{0}) != len(b)-len(unpadded) {
+		t.Errorf("MarshalBinaryPadded() = %v, want %v followed by zeros", b, unpadded)
+	}
+
+	var got Packet
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary() = %v", err)
+	}
+	if !got.Equal(p) {
+		t.Errorf("UnmarshalBinary(MarshalBinaryPadded()) differs: %v", Diff(&got, p))
+	}
+
+	// Packets already long enough are not padded.
+	p.Options.AddRaw(224, bytes.Repeat([]byte{1}, 100))
+	b, _ = p.MarshalBinaryPadded()
+	unpadded, _ = p.MarshalBinary()
+	if !bytes.Equal(b, unpadded) {
+		t.Errorf("MarshalBinaryPadded() = %v, want %v", b, unpadded)
+	}
+}
+
+func TestPacketOverload(t *testing.T) {
+	p := NewPacket(BootReply)
+	p.Options.AddRaw(OptionDHCPMessageType, []byte{2})
+	p.Options.AddRaw(OptionOverload, []byte{overloadFile | overloadSName})
+	p.Options.AddRaw(OptionRouters, []byte{10, 0, 0, 1})
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill in the fields by hand: the file field holds the boot file
+	// name and a second router, the sname field the server name.
+	sname := b[44 : 44+snameLen]
+	file := b[44+snameLen : 44+snameLen+fileLen]
+	copy(file, append([]byte{byte(OptionBootFileName), 8}, "boot.efi"...))
+	copy(file[10:], []byte{byte(OptionRouters), 4, 10, 0, 0, 2, byte(End)})
+	copy(sname, append([]byte{byte(OptionTFTPServerName), 4}, "tftp"...))
+
+	var got Packet
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary() = %v", err)
+	}
+	if got.Options.Has(OptionOverload) {
+		t.Errorf("option overload kept after reading the fields")
+	}
+	if got.ServerName != "" || got.BootFile != "" {
+		t.Errorf("ServerName, BootFile = %q, %q, want empty", got.ServerName, got.BootFile)
+	}
+	if name := got.BootFileName(); name != "boot.efi" {
+		t.Errorf("BootFileName() = %q, want %q", name, "boot.efi")
+	}
+	if name := got.ServerHostName(); name != "tftp" {
+		t.Errorf("ServerHostName() = %q, want %q", name, "tftp")
+	}
+	if routers := got.Options.GetAll(OptionRouters); len(routers) != 2 {
+		t.Errorf("GetAll(OptionRouters) = %v, want routers from options and file", routers)
+	}
+
+	// Without overload, the fields are names.
+	b, err = got.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Packet
+	if err := again.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary() = %v", err)
+	}
+	if !again.Equal(&got) {
+		t.Errorf("round trip differs: %v", Diff(&again, &got))
+	}
+}
+
+func TestPacketBootFileName(t *testing.T) {
+	p := NewPacket(BootReply)
+	p.BootFile = "field"
+	if name := p.BootFileName(); name != "field" {
+		t.Errorf("BootFileName() = %q, want %q", name, "field")
+	}
+	p.Options.AddRaw(OptionBootFileName, []byte("option"))
+	if name := p.BootFileName(); name != "option" {
+		t.Errorf("BootFileName() = %q, want option 67 to take precedence", name)
+	}
+
+	p.SetBootFileName("pxelinux.0")
+	if p.BootFile != "pxelinux.0" || p.Options.Has(OptionBootFileName) {
+		t.Errorf("SetBootFileName(short) = %q, %v, want it in the file field only", p.BootFile, p.Options.Get(OptionBootFileName))
+	}
+	long := strings.Repeat("a", fileLen+1)
+	p.SetBootFileName(long)
+	if p.BootFile != "" || p.BootFileName() != long {
+		t.Errorf("SetBootFileName(long) = %q, %q, want it in option 67 only", p.BootFile, p.BootFileName())
+	}
+
+	p.ServerName = "field"
+	p.SetServerHostName("tftp.example.com")
+	if p.ServerName != "tftp.example.com" || p.ServerHostName() != "tftp.example.com" {
+		t.Errorf("SetServerHostName() = %q, want it in the sname field", p.ServerName)
+	}
+	p.Options.AddRaw(OptionOverload, []byte{overloadSName})
+	p.SetServerHostName("tftp")
+	if p.ServerName != "" || p.ServerHostName() != "tftp" {
+		t.Errorf("SetServerHostName(overloaded) = %q, %q, want it in option 66 only", p.ServerName, p.ServerHostName())
+	}
+}
+
+func TestPacketUnmarshalBinaryNoCopy(t *testing.T) {
+	want := benchmarkPacket()
+	// A split option must be concatenated into a new slice.
+	want.Options.AddRaw(OptionVendorSpecificInformation, bytes.Repeat([]byte{'v'}, 300))
+	q, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p Packet
+	if err := p.UnmarshalBinaryNoCopy(q); err != nil {
+		t.Fatal(err)
+	}
+	if d := Diff(&p, want); d != nil {
+		t.Fatalf("UnmarshalBinaryNoCopy differs from original: %v", d)
+	}
+
+	// Values are views into q.
+	ref := p.Options.GetRef(OptionDomainName)
+	i := bytes.Index(q, []byte("example.com"))
+	q[i] = 'E'
+	if string(ref) != "Example.com" {
+		t.Errorf("GetRef(OptionDomainName) after changing q = %q, want view into q", ref)
+	}
+	q[16] = 10
+	if !p.YIAddr.Equal(net.IP{10, 168, 0, 10}) {
+		t.Errorf("YIAddr after changing q = %v, want view into q", p.YIAddr)
+	}
+
+	// Changing p never writes to q.
+	before := append([]byte(nil), q...)
+	p.Options.AddRaw(OptionDomainName, []byte(".org"))
+	p.Options.AddRaw(OptionSubnetMask, []byte{0})
+	p.CHAddr = append(p.CHAddr, 7)
+	if !bytes.Equal(q, before) {
+		t.Errorf("changing packet modified the receive buffer")
+	}
+	if v := p.Options.GetRef(OptionVendorSpecificInformation); len(v) != 300 {
+		t.Errorf("GetRef(split option) has %d bytes, want 300", len(v))
+	}
+}
+
+func TestPacketClone(t *testing.T) {
+	q, err := benchmarkPacket().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Packet
+	if err := p.UnmarshalBinaryNoCopy(q); err != nil {
+		t.Fatal(err)
+	}
+
+	c := p.Clone()
+	if d := Diff(c, &p); d != nil {
+		t.Fatalf("Clone() differs from original: %v", d)
+	}
+
+	// The clone outlives the receive buffer.
+	for i := range q {
+		q[i] = 0
+	}
+	if d := Diff(c, benchmarkPacket()); d != nil {
+		t.Errorf("Clone() after reusing receive buffer differs: %v", d)
+	}
+
+	// Changing the clone leaves the original alone.
+	want := benchmarkPacket()
+	o := want.Clone()
+	o.YIAddr[0] = 10
+	o.CHAddr[0] = 0xff
+	o.Options.GetRef(OptionDomainName)[0] = 'E'
+	o.Options.Del(OptionRouters)
+	if d := Diff(want, benchmarkPacket()); d != nil {
+		t.Errorf("changing clone modified the original: %v", d)
+	}
+}
+
+func BenchmarkPacketUnmarshalBinary(b *testing.B) {
+	q, err := benchmarkPacket().MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var p Packet
+		if err := p.UnmarshalBinary(q); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPacketUnmarshalBinaryNoCopy(b *testing.B) {
+	q, err := benchmarkPacket().MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var p Packet
+		if err := p.UnmarshalBinaryNoCopy(q); err != nil {
+			b.Fatal(err)
+		}
+		if p.Options.GetRef(OptionDHCPMessageType) == nil {
+			b.Fatal("no message type")
+		}
+	}
+}
+
+func BenchmarkPacketMarshalBinary(b *testing.B) {
+	p := benchmarkPacket()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.MarshalBinary(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPacketAppendBinary(b *testing.B) {
+	p := benchmarkPacket()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf := GetBuffer()
+			var err error
+			if *buf, err = p.AppendBinary((*buf)[:0]); err != nil {
+				b.Fatal(err)
+			}
+			PutBuffer(buf)
+		}
+	})
+}
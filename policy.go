@@ -0,0 +1,204 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"bytes"
+)
+
+// An OptionPolicy sets or overrides options of a server's reply based on the
+// request it answers.
+//
+// Policies are composed with When and Chain. For example, to serve different
+// boot files to UEFI and BIOS PXE clients:
+//
+//	policy := dhcp4.Chain{
+//		dhcp4.SetBootFile("pxelinux.0"),
+//		dhcp4.When(dhcp4.VendorClassPrefix("PXEClient:Arch:00007"),
+//			dhcp4.SetBootFile("bootx64.efi")),
+//	}
+//	policy.Apply(req, reply)
+type OptionPolicy interface {
+	// Apply modifies reply, which answers req.
+	Apply(req, reply *Packet)
+}
+
+// OptionPolicyFunc is an OptionPolicy implemented by a function.
+type OptionPolicyFunc func(req, reply *Packet)
+
+// Apply implements OptionPolicy.Apply by calling f(req, reply).
+func (f OptionPolicyFunc) Apply(req, reply *Packet) {
+	f(req, reply)
+}
+
+// Chain is an OptionPolicy applying each of its policies in order, so that
+// later policies override earlier ones.
+type Chain []OptionPolicy
+
+// Apply implements OptionPolicy.Apply.
+func (c Chain) Apply(req, reply *Packet) {
+	for _, p := range c {
+		p.Apply(req, reply)
+	}
+}
+
+// A Matcher reports whether a request should be subject to a policy.
+type Matcher func(req *Packet) bool
+
+// When returns a policy that applies policies in order to requests matched
+// by m, and leaves replies to all other requests alone.
+func When(m Matcher, policies ...OptionPolicy) OptionPolicy {
+	return OptionPolicyFunc(func(req, reply *Packet) {
+		if m(req) {
+			Chain(policies).Apply(req, reply)
+		}
+	})
+}
+
+// All returns a Matcher matching requests matched by every one of ms.
+func All(ms ...Matcher) Matcher {
+	return func(req *Packet) bool {
+		for _, m := range ms {
+			if !m(req) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any returns a Matcher matching requests matched by at least one of ms.
+func Any(ms ...Matcher) Matcher {
+	return func(req *Packet) bool {
+		for _, m := range ms {
+			if m(req) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasOption returns a Matcher matching requests carrying option code.
+func HasOption(code OptionCode) Matcher {
+	return func(req *Packet) bool {
+		return req.Options.Has(code)
+	}
+}
+
+// OptionEquals returns a Matcher matching requests whose option code is
+// exactly value.
+func OptionEquals(code OptionCode, value []byte) Matcher {
+	return func(req *Packet) bool {
+		v := req.Options.Get(code)
+		return v != nil && bytes.Equal(v, value)
+	}
+}
+
+// VendorClassPrefix returns a Matcher matching requests whose vendor class
+// identifier (option 60) starts with prefix, such as "PXEClient".
+func VendorClassPrefix(prefix string) Matcher {
+	return func(req *Packet) bool {
+		return bytes.HasPrefix(req.Options.Get(OptionVendorClassIdentifier), []byte(prefix))
+	}
+}
+
+// Requests returns a Matcher matching requests whose parameter request list
+// (option 55) asks for option code.
+func Requests(code OptionCode) Matcher {
+	return func(req *Packet) bool {
+		return bytes.IndexByte(req.Options.Get(OptionParameterRequestList), byte(code)) >= 0
+	}
+}
+
+// UserClass returns a Matcher matching requests whose user class option (77,
+// RFC 3004) lists class.
+func UserClass(class string) Matcher {
+	return func(req *Packet) bool {
+		classes, _ := userClasses(req.Options.Get(OptionUserClass))
+		for _, c := range classes {
+			if string(c) == class {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// userClasses splits the value of a user class option into its
+// length-prefixed items. It returns false if b is malformed, together with the
+// items before the malformed one.
+func userClasses(b []byte) ([][]byte, bool) {
+	var classes [][]byte
+	for len(b) > 0 {
+		n := int(b[0])
+		if n == 0 || len(b) < 1+n {
+			return classes, false
+		}
+		classes = append(classes, b[1:1+n])
+		b = b[1+n:]
+	}
+	return classes, true
+}
+
+// RelayAgentSubOption returns a Matcher matching relayed requests whose
+// relay agent information (option 82, RFC 3046) carries sub-option sub with
+// exactly value, such as the circuit ID (1) or remote ID (2) of the port the
+// client is connected to.
+func RelayAgentSubOption(sub uint8, value []byte) Matcher {
+	return func(req *Packet) bool {
+		v, ok := subOption(req.Options.Get(OptionRelayAgentInformation), sub)
+		return ok && bytes.Equal(v, value)
+	}
+}
+
+// subOption returns the value of sub-option code in b, a sequence of
+// code-length-value encoded sub-options.
+func subOption(b []byte, code uint8) ([]byte, bool) {
+	for len(b) >= 2 {
+		c, n := b[0], int(b[1])
+		if len(b) < 2+n {
+			return nil, false
+		}
+		if c == code {
+			return b[2 : 2+n], true
+		}
+		b = b[2+n:]
+	}
+	return nil, false
+}
+
+// SetOption returns a policy setting option code of the reply to value,
+// replacing any value it already has.
+func SetOption(code OptionCode, value []byte) OptionPolicy {
+	return OptionPolicyFunc(func(_, reply *Packet) {
+		reply.Options.ReplaceRaw(code, value)
+	})
+}
+
+// DelOption returns a policy removing option code from the reply.
+func DelOption(code OptionCode) OptionPolicy {
+	return OptionPolicyFunc(func(_, reply *Packet) {
+		reply.Options.Del(code)
+	})
+}
+
+// SetBootFile returns a policy setting the boot file of the reply, in the
+// file field of the header or, if it does not fit, option 67. See
+// Packet.SetBootFileName.
+func SetBootFile(name string) OptionPolicy {
+	return OptionPolicyFunc(func(_, reply *Packet) {
+		reply.SetBootFileName(name)
+	})
+}
+
+// SetServerName returns a policy setting the server host name of the reply,
+// in the sname field of the header or, if it does not fit, option 66. See
+// Packet.SetServerHostName.
+func SetServerName(name string) OptionPolicy {
+	return OptionPolicyFunc(func(_, reply *Packet) {
+		reply.SetServerHostName(name)
+	})
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOptionPolicy(t *testing.T) {
+	policy := Chain{
+		SetBootFile("pxelinux.0"),
+		SetOption(OptionTFTPServerName, []byte("tftp")),
+		When(VendorClassPrefix("PXEClient:Arch:00007"),
+			SetBootFile("bootx64.efi")),
+		When(All(Requests(OptionDomainName), HasOption(OptionUserClass)),
+			SetOption(OptionDomainName, []byte("lab"))),
+		When(Any(RelayAgentSubOption(1, []byte("eth0/1")), OptionEquals(OptionHostName, []byte("tftpless"))),
+			DelOption(OptionTFTPServerName)),
+	}
+
+	for _, tt := range []struct {
+		desc     string
+		req      Options
+		wantFile string
+		want     Options
+	}{
+		{
+			desc:     "default",
+			req:      NewOptions(),
+			wantFile: "pxelinux.0",
+			want:     NewOptions(Option{OptionTFTPServerName, []byte("tftp")}),
+		},
+		{
+			desc: "UEFI",
+			req: NewOptions(
+				Option{OptionVendorClassIdentifier, []byte("PXEClient:Arch:00007:UNDI:003016")},
+			),
+			wantFile: "bootx64.efi",
+			want:     NewOptions(Option{OptionTFTPServerName, []byte("tftp")}),
+		},
+		{
+			desc: "BIOS",
+			req: NewOptions(
+				Option{OptionVendorClassIdentifier, []byte("PXEClient:Arch:00000:UNDI:002001")},
+			),
+			wantFile: "pxelinux.0",
+			want:     NewOptions(Option{OptionTFTPServerName, []byte("tftp")}),
+		},
+		{
+			desc: "requested and user class",
+			req: NewOptions(
+				Option{OptionParameterRequestList, []byte{1, 3, 15}},
+				Option{OptionUserClass, []byte{3, 'f', 'o', 'o'}},
+			),
+			wantFile: "pxelinux.0",
+			want: NewOptions(
+				Option{OptionTFTPServerName, []byte("tftp")},
+				Option{OptionDomainName, []byte("lab")},
+			),
+		},
+		{
+			desc: "requested only",
+			req: NewOptions(
+				Option{OptionParameterRequestList, []byte{1, 3, 15}},
+			),
+			wantFile: "pxelinux.0",
+			want:     NewOptions(Option{OptionTFTPServerName, []byte("tftp")}),
+		},
+		{
+			desc: "relay circuit ID",
+			req: NewOptions(
+				Option{OptionRelayAgentInformation, []byte{2, 1, 'x', 1, 6, 'e', 't', 'h', '0', '/', '1'}},
+			),
+			wantFile: "pxelinux.0",
+			want:     NewOptions(),
+		},
+		{
+			desc: "other relay circuit ID",
+			req: NewOptions(
+				Option{OptionRelayAgentInformation, []byte{1, 6, 'e', 't', 'h', '0', '/', '2'}},
+			),
+			wantFile: "pxelinux.0",
+			want:     NewOptions(Option{OptionTFTPServerName, []byte("tftp")}),
+		},
+		{
+			desc: "truncated relay information",
+			req: NewOptions(
+				Option{OptionRelayAgentInformation, []byte{1, 7, 'e', 't', 'h', '0', '/', '1'}},
+			),
+			wantFile: "pxelinux.0",
+			want:     NewOptions(Option{OptionTFTPServerName, []byte("tftp")}),
+		},
+		{
+			desc: "host name",
+			req: NewOptions(
+				Option{OptionHostName, []byte("tftpless")},
+			),
+			wantFile: "pxelinux.0",
+			want:     NewOptions(),
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			req := &Packet{Options: tt.req}
+			reply := NewPacket(BootReply)
+			policy.Apply(req, reply)
+
+			if reply.BootFile != tt.wantFile {
+				t.Errorf("BootFile = %q, want %q", reply.BootFile, tt.wantFile)
+			}
+			got, _ := reply.Options.AppendBinary(nil)
+			want, _ := tt.want.AppendBinary(nil)
+			if !bytes.Equal(got, want) {
+				t.Errorf("Options = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"sync"
+)
+
+// pooledBufferLen is the initial capacity of pooled buffers. It fits a
+// packet in a standard 1500-byte Ethernet MTU.
+const pooledBufferLen = 1500
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, pooledBufferLen)
+		return &b
+	},
+}
+
+// GetBuffer returns an empty byte slice from a shared pool for use as the
+// destination of AppendBinary.
+//
+// Pointers are pooled rather than slices so that the round trip through the
+// pool does not allocate. Typical use when serializing many replies is:
+//
+//	buf := dhcp4.GetBuffer()
+//	defer dhcp4.PutBuffer(buf)
+//
+//	var err error
+//	if *buf, err = reply.AppendBinary((*buf)[:0]); err != nil {
+//	  return err
+//	}
+//	_, err = conn.WriteTo(*buf, addr)
+func GetBuffer() *[]byte {
+	b := bufferPool.Get().(*[]byte)
+	*b = (*b)[:0]
+	return b
+}
+
+// PutBuffer returns a buffer obtained from GetBuffer to the pool.
+//
+// The buffer must not be used after it is returned.
+func PutBuffer(b *[]byte) {
+	bufferPool.Put(b)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+	"time"
+)
+
+// DefaultReceiveBufferLen is the receive buffer size of a Receiver that does
+// not set one, which fits a packet in a standard 1500-byte Ethernet MTU.
+const DefaultReceiveBufferLen = 1500
+
+// A Receiver reads DHCP packets from a connection and hands each valid one to
+// a handler, as every client, server, and relay needs to.
+//
+// Datagrams that are not valid DHCP packets, or that Accept rejects, are
+// skipped.
+type Receiver struct {
+	// Conn is the connection packets are read from.
+	Conn net.PacketConn
+
+	// BufLen is the size of the receive buffer; longer datagrams are
+	// truncated. If zero, DefaultReceiveBufferLen is used.
+	BufLen int
+
+	// Accept, if not nil, reports whether a packet received from addr
+	// should be handled.
+	Accept func(p *Packet, addr net.Addr) bool
+
+	// Deadline, if not nil, returns the read deadline set before every
+	// read. Reads that time out are retried, so a deadline in the near
+	// future makes Run notice that done has been closed even when no
+	// packets arrive. If nil, no deadline is set, and Run only notices
+	// done when a read fails, such as when Conn is closed.
+	Deadline func() time.Time
+}
+
+// PollDeadline returns a Receiver deadline function waking it up every d.
+func PollDeadline(d time.Duration) func() time.Time {
+	return func() time.Time {
+		return time.Now().Add(d)
+	}
+}
+
+// Run reads packets until done is closed, calling handle for every accepted
+// packet in the order they arrive.
+//
+// Packets are decoded into new Packets, so handle may keep them. Run returns
+// nil once done is closed, and otherwise the first read error that is not a
+// timeout.
+func (r *Receiver) Run(done <-chan struct{}, handle func(p *Packet, addr net.Addr)) error {
+	bufLen := r.BufLen
+	if bufLen == 0 {
+		bufLen = DefaultReceiveBufferLen
+	}
+	// Packets are copied out of b by UnmarshalBinary, so the buffer can
+	// be reused for every read.
+	b := make([]byte, bufLen)
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		if r.Deadline != nil {
+			r.Conn.SetReadDeadline(r.Deadline())
+		}
+
+		n, addr, err := r.Conn.ReadFrom(b)
+		if err != nil {
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		p := &Packet{}
+		if err := p.UnmarshalBinary(b[:n]); err != nil {
+			continue
+		}
+		if r.Accept != nil && !r.Accept(p, addr) {
+			continue
+		}
+		handle(p, addr)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReceiver(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer conn.Close()
+	sender, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	got := make(chan *Packet, 10)
+	done := make(chan struct{})
+	errc := make(chan error, 1)
+	r := &Receiver{
+		Conn: conn,
+		Accept: func(p *Packet, _ net.Addr) bool {
+			return p.Op == BootReply
+		},
+		Deadline: PollDeadline(10 * time.Millisecond),
+	}
+	go func() {
+		errc <- r.Run(done, func(p *Packet, _ net.Addr) { got <- p })
+	}()
+
+	send := func(b []byte) {
+		if _, err := sender.WriteTo(b, conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	marshal := func(op OpCode, xid byte) []byte {
+		p := NewPacket(op)
+		p.TransactionID = [4]byte{0, 0, 0, xid}
+		b, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	send([]byte("garbage"))
+	send(marshal(BootRequest, 1))
+	send(marshal(BootReply, 2))
+
+	select {
+	case p := <-got:
+		if p.TransactionID[3] != 2 {
+			t.Errorf("handled packet with xid %v, want only the reply", p.TransactionID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply not handled")
+	}
+
+	close(done)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Run() = %v, want nil after done is closed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after done was closed")
+	}
+	if len(got) != 0 {
+		t.Errorf("handled %d unexpected packets", len(got))
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"encoding"
+	"fmt"
+	"sync"
+)
+
+// OptionValue is the decoded value of an option, which can be converted to
+// and from its wire format.
+//
+// UnmarshalBinary is called on a value returned by the constructor registered
+// for the option, so implementations are usually pointers.
+type OptionValue interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[OptionCode]func() OptionValue)
+)
+
+// RegisterOptionValue registers newValue as the constructor of the values of
+// option code returned by GetTyped, replacing any earlier registration.
+//
+// The types of package dhcp4opts register themselves for the options they
+// implement when it is imported. Other packages may register types for
+// vendor-specific or newer options, or replace the default ones.
+func RegisterOptionValue(code OptionCode, newValue func() OptionValue) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if newValue == nil {
+		delete(registry, code)
+		return
+	}
+	registry[code] = newValue
+}
+
+// NewOptionValue returns a new, empty value of the type registered for option
+// code, or nil if there is none.
+func NewOptionValue(code OptionCode) OptionValue {
+	registryMu.RLock()
+	newValue := registry[code]
+	registryMu.RUnlock()
+	if newValue == nil {
+		return nil
+	}
+	return newValue()
+}
+
+// GetTyped returns the value of option code decoded into the type registered
+// for it with RegisterOptionValue.
+//
+// It returns ErrOptionNotPresent if o has no option code, and
+// ErrUnknownOptionType if no type is registered for it.
+//
+// Options split into several instances, as RFC 3396 allows values longer than
+// 255 bytes to be, are decoded from the concatenation of all instances, so
+// that types such as lists of routes or domains need not know about the
+// split.
+func (o Options) GetTyped(code OptionCode) (OptionValue, error) {
+	if !o.Has(code) {
+		return nil, ErrOptionNotPresent
+	}
+	v := NewOptionValue(code)
+	if v == nil {
+		return nil, ErrUnknownOptionType
+	}
+	if err := v.UnmarshalBinary(o.Get(code)); err != nil {
+		return nil, fmt.Errorf("option %d: %v", code, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"errors"
+	"testing"
+)
+
+// testValue is an OptionValue holding a single non-zero byte.
+type testValue byte
+
+func (v testValue) MarshalBinary() ([]byte, error) {
+	return []byte{byte(v)}, nil
+}
+
+func (v *testValue) UnmarshalBinary(p []byte) error {
+	if len(p) != 1 || p[0] == 0 {
+		return errors.New("bad test value")
+	}
+	*v = testValue(p[0])
+	return nil
+}
+
+func TestGetTyped(t *testing.T) {
+	const code OptionCode = 224
+	RegisterOptionValue(code, func() OptionValue { return new(testValue) })
+	defer RegisterOptionValue(code, nil)
+
+	o := NewOptions(
+		Option{Code: code, Value: []byte{42}},
+		Option{Code: 225, Value: []byte{1}},
+	)
+	v, err := o.GetTyped(code)
+	if err != nil {
+		t.Fatalf("GetTyped() = %v", err)
+	}
+	if got, ok := v.(*testValue); !ok || *got != 42 {
+		t.Errorf("GetTyped() = %#v, want 42", v)
+	}
+
+	if _, err := o.GetTyped(225); err != ErrUnknownOptionType {
+		t.Errorf("GetTyped(unregistered) = %v, want %v", err, ErrUnknownOptionType)
+	}
+	if _, err := o.GetTyped(226); err != ErrOptionNotPresent {
+		t.Errorf("GetTyped(missing) = %v, want %v", err, ErrOptionNotPresent)
+	}
+	o.ReplaceRaw(code, []byte{0})
+	if _, err := o.GetTyped(code); err == nil {
+		t.Errorf("GetTyped(invalid) = nil, want error")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+)
+
+func isZeroIP(ip net.IP) bool {
+	return ip == nil || ip.IsUnspecified()
+}
+
+// ReplyDestination returns where a server must send reply in answer to req,
+// following RFC 2131, Section 4.1:
+//
+//   - If req was relayed, the reply goes to the relay agent at giaddr on the
+//     server port.
+//   - A DHCPNAK that was not relayed is always broadcast.
+//   - If req has a client address, the reply is unicast to ciaddr.
+//   - If req has the broadcast bit set, the reply is broadcast.
+//   - Otherwise, the reply is unicast to reply's yiaddr at the link-layer
+//     address chaddr.
+//
+// In the last case, the returned hardware address is non-nil. The client
+// does not have its address configured yet and will not answer ARP, so the
+// reply must be sent with a raw socket addressed to that hardware address.
+// In all other cases, the returned hardware address is nil and the reply can
+// be sent on a regular UDP socket.
+//
+// When relaying a DHCPNAK, RFC 2131 also requires the server to set the
+// broadcast bit of the reply; ReplyDestination does not modify reply.
+func ReplyDestination(req, reply *Packet) (*net.UDPAddr, net.HardwareAddr) {
+	if !isZeroIP(req.GIAddr) {
+		return &net.UDPAddr{IP: req.GIAddr, Port: ServerPort}, nil
+	}
+	if reply.MessageType() == MessageTypeNAK {
+		return &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}, nil
+	}
+	return clientDestination(req.CIAddr, req.Broadcast(), reply)
+}
+
+// RelayReplyDestination returns where a relay agent must forward reply, which
+// it received from a server, so that it reaches the client, as described by
+// RFC 1542, Section 5.4.
+//
+// As with ReplyDestination, a non-nil hardware address means the reply must
+// be sent with a raw socket addressed to that hardware address.
+func RelayReplyDestination(reply *Packet) (*net.UDPAddr, net.HardwareAddr) {
+	return clientDestination(reply.CIAddr, reply.Broadcast(), reply)
+}
+
+func clientDestination(ciaddr net.IP, broadcast bool, reply *Packet) (*net.UDPAddr, net.HardwareAddr) {
+	switch {
+	case !isZeroIP(ciaddr):
+		return &net.UDPAddr{IP: ciaddr, Port: ClientPort}, nil
+	case broadcast || isZeroIP(reply.YIAddr):
+		return &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}, nil
+	default:
+		return &net.UDPAddr{IP: reply.YIAddr, Port: ClientPort}, reply.CHAddr
+	}
+}
+
+// BuildReplySkeleton returns the start of a reply of message type mt to req
+// from the server with address serverID, following RFC 2131, Section 4.3.1,
+// Table 3:
+//
+//   - htype, chaddr, xid, flags, and giaddr are copied from req, as is
+//     ciaddr for a DHCPACK.
+//   - The reply carries the message type and the server identifier.
+//   - The client identifier of req, if any, is echoed as required by RFC
+//     6842.
+//   - The relay agent information of req, if any, is echoed as required by
+//     RFC 3046, Section 2.2. Marshal writes it after any options the caller
+//     adds, unless the caller sets Options.Priority.
+//   - A DHCPNAK sent to a relay agent has the broadcast bit set.
+//
+// No other option of req is copied: options such as the parameter request
+// list, the requested IP address, and the maximum message size must never
+// appear in replies. The caller fills in yiaddr, the lease time, and the
+// configuration options the client asked for, none of which a DHCPNAK may
+// carry.
+func BuildReplySkeleton(req *Packet, mt MessageType, serverID net.IP) *Packet {
+	p := NewPacket(BootReply)
+	p.HType = req.HType
+	p.CHAddr = req.CHAddr
+	p.TransactionID = req.TransactionID
+	p.Flags = req.Flags
+	p.GIAddr = req.GIAddr
+	if mt == MessageTypeACK {
+		p.CIAddr = req.CIAddr
+	}
+	if mt == MessageTypeNAK && !isZeroIP(req.GIAddr) {
+		p.SetBroadcast(true)
+	}
+
+	p.SetMessageType(mt)
+	if ip := serverID.To4(); ip != nil {
+		p.Options.AddRaw(OptionServerIdentifier, ip)
+	}
+	for _, code := range []OptionCode{OptionClientIdentifier, OptionRelayAgentInformation} {
+		if v := req.Options.Get(code); v != nil {
+			p.Options.AddRaw(code, v)
+		}
+	}
+	return p
+}
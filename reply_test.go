@@ -0,0 +1,195 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestReplyDestination(t *testing.T) {
+	chaddr := net.HardwareAddr{1, 2, 3, 4, 5, 6}
+	yiaddr := net.IP{192, 168, 0, 10}
+	bcast := &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}
+
+	for _, tt := range []struct {
+		desc       string
+		req        *Packet
+		nak        bool
+		wantAddr   *net.UDPAddr
+		wantHWAddr net.HardwareAddr
+	}{
+		{
+			desc:     "relayed",
+			req:      &Packet{GIAddr: net.IP{10, 0, 0, 1}, CIAddr: net.IP{10, 0, 0, 5}},
+			wantAddr: &net.UDPAddr{IP: net.IP{10, 0, 0, 1}, Port: ServerPort},
+		},
+		{
+			desc:     "relayed NAK",
+			req:      &Packet{GIAddr: net.IP{10, 0, 0, 1}},
+			nak:      true,
+			wantAddr: &net.UDPAddr{IP: net.IP{10, 0, 0, 1}, Port: ServerPort},
+		},
+		{
+			desc:     "NAK to renewing client",
+			req:      &Packet{CIAddr: net.IP{10, 0, 0, 5}},
+			nak:      true,
+			wantAddr: bcast,
+		},
+		{
+			desc:     "renewing client",
+			req:      &Packet{CIAddr: net.IP{10, 0, 0, 5}, Flags: FlagBroadcast},
+			wantAddr: &net.UDPAddr{IP: net.IP{10, 0, 0, 5}, Port: ClientPort},
+		},
+		{
+			desc:     "broadcast bit",
+			req:      &Packet{CIAddr: net.IPv4zero, Flags: FlagBroadcast},
+			wantAddr: bcast,
+		},
+		{
+			desc:       "unicast to new client",
+			req:        &Packet{CIAddr: net.IPv4zero},
+			wantAddr:   &net.UDPAddr{IP: yiaddr, Port: ClientPort},
+			wantHWAddr: chaddr,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			reply := NewPacket(BootReply)
+			reply.YIAddr = yiaddr
+			reply.CHAddr = chaddr
+			if tt.nak {
+				reply.Options.ReplaceRaw(OptionDHCPMessageType, []byte{byte(MessageTypeNAK)})
+			}
+
+			addr, hwaddr := ReplyDestination(tt.req, reply)
+			if !reflect.DeepEqual(addr, tt.wantAddr) {
+				t.Errorf("ReplyDestination() addr = %v, want %v", addr, tt.wantAddr)
+			}
+			if !reflect.DeepEqual(hwaddr, tt.wantHWAddr) {
+				t.Errorf("ReplyDestination() hwaddr = %v, want %v", hwaddr, tt.wantHWAddr)
+			}
+		})
+	}
+}
+
+func TestBuildReplySkeleton(t *testing.T) {
+	const offer = 2
+	serverID := net.IP{10, 0, 0, 1}
+	req := &Packet{
+		Op:            BootRequest,
+		HType:         HTypeEthernet,
+		TransactionID: [4]byte{1, 2, 3, 4},
+		Secs:          5,
+		CIAddr:        net.IP{192, 168, 0, 10},
+		GIAddr:        net.IP{192, 168, 0, 1},
+		CHAddr:        net.HardwareAddr{1, 2, 3, 4, 5, 6},
+		Options: NewOptions(
+			Option{OptionDHCPMessageType, []byte{3}},
+			Option{OptionClientIdentifier, []byte{1, 1, 2, 3, 4, 5, 6}},
+			Option{OptionParameterRequestList, []byte{1, 3}},
+			Option{OptionRequestedIPAddress, []byte{192, 168, 0, 10}},
+			Option{OptionMaximumDHCPMessageSize, []byte{5, 220}},
+			Option{OptionRelayAgentInformation, []byte{1, 3, 'g', 'e', '0'}},
+		),
+	}
+
+	for _, tt := range []struct {
+		desc string
+		mt   MessageType
+		want *Packet
+	}{
+		{
+			desc: "offer",
+			mt:   offer,
+			want: &Packet{
+				Op:            BootReply,
+				HType:         HTypeEthernet,
+				TransactionID: [4]byte{1, 2, 3, 4},
+				GIAddr:        net.IP{192, 168, 0, 1},
+				CHAddr:        net.HardwareAddr{1, 2, 3, 4, 5, 6},
+				Options: NewOptions(
+					Option{OptionDHCPMessageType, []byte{offer}},
+					Option{OptionServerIdentifier, serverID},
+					Option{OptionClientIdentifier, []byte{1, 1, 2, 3, 4, 5, 6}},
+					Option{OptionRelayAgentInformation, []byte{1, 3, 'g', 'e', '0'}},
+				),
+			},
+		},
+		{
+			desc: "ACK keeps ciaddr",
+			mt:   MessageTypeACK,
+			want: &Packet{
+				Op:            BootReply,
+				HType:         HTypeEthernet,
+				TransactionID: [4]byte{1, 2, 3, 4},
+				CIAddr:        net.IP{192, 168, 0, 10},
+				GIAddr:        net.IP{192, 168, 0, 1},
+				CHAddr:        net.HardwareAddr{1, 2, 3, 4, 5, 6},
+				Options: NewOptions(
+					Option{OptionDHCPMessageType, []byte{byte(MessageTypeACK)}},
+					Option{OptionServerIdentifier, serverID},
+					Option{OptionClientIdentifier, []byte{1, 1, 2, 3, 4, 5, 6}},
+					Option{OptionRelayAgentInformation, []byte{1, 3, 'g', 'e', '0'}},
+				),
+			},
+		},
+		{
+			desc: "relayed NAK is broadcast",
+			mt:   MessageTypeNAK,
+			want: &Packet{
+				Op:            BootReply,
+				HType:         HTypeEthernet,
+				TransactionID: [4]byte{1, 2, 3, 4},
+				Flags:         FlagBroadcast,
+				GIAddr:        net.IP{192, 168, 0, 1},
+				CHAddr:        net.HardwareAddr{1, 2, 3, 4, 5, 6},
+				Options: NewOptions(
+					Option{OptionDHCPMessageType, []byte{byte(MessageTypeNAK)}},
+					Option{OptionServerIdentifier, serverID},
+					Option{OptionClientIdentifier, []byte{1, 1, 2, 3, 4, 5, 6}},
+					Option{OptionRelayAgentInformation, []byte{1, 3, 'g', 'e', '0'}},
+				),
+			},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := BuildReplySkeleton(req, tt.mt, serverID)
+			if d := Diff(got, tt.want); d != nil {
+				t.Errorf("BuildReplySkeleton() differs: %v", d)
+			}
+		})
+	}
+}
+
+func TestBuildReplySkeletonRelayAgentLast(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.GIAddr = net.IP{192, 168, 0, 1}
+	req.Options.AddRaw(OptionDHCPMessageType, []byte{byte(MessageTypeRequest)})
+	req.Options.AddRaw(OptionRelayAgentInformation, []byte{1, 3, 'g', 'e', '0'})
+
+	p := BuildReplySkeleton(req, MessageTypeACK, net.IP{10, 0, 0, 1})
+	p.Options.AddRaw(OptionIPAddressLeaseTime, []byte{0, 0, 0x0e, 0x10})
+	p.Options.AddRaw(OptionSubnetMask, []byte{255, 255, 255, 0})
+
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []OptionCode
+	for it := IterOptions(b); it.Next(); {
+		got = append(got, it.Code())
+	}
+	want := []OptionCode{
+		OptionDHCPMessageType,
+		OptionServerIdentifier,
+		OptionIPAddressLeaseTime,
+		OptionSubnetMask,
+		OptionRelayAgentInformation,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reply options = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// optionSchema constrains the values of an option.
+type optionSchema struct {
+	// min and max bound the length of the value. A max of 0 means the
+	// length is unbounded, as RFC 3396 allows long options to be split.
+	min, max int
+
+	// multiple, if non-zero, is the size of the records the value is a
+	// list of.
+	multiple int
+
+	// record, if non-nil, returns the length of the variable-size record
+	// the value starts with, or 0 if it does not start with one, for
+	// options whose value is a list of such records.
+	record func(v []byte) int
+
+	// check, if non-nil, describes what is wrong with a value of legal
+	// length, or returns the empty string if it is valid.
+	check func(v []byte) string
+}
+
+var (
+	ipSchema      = optionSchema{min: 4, max: 4}
+	ipsSchema     = optionSchema{min: 4, multiple: 4}
+	ipPairsSchema = optionSchema{min: 8, multiple: 8}
+	stringSchema  = optionSchema{min: 1}
+	uint8Schema   = optionSchema{min: 1, max: 1}
+	uint16Schema  = optionSchema{min: 2, max: 2}
+	uint32Schema  = optionSchema{min: 4, max: 4}
+	boolSchema    = optionSchema{min: 1, max: 1, check: oneOf(0, 1)}
+)
+
+// oneOf returns a check accepting single-byte values in vals.
+func oneOf(vals ...byte) func([]byte) string {
+	return func(v []byte) string {
+		for _, val := range vals {
+			if v[0] == val {
+				return ""
+			}
+		}
+		return fmt.Sprintf("value %d is not one of %v", v[0], vals)
+	}
+}
+
+// validSubOptions reports whether b is a well-formed sequence of
+// code-length-value encoded sub-options.
+func validSubOptions(b []byte) bool {
+	for len(b) >= 2 {
+		n := int(b[1])
+		if len(b) < 2+n {
+			return false
+		}
+		b = b[2+n:]
+	}
+	return len(b) == 0
+}
+
+// validClasslessRoutes reports whether b is a well-formed list of classless
+// static routes as defined by RFC 3442: each a prefix length of at most 32,
+// the significant octets of the destination, and a router address.
+func validClasslessRoutes(b []byte) bool {
+	for len(b) > 0 {
+		n := classlessRouteLen(b)
+		if n == 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+// classlessRouteLen returns the length of the classless static route b
+// starts with, or 0 if b does not start with a whole route.
+func classlessRouteLen(b []byte) int {
+	if len(b) == 0 || b[0] > 32 {
+		return 0
+	}
+	n := 1 + (int(b[0])+7)/8 + 4
+	if len(b) < n {
+		return 0
+	}
+	return n
+}
+
+// optionSchemas are the constraints on the options defined by RFC 2132 and
+// the later RFCs named in const.go.
+var optionSchemas = map[OptionCode]optionSchema{
+	OptionSubnetMask:                                 ipSchema,
+	OptionTimeOffset:                                 uint32Schema,
+	OptionRouters:                                    ipsSchema,
+	OptionTimeServers:                                ipsSchema,
+	OptionNameServers:                                ipsSchema,
+	OptionDomainNameServers:                          ipsSchema,
+	OptionLogServers:                                 ipsSchema,
+	OptionCookieServers:                              ipsSchema,
+	OptionLPRServers:                                 ipsSchema,
+	OptionImpressServers:                             ipsSchema,
+	OptionResourceLocationServers:                    ipsSchema,
+	OptionHostName:                                   stringSchema,
+	OptionBootFileSize:                               uint16Schema,
+	OptionMeritDumpFile:                              stringSchema,
+	OptionDomainName:                                 stringSchema,
+	OptionSwapServer:                                 ipSchema,
+	OptionRootPath:                                   stringSchema,
+	OptionExtensionsPath:                             stringSchema,
+	OptionIPForwardingEnableDisable:                  boolSchema,
+	OptionNonLocalSourceRoutingEnableDisable:         boolSchema,
+	OptionPolicyFilter:                               ipPairsSchema,
+	OptionMaximumDatagramReassemblySize:              uint16Schema,
+	OptionDefaultIPTimeToLive:                        uint8Schema,
+	OptionPathMTUAgingTimeout:                        uint32Schema,
+	OptionPathMTUPlateauTable:                        {min: 2, multiple: 2},
+	OptionInterfaceMTU:                               uint16Schema,
+	OptionAllSubnetsAreLocal:                         boolSchema,
+	OptionBroadcastAddress:                           ipSchema,
+	OptionPerformMaskDiscovery:                       boolSchema,
+	OptionMaskSupplier:                               boolSchema,
+	OptionPerformRouterDiscovery:                     boolSchema,
+	OptionRouterSolicitationAddress:                  ipSchema,
+	OptionStaticRoute:                                ipPairsSchema,
+	OptionTrailerEncapsulation:                       boolSchema,
+	OptionARPCacheTimeout:                            uint32Schema,
+	OptionEthernetEncapsulation:                      boolSchema,
+	OptionTCPDefaultTTL:                              uint8Schema,
+	OptionTCPKeepaliveInterval:                       uint32Schema,
+	OptionTCPKeepaliveGarbage:                        boolSchema,
+	OptionNetworkInformationServiceDomain:            stringSchema,
+	OptionNetworkInformationServers:                  ipsSchema,
+	OptionNetworkTimeProtocolServers:                 ipsSchema,
+	OptionVendorSpecificInformation:                  stringSchema,
+	OptionNetBIOSOverTCPIPNameServer:                 ipsSchema,
+	OptionNetBIOSOverTCPIPDatagramDistributionServer: ipsSchema,
+	OptionNetBIOSOverTCPIPNodeType:                   {min: 1, max: 1, check: oneOf(1, 2, 4, 8)},
+	OptionNetBIOSOverTCPIPScope:                      stringSchema,
+	OptionXWindowSystemFontServer:                    ipsSchema,
+	OptionXWindowSystemDisplayManager:                ipsSchema,
+
+	OptionRequestedIPAddress:   ipSchema,
+	OptionIPAddressLeaseTime:   uint32Schema,
+	OptionOverload:             {min: 1, max: 1, check: oneOf(1, 2, 3)},
+	OptionDHCPMessageType:      {min: 1, max: 1, check: oneOf(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13)},
+	OptionServerIdentifier:     ipSchema,
+	OptionParameterRequestList: stringSchema,
+	OptionMessage:              stringSchema,
+	OptionMaximumDHCPMessageSize: {min: 2, max: 2, check: func(v []byte) string {
+		if size := int(v[0])<<8 | int(v[1]); size < minMaxMessageSize {
+			return fmt.Sprintf("maximum message size %d is less than %d", size, minMaxMessageSize)
+		}
+		return ""
+	}},
+	OptionRenewalTimeValue:      uint32Schema,
+	OptionRebindingTimeValue:    uint32Schema,
+	OptionVendorClassIdentifier: stringSchema,
+	OptionClientIdentifier:      {min: 2},
+	OptionTFTPServerName:        stringSchema,
+	OptionBootFileName:          stringSchema,
+
+	OptionUserClass: {min: 2, check: func(v []byte) string {
+		if _, ok := userClasses(v); !ok {
+			return "malformed user class data"
+		}
+		return ""
+	}},
+	OptionRelayAgentInformation: {min: 2, check: func(v []byte) string {
+		if !validSubOptions(v) {
+			return "malformed sub-options"
+		}
+		return ""
+	}},
+	OptionClientLastTransactionTime: uint32Schema,
+	OptionAssociatedIP:              ipsSchema,
+	OptionClientSystemArch:          {min: 2, multiple: 2},
+	OptionIPv6OnlyPreferred:         uint32Schema,
+	OptionCaptivePortal:             stringSchema,
+	OptionSubnetSelection:           ipSchema,
+	OptionDomainSearch:              stringSchema,
+	OptionClasslessStaticRoute: {min: 5, record: classlessRouteLen, check: func(v []byte) string {
+		if !validClasslessRoutes(v) {
+			return "malformed classless static routes"
+		}
+		return ""
+	}},
+	OptionMUDURL: {min: 1, check: func(v []byte) string {
+		if !strings.HasPrefix(string(v), "https://") {
+			return "MUD URL must use the https scheme"
+		}
+		return ""
+	}},
+}
+
+// splitLen returns how many bytes of v, a value of option code too long for
+// a single instance, go into the next instance: as many whole records as fit
+// if the value is a list of records, or otherwise 255.
+func splitLen(code OptionCode, v []byte) int {
+	s := optionSchemas[code]
+	switch {
+	case s.multiple > 0:
+		return math.MaxUint8 - math.MaxUint8%s.multiple
+	case s.record != nil:
+		var n int
+		for {
+			m := s.record(v[n:])
+			if m == 0 || n+m > math.MaxUint8 {
+				break
+			}
+			n += m
+		}
+		if n > 0 {
+			return n
+		}
+	}
+	return math.MaxUint8
+}
+
+// ValidateOption checks value against the constraints RFC 2132 places on
+// option code, such as the 4-byte length of a subnet mask.
+//
+// Options without known constraints accept any value. ValidateOption
+// returns a *ValidationError, or nil if value is valid.
+func ValidateOption(code OptionCode, value []byte) error {
+	field := fmt.Sprintf("option %d", code)
+	if code == Pad || code == End {
+		return invalid(field, "must not carry a value")
+	}
+
+	s, ok := optionSchemas[code]
+	if !ok {
+		return nil
+	}
+	if len(value) < s.min || (s.max > 0 && len(value) > s.max) {
+		if s.max > 0 {
+			return invalid(field, "length %d out of range [%d, %d]", len(value), s.min, s.max)
+		}
+		return invalid(field, "length %d is less than %d", len(value), s.min)
+	}
+	if s.multiple > 0 && len(value)%s.multiple != 0 {
+		return invalid(field, "length %d is not a multiple of %d", len(value), s.multiple)
+	}
+	if s.check != nil {
+		if reason := s.check(value); reason != "" {
+			return invalid(field, "%s", reason)
+		}
+	}
+	return nil
+}
+
+// Validate checks every option with ValidateOption and returns the error for
+// the first invalid option, or nil.
+func (o Options) Validate() error {
+	for _, opt := range o.list {
+		if err := ValidateOption(opt.Code, opt.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddStrict is like Add, but rejects the value if the option would no longer
+// be valid according to ValidateOption. The Options are unchanged on error.
+func (o *Options) AddStrict(key OptionCode, value encoding.BinaryMarshaler) error {
+	var b []byte
+	if value != nil {
+		var err error
+		if b, err = value.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	return o.AddRawStrict(key, b)
+}
+
+// AddRawStrict is like AddRaw, but rejects the value if the option would no
+// longer be valid according to ValidateOption. The Options are unchanged on
+// error.
+//
+// Since values of an option added more than once are concatenated, the
+// concatenated value is what is checked.
+func (o *Options) AddRawStrict(key OptionCode, value []byte) error {
+	old := o.Get(key)
+	if err := ValidateOption(key, append(old[:len(old):len(old)], value...)); err != nil {
+		return err
+	}
+	o.AddRaw(key, value)
+	return nil
+}
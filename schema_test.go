@@ -0,0 +1,116 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateOption(t *testing.T) {
+	for _, tt := range []struct {
+		code  OptionCode
+		value []byte
+		valid bool
+	}{
+		{code: OptionSubnetMask, value: []byte{255, 255, 255, 0}, valid: true},
+		{code: OptionSubnetMask, value: []byte{255, 255, 255, 0, 0}},
+		{code: OptionSubnetMask, value: []byte{255, 255, 255}},
+		{code: OptionRouters, value: []byte{10, 0, 0, 1, 10, 0, 0, 2}, valid: true},
+		{code: OptionRouters, value: []byte{10, 0, 0, 1, 10}},
+		{code: OptionRouters, value: []byte{}},
+		{code: OptionStaticRoute, value: make([]byte, 16), valid: true},
+		{code: OptionStaticRoute, value: make([]byte, 12)},
+		{code: OptionHostName, value: []byte("foo"), valid: true},
+		{code: OptionHostName, value: []byte{}},
+		{code: OptionIPForwardingEnableDisable, value: []byte{1}, valid: true},
+		{code: OptionIPForwardingEnableDisable, value: []byte{2}},
+		{code: OptionDHCPMessageType, value: []byte{9}, valid: true},
+		{code: OptionDHCPMessageType, value: []byte{0}},
+		{code: OptionMaximumDHCPMessageSize, value: []byte{0x02, 0x40}, valid: true},
+		{code: OptionMaximumDHCPMessageSize, value: []byte{0x02, 0x3f}},
+		{code: OptionClientIdentifier, value: []byte{1}},
+		{code: OptionUserClass, value: []byte{3, 'f', 'o', 'o', 1, 'x'}, valid: true},
+		{code: OptionUserClass, value: []byte{3, 'f', 'o'}},
+		{code: OptionUserClass, value: []byte{0, 1, 'x'}},
+		{code: OptionRelayAgentInformation, value: []byte{1, 2, 'a', 'b', 5, 4, 10, 0, 0, 0}, valid: true},
+		{code: OptionRelayAgentInformation, value: []byte{1, 3, 'a', 'b'}},
+		{code: OptionSubnetSelection, value: []byte{10, 0, 0}},
+		{code: OptionMUDURL, value: []byte("https://example.com/mud"), valid: true},
+		{code: OptionMUDURL, value: []byte("http://example.com/mud")},
+		{code: End, value: []byte{}},
+		// Unknown options may carry anything.
+		{code: 224, value: []byte{}, valid: true},
+	} {
+		err := ValidateOption(tt.code, tt.value)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateOption(%d, %v) = %v, want nil", tt.code, tt.value, err)
+		} else if !tt.valid {
+			if _, ok := err.(*ValidationError); !ok {
+				t.Errorf("ValidateOption(%d, %v) = %v, want *ValidationError", tt.code, tt.value, err)
+			}
+		}
+	}
+}
+
+func TestAddRawStrict(t *testing.T) {
+	o := Options{}
+	if err := o.AddRawStrict(OptionSubnetMask, []byte{255, 255, 255, 0}); err != nil {
+		t.Fatalf("AddRawStrict() = %v, want nil", err)
+	}
+	// Adding again would concatenate to an 8-byte mask.
+	if err := o.AddRawStrict(OptionSubnetMask, []byte{255, 255, 255, 0}); err == nil {
+		t.Errorf("AddRawStrict() of second mask = nil, want error")
+	}
+	if err := o.AddStrict(OptionRouters, rawValue{10, 0, 0, 1}); err != nil {
+		t.Errorf("AddStrict() = %v, want nil", err)
+	}
+	if err := o.AddRawStrict(OptionRouters, []byte{10, 0}); err == nil {
+		t.Errorf("AddRawStrict() of partial router = nil, want error")
+	}
+
+	want := NewOptions(
+		Option{OptionSubnetMask, []byte{255, 255, 255, 0}},
+		Option{OptionRouters, []byte{10, 0, 0, 1}},
+	)
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("options = %v, want %v", o, want)
+	}
+}
+
+// rawValue is a BinaryMarshaler returning its own bytes.
+type rawValue []byte
+
+func (b rawValue) MarshalBinary() ([]byte, error) {
+	return b, nil
+}
+
+func TestUnmarshalBinaryStrict(t *testing.T) {
+	p := NewPacket(BootRequest)
+	p.Options.AddRaw(OptionSubnetMask, []byte{255, 255, 255, 0, 0})
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lax Packet
+	if err := lax.UnmarshalBinary(b); err != nil {
+		t.Errorf("UnmarshalBinary() = %v, want nil", err)
+	}
+	var strict Packet
+	if _, ok := strict.UnmarshalBinaryStrict(b).(*ValidationError); !ok {
+		t.Errorf("UnmarshalBinaryStrict() did not return a *ValidationError")
+	}
+
+	// RFC 3203 messages are valid.
+	fr := NewPacket(BootReply)
+	fr.SetMessageType(MessageTypeForceRenew)
+	if b, err = fr.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := strict.UnmarshalBinaryStrict(b); err != nil {
+		t.Errorf("UnmarshalBinaryStrict(DHCPFORCERENEW) = %v, want nil", err)
+	}
+}
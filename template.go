@@ -0,0 +1,138 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+)
+
+// Offsets of the fields of the fixed-size header ReplyTemplate patches.
+const (
+	offHType  = 1
+	offHLen   = 2
+	offXID    = 4
+	offFlags  = 10
+	offCIAddr = 12
+	offYIAddr = 16
+	offGIAddr = 24
+	offCHAddr = 28
+	headerLen = offCHAddr + chaddrLen + snameLen + fileLen + len(magicCookie)
+)
+
+// ReplyTemplate is a reply serialized once and sent to many clients, for
+// servers answering large numbers of clients with the same options.
+//
+// Marshaling a Packet writes every option anew. A ReplyTemplate keeps the
+// wire format instead and only fills in what differs between clients, as
+// BuildReplySkeleton would: htype, chaddr, xid, flags, giaddr, and ciaddr
+// from the request, yiaddr, and the client identifier and relay agent
+// information options echoed from the request. A ReplyTemplate is immutable,
+// so it may be used concurrently.
+type ReplyTemplate struct {
+	// header is the fixed-size header, including the magic cookie.
+	header []byte
+
+	// options are the options of the template, without the End option.
+	options []byte
+
+	// copyCIAddr is whether the reply is a DHCPACK, which carries the
+	// ciaddr of the request.
+	copyCIAddr bool
+}
+
+// NewReplyTemplate returns a template of reply, typically made with
+// BuildReplySkeleton from any request and filled in with the options every
+// client gets.
+//
+// The per-client fields of reply are ignored, as are its client identifier
+// and relay agent information options.
+func NewReplyTemplate(reply *Packet) (*ReplyTemplate, error) {
+	p := *reply
+	p.Options = Options{Sorted: reply.Options.Sorted, Priority: reply.Options.Priority}
+	for _, code := range reply.Options.Codes() {
+		if code == OptionClientIdentifier || code == OptionRelayAgentInformation {
+			continue
+		}
+		p.Options.AddRaw(code, reply.Options.Get(code))
+	}
+	p.CHAddr = nil
+	p.TransactionID = [4]byte{}
+	p.Flags = 0
+	p.YIAddr, p.GIAddr = nil, nil
+
+	b, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &ReplyTemplate{
+		header: b[:headerLen:headerLen],
+		// Drop the End option; Append writes it after the echoed
+		// options.
+		options:    b[headerLen : len(b)-1],
+		copyCIAddr: reply.MessageType() == MessageTypeACK,
+	}, nil
+}
+
+// Append appends the reply to req offering yiaddr to dst and returns the
+// extended slice. If dst has enough capacity, Append does not allocate.
+func (t *ReplyTemplate) Append(dst []byte, req *Packet, yiaddr net.IP) ([]byte, error) {
+	chaddr := req.wireCHAddr()
+	if len(chaddr) > chaddrLen {
+		return nil, fmt.Errorf("hardware address %v is longer than %d bytes", chaddr, chaddrLen)
+	}
+
+	start := len(dst)
+	dst = append(dst, t.header...)
+	h := dst[start:]
+	h[offHType] = req.HType
+	h[offHLen] = uint8(len(chaddr))
+	copy(h[offXID:offXID+4], req.TransactionID[:])
+	binary.BigEndian.PutUint16(h[offFlags:], req.Flags)
+	if t.copyCIAddr {
+		copyIP(h[offCIAddr:], req.CIAddr)
+	} else {
+		copyIP(h[offCIAddr:], nil)
+	}
+	copyIP(h[offYIAddr:], yiaddr)
+	copyIP(h[offGIAddr:], req.GIAddr)
+	copy(h[offCHAddr:offCHAddr+chaddrLen], chaddr)
+
+	dst = append(dst, t.options...)
+	for _, code := range []OptionCode{OptionClientIdentifier, OptionRelayAgentInformation} {
+		if v := req.Options.Get(code); v != nil {
+			dst = appendOption(dst, code, v)
+		}
+	}
+	return append(dst, byte(End)), nil
+}
+
+// copyIP writes the IPv4 address ip to b, or zeros if ip is not one.
+func copyIP(b []byte, ip net.IP) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		ip4 = net.IPv4zero.To4()
+	}
+	copy(b[:net.IPv4len], ip4)
+}
+
+// appendOption appends option code with value v to dst, split into as many
+// instances as RFC 3396 requires.
+func appendOption(dst []byte, code OptionCode, v []byte) []byte {
+	for {
+		n := len(v)
+		if n > math.MaxUint8 {
+			n = math.MaxUint8
+		}
+		dst = append(dst, byte(code), byte(n))
+		dst = append(dst, v[:n]...)
+		v = v[n:]
+		if len(v) == 0 {
+			return dst
+		}
+	}
+}
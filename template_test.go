@@ -0,0 +1,118 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+	"testing"
+)
+
+// templateReply returns a reply to req of message type mt carrying the
+// options a server would send every client.
+func templateReply(req *Packet, mt MessageType, yiaddr net.IP) *Packet {
+	p := BuildReplySkeleton(req, mt, net.IP{10, 0, 0, 1})
+	p.YIAddr = yiaddr
+	p.SIAddr = net.IP{10, 0, 0, 1}
+	p.BootFile = "pxelinux.0"
+	p.Options.AddRaw(OptionIPAddressLeaseTime, []byte{0, 0, 0x0e, 0x10})
+	p.Options.AddRaw(OptionSubnetMask, []byte{255, 255, 255, 0})
+	p.Options.AddRaw(OptionRouters, []byte{10, 0, 0, 1})
+	p.Options.AddRaw(OptionDomainNameServers, []byte{10, 0, 0, 1, 10, 0, 0, 2})
+	p.Options.AddRaw(OptionDomainName, []byte("example.com"))
+	return p
+}
+
+func TestReplyTemplate(t *testing.T) {
+	sample := NewPacket(BootRequest)
+	sample.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 0xff}
+	sample.TransactionID = [4]byte{9, 9, 9, 9}
+	sample.CIAddr = net.IP{10, 0, 0, 99}
+	sample.Options.AddRaw(OptionClientIdentifier, []byte{1, 2, 0, 0, 0, 0, 0xff})
+
+	plain := NewPacket(BootRequest)
+	plain.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	plain.TransactionID = [4]byte{1, 2, 3, 4}
+
+	renewing := NewPacket(BootRequest)
+	renewing.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	renewing.TransactionID = [4]byte{5, 6, 7, 8}
+	renewing.CIAddr = net.IP{10, 0, 0, 12}
+
+	relayed := NewPacket(BootRequest)
+	relayed.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 3}
+	relayed.TransactionID = [4]byte{4, 3, 2, 1}
+	relayed.SetBroadcast(true)
+	relayed.GIAddr = net.IP{10, 1, 0, 1}
+	relayed.Options.AddRaw(OptionClientIdentifier, []byte{1, 2, 0, 0, 0, 0, 3})
+	relayed.Options.AddRaw(OptionRelayAgentInformation, []byte{1, 4, 'e', 't', 'h', '0'})
+
+	for _, mt := range []MessageType{MessageTypeOffer, MessageTypeACK} {
+		tmpl, err := NewReplyTemplate(templateReply(sample, mt, net.IP{10, 0, 0, 99}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, req := range []*Packet{plain, renewing, relayed} {
+			yiaddr := net.IP{10, 0, 0, byte(10 + i)}
+			got, err := tmpl.Append(nil, req, yiaddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotPkt := &Packet{}
+			if err := gotPkt.UnmarshalBinary(got); err != nil {
+				t.Fatal(err)
+			}
+			// The echoed options come last rather than right
+			// after the server identifier.
+			if d := Diff(gotPkt, templateReply(req, mt, yiaddr)); len(d) > 0 {
+				t.Errorf("message type %d, request %d: Append() differs from the naive reply: %v", mt, i, d)
+			}
+			if v := gotPkt.Options.Get(OptionRelayAgentInformation); v != nil && got[len(got)-len(v)-3] != byte(OptionRelayAgentInformation) {
+				t.Errorf("message type %d, request %d: relay agent information is not the last option", mt, i)
+			}
+		}
+	}
+}
+
+func TestReplyTemplateAllocs(t *testing.T) {
+	req := NewPacket(BootRequest)
+	req.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	tmpl, err := NewReplyTemplate(templateReply(req, MessageTypeACK, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 0, 1500)
+	yiaddr := net.IP{10, 0, 0, 10}
+	if n := testing.AllocsPerRun(100, func() {
+		buf, _ = tmpl.Append(buf[:0], req, yiaddr)
+	}); n != 0 {
+		t.Errorf("Append allocated %v times, want 0", n)
+	}
+}
+
+func BenchmarkReplyNaive(b *testing.B) {
+	req := NewPacket(BootRequest)
+	req.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	buf := make([]byte, 0, 1500)
+	yiaddr := net.IP{10, 0, 0, 10}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = templateReply(req, MessageTypeACK, yiaddr).AppendBinary(buf[:0])
+	}
+}
+
+func BenchmarkReplyTemplate(b *testing.B) {
+	req := NewPacket(BootRequest)
+	req.CHAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	tmpl, err := NewReplyTemplate(templateReply(req, MessageTypeACK, nil))
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 0, 1500)
+	yiaddr := net.IP{10, 0, 0, 10}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = tmpl.Append(buf[:0], req, yiaddr)
+	}
+}
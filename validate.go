@@ -0,0 +1,95 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"fmt"
+)
+
+// ValidationError describes why a packet is not a valid DHCP message.
+type ValidationError struct {
+	// Field is the name of the invalid header field, or the option
+	// code of the invalid option.
+	Field string
+
+	// Reason describes what is wrong with Field.
+	Reason string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid DHCP packet: %s: %s", e.Field, e.Reason)
+}
+
+func invalid(field, format string, a ...interface{}) *ValidationError {
+	return &ValidationError{
+		Field:  field,
+		Reason: fmt.Sprintf(format, a...),
+	}
+}
+
+// minMaxMessageSize is the smallest legal maximum DHCP message size as
+// defined by RFC 2132, Section 9.10.
+const minMaxMessageSize = 576
+
+// hardwareAddrLens maps ARP hardware types to the length of their addresses.
+var hardwareAddrLens = map[uint8]int{
+	HTypeEthernet:   6,
+	HTypeIEEE802:    6,
+	HTypeEUI64:      8,
+	HTypeInfiniBand: 0, // RFC 4390, Section 2.1
+}
+
+// requestMessageTypes are the DHCP message types a client may send, as
+// listed in RFC 2132, Section 9.6, and the DHCPLEASEQUERY of RFC 4388,
+// Section 6.1, sent by access concentrators and other leasequery clients.
+var requestMessageTypes = map[MessageType]bool{
+	MessageTypeDiscover:   true,
+	MessageTypeRequest:    true,
+	MessageTypeDecline:    true,
+	MessageTypeRelease:    true,
+	MessageTypeInform:     true,
+	MessageTypeLeaseQuery: true,
+}
+
+// ValidateRequest checks that p is a well-formed message from a DHCP client.
+//
+// It checks that p is a BOOTREQUEST, that the hardware address length
+// matches the hardware type, that InfiniBand clients send a client
+// identifier as RFC 4390 requires, that p carries a DHCP message type a client may
+// send, and that options are valid according to ValidateOption. The magic
+// cookie is checked by UnmarshalBinary, which rejects packets without it.
+//
+// ValidateRequest returns a *ValidationError describing the first problem
+// found, or nil.
+func ValidateRequest(p *Packet) error {
+	if p.Op != BootRequest {
+		return invalid("op", "got %d, want BOOTREQUEST (%d)", p.Op, BootRequest)
+	}
+
+	hlen := len(p.wireCHAddr())
+	if hlen > chaddrLen {
+		return invalid("hlen", "hardware address length %d exceeds %d", hlen, chaddrLen)
+	}
+	if want, ok := hardwareAddrLens[p.HType]; ok && hlen != want {
+		return invalid("hlen", "hardware type %d requires address length %d, got %d", p.HType, want, hlen)
+	}
+
+	if p.HType == HTypeInfiniBand && !p.Options.Has(OptionClientIdentifier) {
+		return invalid("option 61", "InfiniBand clients must send a client identifier")
+	}
+
+	mt := p.Options.Get(OptionDHCPMessageType)
+	if mt == nil {
+		return invalid("option 53", "DHCP message type is missing")
+	}
+	if err := p.Options.Validate(); err != nil {
+		return err
+	}
+	if !requestMessageTypes[MessageType(mt[0])] {
+		return invalid("option 53", "message type %d is not sent by clients", mt[0])
+	}
+	return nil
+}
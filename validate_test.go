@@ -0,0 +1,139 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"net"
+	"testing"
+)
+
+func TestValidateRequest(t *testing.T) {
+	valid := func() *Packet {
+		p := NewPacket(BootRequest)
+		p.CHAddr = net.HardwareAddr{1, 2, 3, 4, 5, 6}
+		p.Options.AddRaw(OptionDHCPMessageType, []byte{1})
+		p.Options.AddRaw(OptionMaximumDHCPMessageSize, []byte{0x05, 0xdc})
+		return p
+	}
+
+	for _, tt := range []struct {
+		desc      string
+		modify    func(p *Packet)
+		wantField string
+	}{
+		{
+			desc:   "valid discover",
+			modify: func(p *Packet) {},
+		},
+		{
+			desc:      "reply op code",
+			modify:    func(p *Packet) { p.Op = BootReply },
+			wantField: "op",
+		},
+		{
+			desc:      "ethernet with short chaddr",
+			modify:    func(p *Packet) { p.CHAddr = p.CHAddr[:3] },
+			wantField: "hlen",
+		},
+		{
+			desc: "unknown hardware type",
+			modify: func(p *Packet) {
+				p.HType = 200
+				p.CHAddr = p.CHAddr[:3]
+			},
+		},
+		{
+			desc:      "chaddr too long",
+			modify:    func(p *Packet) { p.CHAddr = make(net.HardwareAddr, 17) },
+			wantField: "hlen",
+		},
+		{
+			desc:      "missing message type",
+			modify:    func(p *Packet) { p.Options.Del(OptionDHCPMessageType) },
+			wantField: "option 53",
+		},
+		{
+			desc:      "server message type",
+			modify:    func(p *Packet) { p.Options.ReplaceRaw(OptionDHCPMessageType, []byte{2}) },
+			wantField: "option 53",
+		},
+		{
+			desc:      "EUI-64 with Ethernet address",
+			modify:    func(p *Packet) { p.HType = HTypeEUI64 },
+			wantField: "hlen",
+		},
+		{
+			desc: "InfiniBand",
+			modify: func(p *Packet) {
+				p.HType = HTypeInfiniBand
+				p.CHAddr = make(net.HardwareAddr, 20)
+				p.Options.AddRaw(OptionClientIdentifier, []byte{0xff, 1, 2, 3, 4, 0, 3, 0, 32, 1, 2, 3, 4, 5, 6, 7, 8})
+			},
+		},
+		{
+			desc: "InfiniBand without client identifier",
+			modify: func(p *Packet) {
+				p.HType = HTypeInfiniBand
+				p.CHAddr = make(net.HardwareAddr, 20)
+			},
+			wantField: "option 61",
+		},
+		{
+			desc: "leasequery by address",
+			modify: func(p *Packet) {
+				p.HType = 0
+				p.CHAddr = nil
+				p.CIAddr = net.IP{10, 0, 0, 42}
+				p.GIAddr = net.IP{10, 0, 0, 1}
+				p.Options.ReplaceRaw(OptionDHCPMessageType, []byte{byte(MessageTypeLeaseQuery)})
+			},
+		},
+		{
+			desc:      "leasequery reply message type",
+			modify:    func(p *Packet) { p.Options.ReplaceRaw(OptionDHCPMessageType, []byte{byte(MessageTypeLeaseActive)}) },
+			wantField: "option 53",
+		},
+		{
+			desc:      "long message type",
+			modify:    func(p *Packet) { p.Options.ReplaceRaw(OptionDHCPMessageType, []byte{1, 1}) },
+			wantField: "option 53",
+		},
+		{
+			desc:      "short requested IP",
+			modify:    func(p *Packet) { p.Options.ReplaceRaw(OptionRequestedIPAddress, []byte{10, 0, 0}) },
+			wantField: "option 50",
+		},
+		{
+			desc:      "tiny max message size",
+			modify:    func(p *Packet) { p.Options.ReplaceRaw(OptionMaximumDHCPMessageSize, []byte{0, 100}) },
+			wantField: "option 57",
+		},
+		{
+			desc:      "end option in map",
+			modify:    func(p *Packet) { p.Options.ReplaceRaw(End, []byte{}) },
+			wantField: "option 255",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			p := valid()
+			tt.modify(p)
+
+			err := ValidateRequest(p)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("ValidateRequest() = %v, want nil", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("ValidateRequest() = %v, want *ValidationError", err)
+			}
+			if verr.Field != tt.wantField {
+				t.Errorf("ValidateRequest() field = %q, want %q (%v)", verr.Field, tt.wantField, verr)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Arch is a client system architecture type, as sent in option 93 and in the
+// vendor class identifier of PXE clients. The values are assigned by RFC
+// 4578, Section 2.1, and the IANA "Processor Architecture Types" registry.
+type Arch uint16
+
+// Client system architecture types.
+const (
+	ArchX86BIOS      Arch = 0
+	ArchEFIIA32      Arch = 6
+	ArchEFIX64       Arch = 7
+	ArchEFIBC        Arch = 9
+	ArchEFIARM32     Arch = 10
+	ArchEFIARM64     Arch = 11
+	ArchEFIX64HTTP   Arch = 16
+	ArchEFIARM64HTTP Arch = 19
+)
+
+var archNames = map[Arch]string{
+	ArchX86BIOS:      "x86 BIOS",
+	ArchEFIIA32:      "EFI IA32",
+	ArchEFIX64:       "EFI x64",
+	ArchEFIBC:        "EFI BC",
+	ArchEFIARM32:     "EFI ARM32",
+	ArchEFIARM64:     "EFI ARM64",
+	ArchEFIX64HTTP:   "EFI x64 HTTP",
+	ArchEFIARM64HTTP: "EFI ARM64 HTTP",
+}
+
+// String returns the name of a, or its number if it is not known.
+func (a Arch) String() string {
+	if s, ok := archNames[a]; ok {
+		return s
+	}
+	return fmt.Sprintf("arch %d", uint16(a))
+}
+
+// PXEVendorClass is a parsed vendor class identifier of a PXE or UEFI HTTP
+// boot client, such as "PXEClient:Arch:00007:UNDI:003016".
+type PXEVendorClass struct {
+	// HTTP is whether the client is a UEFI HTTP boot client
+	// ("HTTPClient") rather than a PXE client ("PXEClient").
+	HTTP bool
+
+	// Arch is the client's architecture, if HasArch is set.
+	Arch    Arch
+	HasArch bool
+
+	// UNDIMajor and UNDIMinor are the version of the client's network
+	// driver interface, if HasUNDI is set.
+	UNDIMajor, UNDIMinor uint8
+	HasUNDI              bool
+}
+
+// ParsePXEVendorClass parses a vendor class identifier (option 60) of the
+// form defined by the PXE specification and RFC 4578:
+//
+//	PXEClient[:Arch:xxxxx[:UNDI:yyyzzz]]
+//
+// where xxxxx is the decimal architecture type and yyy and zzz the decimal
+// major and minor UNDI versions. UEFI HTTP boot clients send the same with
+// "HTTPClient". Anything after the UNDI version is ignored. It returns false
+// if vc is not such an identifier.
+func ParsePXEVendorClass(vc string) (PXEVendorClass, bool) {
+	var p PXEVendorClass
+	fields := strings.Split(vc, ":")
+	switch fields[0] {
+	case "PXEClient":
+	case "HTTPClient":
+		p.HTTP = true
+	default:
+		return p, false
+	}
+	fields = fields[1:]
+
+	if len(fields) >= 2 && fields[0] == "Arch" {
+		arch, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil || len(fields[1]) != 5 {
+			return p, false
+		}
+		p.Arch, p.HasArch = Arch(arch), true
+		fields = fields[2:]
+	}
+	if len(fields) >= 2 && fields[0] == "UNDI" {
+		v := fields[1]
+		if len(v) != 6 {
+			return p, false
+		}
+		major, err1 := strconv.ParseUint(v[:3], 10, 8)
+		minor, err2 := strconv.ParseUint(v[3:], 10, 8)
+		if err1 != nil || err2 != nil {
+			return p, false
+		}
+		p.UNDIMajor, p.UNDIMinor, p.HasUNDI = uint8(major), uint8(minor), true
+	}
+	return p, true
+}
+
+// VendorClassMatches returns a Matcher matching requests whose vendor class
+// identifier (option 60) matches the shell pattern pattern, such as
+// "PXEClient:Arch:0000[79]:*" or "MSFT 5.*". Requests without a vendor class
+// identifier never match.
+//
+// The pattern syntax is that of path.Match, except that '/' is an ordinary
+// character: '*' matches any sequence of characters, including the slashes
+// of vendor classes such as "HP/LaserJet". VendorClassMatches panics if
+// pattern is malformed, as regexp.MustCompile does.
+func VendorClassMatches(pattern string) Matcher {
+	re, err := globRegexp(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("dhcp4: VendorClassMatches(%q): %v", pattern, err))
+	}
+	return func(req *Packet) bool {
+		vc := req.Options.Get(OptionVendorClassIdentifier)
+		return vc != nil && re.Match(vc)
+	}
+}
+
+// globRegexp compiles the shell pattern of VendorClassMatches into an
+// equivalent regular expression.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString(`^(?s:`)
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			if i++; i == len(runes) {
+				return nil, path.ErrBadPattern
+			}
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			b.WriteByte('[')
+			if i+1 < len(runes) && runes[i+1] == '^' {
+				b.WriteByte('^')
+				i++
+			}
+			ranges := 0
+			for {
+				if i++; i == len(runes) {
+					return nil, path.ErrBadPattern
+				}
+				c := runes[i]
+				if c == ']' && ranges > 0 {
+					break
+				}
+				if c == '\\' {
+					if i++; i == len(runes) {
+						return nil, path.ErrBadPattern
+					}
+					c = runes[i]
+				} else if c == '-' || c == ']' {
+					return nil, path.ErrBadPattern
+				}
+				b.WriteString(classChar(c))
+				if i+2 < len(runes) && runes[i+1] == '-' && runes[i+2] != ']' {
+					i += 2
+					hi := runes[i]
+					if hi == '\\' {
+						if i++; i == len(runes) {
+							return nil, path.ErrBadPattern
+						}
+						hi = runes[i]
+					}
+					b.WriteByte('-')
+					b.WriteString(classChar(hi))
+				}
+				ranges++
+			}
+			b.WriteByte(']')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`)$`)
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, path.ErrBadPattern
+	}
+	return re, nil
+}
+
+// classChar returns r for use in a regular expression character class.
+func classChar(r rune) string {
+	if r < utf8.RuneSelf && !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+		return `\` + string(r)
+	}
+	return string(r)
+}
+
+// ClientArch returns the architecture of the client sending req, from its
+// client system architecture option (93) or, failing that, its PXE vendor
+// class identifier. It returns false if req names no architecture.
+func ClientArch(req *Packet) (Arch, bool) {
+	if v := req.Options.Get(OptionClientSystemArch); len(v) >= 2 {
+		return Arch(uint16(v[0])<<8 | uint16(v[1])), true
+	}
+	p, ok := ParsePXEVendorClass(string(req.Options.Get(OptionVendorClassIdentifier)))
+	return p.Arch, ok && p.HasArch
+}
+
+// IsArch returns a Matcher matching requests from clients of architecture
+// arch, according to ClientArch.
+func IsArch(arch Arch) Matcher {
+	return func(req *Packet) bool {
+		a, ok := ClientArch(req)
+		return ok && a == arch
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dhcp4
+
+import (
+	"testing"
+)
+
+func TestParsePXEVendorClass(t *testing.T) {
+	for _, tt := range []struct {
+		in     string
+		want   PXEVendorClass
+		wantOK bool
+	}{
+		{
+			in:     "PXEClient:Arch:00007:UNDI:003016",
+			want:   PXEVendorClass{Arch: ArchEFIX64, HasArch: true, UNDIMajor: 3, UNDIMinor: 16, HasUNDI: true},
+			wantOK: true,
+		},
+		{
+			in:     "PXEClient:Arch:00000:UNDI:002001",
+			want:   PXEVendorClass{Arch: ArchX86BIOS, HasArch: true, UNDIMajor: 2, UNDIMinor: 1, HasUNDI: true},
+			wantOK: true,
+		},
+		{
+			in:     "HTTPClient:Arch:00016:UNDI:003001",
+			want:   PXEVendorClass{HTTP: true, Arch: ArchEFIX64HTTP, HasArch: true, UNDIMajor: 3, UNDIMinor: 1, HasUNDI: true},
+			wantOK: true,
+		},
+		{
+			in:     "PXEClient",
+			wantOK: true,
+		},
+		{
+			in:     "PXEClient:Arch:00011",
+			want:   PXEVendorClass{Arch: ArchEFIARM64, HasArch: true},
+			wantOK: true,
+		},
+		{in: "PXEClient:Arch:7"},
+		{in: "PXEClient:Arch:00007:UNDI:3016"},
+		{in: "PXEClient:Arch:99999"},
+		{in: "MSFT 5.0"},
+		{in: "udhcp 1.30.1"},
+		{in: ""},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := ParsePXEVendorClass(tt.in)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("ParsePXEVendorClass(%q) = %+v, %t, want %+v, %t", tt.in, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVendorClassMatchers(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		opts     Options
+		pattern  string
+		arch     Arch
+		wantVC   bool
+		wantArch bool
+	}{
+		{
+			desc:     "PXE",
+			opts:     NewOptions(Option{OptionVendorClassIdentifier, []byte("PXEClient:Arch:00007:UNDI:003016")}),
+			pattern:  "PXEClient:Arch:0000[79]:*",
+			arch:     ArchEFIX64,
+			wantVC:   true,
+			wantArch: true,
+		},
+		{
+			desc:    "option 93 wins",
+			opts:    NewOptions(Option{OptionVendorClassIdentifier, []byte("PXEClient:Arch:00007")}, Option{OptionClientSystemArch, []byte{0, 11}}),
+			pattern: "PXEClient",
+			arch:    ArchEFIX64,
+		},
+		{
+			desc:     "option 93 only",
+			opts:     NewOptions(Option{OptionClientSystemArch, []byte{0, 11}}),
+			pattern:  "*",
+			arch:     ArchEFIARM64,
+			wantArch: true,
+		},
+		{
+			desc:    "Windows",
+			opts:    NewOptions(Option{OptionVendorClassIdentifier, []byte("MSFT 5.0")}),
+			pattern: "MSFT 5.*",
+			arch:    ArchX86BIOS,
+			wantVC:  true,
+		},
+		{
+			desc:    "slash",
+			opts:    NewOptions(Option{OptionVendorClassIdentifier, []byte("HP/LaserJet 4050")}),
+			pattern: "HP/*",
+			wantVC:  true,
+		},
+		{
+			desc:    "star across slash",
+			opts:    NewOptions(Option{OptionVendorClassIdentifier, []byte("HP/LaserJet 4050")}),
+			pattern: "*Jet 40[4-5]?",
+			wantVC:  true,
+		},
+		{
+			desc:     "negated class",
+			opts:     NewOptions(Option{OptionVendorClassIdentifier, []byte("PXEClient:Arch:00007")}),
+			pattern:  "PXEClient:Arch:0000[^79]",
+			arch:     ArchEFIX64,
+			wantArch: true,
+		},
+		{
+			desc:    "escaped metacharacters",
+			opts:    NewOptions(Option{OptionVendorClassIdentifier, []byte("a*b.c")}),
+			pattern: `a\*b.c`,
+			wantVC:  true,
+		},
+		{
+			desc:    "udhcp",
+			opts:    NewOptions(Option{OptionVendorClassIdentifier, []byte("udhcp 1.30.1")}),
+			pattern: "MSFT*",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			req := &Packet{Options: tt.opts}
+			if got := VendorClassMatches(tt.pattern)(req); got != tt.wantVC {
+				t.Errorf("VendorClassMatches(%q) = %t, want %t", tt.pattern, got, tt.wantVC)
+			}
+			if got := IsArch(tt.arch)(req); got != tt.wantArch {
+				t.Errorf("IsArch(%v) = %t, want %t", tt.arch, got, tt.wantArch)
+			}
+		})
+	}
+}
+
+func TestVendorClassMatchesBadPattern(t *testing.T) {
+	for _, pattern := range []string{
+		"PXEClient:Arch:0000[7",
+		"PXEClient[]",
+		"a[z-a]",
+		"a[-]",
+		`trailing\`,
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("VendorClassMatches(%q) did not panic", pattern)
+				}
+			}()
+			VendorClassMatches(pattern)
+		}()
+	}
+}